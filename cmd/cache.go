@@ -0,0 +1,554 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"doc-to-text/pkg/cache"
+	"doc-to-text/pkg/utils"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	cacheJSON       bool
+	pruneOlderThan  string
+	pruneMaxSize    string
+	pruneEngine     string
+	pruneArtifacts  bool
+	cacheDryRun     bool
+	purgeConfirmed  bool
+	cacheListSortBy string
+)
+
+// cacheCmd represents the cache command
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the cache of work directories",
+	Long: "Work directories hold the intermediate files and cached text of every document.\n" +
+		"They are named after the MD5 of the input and live in --cache-dir\n" +
+		"(default: $XDG_CACHE_HOME/doc-to-text), so identical files are processed once.\n\n" +
+		"Page PDFs and 300 DPI page images make up most of the cache; 'prune --artifacts'\n" +
+		"removes them while keeping page texts, OCR data and outputs.",
+}
+
+// cacheListCmd represents the cache list command
+var cacheListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List cache entries with source, size, engine and age",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		fatalOnError(runCacheList())
+	},
+}
+
+// cacheInspectCmd represents the cache inspect command
+var cacheInspectCmd = &cobra.Command{
+	Use:   "inspect <hash|file>",
+	Short: "Show the sources, manifest and files of a cache entry",
+	Long: "Shows a cache entry, given by its hash, a unique hash prefix or an input file.\n\n" +
+		"Examples:\n" +
+		"  doc-to-text cache inspect 6b78f340\n" +
+		"  doc-to-text cache inspect ~/scans/report.pdf --json",
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		fatalOnError(runCacheInspect(args[0]))
+	},
+}
+
+// cacheVerifyCmd represents the cache verify command
+var cacheVerifyCmd = &cobra.Command{
+	Use:   "verify [hash|file]...",
+	Short: "Check that cached outputs still match their manifest",
+	Long: "Compares the outputs of cache entries with the checksums recorded in their\n" +
+		"manifest when they were written. Without arguments every entry is checked.\n" +
+		"The exit code is non-zero when an output is missing or has changed.",
+	Run: func(cmd *cobra.Command, args []string) {
+		fatalOnError(runCacheVerify(args))
+	},
+}
+
+// cachePruneCmd represents the cache prune command
+var cachePruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove cache entries by age, size budget or engine",
+	Long: "Removes cache entries not written for --older-than, produced by --engine, or the\n" +
+		"oldest entries until the cache fits in --max-size. Filters combine: all given\n" +
+		"filters must match. With --artifacts only page PDFs, page images and other files\n" +
+		"that can be rebuilt from the input are removed; page texts, OCR data and outputs\n" +
+		"are kept, so nothing has to be recognized again.\n\n" +
+		"Examples:\n" +
+		"  doc-to-text cache prune --artifacts                  # Keep text, drop page images\n" +
+		"  doc-to-text cache prune --older-than 90d\n" +
+		"  doc-to-text cache prune --max-size 20GB --dry-run\n" +
+		"  doc-to-text cache prune --engine llm-caller --older-than 2w",
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		fatalOnError(runCachePrune())
+	},
+}
+
+// cachePurgeCmd represents the cache purge command
+var cachePurgeCmd = &cobra.Command{
+	Use:   "purge",
+	Short: "Remove every cache entry",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		fatalOnError(runCachePurge())
+	},
+}
+
+// cacheMigrateCmd represents the cache migrate command
+var cacheMigrateCmd = &cobra.Command{
+	Use:   "migrate <dir>...",
+	Short: "Move {md5} work directories next to inputs into the cache",
+	Long: "Scans directories for {md5} work directories written next to their inputs by earlier\n" +
+		"versions or with --cache-dir local, and moves them into the cache. The files beside\n" +
+		"each work directory are recorded as its sources. Work directories that already have\n" +
+		"a cache entry are merged into it.\n\n" +
+		"Examples:\n" +
+		"  doc-to-text cache migrate ~/Documents\n" +
+		"  doc-to-text cache migrate ./scans --cache-dir /mnt/fast/doc-to-text",
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		fatalOnError(runCacheMigrate(args))
+	},
+}
+
+// newCacheHandler initializes a handler for the cache commands, logging to stderr
+func newCacheHandler() (*AppHandler, error) {
+	handler := NewAppHandler()
+	handler.unattended = true
+	handler.logStderr = true
+	if err := handler.initialize(nil); err != nil {
+		return nil, err
+	}
+	return handler, nil
+}
+
+// runCacheList prints the cache entries
+func runCacheList() error {
+	handler, err := newCacheHandler()
+	if err != nil {
+		return err
+	}
+	entries, err := handler.extractor.CacheEntries()
+	if err != nil {
+		return err
+	}
+	switch cacheListSortBy {
+	case "age":
+	case "size":
+		sort.SliceStable(entries, func(i, j int) bool { return entries[i].Size > entries[j].Size })
+	default:
+		return utils.NewValidationError("unknown sort order "+cacheListSortBy+", use age or size", nil)
+	}
+
+	if cacheJSON {
+		if entries == nil {
+			entries = []*cache.Entry{}
+		}
+		return printJSON(entries)
+	}
+
+	if len(entries) == 0 {
+		fmt.Printf("🗄️ Cache is empty: %s\n", handler.extractor.CacheDir())
+		return nil
+	}
+	var total, artifacts int64
+	fmt.Printf("%-12s  %9s  %6s  %-20s  %s\n", "HASH", "SIZE", "AGE", "ENGINE", "SOURCE")
+	for _, entry := range entries {
+		source := "-"
+		if len(entry.Sources) > 0 {
+			source = entry.Sources[0]
+			if len(entry.Sources) > 1 {
+				source += fmt.Sprintf(" (+%d)", len(entry.Sources)-1)
+			}
+		}
+		if entry.Lock != nil {
+			source += " [locked]"
+		} else if entry.Incomplete != nil {
+			source += " [incomplete]"
+		}
+		fmt.Printf("%-12s  %9s  %6s  %-20s  %s\n", entry.Hash[:12], formatSize(entry.Size), formatAge(entry.ModTime), entry.Label(), source)
+		total += entry.Size
+		artifacts += entry.Artifacts
+	}
+	fmt.Printf("\n📊 %d entries, %s in %s (%s in page images and other artifacts)\n",
+		len(entries), formatSize(total), handler.extractor.CacheDir(), formatSize(artifacts))
+	return nil
+}
+
+// runCacheInspect prints one cache entry
+func runCacheInspect(ref string) error {
+	handler, err := newCacheHandler()
+	if err != nil {
+		return err
+	}
+	entry, err := handler.extractor.CacheEntry(ref)
+	if err != nil {
+		return err
+	}
+
+	if cacheJSON {
+		return printJSON(struct {
+			*cache.Entry
+			Manifest *cache.Manifest `json:"manifest,omitempty"`
+		}{entry, entry.Manifest})
+	}
+
+	fmt.Printf("🗄️ %s\n", entry.Dir)
+	for _, source := range entry.Sources {
+		fmt.Printf("📄 %s\n", source)
+	}
+	fmt.Printf("⚙️ Engine: %s\n", entry.Label())
+	fmt.Printf("📦 Size: %s (%s in page images and other artifacts)\n", formatSize(entry.Size), formatSize(entry.Artifacts))
+	fmt.Printf("🕒 Last written: %s (%s ago)\n", entry.ModTime.Format(time.RFC3339), formatAge(entry.ModTime))
+	if lock := entry.Lock; lock != nil {
+		fmt.Printf("🔒 Locked by %s since %s (refreshed %s ago)\n", lock, lock.AcquiredAt.Format(time.RFC3339), formatAge(lock.RefreshedAt))
+	}
+	if incomplete := entry.Incomplete; incomplete != nil {
+		fmt.Printf("⏸️ Incomplete: %s at %s", incomplete.Reason, incomplete.StoppedAt.Format(time.RFC3339))
+		if incomplete.TotalPages > 0 {
+			fmt.Printf(", %d of %d pages done", incomplete.PagesDone, incomplete.TotalPages)
+		}
+		if incomplete.Output != "" {
+			fmt.Printf(", partial text in %s", incomplete.Output)
+		}
+		fmt.Println()
+	}
+	if manifest := entry.Manifest; manifest != nil {
+		if manifest.Format != "" {
+			fmt.Printf("🔑 Input: %s, %s, sha256:%s\n", manifest.Format, formatSize(manifest.Size), manifest.SHA256)
+		}
+		if len(manifest.Settings) > 0 {
+			fmt.Printf("🔧 Settings: %s\n", formatSettings(manifest.Settings))
+		}
+		for _, attempt := range manifest.Attempts {
+			status := "ok"
+			if attempt.Error != "" {
+				status = attempt.Error
+			}
+			fmt.Printf("🔄 Attempt: %s (%dms) %s\n", attempt.Extractor, attempt.DurationMs, status)
+		}
+		if len(manifest.Tools) > 0 {
+			fmt.Println("🧰 Tools:")
+			for _, tool := range sortedKeys(manifest.Tools) {
+				fmt.Printf("   %s: %s\n", tool, manifest.Tools[tool])
+			}
+		}
+		if len(manifest.Pages) > 0 {
+			counts := make(map[string]int)
+			for _, page := range manifest.Pages {
+				counts[page.Status]++
+			}
+			fmt.Printf("📄 Pages: %d ok, %d empty, %d failed\n", counts[cache.PageOK], counts[cache.PageEmpty], counts[cache.PageFailed])
+			for _, page := range manifest.Pages {
+				if page.Status == cache.PageFailed {
+					fmt.Printf("   page %d: %s\n", page.Number, page.Error)
+				}
+			}
+		}
+		fmt.Println("🧾 Outputs:")
+		names := make([]string, 0, len(manifest.Outputs))
+		for name := range manifest.Outputs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			output := manifest.Outputs[name]
+			fmt.Printf("   %s  %s  sha256:%s\n", name, formatSize(output.Size), output.SHA256)
+		}
+	} else if entry.Incomplete != nil {
+		fmt.Println("🧾 No manifest (no extraction finished yet)")
+	} else {
+		fmt.Println("🧾 No manifest (written by an earlier version)")
+	}
+
+	fmt.Println("📁 Files:")
+	files, err := os.ReadDir(entry.Dir)
+	if err != nil {
+		return utils.WrapError(err, utils.ErrorTypeIO, "failed to read cache entry")
+	}
+	for _, file := range files {
+		path := filepath.Join(entry.Dir, file.Name())
+		if file.IsDir() {
+			fmt.Printf("   %s/  %s\n", file.Name(), formatSize(dirSize(path)))
+		} else if info, err := file.Info(); err == nil {
+			fmt.Printf("   %s  %s\n", file.Name(), formatSize(info.Size()))
+		}
+	}
+	return nil
+}
+
+// runCacheVerify checks cache entries against their manifests
+func runCacheVerify(refs []string) error {
+	handler, err := newCacheHandler()
+	if err != nil {
+		return err
+	}
+	var entries []*cache.Entry
+	if len(refs) == 0 {
+		if entries, err = handler.extractor.CacheEntries(); err != nil {
+			return err
+		}
+	}
+	for _, ref := range refs {
+		entry, err := handler.extractor.CacheEntry(ref)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry)
+	}
+
+	var ok, failed, unverified int
+	for _, entry := range entries {
+		if entry.Manifest == nil {
+			fmt.Printf("⚠️  %s  no manifest\n", entry.Hash)
+			unverified++
+			continue
+		}
+		problems := cache.Verify(entry.Dir, entry.Manifest)
+		if len(problems) == 0 {
+			fmt.Printf("✅ %s\n", entry.Hash)
+			ok++
+			continue
+		}
+		failed++
+		for _, problem := range problems {
+			fmt.Printf("❌ %s  %s: %s\n", entry.Hash, problem.File, problem.Reason)
+		}
+	}
+
+	fmt.Printf("\n📊 Verified: %d ok, %d failed, %d without manifest\n", ok, failed, unverified)
+	if failed > 0 {
+		return utils.NewValidationError(fmt.Sprintf("%d cache entries do not match their manifest", failed), nil)
+	}
+	return nil
+}
+
+// runCachePrune removes the cache entries selected by the prune flags
+func runCachePrune() error {
+	opts := cache.PruneOptions{Engine: pruneEngine, Artifacts: pruneArtifacts, DryRun: cacheDryRun}
+	if pruneOlderThan != "" {
+		age, err := parseAge(pruneOlderThan)
+		if err != nil {
+			return err
+		}
+		opts.OlderThan = age
+	}
+	if pruneMaxSize != "" {
+		size, err := parseSize(pruneMaxSize)
+		if err != nil {
+			return err
+		}
+		opts.MaxSize = size
+	}
+
+	handler, err := newCacheHandler()
+	if err != nil {
+		return err
+	}
+	result, err := handler.extractor.PruneCache(opts)
+	if err != nil {
+		return err
+	}
+	printPruneResult(result, pruneArtifacts)
+	return nil
+}
+
+// runCachePurge removes every cache entry once confirmed
+func runCachePurge() error {
+	handler, err := newCacheHandler()
+	if err != nil {
+		return err
+	}
+	if !purgeConfirmed && !cacheDryRun {
+		result, err := handler.extractor.PurgeCache(true)
+		if err != nil {
+			return err
+		}
+		return utils.NewValidationError(fmt.Sprintf("purge would remove %d entries (%s) from %s, run again with --yes",
+			len(result.Entries), formatSize(result.Freed), handler.extractor.CacheDir()), nil)
+	}
+	result, err := handler.extractor.PurgeCache(cacheDryRun)
+	if err != nil {
+		return err
+	}
+	printPruneResult(result, false)
+	return nil
+}
+
+// printPruneResult prints the entries pruned and the space freed
+func printPruneResult(result *cache.PruneResult, artifacts bool) {
+	action := "Removed"
+	if artifacts {
+		action = "Stripped"
+	}
+	if cacheDryRun {
+		action = "Would remove"
+		if artifacts {
+			action = "Would strip"
+		}
+	}
+	for _, entry := range result.Entries {
+		source := ""
+		if len(entry.Sources) > 0 {
+			source = "  " + entry.Sources[0]
+		}
+		fmt.Printf("🗑️  %s %s (%s)%s\n", action, entry.Hash, entry.Label(), source)
+	}
+	for _, entry := range result.Locked {
+		fmt.Printf("🔒 Kept %s, being processed by %s\n", entry.Hash, entry.Lock)
+	}
+	fmt.Printf("\n📊 %s %d entries, %s freed\n", action, len(result.Entries), formatSize(result.Freed))
+}
+
+// runCacheMigrate moves the work directories under the given directories into the cache
+func runCacheMigrate(dirs []string) error {
+	handler := NewAppHandler()
+	handler.unattended = true
+	if err := handler.initialize(nil); err != nil {
+		return err
+	}
+
+	handler.logger.ProgressAlways("🚚", "Migrating work directories into %s", handler.config.CacheRoot())
+	migrated, err := handler.extractor.MigrateWorkDirs(dirs)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\n🗄️ Migrated %d work directories into %s\n", migrated, handler.config.CacheRoot())
+	return nil
+}
+
+// printJSON writes a value to stdout as indented JSON
+func printJSON(value interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		return utils.WrapError(err, utils.ErrorTypeIO, "failed to write JSON")
+	}
+	return nil
+}
+
+// parseAge parses a duration that may use days and weeks, e.g. "90d", "2w" or "36h"
+func parseAge(value string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if number, ok := strings.CutSuffix(value, suffix); ok {
+			if n, err := strconv.ParseFloat(number, 64); err == nil && n > 0 {
+				return time.Duration(n * float64(unit)), nil
+			}
+		}
+	}
+	age, err := time.ParseDuration(value)
+	if err != nil || age <= 0 {
+		return 0, utils.NewValidationError("invalid age "+value+", use e.g. 90d, 2w or 36h", err)
+	}
+	return age, nil
+}
+
+// parseSize parses a size such as "20GB", "500M" or "1.5GiB"; units are powers of 1024
+func parseSize(value string) (int64, error) {
+	upper := strings.ToUpper(strings.TrimSpace(value))
+	upper = strings.TrimSuffix(strings.TrimSuffix(upper, "B"), "I")
+	multiplier := int64(1)
+	if n := len(upper); n > 0 {
+		if i := strings.IndexByte("KMGT", upper[n-1]); i >= 0 {
+			multiplier = int64(1) << (10 * (i + 1))
+			upper = upper[:n-1]
+		}
+	}
+	number, err := strconv.ParseFloat(strings.TrimSpace(upper), 64)
+	if err != nil || number <= 0 {
+		return 0, utils.NewValidationError("invalid size "+value+", use e.g. 20GB or 500MB", err)
+	}
+	return int64(number * float64(multiplier)), nil
+}
+
+// formatSize formats a number of bytes with a binary unit
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	value, exp := float64(size)/unit, 0
+	for value >= unit && exp < 3 {
+		value /= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", value, "KMGT"[exp])
+}
+
+// formatAge formats the time since t in its largest whole unit
+func formatAge(t time.Time) string {
+	age := time.Since(t)
+	switch {
+	case age < time.Minute:
+		return "<1m"
+	case age < time.Hour:
+		return fmt.Sprintf("%dm", int(age.Minutes()))
+	case age < 24*time.Hour:
+		return fmt.Sprintf("%dh", int(age.Hours()))
+	default:
+		return fmt.Sprintf("%dd", int(age.Hours()/24))
+	}
+}
+
+// formatSettings lists settings as "key=value", sorted by name
+func formatSettings(settings map[string]string) string {
+	keys := sortedKeys(settings)
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = key + "=" + settings[key]
+	}
+	return strings.Join(parts, " ")
+}
+
+// sortedKeys returns the keys of a map in order
+func sortedKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// dirSize returns the bytes used by the files under dir
+func dirSize(dir string) int64 {
+	var size int64
+	filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			if info, err := d.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return size
+}
+
+func init() {
+	cacheListCmd.Flags().BoolVar(&cacheJSON, "json", false, "Print the entries as JSON")
+	cacheListCmd.Flags().StringVar(&cacheListSortBy, "sort", "age", "Order of the entries: age (newest first) or size (largest first)")
+	cacheInspectCmd.Flags().BoolVar(&cacheJSON, "json", false, "Print the entry as JSON")
+
+	cachePruneCmd.Flags().StringVar(&pruneOlderThan, "older-than", "", "Prune entries not written for this long (e.g. 90d, 2w, 36h)")
+	cachePruneCmd.Flags().StringVar(&pruneMaxSize, "max-size", "", "Prune the oldest entries until the cache fits in this size (e.g. 20GB)")
+	cachePruneCmd.Flags().StringVar(&pruneEngine, "engine", "", "Prune entries produced by this extractor or OCR engine (e.g. ocr, surya_ocr, unknown)")
+	cachePruneCmd.Flags().BoolVar(&pruneArtifacts, "artifacts", false, "Only remove page PDFs, page images and other rebuildable files, keeping text")
+	cachePruneCmd.Flags().BoolVar(&cacheDryRun, "dry-run", false, "Show what would be removed without removing anything")
+
+	cachePurgeCmd.Flags().BoolVar(&purgeConfirmed, "yes", false, "Confirm removing every entry")
+	cachePurgeCmd.Flags().BoolVar(&cacheDryRun, "dry-run", false, "Show what would be removed without removing anything")
+
+	cacheCmd.AddCommand(cacheListCmd, cacheInspectCmd, cacheVerifyCmd, cachePruneCmd, cachePurgeCmd, cacheMigrateCmd)
+	rootCmd.AddCommand(cacheCmd)
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+
+	"doc-to-text/pkg/utils"
+
+	"github.com/spf13/cobra"
+)
+
+var indexRebuild bool
+
+// indexCmd represents the index command
+var indexCmd = &cobra.Command{
+	Use:   "index [dir]...",
+	Short: "Build the search index over extracted outputs",
+	Long: "Scans directories for {md5} work directories and adds their outputs to a local\n" +
+		"search index, mapping each hash back to its input files. Without directories the\n" +
+		"cache (--cache-dir) is scanned.\n\n" +
+		"Unchanged outputs are skipped and outputs that disappeared are removed, so running\n" +
+		"the command again is cheap. Once the index exists, every new extraction is added to\n" +
+		"it as it completes. The index lives in --index-dir (default: $XDG_DATA_HOME/doc-to-text/index).\n\n" +
+		"Examples:\n" +
+		"  doc-to-text index                                     # Index everything in the cache\n" +
+		"  doc-to-text index ~/Documents ~/scans                 # Work directories left next to inputs\n" +
+		"  doc-to-text index ./archive --rebuild                 # Drop the index and start over\n" +
+		"  doc-to-text index ./project --index-dir ./project/.index",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := runIndex(args); err != nil {
+			if appErr, ok := err.(*utils.AppError); ok {
+				log.Fatalf("Error (%s): %s", appErr.Type, appErr.Message)
+			} else {
+				log.Fatalf("Error: %v", err)
+			}
+		}
+	},
+}
+
+// runIndex indexes the outputs under the given directories
+func runIndex(dirs []string) error {
+	handler := NewAppHandler()
+	handler.unattended = true
+	if err := handler.initialize(nil); err != nil {
+		return err
+	}
+
+	if len(dirs) == 0 {
+		handler.logger.ProgressAlways("📖", "Indexing the cache %s into %s", handler.config.CacheRoot(), handler.config.SearchIndexDir())
+	} else {
+		handler.logger.ProgressAlways("📖", "Indexing %d directories into %s", len(dirs), handler.config.SearchIndexDir())
+	}
+	stats, err := handler.extractor.Index(dirs, indexRebuild)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\n📖 Index updated: %s\n", handler.config.SearchIndexDir())
+	fmt.Printf("📊 Added: %d, updated: %d, unchanged: %d, removed: %d, failed: %d\n",
+		stats.Added, stats.Updated, stats.Unchanged, stats.Removed, stats.Failed)
+	return nil
+}
+
+func init() {
+	indexCmd.Flags().BoolVar(&indexRebuild, "rebuild", false, "Discard the existing index before indexing")
+
+	rootCmd.AddCommand(indexCmd)
+}
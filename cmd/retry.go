@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+
+	"doc-to-text/pkg/batch"
+
+	"github.com/spf13/cobra"
+)
+
+// retryFailedCmd represents the retry-failed command
+var retryFailedCmd = &cobra.Command{
+	Use:   "retry-failed <file|dir|glob>...",
+	Short: "Recognize the PDF pages that failed in earlier runs again",
+	Long: "OCR records the progress of every PDF page in the work directory. A resumed run\n" +
+		"reuses the pages already done and keeps failed pages failed; retry-failed\n" +
+		"recognizes only those pages again and writes the output anew.\n\n" +
+		"Examples:\n" +
+		"  doc-to-text retry-failed scan.pdf\n" +
+		"  doc-to-text retry-failed ~/scans -o ~/texts",
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		fatalOnError(runRetryFailed(args))
+	},
+}
+
+// runRetryFailed reprocesses the inputs that have failed pages
+func runRetryFailed(inputs []string) error {
+	items, err := batch.Collect(inputs, batch.Options{Include: includes, Exclude: excludes})
+	if err != nil {
+		return err
+	}
+	handler, err := newCacheHandler()
+	if err != nil {
+		return err
+	}
+
+	var retryItems []batch.Item
+	for _, item := range items {
+		failed, err := handler.extractor.FailedPages(item.Path)
+		if err != nil {
+			return err
+		}
+		if len(failed) == 0 {
+			fmt.Printf("✅ No failed pages: %s\n", item.Path)
+			continue
+		}
+		fmt.Printf("🔁 %d failed page(s): %s\n", len(failed), item.Path)
+		for _, page := range failed {
+			fmt.Printf("   page %d: %s\n", page.Number, page.Error)
+		}
+		retryItems = append(retryItems, item)
+	}
+	if len(retryItems) == 0 {
+		return nil
+	}
+
+	retry := NewAppHandler()
+	retry.retryFailed = true
+	if !batch.IsBatchInput(inputs) {
+		return retry.ProcessFile(retryItems[0].Path)
+	}
+	return retry.processItems(retryItems)
+}
+
+func init() {
+	rootCmd.AddCommand(retryFailedCmd)
+}
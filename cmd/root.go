@@ -7,11 +7,10 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
-	"time"
 
+	"doc-to-text/pkg/batch"
 	"doc-to-text/pkg/config"
-	"doc-to-text/pkg/constants"
-	"doc-to-text/pkg/core"
+	"doc-to-text/pkg/doctotext"
 	"doc-to-text/pkg/interfaces"
 	"doc-to-text/pkg/logger"
 	"doc-to-text/pkg/types"
@@ -21,19 +20,47 @@ import (
 )
 
 var (
-	outputPath  string
-	ocrStrategy string
-	llmTemplate string
-	contentType string
-	verbose     bool
-	showVersion bool
+	outputPath     string
+	ocrStrategy    string
+	llmTemplate    string
+	contentType    string
+	ocrLangs       []string
+	tablesFlag     bool
+	formulaCmd     string
+	formulaTmpl    string
+	correctTmpl    string
+	correctImg     bool
+	correctConf    float64
+	removeHeaders  bool
+	reflowText     bool
+	outputFormat   string
+	chunkSize      int
+	chunkOverlap   int
+	chunkUnit      string
+	indexDir       string
+	cacheDir       string
+	mirrorOutput   bool
+	onLocked       string
+	includes       []string
+	excludes       []string
+	jobs           int
+	extractorOrder []string
+	stdinName      string
+	stdinType      string
+	verbose        bool
+	showVersion    bool
 )
 
 // AppHandler encapsulates application main processing logic
 type AppHandler struct {
-	config    *config.Config
-	logger    *logger.Logger
-	processor interfaces.FileProcessor
+	config      *config.Config
+	logger      *logger.Logger
+	extractor   *doctotext.Extractor
+	inputFiles  []string
+	unattended  bool // never prompt (watch and server modes)
+	logStderr   bool // keep stdout for the extracted text
+	retryFailed bool // recognize pages that failed in earlier runs again (retry-failed)
+	ctx         context.Context
 }
 
 // NewAppHandler creates an application handler
@@ -43,11 +70,28 @@ func NewAppHandler() *AppHandler {
 
 // ProcessFile is the main entry point for file processing
 func (h *AppHandler) ProcessFile(inputFile string) error {
+	// Validate input file path
+	if _, err := filepath.Abs(inputFile); err != nil {
+		return utils.WrapError(err, utils.ErrorTypeValidation, "error resolving file path")
+	}
+
+	// "-o -" writes the output to stdout and keeps log output on stderr
+	h.logStderr = outputPath == "-"
+
 	// Initialize configuration and components
-	if err := h.initialize(inputFile); err != nil {
+	if err := h.initialize([]string{inputFile}); err != nil {
 		return err
 	}
 
+	if h.logStderr {
+		absPath, _ := filepath.Abs(inputFile)
+		result, err := h.extractor.ExtractFile(h.context(), absPath)
+		if err != nil {
+			return err
+		}
+		return h.writeStdout(result)
+	}
+
 	// Process the file
 	result, err := h.processFile(inputFile)
 	if err != nil {
@@ -59,45 +103,209 @@ func (h *AppHandler) ProcessFile(inputFile string) error {
 	return nil
 }
 
-// initialize initializes application components
-func (h *AppHandler) initialize(inputFile string) error {
-	// Validate input file path
-	_, err := filepath.Abs(inputFile)
+// ProcessStdin extracts text from a document piped to stdin. The text is written
+// to the -o file or, without -o or with "-o -", to stdout with log output on stderr.
+func (h *AppHandler) ProcessStdin() error {
+	// Stdin carries the document, so it cannot answer prompts
+	h.unattended = true
+	h.logStderr = outputPath == "" || outputPath == "-"
+	if err := h.initialize(nil); err != nil {
+		return err
+	}
+
+	hint := types.SourceHint{Name: stdinName, MimeType: stdinType}
+	if h.logStderr {
+		result, err := h.extractor.ExtractReader(h.context(), os.Stdin, hint)
+		if err != nil {
+			return err
+		}
+		return h.writeStdout(result)
+	}
+
+	outputFilePath, err := filepath.Abs(outputPath)
 	if err != nil {
-		return utils.WrapError(err, utils.ErrorTypeValidation, "error resolving file path")
+		return utils.WrapError(err, utils.ErrorTypeValidation, fmt.Sprintf("failed to resolve output path '%s'", outputPath))
+	}
+	if err := h.validateOutputPath(outputFilePath); err != nil {
+		return utils.WrapError(err, utils.ErrorTypeValidation, "output path validation failed")
+	}
+
+	result, err := h.extractor.ExtractReaderTo(h.context(), os.Stdin, hint, outputFilePath)
+	if err != nil {
+		return err
+	}
+	h.displayResults(result)
+	return nil
+}
+
+// writeStdout writes a result to stdout in the configured output format, or its
+// chunks as JSONL when chunking is enabled
+func (h *AppHandler) writeStdout(result *interfaces.ExtractionResult) error {
+	content, err := doctotext.Render(result, h.config.OutputFormat)
+	if h.config.ChunkSize > 0 {
+		content, err = doctotext.RenderChunks(result, h.config.ChunkOptions())
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stdout.Write(content); err != nil {
+		return utils.WrapError(err, utils.ErrorTypeIO, "failed to write output to stdout")
+	}
+	return nil
+}
+
+// ProcessBatch processes files, directories and glob patterns concurrently
+// and prints a summary. Returns an error when any file failed.
+func (h *AppHandler) ProcessBatch(inputs []string) error {
+	items, err := batch.Collect(inputs, batch.Options{Include: includes, Exclude: excludes})
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return utils.NewNotFoundError("no supported files found in the given inputs", nil)
 	}
+	return h.processItems(items)
+}
+
+// processItems processes collected batch items concurrently and prints a summary
+func (h *AppHandler) processItems(items []batch.Item) error {
+	if outputPath == "-" {
+		return utils.NewValidationError("'-o -' writes a single result to stdout and cannot be used with several inputs", nil)
+	}
+
+	inputFiles := make([]string, len(items))
+	for i, item := range items {
+		inputFiles[i] = item.Path
+	}
+	if err := h.initialize(inputFiles); err != nil {
+		return err
+	}
+
+	// With several inputs, -o names a directory mirroring the input tree
+	outputDir := ""
+	if outputPath != "" {
+		var err error
+		outputDir, err = filepath.Abs(outputPath)
+		if err != nil {
+			return utils.WrapError(err, utils.ErrorTypeValidation, fmt.Sprintf("failed to resolve output path '%s'", outputPath))
+		}
+		if info, err := os.Stat(outputDir); err == nil && !info.IsDir() {
+			return utils.NewValidationError(fmt.Sprintf("output path '%s' is a file, please specify a directory for batch processing", outputDir), nil)
+		}
+	}
+
+	h.logger.ProgressAlways("📚", "Processing %d files with up to %d in parallel", len(items), h.config.MaxConcurrency)
+	if mayNeedOCR(items) {
+		if err := h.resolveOCRStrategy(); err != nil && !h.unattended {
+			return utils.WrapError(err, utils.ErrorTypeValidation, "error selecting OCR tool")
+		}
+	}
+
+	summary := batch.Run(h.context(), items, h.config.MaxConcurrency, func(ctx context.Context, item batch.Item) (*interfaces.ExtractionResult, error) {
+		outputFilePath, err := h.batchOutputPath(item, outputDir)
+		if err != nil {
+			return nil, err
+		}
+		if err := h.validateOutputPath(outputFilePath); err != nil {
+			return nil, utils.WrapError(err, utils.ErrorTypeValidation, "output path validation failed")
+		}
+		return h.extractor.ExtractFileTo(ctx, item.Path, outputFilePath)
+	}, h.logger)
+
+	h.displayBatchSummary(summary)
+
+	if err := h.context().Err(); err != nil {
+		return utils.NewError(utils.ErrorTypeInterrupted, fmt.Sprintf("interrupted after %d of %d files", summary.Succeeded+summary.Skipped, len(items)), err)
+	}
+	if summary.Failed > 0 {
+		return utils.NewError(batchErrorType(summary), fmt.Sprintf("%d of %d files failed", summary.Failed, len(items)), nil)
+	}
+	return nil
+}
+
+// resolveOCRStrategy settles an interactive OCR strategy before files are
+// processed in parallel, so the choice is made (and prompted for) only once.
+// Without a tool, files that need OCR fail on their own.
+func (h *AppHandler) resolveOCRStrategy() error {
+	if err := h.extractor.ResolveOCRStrategy(); err != nil {
+		h.logger.Warn("No OCR tool selected, files that need OCR will fail: %v", err)
+		return err
+	}
+	h.config.OCRStrategy = h.extractor.Config().OCRStrategy
+	return nil
+}
+
+// mayNeedOCR reports whether any batch item is a PDF or an image, judged by its
+// extension, so a batch of text documents does not ask for an OCR tool
+func mayNeedOCR(items []batch.Item) bool {
+	for _, item := range items {
+		extension := strings.TrimPrefix(strings.ToLower(filepath.Ext(item.Path)), ".")
+		if extension == "pdf" || utils.IsImageFile(extension) {
+			return true
+		}
+	}
+	return false
+}
+
+// initialize initializes application components
+func (h *AppHandler) initialize(inputFiles []string) error {
+	h.inputFiles = inputFiles
 
 	// Load configuration with environment overrides (no file persistence)
 	h.config = config.LoadConfigWithEnvOverrides()
-	h.applyCommandLineOverrides()
+	if err := h.applyCommandLineOverrides(); err != nil {
+		return err
+	}
+	if h.retryFailed {
+		// Existing outputs lack the failed pages, so they are not reused
+		h.config.RetryFailedPages = true
+		h.config.SkipExisting = false
+	}
 
 	// Validate configuration
 	if err := h.config.Validate(); err != nil {
 		return utils.WrapError(err, utils.ErrorTypeValidation, "configuration validation failed")
 	}
 
-	// Create logger and processor
-	h.logger = logger.NewLogger(h.config.LogLevel, h.config.EnableVerbose)
-	h.processor = core.NewFileProcessor(h.config, h.logger)
+	// Create logger and the extractor shared by all files of this run
+	if h.logStderr {
+		h.logger = logger.NewLoggerWithOutput(h.config.LogLevel, h.config.EnableVerbose, os.Stderr)
+	} else {
+		h.logger = logger.NewLogger(h.config.LogLevel, h.config.EnableVerbose)
+	}
+
+	options := []doctotext.Option{doctotext.WithConfig(h.config), doctotext.WithLogger(h.logger)}
+	if !h.unattended {
+		options = append(options, doctotext.WithPrompts())
+	}
+	extractor, err := doctotext.New(options...)
+	if err != nil {
+		return err
+	}
+	h.extractor = extractor
 
 	return nil
 }
 
 // applyCommandLineOverrides applies command line parameter overrides
-func (h *AppHandler) applyCommandLineOverrides() {
+func (h *AppHandler) applyCommandLineOverrides() error {
 	if ocrStrategy != "" {
 		h.config.OCRStrategy = types.OCRStrategy(ocrStrategy)
 
 		// Validate LLM template parameter
 		if h.config.OCRStrategy == types.OCRStrategyLLMCaller && llmTemplate == "" {
-			log.Fatalf("Error: LLM template is required when using llm-caller OCR strategy")
+			return utils.NewValidationError("LLM template is required when using llm-caller OCR strategy", nil)
 		}
 		h.config.LLMTemplate = llmTemplate
 	}
 
+	if len(ocrLangs) > 0 {
+		h.config.OCRLanguages = utils.NormalizeLanguages(ocrLangs)
+	}
+
 	if contentType != "" {
 		h.config.ContentType = types.ContentType(contentType)
-	} else if ocrStrategy == "" {
+	} else if ocrStrategy == "" && !h.unattended {
 		// When neither ocr nor content-type is specified, smart detection
 		// For pure text and HTML documents, use default settings without prompting
 		if h.shouldSkipContentTypePrompt() {
@@ -108,31 +316,122 @@ func (h *AppHandler) applyCommandLineOverrides() {
 			// For other document types (like PDF), ask user interactively
 			selectedContentType, err := h.promptForContentType()
 			if err != nil {
-				log.Fatalf("Error selecting content type: %v", err)
+				return utils.WrapError(err, utils.ErrorTypeValidation, "error selecting content type")
 			}
 			h.config.ContentType = selectedContentType
 		}
 	}
 
+	if tablesFlag {
+		h.config.DetectTables = true
+	}
+
+	if formulaCmd != "" {
+		h.config.FormulaCommand = formulaCmd
+	}
+	if formulaTmpl != "" {
+		h.config.FormulaTemplate = formulaTmpl
+	}
+
+	if correctTmpl != "" {
+		h.config.CorrectionTemplate = correctTmpl
+	}
+	if correctImg {
+		h.config.CorrectionWithImage = true
+	}
+	if correctConf >= 0 {
+		h.config.CorrectionSkipConfidence = correctConf
+	}
+
+	if removeHeaders {
+		h.config.RemoveHeadersFooters = true
+	}
+
+	if reflowText {
+		h.config.Reflow = true
+	}
+
+	if outputFormat != "" {
+		format, ok := types.ParseOutputFormat(outputFormat)
+		if !ok {
+			return utils.NewValidationError(fmt.Sprintf("invalid output format '%s' (expected text, markdown, json or jsonl)", outputFormat), nil)
+		}
+		h.config.OutputFormat = format
+	}
+
+	if chunkSize >= 0 {
+		h.config.ChunkSize = chunkSize
+	}
+	if chunkOverlap >= 0 {
+		h.config.ChunkOverlap = chunkOverlap
+	}
+	if chunkUnit != "" {
+		unit, ok := types.ParseChunkUnit(chunkUnit)
+		if !ok {
+			return utils.NewValidationError(fmt.Sprintf("invalid chunk unit '%s' (expected chars or tokens)", chunkUnit), nil)
+		}
+		h.config.ChunkUnit = unit
+	}
+
+	if indexDir != "" {
+		h.config.IndexDir = indexDir
+	}
+	if cacheDir != "" {
+		h.config.CacheDir = cacheDir
+	}
+	if onLocked != "" {
+		mode, ok := types.ParseLockMode(onLocked)
+		if !ok {
+			return utils.NewValidationError(fmt.Sprintf("invalid lock mode '%s' (expected wait, skip or piggyback)", onLocked), nil)
+		}
+		h.config.OnLocked = mode
+	}
+
 	// Apply verbose parameter override
 	if verbose {
 		h.config.EnableVerbose = true
 	}
+
+	if jobs > 0 {
+		h.config.MaxConcurrency = jobs
+	}
+
+	if len(extractorOrder) > 0 {
+		order, err := config.ParseExtractorOrder(extractorOrder)
+		if err != nil {
+			return err
+		}
+		merged := make(map[string][]string, len(h.config.ExtractorOrder)+len(order))
+		for format, names := range h.config.ExtractorOrder {
+			merged[format] = names
+		}
+		for format, names := range order {
+			merged[format] = names
+		}
+		h.config.ExtractorOrder = merged
+	}
+
+	return nil
 }
 
 // shouldSkipContentTypePrompt checks whether to skip content type prompting
-// For pure text documents, HTML documents, e-books, and image files, no need to ask for content type
+// For pure text documents, HTML documents, e-books, and image files, no need to ask for content type.
+// In batch mode the prompt is shown once when any input needs it.
 func (h *AppHandler) shouldSkipContentTypePrompt() bool {
-	// This method is called before initialization, so we need to temporarily get file info
-	if len(os.Args) < 2 {
+	if len(h.inputFiles) == 0 {
 		return false
 	}
 
-	inputFile := os.Args[len(os.Args)-1] // Get the last parameter as input file
-	if inputFile == "" || strings.HasPrefix(inputFile, "-") {
-		return false // If it's an option parameter, not a file path
+	for _, inputFile := range h.inputFiles {
+		if !skipsContentTypePrompt(inputFile) {
+			return false
+		}
 	}
+	return true
+}
 
+// skipsContentTypePrompt reports whether a file's type never needs the PDF content type
+func skipsContentTypePrompt(inputFile string) bool {
 	// Get file extension
 	ext := strings.ToLower(filepath.Ext(inputFile))
 	if ext != "" && ext[0] == '.' {
@@ -174,27 +473,7 @@ func (h *AppHandler) processFile(inputFile string) (*interfaces.ExtractionResult
 		return nil, utils.WrapError(err, utils.ErrorTypeValidation, "output path validation failed")
 	}
 
-	// Create timeout context
-	ctx, cancel := context.WithTimeout(context.Background(),
-		time.Duration(h.config.TimeoutMinutes)*time.Minute)
-	defer cancel()
-
-	// Execute file processing (with retry)
-	var result *interfaces.ExtractionResult
-	err = utils.WithRetry(func() error {
-		var processErr error
-		result, processErr = h.processor.ProcessFile(ctx, absPath, outputFilePath)
-		if processErr != nil {
-			return utils.WrapError(processErr, utils.ErrorTypeOCR, "file processing failed")
-		}
-
-		if result.Error != "" {
-			return utils.NewOCRError(result.Error, nil)
-		}
-		return nil
-	}, constants.DefaultMaxRetries, nil)
-
-	return result, err
+	return h.extractor.ExtractFileTo(h.context(), absPath, outputFilePath)
 }
 
 // determineOutputPath determines the output file path
@@ -222,15 +501,30 @@ func (h *AppHandler) determineOutputPath(inputPath string) (string, error) {
 		return absOutputPath, nil
 	}
 
-	// Use MD5 hash path
+	return h.defaultOutputPath(inputPath)
+}
+
+// defaultOutputPath returns the output of an input without -o: next to the input
+// with --mirror (report.pdf -> report.pdf.txt), otherwise in its work directory
+func (h *AppHandler) defaultOutputPath(inputPath string) (string, error) {
+	if mirrorOutput {
+		return inputPath + h.config.OutputFormat.Extension(), nil
+	}
+
 	md5Hash, err := utils.CalculateFileMD5(inputPath)
 	if err != nil {
 		return "", utils.WrapError(err, utils.ErrorTypeIO, "failed to calculate MD5 hash")
 	}
+	return filepath.Join(h.config.WorkDir(inputPath, md5Hash), "text"+h.config.OutputFormat.Extension()), nil
+}
 
-	// Generate output path based on input directory and MD5 hash
-	inputDir := filepath.Dir(inputPath)
-	return filepath.Join(inputDir, md5Hash, "text.txt"), nil
+// batchOutputPath determines the output file of a batch item: the item's relative
+// path under outputDir when given, otherwise its default output
+func (h *AppHandler) batchOutputPath(item batch.Item, outputDir string) (string, error) {
+	if outputDir != "" {
+		return filepath.Join(outputDir, filepath.FromSlash(item.RelPath)+h.config.OutputFormat.Extension()), nil
+	}
+	return h.defaultOutputPath(item.Path)
 }
 
 // validateOutputPath validates the output file path
@@ -265,6 +559,9 @@ func (h *AppHandler) validateOutputPath(outputPath string) error {
 func (h *AppHandler) displayResults(result *interfaces.ExtractionResult) {
 	fmt.Printf("✅ Text extraction completed successfully\n")
 	fmt.Printf("📊 Extractor used: %s\n", result.ExtractorUsed)
+	if result.DetectedType != "" {
+		fmt.Printf("🔎 Detected type: %s (%s)\n", result.Format, result.DetectedType)
+	}
 	fmt.Printf("⏱️  Processing time: %dms\n", result.ProcessTime)
 
 	if result.FallbackUsed {
@@ -278,6 +575,61 @@ func (h *AppHandler) displayResults(result *interfaces.ExtractionResult) {
 	}
 }
 
+// displayBatchSummary displays the outcome of every batch item and the totals
+func (h *AppHandler) displayBatchSummary(summary *batch.Summary) {
+	fmt.Printf("\n📚 Batch summary\n")
+	fmt.Println("================")
+	for _, result := range summary.Results {
+		switch result.Status {
+		case batch.StatusSucceeded:
+			fmt.Printf("✅ %s (%s, %dms)\n", result.Item.RelPath, result.ExtractorUsed, result.Duration.Milliseconds())
+		case batch.StatusSkipped:
+			if result.ErrorType == utils.ErrorTypeLocked {
+				fmt.Printf("🔒 %s (being processed by another process)\n", result.Item.RelPath)
+			} else {
+				fmt.Printf("⏭️  %s (existing output)\n", result.Item.RelPath)
+			}
+		case batch.StatusFailed:
+			fmt.Printf("❌ %s [%s]: %v\n", result.Item.RelPath, result.ErrorType, result.Error)
+		}
+	}
+
+	fmt.Printf("\n📊 Total: %d, succeeded: %d, skipped: %d, failed: %d\n",
+		len(summary.Results), summary.Succeeded, summary.Skipped, summary.Failed)
+	fmt.Printf("⏱️  Processing time: %dms\n", summary.Duration.Milliseconds())
+
+	if summary.Failed > 0 {
+		counts := make(map[utils.ErrorType]int)
+		var order []utils.ErrorType
+		for _, result := range summary.Results {
+			if result.Status == batch.StatusFailed {
+				if counts[result.ErrorType] == 0 {
+					order = append(order, result.ErrorType)
+				}
+				counts[result.ErrorType]++
+			}
+		}
+		for _, errorType := range order {
+			fmt.Printf("⚠️  %s: %d\n", errorType, counts[errorType])
+		}
+	}
+}
+
+// batchErrorType returns the error type shared by all failures, or system when they differ
+func batchErrorType(summary *batch.Summary) utils.ErrorType {
+	var errorType utils.ErrorType
+	for _, result := range summary.Results {
+		if result.Status != batch.StatusFailed {
+			continue
+		}
+		if errorType != "" && errorType != result.ErrorType {
+			return utils.ErrorTypeSystem
+		}
+		errorType = result.ErrorType
+	}
+	return errorType
+}
+
 // showTextPreview displays text preview
 func (h *AppHandler) showTextPreview(text string) {
 	if len(text) > 200 {
@@ -318,7 +670,7 @@ func (h *AppHandler) promptForContentType() (types.ContentType, error) {
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	SilenceUsage: true,
-	Use:          "doc-to-text [input_file]",
+	Use:          "doc-to-text [input_file|directory|glob|-]...",
 	Short:        "A CLI tool for extracting text from various document formats",
 	Long: "A CLI tool for extracting text from various document formats with configurable OCR capabilities.\n\n" +
 		"Features:\n" +
@@ -331,14 +683,18 @@ var rootCmd = &cobra.Command{
 		"  doc-to-text document.pdf                                        # Interactive mode with tool selection\n" +
 		"  doc-to-text document.pdf --ocr llm-caller --llm-template qwen-vl-ocr  # Use LLM Caller with template\n" +
 		"  doc-to-text document.pdf --ocr surya_ocr                       # Use Surya OCR\n" +
+		"  doc-to-text document.pdf --ocr surya_ocr --lang zh --lang en   # OCR with language hints\n" +
 		"  doc-to-text document.pdf --content-type text                   # Text-first processing\n" +
 		"  doc-to-text document.pdf --content-type image                  # Image-first processing\n" +
 		"  doc-to-text ebook.epub                                          # Extract from e-book\n" +
 		"  doc-to-text image.png                                           # Extract from image\n" +
 		"  doc-to-text document.pdf -o ./output.txt                       # Custom output file\n" +
+		"  cat page.html | doc-to-text - --stdin-name page.html > page.txt  # Read stdin, text to stdout\n" +
+		"  doc-to-text ./docs \"scans/*.pdf\" -j 4 -o ./texts               # Batch: directories and globs\n" +
+		"  doc-to-text ./docs --include \"*.pdf\" --exclude \"drafts/**\"     # Batch with filters\n" +
 		"  doc-to-text document.pdf --verbose                             # Enable verbose output\n" +
 		"  doc-to-text document.pdf -v --ocr surya_ocr                    # Verbose with Surya OCR",
-	Args: cobra.MaximumNArgs(1),
+	Args: cobra.ArbitraryArgs,
 	Run: func(cmd *cobra.Command, args []string) {
 		// Handle version flag
 		if showVersion {
@@ -351,11 +707,20 @@ var rootCmd = &cobra.Command{
 			return
 		}
 
+		// Several files, directories and glob patterns are processed as a batch
+		if batch.IsBatchInput(args) {
+			handler := NewAppHandler()
+			fatalOnError(handler.ProcessBatch(args))
+			return
+		}
+
 		inputFile := args[0]
 
-		// Early validation: Check if input is a directory
-		if fileInfo, err := os.Stat(inputFile); err == nil && fileInfo.IsDir() {
-			log.Fatalf("Error (validation): Input path '%s' is a directory, please specify a file", inputFile)
+		// "-" reads the document from stdin
+		if inputFile == "-" {
+			handler := NewAppHandler()
+			fatalOnError(handler.ProcessStdin())
+			return
 		}
 
 		// Early validation: Check if output path (if specified) points to an existing directory
@@ -368,13 +733,7 @@ var rootCmd = &cobra.Command{
 		}
 
 		handler := NewAppHandler()
-		if err := handler.ProcessFile(inputFile); err != nil {
-			if appErr, ok := err.(*utils.AppError); ok {
-				log.Fatalf("Error (%s): %s", appErr.Type, appErr.Message)
-			} else {
-				log.Fatalf("Error: %v", err)
-			}
-		}
+		fatalOnError(handler.ProcessFile(inputFile))
 	},
 }
 
@@ -387,11 +746,34 @@ func NewRootCmd() *cobra.Command {
 
 // updateFlagDescriptions updates flag descriptions
 func updateFlagDescriptions() {
-	rootCmd.Flags().Lookup("output").Usage = "Output file path"
-	rootCmd.Flags().Lookup("ocr").Usage = "OCR strategy (interactive, llm-caller, surya_ocr)"
-	rootCmd.Flags().Lookup("llm-template").Usage = "LLM template name (required for llm-caller)"
-	rootCmd.Flags().Lookup("content-type").Usage = "Content processing type (text, image)"
-	rootCmd.Flags().Lookup("verbose").Usage = "Enable verbose output"
+	rootCmd.PersistentFlags().Lookup("output").Usage = "Output file path, - for stdout (output directory when processing several files or watching a folder)"
+	rootCmd.PersistentFlags().Lookup("ocr").Usage = "OCR strategy (interactive, llm-caller, surya_ocr)"
+	rootCmd.PersistentFlags().Lookup("llm-template").Usage = "LLM template name (required for llm-caller)"
+	rootCmd.PersistentFlags().Lookup("lang").Usage = "OCR language hints as ISO 639 codes, repeatable (auto-detected when omitted)"
+	rootCmd.PersistentFlags().Lookup("tables").Usage = "Detect tables on OCR pages (Markdown in text, CSV files under the {md5} directory)"
+	rootCmd.PersistentFlags().Lookup("formula-cmd").Usage = "Formula-OCR command converting math regions to LaTeX (e.g. texify); enables formula recognition"
+	rootCmd.PersistentFlags().Lookup("formula-template").Usage = "LLM template locating formula regions (default: surya_layout)"
+	rootCmd.PersistentFlags().Lookup("remove-headers").Usage = "Remove running headers, footers and page numbers repeated across PDF pages"
+	rootCmd.PersistentFlags().Lookup("correct-template").Usage = "LLM template post-correcting OCR text per page (original text is kept alongside)"
+	rootCmd.PersistentFlags().Lookup("correct-with-image").Usage = "Send the page image to the correction template together with the OCR text"
+	rootCmd.PersistentFlags().Lookup("correct-skip-confidence").Usage = "Skip correction of pages whose OCR confidence is at least this value, 0 never skips (default: 0.95)"
+	rootCmd.PersistentFlags().Lookup("reflow").Usage = "Join hyphenated line breaks and reflow hard-wrapped lines into paragraphs"
+	rootCmd.PersistentFlags().Lookup("format").Usage = "Output format: text, markdown, json (full result) or jsonl (one record per page); sets the output file extension"
+	rootCmd.PersistentFlags().Lookup("chunk-size").Usage = "Split the text into chunks of this size for RAG ingestion, written as JSONL next to the output (to stdout with -o -); 0 disables"
+	rootCmd.PersistentFlags().Lookup("chunk-overlap").Usage = "Amount of text repeated from the end of the previous chunk (default 0)"
+	rootCmd.PersistentFlags().Lookup("chunk-unit").Usage = "Unit of --chunk-size and --chunk-overlap: tokens (approximate, default) or chars"
+	rootCmd.PersistentFlags().Lookup("cache-dir").Usage = "Root of the per-document work directories holding intermediate files and cached text, or 'local' for {md5} directories next to the inputs (default: $XDG_CACHE_HOME/doc-to-text)"
+	rootCmd.PersistentFlags().Lookup("mirror").Usage = "Without -o, write the output next to the input (report.pdf -> report.pdf.txt) instead of the cache"
+	rootCmd.PersistentFlags().Lookup("on-locked").Usage = "When another process is extracting the same document: wait for it and extract (default), skip the document, or piggyback to wait and reuse its result"
+	rootCmd.PersistentFlags().Lookup("index-dir").Usage = "Search index directory used by index and search, and updated after each extraction once created (default: $XDG_DATA_HOME/doc-to-text/index)"
+	rootCmd.PersistentFlags().Lookup("include").Usage = "Batch mode: only process files matching these glob patterns (repeatable, ** matches directories)"
+	rootCmd.PersistentFlags().Lookup("exclude").Usage = "Batch mode: skip files matching these glob patterns (repeatable)"
+	rootCmd.PersistentFlags().Lookup("jobs").Usage = "Batch mode: number of files processed in parallel (default: max concurrency setting)"
+	rootCmd.PersistentFlags().Lookup("extractors").Usage = "Extractor order for a format, e.g. pdf=calibre,ocr (repeatable; extractors: text, html, ocr, ebook, epub, docx, calibre)"
+	rootCmd.PersistentFlags().Lookup("content-type").Usage = "Content processing type (text, image)"
+	rootCmd.PersistentFlags().Lookup("verbose").Usage = "Enable verbose output"
+	rootCmd.Flags().Lookup("stdin-name").Usage = "File name hint for input read from stdin with '-', e.g. scan.pdf (selects the extractor)"
+	rootCmd.Flags().Lookup("stdin-type").Usage = "MIME type hint for input read from stdin with '-', e.g. application/pdf"
 	rootCmd.Flags().Lookup("version").Usage = "Show version information"
 }
 
@@ -404,11 +786,34 @@ func Execute() {
 }
 
 func init() {
-	// Initialize flags
-	rootCmd.Flags().StringVarP(&outputPath, "output", "o", "", "Output file path")
-	rootCmd.Flags().StringVar(&ocrStrategy, "ocr", "", "OCR strategy")
-	rootCmd.Flags().StringVar(&llmTemplate, "llm-template", "", "LLM template")
-	rootCmd.Flags().StringVar(&contentType, "content-type", "", "Content type")
-	rootCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose output")
+	// Initialize flags (processing options are shared with subcommands)
+	rootCmd.PersistentFlags().StringVarP(&outputPath, "output", "o", "", "Output file path")
+	rootCmd.PersistentFlags().StringVar(&ocrStrategy, "ocr", "", "OCR strategy")
+	rootCmd.PersistentFlags().StringVar(&llmTemplate, "llm-template", "", "LLM template")
+	rootCmd.PersistentFlags().StringVar(&contentType, "content-type", "", "Content type")
+	rootCmd.PersistentFlags().StringSliceVar(&ocrLangs, "lang", nil, "OCR language hints")
+	rootCmd.PersistentFlags().BoolVar(&tablesFlag, "tables", false, "Detect tables")
+	rootCmd.PersistentFlags().StringVar(&formulaCmd, "formula-cmd", "", "Formula-OCR command")
+	rootCmd.PersistentFlags().StringVar(&formulaTmpl, "formula-template", "", "Formula detection template")
+	rootCmd.PersistentFlags().BoolVar(&removeHeaders, "remove-headers", false, "Remove repeated headers and footers")
+	rootCmd.PersistentFlags().StringVar(&correctTmpl, "correct-template", "", "Correction template")
+	rootCmd.PersistentFlags().BoolVar(&correctImg, "correct-with-image", false, "Send page image to correction")
+	rootCmd.PersistentFlags().Float64Var(&correctConf, "correct-skip-confidence", -1, "Correction skip confidence")
+	rootCmd.PersistentFlags().BoolVar(&reflowText, "reflow", false, "Reflow text into paragraphs")
+	rootCmd.PersistentFlags().StringVarP(&outputFormat, "format", "f", "", "Output format")
+	rootCmd.PersistentFlags().IntVar(&chunkSize, "chunk-size", -1, "Chunk size")
+	rootCmd.PersistentFlags().IntVar(&chunkOverlap, "chunk-overlap", -1, "Chunk overlap")
+	rootCmd.PersistentFlags().StringVar(&chunkUnit, "chunk-unit", "", "Chunk unit")
+	rootCmd.PersistentFlags().StringVar(&cacheDir, "cache-dir", "", "Cache directory")
+	rootCmd.PersistentFlags().BoolVar(&mirrorOutput, "mirror", false, "Write output next to the input")
+	rootCmd.PersistentFlags().StringVar(&onLocked, "on-locked", "", "Behavior for documents locked by another process")
+	rootCmd.PersistentFlags().StringVar(&indexDir, "index-dir", "", "Search index directory")
+	rootCmd.PersistentFlags().StringSliceVar(&includes, "include", nil, "Include patterns")
+	rootCmd.PersistentFlags().StringSliceVar(&excludes, "exclude", nil, "Exclude patterns")
+	rootCmd.PersistentFlags().IntVarP(&jobs, "jobs", "j", 0, "Parallel files")
+	rootCmd.PersistentFlags().StringArrayVar(&extractorOrder, "extractors", nil, "Extractor order per format")
+	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose output")
+	rootCmd.Flags().StringVar(&stdinName, "stdin-name", "", "Stdin name hint")
+	rootCmd.Flags().StringVar(&stdinType, "stdin-type", "", "Stdin MIME type hint")
 	rootCmd.Flags().BoolVarP(&showVersion, "version", "V", false, "Show version")
 }
@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"doc-to-text/pkg/search"
+	"doc-to-text/pkg/utils"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	searchLimit int
+	searchPages int
+	searchJSON  bool
+)
+
+// searchCmd represents the search command
+var searchCmd = &cobra.Command{
+	Use:   "search <query>",
+	Short: "Search the indexed outputs",
+	Long: "Ranks the pages of indexed outputs with BM25 and prints the best documents with\n" +
+		"their matching pages and snippets. Words match any page containing them, \"quoted\n" +
+		"phrases\" match in order, and CJK text matches without spaces.\n\n" +
+		"Build the index first with 'doc-to-text index'.\n\n" +
+		"Examples:\n" +
+		"  doc-to-text search invoice 2024\n" +
+		"  doc-to-text search '\"limitation of liability\" contract'\n" +
+		"  doc-to-text search 机器学习 --limit 5 --json | jq '.[].sources'",
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := runSearch(strings.Join(args, " ")); err != nil {
+			if appErr, ok := err.(*utils.AppError); ok {
+				log.Fatalf("Error (%s): %s", appErr.Type, appErr.Message)
+			} else {
+				log.Fatalf("Error: %v", err)
+			}
+		}
+	},
+}
+
+// runSearch runs a query and prints the results
+func runSearch(query string) error {
+	handler := NewAppHandler()
+	handler.unattended = true
+	handler.logStderr = true
+	if err := handler.initialize(nil); err != nil {
+		return err
+	}
+
+	indexDir := handler.config.SearchIndexDir()
+	if !search.Exists(indexDir) {
+		return utils.NewNotFoundError(fmt.Sprintf("no search index in %s, create it with 'doc-to-text index'", indexDir), nil)
+	}
+	results, err := handler.extractor.Search(query, search.Options{Limit: searchLimit, PagesPerDoc: searchPages})
+	if err != nil {
+		return err
+	}
+
+	if searchJSON {
+		if results == nil {
+			results = []search.Result{}
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(results); err != nil {
+			return utils.WrapError(err, utils.ErrorTypeIO, "failed to write search results")
+		}
+		return nil
+	}
+
+	if len(results) == 0 {
+		fmt.Printf("🔍 No matches for: %s\n", query)
+		return nil
+	}
+	for i, result := range results {
+		title := result.Output
+		if len(result.Sources) > 0 {
+			title = result.Sources[0]
+		}
+		fmt.Printf("\n%d. %s (score %.2f, %d matching pages)\n", i+1, filepath.Base(title), result.Score, result.Hits)
+		for _, source := range result.Sources {
+			fmt.Printf("   📄 %s\n", source)
+		}
+		fmt.Printf("   💾 %s\n", result.Output)
+		for _, hit := range result.Pages {
+			location := "text"
+			if result.Paged {
+				location = fmt.Sprintf("page %d", hit.Page)
+			}
+			fmt.Printf("   📍 %s: %s\n", location, hit.Snippet)
+		}
+	}
+	return nil
+}
+
+func init() {
+	searchCmd.Flags().IntVar(&searchLimit, "limit", 10, "Largest number of documents shown")
+	searchCmd.Flags().IntVar(&searchPages, "pages", 3, "Largest number of matching pages shown per document")
+	searchCmd.Flags().BoolVar(&searchJSON, "json", false, "Print the results as JSON")
+
+	rootCmd.AddCommand(searchCmd)
+}
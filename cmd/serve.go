@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"doc-to-text/pkg/server"
+	"doc-to-text/pkg/utils"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	serveAddr       string
+	serveToken      string
+	servePublic     bool
+	serveRetention  time.Duration
+	serveJobsDir    string
+	serveQueueSize  int
+	serveMaxUpload  int64
+	serveAllowPaths []string
+)
+
+// serveCmd represents the HTTP server command
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Run an HTTP server exposing text extraction as a REST API",
+	Long: "Runs an HTTP server with an asynchronous job queue.\n\n" +
+		"Endpoints:\n" +
+		"  POST   /v1/jobs               Upload a file (multipart field \"file\") or send {\"path\": \"...\"}\n" +
+		"  GET    /v1/jobs               List jobs (?status=queued|running|succeeded|failed|cancelled)\n" +
+		"  GET    /v1/jobs/{id}          Job status and progress\n" +
+		"  GET    /v1/jobs/{id}/result   Result (?format=text|json|markdown)\n" +
+		"  DELETE /v1/jobs/{id}          Cancel a queued or running job\n" +
+		"  GET    /healthz               Liveness\n" +
+		"  GET    /readyz                Readiness and external tool availability\n\n" +
+		"The server listens on 127.0.0.1 by default. Binding another address requires a\n" +
+		"bearer token (--token or DOC_TEXT_SERVE_TOKEN) or --public.\n\n" +
+		"Examples:\n" +
+		"  doc-to-text serve --ocr surya_ocr --content-type image\n" +
+		"  DOC_TEXT_SERVE_TOKEN=secret doc-to-text serve --addr :8080 --retention 72h\n" +
+		"  doc-to-text serve --jobs-dir /var/lib/doc-to-text --allow-path /srv/documents -j 2",
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		fatalOnError(runServe())
+	},
+}
+
+// runServe configures the application and runs the server until interrupted
+func runServe() error {
+	handler := NewAppHandler()
+	handler.unattended = true
+	if err := handler.initialize(nil); err != nil {
+		return err
+	}
+
+	handler.resolveOCRStrategy() // Jobs needing OCR fail without a tool
+
+	jobsDir, err := filepath.Abs(serveJobsDir)
+	if err != nil {
+		return utils.WrapError(err, utils.ErrorTypeValidation, "error resolving jobs directory")
+	}
+
+	var allowedRoots []string
+	for _, root := range serveAllowPaths {
+		absRoot, err := filepath.Abs(root)
+		if err != nil {
+			return utils.WrapError(err, utils.ErrorTypeValidation, "error resolving allowed path")
+		}
+		if resolved, err := filepath.EvalSymlinks(absRoot); err == nil {
+			absRoot = resolved
+		}
+		allowedRoots = append(allowedRoots, absRoot)
+	}
+
+	token := serveToken
+	if token == "" {
+		token = os.Getenv("DOC_TEXT_SERVE_TOKEN")
+	}
+
+	srv, err := server.NewServer(handler.config, handler.logger, server.Options{
+		Address:       serveAddr,
+		Token:         token,
+		Public:        servePublic,
+		JobsDir:       jobsDir,
+		QueueSize:     serveQueueSize,
+		Workers:       handler.config.MaxConcurrency,
+		MaxUploadMB:   serveMaxUpload,
+		AllowedRoots:  allowedRoots,
+		Retention:     serveRetention,
+		ShutdownGrace: 10 * time.Second,
+	})
+	if err != nil {
+		return err
+	}
+
+	// SIGINT and SIGTERM stop the server once running jobs saved their progress
+	ctx := handler.context()
+	if err := srv.ListenAndServe(ctx); err != nil {
+		return err
+	}
+	if ctx.Err() != nil {
+		return utils.NewError(utils.ErrorTypeInterrupted, "server stopped; unfinished jobs are requeued on the next start", ctx.Err())
+	}
+	return nil
+}
+
+func init() {
+	serveCmd.Flags().StringVar(&serveAddr, "addr", "127.0.0.1:8080", "Listen address (non-loopback addresses need --token or --public)")
+	serveCmd.Flags().StringVar(&serveToken, "token", "", "Bearer token required on /v1 routes (default: $DOC_TEXT_SERVE_TOKEN)")
+	serveCmd.Flags().BoolVar(&servePublic, "public", false, "Allow a non-loopback --addr without a token")
+	serveCmd.Flags().DurationVar(&serveRetention, "retention", 24*time.Hour, "Delete finished jobs, their uploads and results this long after they finish (0 keeps them)")
+	serveCmd.Flags().StringVar(&serveJobsDir, "jobs-dir", "doc-to-text-jobs", "Directory where jobs, uploads and results are persisted")
+	serveCmd.Flags().IntVar(&serveQueueSize, "queue-size", 16, "Queued jobs accepted before new ones are rejected with 503")
+	serveCmd.Flags().Int64Var(&serveMaxUpload, "max-upload-mb", 200, "Largest accepted upload in MB")
+	serveCmd.Flags().StringSliceVar(&serveAllowPaths, "allow-path", nil, "Directory server-side path jobs may read from (repeatable; path jobs are disabled when unset)")
+
+	rootCmd.AddCommand(serveCmd)
+}
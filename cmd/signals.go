@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"doc-to-text/pkg/constants"
+	"doc-to-text/pkg/utils"
+)
+
+// interruptContext returns a context cancelled by the first SIGINT or SIGTERM, so
+// running extractions stop external tools, save their progress and return. A
+// second signal terminates the process right away.
+func interruptContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		signal.Stop(signals)
+		fmt.Fprintf(os.Stderr, "\n⏹️ Received %s, stopping after saving progress (repeat to quit immediately)\n", sig)
+		cancel()
+	}()
+	return ctx
+}
+
+// context returns the context of the extractions of this handler, cancelled on
+// SIGINT or SIGTERM. Signals are only caught once an extraction starts.
+func (h *AppHandler) context() context.Context {
+	if h.ctx == nil {
+		h.ctx = interruptContext()
+	}
+	return h.ctx
+}
+
+// fatalOnError exits with the error of a command; interrupted runs exit with
+// constants.ExitCodeInterrupted, and documents skipped because another process
+// is extracting them are not an error
+func fatalOnError(err error) {
+	if err == nil {
+		return
+	}
+	appErr, ok := err.(*utils.AppError)
+	switch {
+	case ok && appErr.Type == utils.ErrorTypeInterrupted:
+		log.Printf("Interrupted: %s", appErr.Message)
+		os.Exit(constants.ExitCodeInterrupted)
+	case ok && appErr.Type == utils.ErrorTypeLocked:
+		log.Printf("Skipped: %s", appErr.Message)
+		return
+	case ok:
+		log.Fatalf("Error (%s): %s", appErr.Type, appErr.Message)
+	}
+	log.Fatalf("Error: %v", err)
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"context"
+	"path/filepath"
+	"time"
+
+	"doc-to-text/pkg/interfaces"
+	"doc-to-text/pkg/utils"
+	"doc-to-text/pkg/watch"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	watchDoneDir   string
+	watchFailedDir string
+	watchInterval  time.Duration
+	watchSettle    time.Duration
+	watchOnce      bool
+)
+
+// watchCmd represents the hot-folder command
+var watchCmd = &cobra.Command{
+	Use:   "watch <dir>",
+	Short: "Watch a folder and extract text from new files",
+	Long: "Polls a directory for new files and extracts their text once their size has settled.\n\n" +
+		"Outputs are written to an output tree mirroring the inbox (default: <dir>/output).\n" +
+		"Processed sources move to done/, failed ones to failed/ with a .error.json sidecar.\n" +
+		"Files left in the inbox are picked up again after a restart.\n\n" +
+		"Examples:\n" +
+		"  doc-to-text watch ./inbox --ocr surya_ocr --content-type image\n" +
+		"  doc-to-text watch ./inbox -o ./texts --done-dir ./archive --interval 30s\n" +
+		"  doc-to-text watch ./inbox --once                                 # Process current files and exit",
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		fatalOnError(runWatch(args[0]))
+	},
+}
+
+// runWatch configures the application and runs the watcher
+func runWatch(inboxDir string) error {
+	opts := watch.Options{
+		DoneDir:   watchDoneDir,
+		FailedDir: watchFailedDir,
+		OutputDir: outputPath,
+		Interval:  watchInterval,
+		Settle:    watchSettle,
+		Once:      watchOnce,
+	}
+
+	var err error
+	for _, dir := range []*string{&inboxDir, &opts.OutputDir, &opts.DoneDir, &opts.FailedDir} {
+		if *dir == "" {
+			continue
+		}
+		if *dir, err = filepath.Abs(*dir); err != nil {
+			return utils.WrapError(err, utils.ErrorTypeValidation, "error resolving directory path")
+		}
+	}
+	opts.InboxDir = inboxDir
+
+	// A hot folder runs unattended, so never prompt for the content type
+	handler := NewAppHandler()
+	handler.unattended = true
+	if err := handler.initialize(nil); err != nil {
+		return err
+	}
+	handler.resolveOCRStrategy() // Watched files needing OCR fail without a tool
+	opts.Concurrency = handler.config.MaxConcurrency
+	opts.Extension = handler.config.OutputFormat.Extension()
+	opts.CacheRoot = handler.config.CacheRoot()
+
+	watcher := watch.NewWatcher(opts, func(ctx context.Context, inputFile, outputFile string) (*interfaces.ExtractionResult, error) {
+		if err := handler.validateOutputPath(outputFile); err != nil {
+			return nil, utils.WrapError(err, utils.ErrorTypeValidation, "output path validation failed")
+		}
+		return handler.extractor.ExtractFileTo(ctx, inputFile, outputFile)
+	}, handler.logger)
+
+	// SIGINT and SIGTERM stop the watcher once running files saved their progress
+	return watcher.Run(handler.context())
+}
+
+func init() {
+	watchCmd.Flags().StringVar(&watchDoneDir, "done-dir", "", "Directory for processed sources (default: <dir>/done)")
+	watchCmd.Flags().StringVar(&watchFailedDir, "failed-dir", "", "Directory for failed sources and error sidecars (default: <dir>/failed)")
+	watchCmd.Flags().DurationVar(&watchInterval, "interval", 5*time.Second, "Time between folder scans")
+	watchCmd.Flags().DurationVar(&watchSettle, "settle", 10*time.Second, "Time a file must stay unchanged before it is processed")
+	watchCmd.Flags().BoolVar(&watchOnce, "once", false, "Process the files currently in the folder and exit")
+
+	rootCmd.AddCommand(watchCmd)
+}
@@ -0,0 +1,252 @@
+package batch
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"doc-to-text/pkg/constants"
+	"doc-to-text/pkg/utils"
+)
+
+// IgnoreFileName is the per-directory file listing paths to leave out of batch runs
+const IgnoreFileName = ".doctotextignore"
+
+// md5DirPattern matches the {md5} work directories written next to processed inputs
+var md5DirPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// Item is one input file of a batch
+type Item struct {
+	Path    string // absolute path of the input file
+	RelPath string // path relative to the directory or glob base it was found under
+}
+
+// Options filter the files collected for a batch
+type Options struct {
+	Include []string // glob patterns a file must match (any), matched against its relative path and name
+	Exclude []string // glob patterns that drop a file
+}
+
+// IsBatchInput reports whether the arguments need batch processing:
+// several inputs, a directory or a glob pattern
+func IsBatchInput(inputs []string) bool {
+	if len(inputs) != 1 {
+		return len(inputs) > 1
+	}
+	info, err := os.Stat(inputs[0])
+	if err == nil {
+		return info.IsDir()
+	}
+	return hasGlobMeta(inputs[0])
+}
+
+// Collect expands files, directories (recursively) and glob patterns into the list
+// of input files. Files given explicitly are always kept; files found by walking a
+// directory must have a supported extension and pass the include/exclude filters
+// and any .doctotextignore files. Duplicates are removed and the order is stable.
+func Collect(inputs []string, opts Options) ([]Item, error) {
+	var items []Item
+	seen := make(map[string]bool)
+
+	add := func(path, relPath string) {
+		if !seen[path] {
+			seen[path] = true
+			items = append(items, Item{Path: path, RelPath: filepath.ToSlash(relPath)})
+		}
+	}
+
+	for _, input := range inputs {
+		paths := []string{input}
+		globbed := false
+		if _, err := os.Stat(input); err != nil && hasGlobMeta(input) {
+			matches, globErr := filepath.Glob(input)
+			if globErr != nil {
+				return nil, utils.NewValidationError(fmt.Sprintf("invalid glob pattern '%s'", input), globErr)
+			}
+			if len(matches) == 0 {
+				return nil, utils.NewNotFoundError(fmt.Sprintf("no files match '%s'", input), nil)
+			}
+			paths = matches
+			globbed = true
+		}
+
+		for _, path := range paths {
+			absPath, err := filepath.Abs(path)
+			if err != nil {
+				return nil, utils.WrapError(err, utils.ErrorTypeValidation, fmt.Sprintf("error resolving path '%s'", path))
+			}
+
+			info, err := os.Stat(absPath)
+			if os.IsNotExist(err) {
+				return nil, utils.NewNotFoundError(fmt.Sprintf("file not found: %s", path), err)
+			}
+			if err != nil {
+				return nil, utils.WrapError(err, utils.ErrorTypeIO, fmt.Sprintf("failed to access '%s'", path))
+			}
+
+			if info.IsDir() {
+				found, err := walkDir(absPath, opts)
+				if err != nil {
+					return nil, err
+				}
+				for _, item := range found {
+					add(item.Path, item.RelPath)
+				}
+				continue
+			}
+
+			// Glob matches are filtered like walked files; explicit files are taken as given
+			if globbed && (!isSupportedExtension(absPath) || !opts.matches(filepath.Base(absPath))) {
+				continue
+			}
+			add(absPath, filepath.Base(absPath))
+		}
+	}
+
+	return items, nil
+}
+
+// walkDir collects the supported files below root
+func walkDir(root string, opts Options) ([]Item, error) {
+	var items []Item
+	ignores := make(map[string]*ignoreRules)
+
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return utils.WrapError(err, utils.ErrorTypeIO, fmt.Sprintf("failed to read '%s'", path))
+		}
+
+		relPath, _ := filepath.Rel(root, path)
+		relPath = filepath.ToSlash(relPath)
+
+		if entry.IsDir() {
+			if path != root {
+				// Skip hidden directories and the tool's own {md5} work directories
+				name := entry.Name()
+				if strings.HasPrefix(name, ".") || md5DirPattern.MatchString(name) || isIgnored(ignores, relPath, true) {
+					return filepath.SkipDir
+				}
+			}
+			rules, err := loadIgnoreFile(filepath.Join(path, IgnoreFileName))
+			if err != nil {
+				return err
+			}
+			if rules != nil {
+				ignores[relPath] = rules
+			}
+			return nil
+		}
+
+		if strings.HasPrefix(entry.Name(), ".") || !isSupportedExtension(entry.Name()) {
+			return nil
+		}
+		if isMirroredOutput(filepath.Dir(path), entry.Name()) || isIgnored(ignores, relPath, false) || !opts.matches(relPath) {
+			return nil
+		}
+
+		items = append(items, Item{Path: path, RelPath: relPath})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(items, func(i, j int) bool { return items[i].RelPath < items[j].RelPath })
+	return items, nil
+}
+
+// matches applies the include and exclude patterns to a slash-separated relative path
+func (o Options) matches(relPath string) bool {
+	for _, pattern := range o.Exclude {
+		if matchPattern(pattern, relPath) {
+			return false
+		}
+	}
+	if len(o.Include) == 0 {
+		return true
+	}
+	for _, pattern := range o.Include {
+		if matchPattern(pattern, relPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPattern matches a glob pattern against a relative path. Patterns without a
+// slash match the file name at any depth; "**" matches any number of directories.
+func matchPattern(pattern, relPath string) bool {
+	pattern = strings.TrimPrefix(filepath.ToSlash(pattern), "./")
+	if !strings.Contains(pattern, "/") {
+		return matchSegments([]string{pattern}, []string{relPath[strings.LastIndex(relPath, "/")+1:]})
+	}
+	return matchSegments(strings.Split(strings.TrimPrefix(pattern, "/"), "/"), strings.Split(relPath, "/"))
+}
+
+// matchSegments matches path segments, expanding "**" to zero or more segments
+func matchSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(segments); i++ {
+				if matchSegments(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		if ok, _ := filepath.Match(pattern[0], segments[0]); !ok {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
+
+// mirroredOutputSuffixes are appended to an input's name by --mirror outputs and their sidecars
+var mirroredOutputSuffixes = []string{".metadata.json", ".chunks.jsonl", ".txt", ".md", ".json", ".jsonl"}
+
+// isMirroredOutput reports whether a file is the output of a supported input next
+// to it, such as report.pdf.txt beside report.pdf
+func isMirroredOutput(dir, name string) bool {
+	for _, suffix := range mirroredOutputSuffixes {
+		input := strings.TrimSuffix(name, suffix)
+		if input == name || !isSupportedExtension(input) {
+			continue
+		}
+		if info, err := os.Stat(filepath.Join(dir, input)); err == nil && info.Mode().IsRegular() {
+			return true
+		}
+	}
+	return false
+}
+
+// isSupportedExtension reports extensions handled by one of the extractors
+func isSupportedExtension(name string) bool {
+	ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(name)), ".")
+	if ext == "" {
+		return false
+	}
+	for _, group := range [][]string{
+		constants.DocumentExtensions, constants.TextExtensions,
+		constants.EbookExtensions, constants.ImageExtensions,
+	} {
+		for _, supported := range group {
+			if ext == supported {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hasGlobMeta reports whether a path contains glob metacharacters
+func hasGlobMeta(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
@@ -0,0 +1,105 @@
+package batch
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"doc-to-text/pkg/utils"
+)
+
+// ignorePattern is one line of a .doctotextignore file
+type ignorePattern struct {
+	pattern  string
+	negate   bool // "!pattern" re-includes a previously ignored path
+	dirOnly  bool // "pattern/" only matches directories
+	anchored bool // patterns containing a slash match relative to the ignore file
+}
+
+// ignoreRules are the patterns of one .doctotextignore file, in file order
+type ignoreRules struct {
+	patterns []ignorePattern
+}
+
+// loadIgnoreFile parses a .doctotextignore file using a subset of .gitignore syntax:
+// comments, blank lines, "!" negation, trailing "/" for directories and "**".
+// Returns nil when the file does not exist.
+func loadIgnoreFile(filePath string) (*ignoreRules, error) {
+	file, err := os.Open(filePath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, utils.WrapError(err, utils.ErrorTypeIO, fmt.Sprintf("failed to read ignore file '%s'", filePath))
+	}
+	defer file.Close()
+
+	rules := &ignoreRules{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var pattern ignorePattern
+		if strings.HasPrefix(line, "!") {
+			pattern.negate = true
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			pattern.dirOnly = true
+			line = strings.TrimSuffix(line, "/")
+		}
+		pattern.anchored = strings.Contains(line, "/")
+		pattern.pattern = strings.TrimPrefix(line, "/")
+		if pattern.pattern != "" {
+			rules.patterns = append(rules.patterns, pattern)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, utils.WrapError(err, utils.ErrorTypeIO, fmt.Sprintf("failed to read ignore file '%s'", filePath))
+	}
+
+	return rules, nil
+}
+
+// match returns whether the rules ignore relPath (relative to the ignore file's
+// directory) and whether any rule applied at all
+func (r *ignoreRules) match(relPath string, isDir bool) (ignored, matched bool) {
+	for _, pattern := range r.patterns {
+		if pattern.dirOnly && !isDir {
+			continue
+		}
+		var ok bool
+		if pattern.anchored {
+			ok = matchSegments(strings.Split(pattern.pattern, "/"), strings.Split(relPath, "/"))
+		} else {
+			ok = matchSegments([]string{pattern.pattern}, []string{path.Base(relPath)})
+		}
+		if ok {
+			ignored, matched = !pattern.negate, true
+		}
+	}
+	return ignored, matched
+}
+
+// isIgnored applies the ignore files of every directory above relPath, deeper files
+// taking precedence over those closer to the root
+func isIgnored(ignores map[string]*ignoreRules, relPath string, isDir bool) bool {
+	ignored := false
+	dir := "."
+	segments := strings.Split(relPath, "/")
+	for i := 0; i < len(segments); i++ {
+		if rules, ok := ignores[dir]; ok {
+			rest := strings.Join(segments[i:], "/")
+			if result, matched := rules.match(rest, isDir); matched {
+				ignored = result
+			}
+		}
+		dir = path.Join(dir, segments[i])
+	}
+	return ignored
+}
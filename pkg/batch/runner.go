@@ -0,0 +1,128 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"doc-to-text/pkg/interfaces"
+	"doc-to-text/pkg/logger"
+	"doc-to-text/pkg/utils"
+)
+
+// Status is the outcome of one batch item
+type Status string
+
+const (
+	StatusSucceeded Status = "succeeded"
+	StatusSkipped   Status = "skipped"
+	StatusFailed    Status = "failed"
+)
+
+// ProcessFunc extracts the text of one batch item
+type ProcessFunc func(ctx context.Context, item Item) (*interfaces.ExtractionResult, error)
+
+// Result records the outcome of one batch item
+type Result struct {
+	Item          Item
+	Status        Status
+	ExtractorUsed string
+	Duration      time.Duration
+	Error         error
+	ErrorType     utils.ErrorType
+}
+
+// Summary collects the results of a batch run in input order
+type Summary struct {
+	Results   []Result
+	Succeeded int
+	Skipped   int
+	Failed    int
+	Duration  time.Duration
+}
+
+// Run processes the items with at most concurrency files in flight. Items not yet
+// started when ctx is done are reported as failed with an interrupted or timeout error.
+func Run(ctx context.Context, items []Item, concurrency int, process ProcessFunc, log *logger.Logger) *Summary {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	startTime := time.Now()
+	results := make([]Result, len(items))
+	semaphore := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for i, item := range items {
+		select {
+		case semaphore <- struct{}{}:
+		case <-ctx.Done():
+			results[i] = failedResult(item, ctx.Err(), 0)
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, item Item) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			log.ProgressAlways("📄", "[%d/%d] Processing %s", i+1, len(items), item.RelPath)
+			itemStart := time.Now()
+			result, err := process(ctx, item)
+			duration := time.Since(itemStart)
+
+			if err != nil && RootErrorType(err) == utils.ErrorTypeLocked {
+				// Another process is extracting it, see config.RuntimeConfig.OnLocked
+				results[i] = Result{Item: item, Status: StatusSkipped, Duration: duration, Error: err, ErrorType: utils.ErrorTypeLocked}
+				log.ProgressAlways("🔒", "[%d/%d] Skipped %s: %v", i+1, len(items), item.RelPath, err)
+				return
+			}
+			if err != nil {
+				results[i] = failedResult(item, err, duration)
+				log.Error("[%d/%d] %s failed: %v", i+1, len(items), item.RelPath, err)
+				return
+			}
+
+			status := StatusSucceeded
+			if result.ExtractorUsed == "cached" {
+				status = StatusSkipped
+			}
+			results[i] = Result{Item: item, Status: status, ExtractorUsed: result.ExtractorUsed, Duration: duration}
+		}(i, item)
+	}
+	wg.Wait()
+
+	summary := &Summary{Results: results, Duration: time.Since(startTime)}
+	for _, result := range results {
+		switch result.Status {
+		case StatusSucceeded:
+			summary.Succeeded++
+		case StatusSkipped:
+			summary.Skipped++
+		case StatusFailed:
+			summary.Failed++
+		}
+	}
+	return summary
+}
+
+// failedResult builds the result of a failed item
+func failedResult(item Item, err error, duration time.Duration) Result {
+	return Result{Item: item, Status: StatusFailed, Duration: duration, Error: err, ErrorType: RootErrorType(err)}
+}
+
+// RootErrorType returns the type of the innermost AppError, which names the original
+// cause rather than the stage that wrapped it
+func RootErrorType(err error) utils.ErrorType {
+	errorType := utils.GetErrorType(err)
+	for err != nil {
+		var appErr *utils.AppError
+		if !errors.As(err, &appErr) {
+			break
+		}
+		errorType = appErr.Type
+		err = appErr.Cause
+	}
+	return errorType
+}
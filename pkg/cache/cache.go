@@ -0,0 +1,167 @@
+// Package cache locates the work directories holding the intermediate files and
+// cached outputs of each document. Work directories are content-addressed: they
+// are named after the MD5 of the input and live under a central cache root, so
+// the same file in two folders is processed once and inputs may sit on read-only
+// shares. The "local" root keeps the original layout of a {md5} directory next
+// to every input.
+package cache
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"doc-to-text/pkg/constants"
+	"doc-to-text/pkg/utils"
+)
+
+const (
+	// Local selects work directories next to the inputs instead of a cache root
+	Local = "local"
+	// sourcesFileName lists the input files seen for a work directory
+	sourcesFileName = "sources.json"
+)
+
+// DefaultRoot returns the cache root used when none is configured:
+// $XDG_CACHE_HOME/doc-to-text, or ~/.cache/doc-to-text
+func DefaultRoot() string {
+	if dir := os.Getenv("XDG_CACHE_HOME"); dir != "" {
+		return filepath.Join(dir, "doc-to-text")
+	}
+	if dir, err := os.UserCacheDir(); err == nil {
+		return filepath.Join(dir, "doc-to-text")
+	}
+	return ".doc-to-text-cache"
+}
+
+// EntryDir returns the work directory of a hash under a cache root; entries are
+// spread over subdirectories named after the first two hex digits
+func EntryDir(root, hash string) string {
+	if len(hash) < 2 {
+		return filepath.Join(root, hash)
+	}
+	return filepath.Join(root, hash[:2], hash)
+}
+
+// LegacyDir returns the work directory next to an input file
+func LegacyDir(inputFile, hash string) string {
+	return filepath.Join(filepath.Dir(inputFile), hash)
+}
+
+// WorkDir returns the work directory of an input. An empty root means work
+// directories next to the inputs. A work directory left next to the input by
+// earlier versions keeps being used until it is migrated, so no work is redone.
+func WorkDir(root, inputFile, hash string) string {
+	if root == "" {
+		return LegacyDir(inputFile, hash)
+	}
+	entry := EntryDir(root, hash)
+	if _, err := os.Stat(entry); err != nil {
+		if info, err := os.Stat(LegacyDir(inputFile, hash)); err == nil && info.IsDir() {
+			return LegacyDir(inputFile, hash)
+		}
+	}
+	return entry
+}
+
+// RecordSource adds an input file to the sources of an existing work directory
+func RecordSource(workDir, source string) error {
+	if _, err := os.Stat(workDir); err != nil {
+		return nil
+	}
+	sources := Sources(workDir)
+	for _, known := range sources {
+		if known == source {
+			return nil
+		}
+	}
+	sources = append(sources, source)
+	sort.Strings(sources)
+
+	data, err := json.MarshalIndent(sources, "", "  ")
+	if err != nil {
+		return utils.WrapError(err, utils.ErrorTypeSystem, "failed to encode sources")
+	}
+	if err := utils.WriteFileAtomic(filepath.Join(workDir, sourcesFileName), append(data, '\n'), constants.DefaultFilePermission); err != nil {
+		return utils.WrapError(err, utils.ErrorTypeIO, "failed to save sources")
+	}
+	return nil
+}
+
+// Sources returns the input files recorded for a work directory
+func Sources(workDir string) []string {
+	data, err := os.ReadFile(filepath.Join(workDir, sourcesFileName))
+	if err != nil {
+		return nil
+	}
+	var sources []string
+	if json.Unmarshal(data, &sources) != nil {
+		return nil
+	}
+	return sources
+}
+
+// Move moves a work directory to dst. When dst already exists the files it lacks
+// are moved into it and the rest of src is dropped, as both hold the work of the
+// same content. Directories on other file systems are copied.
+func Move(src, dst string) error {
+	if _, err := os.Stat(dst); err != nil {
+		if err := utils.EnsureDir(filepath.Dir(dst)); err != nil {
+			return utils.WrapError(err, utils.ErrorTypeIO, "failed to create cache directory")
+		}
+		if os.Rename(src, dst) == nil {
+			return nil
+		}
+		if err := copyTree(src, dst); err != nil {
+			return err
+		}
+		return os.RemoveAll(src)
+	}
+
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return utils.WrapError(err, utils.ErrorTypeIO, "failed to read work directory")
+	}
+	for _, entry := range entries {
+		from, to := filepath.Join(src, entry.Name()), filepath.Join(dst, entry.Name())
+		if entry.Name() == sourcesFileName {
+			for _, source := range Sources(src) {
+				if err := RecordSource(dst, source); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if _, err := os.Stat(to); err == nil {
+			continue
+		}
+		if os.Rename(from, to) != nil {
+			if err := copyTree(from, to); err != nil {
+				return err
+			}
+		}
+	}
+	return os.RemoveAll(src)
+}
+
+// copyTree copies a file or directory recursively
+func copyTree(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if entry.IsDir() {
+			return utils.EnsureDir(target)
+		}
+		if err := utils.CopyFile(path, target); err != nil {
+			return utils.WrapError(err, utils.ErrorTypeIO, "failed to copy "+path)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,168 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"doc-to-text/pkg/utils"
+)
+
+// hashPattern matches the name of a work directory, the MD5 of its input
+var hashPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// artifactExtensions are the heavy intermediate files that can be rebuilt from the
+// input: page PDFs, rendered page images and image crops, plus temporary files left
+// by a killed process
+var artifactExtensions = map[string]bool{
+	".pdf": true, ".png": true, ".jpg": true, ".jpeg": true,
+	".tif": true, ".tiff": true, ".webp": true, ".bmp": true,
+	".tmp": true,
+}
+
+// artifactDirs are intermediate directories removed as a whole with the artifacts
+var artifactDirs = map[string]bool{"temp": true}
+
+// Entry is a work directory in the cache
+type Entry struct {
+	Hash       string      `json:"hash"`
+	Dir        string      `json:"dir"`
+	Sources    []string    `json:"sources,omitempty"`
+	Size       int64       `json:"size"`                // Bytes used by all files
+	Artifacts  int64       `json:"artifacts"`           // Bytes used by page images and other rebuildable files
+	Extractor  string      `json:"extractor,omitempty"` // From the manifest; empty for entries written before manifests
+	Engine     string      `json:"engine,omitempty"`
+	ModTime    time.Time   `json:"mod_time"`             // Last time a file in the entry was written
+	Incomplete *Incomplete `json:"incomplete,omitempty"` // Set when the last extraction was stopped
+	Lock       *LockHolder `json:"lock,omitempty"`       // Process extracting into the entry right now
+	Manifest   *Manifest   `json:"-"`
+}
+
+// Label returns the extractor and engine of an entry, e.g. "ocr/surya_ocr"
+func (e *Entry) Label() string {
+	switch {
+	case e.Extractor == "":
+		return "unknown"
+	case e.Engine == "" || e.Engine == e.Extractor:
+		return e.Extractor
+	default:
+		return e.Extractor + "/" + e.Engine
+	}
+}
+
+// List returns the entries under a cache root, most recently written first
+func List(root string) ([]*Entry, error) {
+	var entries []*Entry
+	shards, err := os.ReadDir(root)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, utils.WrapError(err, utils.ErrorTypeIO, "failed to read cache directory")
+	}
+	for _, shard := range shards {
+		if !shard.IsDir() || len(shard.Name()) != 2 {
+			continue
+		}
+		dirs, err := os.ReadDir(filepath.Join(root, shard.Name()))
+		if err != nil {
+			continue
+		}
+		for _, dir := range dirs {
+			if !dir.IsDir() || !hashPattern.MatchString(dir.Name()) || !strings.HasPrefix(dir.Name(), shard.Name()) {
+				continue
+			}
+			entries = append(entries, Load(EntryDir(root, dir.Name())))
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ModTime.After(entries[j].ModTime) })
+	return entries, nil
+}
+
+// Find returns the entry of a hash, or of the unique hash starting with a prefix
+func Find(root, prefix string) (*Entry, error) {
+	prefix = strings.ToLower(prefix)
+	if hashPattern.MatchString(prefix) {
+		dir := EntryDir(root, prefix)
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			return nil, utils.NewNotFoundError("no cache entry for "+prefix, err)
+		}
+		return Load(dir), nil
+	}
+	if len(prefix) < 2 {
+		return nil, utils.NewValidationError("hash prefix must have at least 2 characters", nil)
+	}
+
+	dirs, _ := os.ReadDir(filepath.Join(root, prefix[:2]))
+	var matches []string
+	for _, dir := range dirs {
+		if dir.IsDir() && hashPattern.MatchString(dir.Name()) && strings.HasPrefix(dir.Name(), prefix) {
+			matches = append(matches, dir.Name())
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, utils.NewNotFoundError("no cache entry for "+prefix, nil)
+	case 1:
+		return Load(EntryDir(root, matches[0])), nil
+	default:
+		return nil, utils.NewValidationError("hash prefix "+prefix+" is ambiguous", nil)
+	}
+}
+
+// Load reads a work directory: its sources, manifest and disk usage
+func Load(dir string) *Entry {
+	entry := &Entry{
+		Hash:       filepath.Base(dir),
+		Dir:        dir,
+		Sources:    Sources(dir),
+		Incomplete: ReadIncomplete(dir),
+		Lock:       ReadLock(dir),
+		Manifest:   ReadManifest(dir),
+	}
+	if entry.Manifest != nil {
+		entry.Extractor = entry.Manifest.Extractor
+		entry.Engine = entry.Manifest.Engine
+	}
+	filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		if info.ModTime().After(entry.ModTime) {
+			entry.ModTime = info.ModTime()
+		}
+		if d.IsDir() {
+			return nil
+		}
+		entry.Size += info.Size()
+		if isArtifact(dir, path) {
+			entry.Artifacts += info.Size()
+		}
+		return nil
+	})
+	return entry
+}
+
+// isArtifact reports whether a file in a work directory can be rebuilt from the
+// input. Inputs copied under their detected format (source.{format}) are artifacts
+// too; page texts, OCR data, tables and outputs are not.
+func isArtifact(workDir, path string) bool {
+	rel, err := filepath.Rel(workDir, path)
+	if err != nil {
+		return false
+	}
+	if first, _, _ := strings.Cut(filepath.ToSlash(rel), "/"); artifactDirs[first] {
+		return true
+	}
+	if strings.HasPrefix(rel, "source.") {
+		return true
+	}
+	return artifactExtensions[strings.ToLower(filepath.Ext(path))]
+}
@@ -0,0 +1,76 @@
+package cache
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"doc-to-text/pkg/constants"
+	"doc-to-text/pkg/utils"
+)
+
+// incompleteFileName marks a work directory whose last extraction was stopped
+const incompleteFileName = "incomplete.json"
+
+// Incomplete describes an extraction that was stopped before it finished. The text
+// of the pages done by then is kept in Output, never under the name of a finished
+// output, so it is not taken for a cached result.
+type Incomplete struct {
+	Reason     string    `json:"reason"` // Error type of the stop: "interrupted" or "timeout"
+	StoppedAt  time.Time `json:"stopped_at"`
+	Extractor  string    `json:"extractor,omitempty"`
+	PagesDone  int       `json:"pages_done,omitempty"`
+	TotalPages int       `json:"total_pages,omitempty"`
+	Output     string    `json:"output,omitempty"` // Partial output in the work directory, e.g. "text.partial.txt"
+	Error      string    `json:"error,omitempty"`
+}
+
+// PartialOutputName returns the name of the partial output for an output extension
+func PartialOutputName(ext string) string {
+	return "text.partial" + ext
+}
+
+// ReadIncomplete reads the incomplete marker of a work directory, returning nil
+// when the last extraction was not stopped
+func ReadIncomplete(workDir string) *Incomplete {
+	data, err := os.ReadFile(filepath.Join(workDir, incompleteFileName))
+	if err != nil {
+		return nil
+	}
+	var incomplete Incomplete
+	if json.Unmarshal(data, &incomplete) != nil {
+		return nil
+	}
+	return &incomplete
+}
+
+// WriteIncomplete marks a work directory as holding a stopped extraction
+func WriteIncomplete(workDir string, incomplete *Incomplete) error {
+	data, err := json.MarshalIndent(incomplete, "", "  ")
+	if err != nil {
+		return utils.WrapError(err, utils.ErrorTypeSystem, "failed to encode incomplete marker")
+	}
+	if err := utils.WriteFileAtomic(filepath.Join(workDir, incompleteFileName), append(data, '\n'), constants.DefaultFilePermission); err != nil {
+		return utils.WrapError(err, utils.ErrorTypeIO, "failed to save incomplete marker")
+	}
+	return nil
+}
+
+// ClearIncomplete removes the incomplete marker of a work directory and the partial
+// output it points to, once an extraction finished
+func ClearIncomplete(workDir string) error {
+	incomplete := ReadIncomplete(workDir)
+	if incomplete == nil {
+		return nil
+	}
+	if incomplete.Output != "" {
+		if err := os.Remove(filepath.Join(workDir, filepath.Base(incomplete.Output))); err != nil && !os.IsNotExist(err) {
+			return utils.WrapError(err, utils.ErrorTypeIO, "failed to remove partial output")
+		}
+	}
+	if err := os.Remove(filepath.Join(workDir, incompleteFileName)); err != nil && !os.IsNotExist(err) {
+		return utils.WrapError(err, utils.ErrorTypeIO, "failed to remove incomplete marker")
+	}
+	return nil
+}
@@ -0,0 +1,221 @@
+package cache
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"doc-to-text/pkg/constants"
+	"doc-to-text/pkg/utils"
+)
+
+const (
+	// lockFileName marks a work directory a process is extracting into
+	lockFileName = "lock.json"
+
+	lockTTL       = 2 * time.Minute  // Age after which a lock that is no longer refreshed is stale
+	lockHeartbeat = 30 * time.Second // Interval at which the holder refreshes its lock
+)
+
+// LockHolder identifies the process holding the lock of a work directory
+type LockHolder struct {
+	PID         int       `json:"pid"`
+	Host        string    `json:"host"`
+	Token       string    `json:"token"` // Tells locks of the same process apart
+	AcquiredAt  time.Time `json:"acquired_at"`
+	RefreshedAt time.Time `json:"refreshed_at"` // Last heartbeat, from the lock file time
+}
+
+// String describes the holder, e.g. "pid 1234 on build-01"
+func (h *LockHolder) String() string {
+	return fmt.Sprintf("pid %d on %s", h.PID, h.Host)
+}
+
+// Stale reports whether the holder is gone. Holders on this host are stale once
+// their process no longer exists, however old the lock; holders on other hosts
+// once their lock was not refreshed within the TTL.
+func (h *LockHolder) Stale() bool {
+	if h.Host == hostname() && !constants.IsWindows() {
+		return !processAlive(h.PID)
+	}
+	return time.Since(h.RefreshedAt) > lockTTL
+}
+
+// Current reports whether the lock is held by this process
+func (h *LockHolder) Current() bool {
+	return h.PID == os.Getpid() && h.Host == hostname()
+}
+
+// Lock is a held work directory lock. It is refreshed in the background until
+// released, so other processes can tell a long extraction from a crashed one.
+type Lock struct {
+	path  string
+	token string
+	stop  chan struct{}
+	once  sync.Once
+	done  sync.WaitGroup
+}
+
+// TryLock locks a work directory for this process. When another live process holds
+// the lock, it returns that holder instead; stale locks are taken over.
+func TryLock(workDir string) (*Lock, *LockHolder, error) {
+	path := filepath.Join(workDir, lockFileName)
+	for {
+		lock, err := createLock(path)
+		if err == nil {
+			return lock, nil, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, nil, utils.WrapError(err, utils.ErrorTypeIO, "failed to lock work directory")
+		}
+
+		holder := readHolder(path)
+		if holder == nil {
+			// Being written or removed right now, or left empty by a crashed process
+			if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > lockTTL {
+				os.Remove(path)
+			}
+			time.Sleep(10 * time.Millisecond)
+			continue
+		}
+		if !holder.Stale() {
+			return nil, holder, nil
+		}
+		takeOver(path, holder)
+	}
+}
+
+// ReadLock returns the live holder of the lock of a work directory, or nil when it
+// is not locked or the lock is stale
+func ReadLock(workDir string) *LockHolder {
+	holder := readHolder(filepath.Join(workDir, lockFileName))
+	if holder == nil || holder.Stale() {
+		return nil
+	}
+	return holder
+}
+
+// Release stops refreshing the lock and removes it, unless another process took it
+// over in the meantime
+func (l *Lock) Release() {
+	l.once.Do(func() {
+		close(l.stop)
+		l.done.Wait()
+		if holder := readHolder(l.path); holder != nil && holder.Token == l.token {
+			os.Remove(l.path)
+		}
+	})
+}
+
+// createLock creates the lock file, failing with os.ErrExist when there is one
+func createLock(path string) (*Lock, error) {
+	holder := LockHolder{PID: os.Getpid(), Host: hostname(), Token: newToken(), AcquiredAt: time.Now()}
+	data, err := json.MarshalIndent(holder, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, constants.DefaultFilePermission)
+	if err != nil {
+		return nil, err
+	}
+	_, err = file.Write(append(data, '\n'))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+
+	lock := &Lock{path: path, token: holder.Token, stop: make(chan struct{})}
+	lock.done.Add(1)
+	go lock.refresh()
+	return lock, nil
+}
+
+// refresh touches the lock file every heartbeat until the lock is released or
+// another process took it over. A lock that cannot be read, e.g. while another
+// process moves it aside to check it, is tried again on the next beat.
+func (l *Lock) refresh() {
+	defer l.done.Done()
+	ticker := time.NewTicker(lockHeartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			holder := readHolder(l.path)
+			if holder != nil && holder.Token != l.token {
+				return
+			}
+			if holder != nil {
+				now := time.Now()
+				os.Chtimes(l.path, now, now)
+			}
+		}
+	}
+}
+
+// takeOver removes a stale lock. The lock is moved aside first and put back when it
+// turns out another process replaced the stale lock in the meantime.
+func takeOver(path string, stale *LockHolder) {
+	moved := fmt.Sprintf("%s.%d.stale", path, os.Getpid())
+	if os.Rename(path, moved) != nil {
+		return
+	}
+	if holder := readHolder(moved); holder == nil || holder.Token != stale.Token {
+		os.Link(moved, path)
+	}
+	os.Remove(moved)
+}
+
+// readHolder reads a lock file, returning nil when there is none or it is incomplete
+func readHolder(path string) *LockHolder {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var holder LockHolder
+	if json.Unmarshal(data, &holder) != nil || holder.Token == "" {
+		return nil
+	}
+	if info, err := os.Stat(path); err == nil {
+		holder.RefreshedAt = info.ModTime()
+	}
+	return &holder
+}
+
+// processAlive reports whether a process of this host is running. Windows cannot be
+// probed without extra privileges, so locks there only expire with the TTL.
+func processAlive(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	err = process.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// hostname returns the name of this host, or "unknown"
+func hostname() string {
+	name, err := os.Hostname()
+	if err != nil || name == "" {
+		return "unknown"
+	}
+	return name
+}
+
+// newToken returns a random lock token
+func newToken() string {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
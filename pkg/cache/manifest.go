@@ -0,0 +1,177 @@
+package cache
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"doc-to-text/pkg/constants"
+	"doc-to-text/pkg/utils"
+)
+
+// manifestFileName describes the outputs of a work directory
+const manifestFileName = "manifest.json"
+
+// Manifest records how the outputs of a work directory were produced and their
+// checksums, so cached text can be verified before it is trusted and is not reused
+// once the settings change
+type Manifest struct {
+	Hash        string                `json:"hash"`             // MD5 of the input
+	SHA256      string                `json:"sha256,omitempty"` // SHA-256 of the input
+	Format      string                `json:"format,omitempty"` // Detected format of the input
+	Size        int64                 `json:"size,omitempty"`   // Size of the input in bytes
+	Extractor   string                `json:"extractor"`        // Extractor that produced the text
+	Engine      string                `json:"engine,omitempty"` // OCR engine, for OCR results
+	CreatedAt   time.Time             `json:"created_at"`
+	ProcessTime int64                 `json:"process_time_ms,omitempty"`
+	Settings    map[string]string     `json:"settings,omitempty"` // Extraction settings of the last run
+	Attempts    []Attempt             `json:"attempts,omitempty"` // Extractors tried, in order
+	Pages       []PageStatus          `json:"pages,omitempty"`
+	Tools       map[string]string     `json:"tools,omitempty"`  // External commands run, with their versions
+	Outputs     map[string]OutputFile `json:"outputs"`          // Output files by name
+	Copies      map[string]OutputFile `json:"copies,omitempty"` // Outputs written outside the work directory, by absolute path
+}
+
+// Attempt is an extractor tried on the input
+type Attempt struct {
+	Extractor  string `json:"extractor"`
+	DurationMs int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+}
+
+// Page statuses
+const (
+	PageOK     = "ok"
+	PageEmpty  = "empty"
+	PageFailed = "failed"
+)
+
+// PageStatus is the outcome of a page
+type PageStatus struct {
+	Number     int     `json:"number"`
+	Status     string  `json:"status"`
+	Method     string  `json:"method,omitempty"`
+	Engine     string  `json:"engine,omitempty"`
+	Confidence float64 `json:"confidence,omitempty"`
+	Chars      int     `json:"chars"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// OutputFile is the checksum of an output file and the settings it was built with
+type OutputFile struct {
+	SHA256   string            `json:"sha256"`
+	Size     int64             `json:"size"`
+	Settings map[string]string `json:"settings,omitempty"`
+}
+
+// Problem is a verification failure of an output file
+type Problem struct {
+	File   string `json:"file"`
+	Reason string `json:"reason"`
+}
+
+// ReadManifest reads the manifest of a work directory, returning nil when there is none
+func ReadManifest(workDir string) *Manifest {
+	data, err := os.ReadFile(filepath.Join(workDir, manifestFileName))
+	if err != nil {
+		return nil
+	}
+	var manifest Manifest
+	if json.Unmarshal(data, &manifest) != nil {
+		return nil
+	}
+	return &manifest
+}
+
+// WriteManifest saves the manifest of a work directory
+func WriteManifest(workDir string, manifest *Manifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return utils.WrapError(err, utils.ErrorTypeSystem, "failed to encode manifest")
+	}
+	if err := utils.WriteFileAtomic(filepath.Join(workDir, manifestFileName), append(data, '\n'), constants.DefaultFilePermission); err != nil {
+		return utils.WrapError(err, utils.ErrorTypeIO, "failed to save manifest")
+	}
+	return nil
+}
+
+// RecordOutput stores the checksum of an output file in the manifest, along with
+// the settings of the run that wrote it
+func (m *Manifest) RecordOutput(path string) error {
+	output, err := checksum(path, m.Settings)
+	if err != nil {
+		return err
+	}
+	if m.Outputs == nil {
+		m.Outputs = make(map[string]OutputFile)
+	}
+	m.Outputs[filepath.Base(path)] = output
+	return nil
+}
+
+// RecordCopy stores the checksum of an output written outside the work directory
+// and the settings it was built with, so a later run can tell whether to reuse it
+func (m *Manifest) RecordCopy(path string, settings map[string]string) error {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return utils.WrapError(err, utils.ErrorTypeValidation, "error resolving output path")
+	}
+	output, err := checksum(absPath, settings)
+	if err != nil {
+		return err
+	}
+	if m.Copies == nil {
+		m.Copies = make(map[string]OutputFile)
+	}
+	m.Copies[absPath] = output
+	return nil
+}
+
+// checksum returns the record of an output file built with settings
+func checksum(path string, settings map[string]string) (OutputFile, error) {
+	sum, err := utils.CalculateFileSHA256(path)
+	if err != nil {
+		return OutputFile{}, utils.WrapError(err, utils.ErrorTypeIO, "failed to checksum output")
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return OutputFile{}, utils.WrapError(err, utils.ErrorTypeIO, "failed to checksum output")
+	}
+	return OutputFile{SHA256: sum, Size: info.Size(), Settings: settings}, nil
+}
+
+// Verify compares the outputs of a work directory with the checksums in its manifest.
+// Copies outside the work directory are not checked; they may have been edited.
+func Verify(workDir string, manifest *Manifest) []Problem {
+	var problems []Problem
+	names := make([]string, 0, len(manifest.Outputs))
+	for name := range manifest.Outputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		expected := manifest.Outputs[name]
+		path := filepath.Join(workDir, name)
+		info, err := os.Stat(path)
+		if err != nil {
+			problems = append(problems, Problem{File: name, Reason: "missing"})
+			continue
+		}
+		if info.Size() != expected.Size {
+			problems = append(problems, Problem{File: name, Reason: "size differs from manifest"})
+			continue
+		}
+		sum, err := utils.CalculateFileSHA256(path)
+		if err != nil {
+			problems = append(problems, Problem{File: name, Reason: err.Error()})
+			continue
+		}
+		if sum != expected.SHA256 {
+			problems = append(problems, Problem{File: name, Reason: "checksum differs from manifest"})
+		}
+	}
+	return problems
+}
@@ -0,0 +1,162 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"doc-to-text/pkg/utils"
+)
+
+// PruneOptions select the entries to prune. Age and engine filters must all match;
+// with a size budget the oldest matching entries are pruned until the cache fits.
+type PruneOptions struct {
+	OlderThan time.Duration // Entries not written for longer than this
+	MaxSize   int64         // Size budget of the whole cache in bytes
+	Engine    string        // Extractor or OCR engine, e.g. "ocr", "surya_ocr" or "unknown"
+	Artifacts bool          // Only drop page images and other rebuildable files, keeping the text
+	DryRun    bool          // Report what would be pruned without removing anything
+}
+
+// PruneResult reports what a prune removed
+type PruneResult struct {
+	Entries []*Entry // Entries removed, or stripped of their artifacts
+	Locked  []*Entry // Matching entries left alone because a process is extracting into them
+	Freed   int64    // Bytes freed
+}
+
+// Prune removes cache entries, or their artifacts, as selected by opts
+func Prune(root string, opts PruneOptions) (*PruneResult, error) {
+	if opts.OlderThan <= 0 && opts.MaxSize <= 0 && opts.Engine == "" && !opts.Artifacts {
+		return nil, utils.NewValidationError("nothing to prune: give an age, a size budget, an engine or artifacts", nil)
+	}
+	entries, err := List(root)
+	if err != nil {
+		return nil, err
+	}
+
+	var total int64
+	var candidates []*Entry
+	for _, entry := range entries {
+		total += entry.Size
+		if opts.OlderThan > 0 && time.Since(entry.ModTime) < opts.OlderThan {
+			continue
+		}
+		if opts.Engine != "" && !entry.matchesEngine(opts.Engine) {
+			continue
+		}
+		if opts.Artifacts && entry.Artifacts == 0 {
+			continue
+		}
+		candidates = append(candidates, entry)
+	}
+	// Oldest first, so a size budget keeps recent work
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].ModTime.Before(candidates[j].ModTime) })
+
+	result := &PruneResult{}
+	for _, entry := range candidates {
+		if opts.MaxSize > 0 && total-result.Freed <= opts.MaxSize {
+			break
+		}
+		freed := entry.Size
+		if opts.Artifacts {
+			freed = entry.Artifacts
+		}
+		done, err := whileLocked(entry, opts.DryRun, func() error {
+			if opts.Artifacts {
+				return StripArtifacts(entry.Dir)
+			}
+			return Remove(entry.Dir)
+		})
+		if err != nil {
+			return result, err
+		}
+		if !done {
+			result.Locked = append(result.Locked, entry)
+			continue
+		}
+		result.Entries = append(result.Entries, entry)
+		result.Freed += freed
+	}
+	return result, nil
+}
+
+// Purge removes every entry under a cache root, except those being extracted into
+func Purge(root string, dryRun bool) (*PruneResult, error) {
+	entries, err := List(root)
+	if err != nil {
+		return nil, err
+	}
+	result := &PruneResult{}
+	for _, entry := range entries {
+		done, err := whileLocked(entry, dryRun, func() error { return Remove(entry.Dir) })
+		if err != nil {
+			return result, err
+		}
+		if !done {
+			result.Locked = append(result.Locked, entry)
+			continue
+		}
+		result.Entries = append(result.Entries, entry)
+		result.Freed += entry.Size
+	}
+	return result, nil
+}
+
+// whileLocked runs change on an entry while holding its lock, so no extraction
+// starts in it meanwhile. It reports false, recording the holder on the entry, when
+// a process is extracting into it; dry runs only check the lock read by List.
+func whileLocked(entry *Entry, dryRun bool, change func() error) (bool, error) {
+	if dryRun {
+		return entry.Lock == nil, nil
+	}
+	lock, holder, err := TryLock(entry.Dir)
+	if err != nil {
+		return false, err
+	}
+	if lock == nil {
+		entry.Lock = holder
+		return false, nil
+	}
+	defer lock.Release()
+	return true, change()
+}
+
+// Remove deletes a work directory, and its shard directory once empty
+func Remove(workDir string) error {
+	if err := os.RemoveAll(workDir); err != nil {
+		return utils.WrapError(err, utils.ErrorTypeIO, "failed to remove cache entry")
+	}
+	os.Remove(filepath.Dir(workDir))
+	return nil
+}
+
+// StripArtifacts deletes the page images and other files of a work directory that
+// can be rebuilt from the input, keeping page texts, OCR data and outputs
+func StripArtifacts(workDir string) error {
+	var paths []string
+	filepath.WalkDir(workDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || path == workDir {
+			return nil
+		}
+		if isArtifact(workDir, path) {
+			paths = append(paths, path)
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+		}
+		return nil
+	})
+	for _, path := range paths {
+		if err := os.RemoveAll(path); err != nil {
+			return utils.WrapError(err, utils.ErrorTypeIO, "failed to remove "+path)
+		}
+	}
+	return nil
+}
+
+// matchesEngine reports whether an entry was produced by an extractor or engine
+func (e *Entry) matchesEngine(name string) bool {
+	return name == e.Extractor || name == e.Engine || name == e.Label()
+}
@@ -0,0 +1,508 @@
+// Package chunk splits extracted text into chunks for retrieval (RAG) pipelines.
+// Chunks break at headings first, then paragraphs, then sentences, and are only
+// cut hard when a single sentence is too long. Pages are tracked through the
+// "--- Page N ---" markers (or "<!-- Page N -->" in Markdown) of the text.
+package chunk
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"doc-to-text/pkg/document"
+	"doc-to-text/pkg/types"
+	"doc-to-text/pkg/utils"
+)
+
+var (
+	pageMarker      = regexp.MustCompile(`^(?:--- Page (\d+) ---|<!-- Page (\d+) -->)$`)
+	markdownHeading = regexp.MustCompile(`^(#{1,6})\s+(.+?)(?:\s+#+)?$`)
+	blankLines      = regexp.MustCompile(`\n{3,}`)
+)
+
+// Options configure the chunk size and overlap
+type Options struct {
+	Size    int             // Largest chunk, overlap included
+	Overlap int             // Text of the previous chunk repeated at the start of each chunk
+	Unit    types.ChunkUnit // Unit of Size and Overlap
+}
+
+// Validate checks that the size is positive and larger than the overlap
+func (o Options) Validate() error {
+	if _, ok := types.ParseChunkUnit(string(o.Unit)); !ok {
+		return utils.NewValidationError(fmt.Sprintf("invalid chunk unit '%s' (expected chars or tokens)", o.Unit), nil)
+	}
+	if o.Size < 1 {
+		return utils.NewValidationError("chunk size must be positive", nil)
+	}
+	if o.Overlap < 0 || o.Overlap >= o.Size {
+		return utils.NewValidationError(fmt.Sprintf("chunk overlap must be between 0 and the chunk size (%d)", o.Size-1), nil)
+	}
+	return nil
+}
+
+// Chunk is a span of the extracted text
+type Chunk struct {
+	ID         string   `json:"id"`
+	Index      int      `json:"index"`
+	Source     string   `json:"source,omitempty"`
+	SourceHash string   `json:"source_hash,omitempty"`
+	PageStart  int      `json:"page_start,omitempty"` // First page of the chunk; 0 for text without page markers
+	PageEnd    int      `json:"page_end,omitempty"`
+	Headings   []string `json:"headings,omitempty"` // Heading path at the start of the chunk, outermost first
+	Start      int      `json:"start"`              // Byte offset of the chunk in the text
+	End        int      `json:"end"`                // Byte offset just past the chunk
+	Chars      int      `json:"chars"`
+	Tokens     int      `json:"tokens"` // Approximate token count
+	Text       string   `json:"text"`   // The text between Start and End without page markers
+}
+
+// span is a byte range of the text with the heading path at its start
+type span struct {
+	start, end int
+	headings   []string
+	section    bool // First piece of a section (a heading and its paragraphs)
+	whole      bool // The piece is a complete section
+}
+
+// paragraph is a block of non-blank lines
+type paragraph struct {
+	span
+	heading bool
+}
+
+// pageStart records where a page begins in the text
+type pageStart struct {
+	offset int
+	number int
+}
+
+// splitter holds the text being split and its page boundaries
+type splitter struct {
+	text     string
+	opts     Options
+	budget   int // Size available before the overlap is added
+	pages    []pageStart
+	barriers []int // Offsets the overlap does not reach back across: headings and page markers
+}
+
+// Split splits text into chunks. Headings are recognized from Markdown "#" lines
+// and from the heading blocks of doc, which may be nil.
+func Split(text string, doc *document.Document, opts Options) []Chunk {
+	if opts.Unit == "" {
+		opts.Unit = types.ChunkUnitTokens
+	}
+	if err := opts.Validate(); err != nil || strings.TrimSpace(text) == "" {
+		return nil
+	}
+
+	s := &splitter{text: text, opts: opts, budget: opts.Size - opts.Overlap}
+	pieces := s.pieces(s.paragraphs(headingLevels(doc)))
+	return s.chunks(s.merge(pieces))
+}
+
+// Size measures text in a chunk unit
+func Size(text string, unit types.ChunkUnit) int {
+	var counter sizeCounter
+	for _, r := range text {
+		counter.add(r)
+	}
+	return counter.size(unit)
+}
+
+// sizeCounter counts CJK and other characters incrementally
+type sizeCounter struct {
+	cjk, other int
+}
+
+// add counts a character
+func (c *sizeCounter) add(r rune) {
+	if isCJK(r) {
+		c.cjk++
+	} else {
+		c.other++
+	}
+}
+
+// size returns the count in a unit; tokens approximate one per CJK character and
+// one per four other characters
+func (c *sizeCounter) size(unit types.ChunkUnit) int {
+	if unit == types.ChunkUnitChars {
+		return c.cjk + c.other
+	}
+	return c.cjk + (c.other+3)/4
+}
+
+// headingLevels maps the heading texts of a document to their levels
+func headingLevels(doc *document.Document) map[string]int {
+	levels := make(map[string]int)
+	if doc == nil {
+		return levels
+	}
+	for _, page := range doc.Pages {
+		for _, block := range page.Blocks {
+			if block.Type == document.BlockHeading && !strings.Contains(block.Text, "\n") {
+				levels[strings.TrimSpace(block.Text)] = max(block.Level, 1)
+			}
+		}
+	}
+	return levels
+}
+
+// paragraphs splits the text at blank lines and page markers, tracking the heading
+// path. Fenced code blocks are kept together.
+func (s *splitter) paragraphs(levels map[string]int) []paragraph {
+	type heading struct {
+		level int
+		title string
+	}
+	var paragraphs []paragraph
+	var stack []heading
+	start, end := -1, 0
+	inFence := false
+
+	flush := func() {
+		if start < 0 {
+			return
+		}
+		para := paragraph{span: span{start: start, end: end}}
+		block := strings.TrimSpace(s.text[start:end])
+		if level, title, ok := headingOf(block, levels); ok && !inFence {
+			for len(stack) > 0 && stack[len(stack)-1].level >= level {
+				stack = stack[:len(stack)-1]
+			}
+			stack = append(stack, heading{level: level, title: title})
+			para.heading = true
+			s.barriers = append(s.barriers, start, end)
+		}
+		for _, h := range stack {
+			para.headings = append(para.headings, h.title)
+		}
+		paragraphs = append(paragraphs, para)
+		start = -1
+	}
+
+	for offset := 0; offset < len(s.text); {
+		lineEnd := strings.IndexByte(s.text[offset:], '\n')
+		if lineEnd < 0 {
+			lineEnd = len(s.text)
+		} else {
+			lineEnd += offset
+		}
+		line := strings.TrimSpace(s.text[offset:lineEnd])
+
+		switch {
+		case strings.HasPrefix(line, "```"):
+			inFence = !inFence
+			fallthrough
+		case inFence || line != "" && !pageMarker.MatchString(line):
+			if start < 0 {
+				start = offset
+			}
+			end = lineEnd
+		case line == "":
+			flush()
+		default:
+			flush()
+			match := pageMarker.FindStringSubmatch(line)
+			number, _ := strconv.Atoi(match[1] + match[2])
+			s.pages = append(s.pages, pageStart{offset: offset, number: number})
+			s.barriers = append(s.barriers, min(lineEnd+1, len(s.text)))
+		}
+		offset = lineEnd + 1
+	}
+	flush()
+	return paragraphs
+}
+
+// headingOf returns the level and title of a single-line heading paragraph
+func headingOf(block string, levels map[string]int) (int, string, bool) {
+	if block == "" || strings.Contains(block, "\n") {
+		return 0, "", false
+	}
+	if match := markdownHeading.FindStringSubmatch(block); match != nil {
+		return len(match[1]), match[2], true
+	}
+	if level, ok := levels[block]; ok {
+		return level, block, true
+	}
+	return 0, "", false
+}
+
+// pieces groups paragraphs into sections at headings and splits whatever does not
+// fit the budget: sections into paragraphs, paragraphs into sentences, and
+// sentences by hard cuts
+func (s *splitter) pieces(paragraphs []paragraph) []span {
+	var pieces []span
+	for i := 0; i < len(paragraphs); {
+		j := i + 1
+		for j < len(paragraphs) && !paragraphs[j].heading {
+			j++
+		}
+
+		section := span{start: paragraphs[i].start, end: paragraphs[j-1].end, headings: paragraphs[i].headings, section: true, whole: true}
+		if s.fits(section) {
+			pieces = append(pieces, section)
+		} else {
+			first := len(pieces)
+			for _, para := range paragraphs[i:j] {
+				if s.fits(para.span) {
+					pieces = append(pieces, para.span)
+					continue
+				}
+				for _, sentence := range s.sentences(para.span) {
+					if s.fits(sentence) {
+						pieces = append(pieces, sentence)
+					} else {
+						pieces = append(pieces, s.hardCut(sentence)...)
+					}
+				}
+			}
+			if first < len(pieces) {
+				pieces[first].section = true
+			}
+		}
+		i = j
+	}
+	return pieces
+}
+
+// merge joins adjacent pieces while they fit the budget. A section that had to be
+// split starts a new chunk so its heading path is reported.
+func (s *splitter) merge(pieces []span) []span {
+	var merged []span
+	for _, piece := range pieces {
+		if n := len(merged); n > 0 && (!piece.section || piece.whole) {
+			candidate := merged[n-1]
+			candidate.end = piece.end
+			if s.fits(candidate) {
+				merged[n-1] = candidate
+				continue
+			}
+		}
+		merged = append(merged, piece)
+	}
+	return merged
+}
+
+// chunks adds the overlap to each span and builds the chunks
+func (s *splitter) chunks(spans []span) []Chunk {
+	chunks := make([]Chunk, 0, len(spans))
+	for i, sp := range spans {
+		start := sp.start
+		if i > 0 && s.opts.Overlap > 0 {
+			start = s.overlapStart(spans[i-1], sp.start)
+		}
+		text := s.clean(start, sp.end)
+		if text == "" {
+			continue
+		}
+		chunks = append(chunks, Chunk{
+			Index:     len(chunks),
+			PageStart: s.pageAt(start),
+			PageEnd:   s.pageAt(sp.end - 1),
+			Headings:  sp.headings,
+			Start:     start,
+			End:       sp.end,
+			Chars:     utf8.RuneCountInString(text),
+			Tokens:    Size(text, types.ChunkUnitTokens),
+			Text:      text,
+		})
+	}
+	return chunks
+}
+
+// sentences splits a span after sentence-ending punctuation and at line breaks.
+// CJK punctuation ends a sentence directly; Latin punctuation needs a following space.
+func (s *splitter) sentences(p span) []span {
+	var sentences []span
+	begin := p.start
+	for pos := p.start; pos < p.end; {
+		r, size := utf8.DecodeRuneInString(s.text[pos:])
+		pos += size
+		if !isSentenceEnd(r) {
+			continue
+		}
+		// Closing quotes and brackets belong to the sentence
+		for pos < p.end {
+			next, nextSize := utf8.DecodeRuneInString(s.text[pos:])
+			if !strings.ContainsRune(`"')]”’」』）》`, next) {
+				break
+			}
+			pos += nextSize
+		}
+		if r == '.' || r == '!' || r == '?' || r == ';' {
+			if next, _ := utf8.DecodeRuneInString(s.text[pos:]); pos < p.end && !unicode.IsSpace(next) {
+				continue
+			}
+		}
+		if sentence := s.trim(span{start: begin, end: pos, headings: p.headings}); sentence.start < sentence.end {
+			sentences = append(sentences, sentence)
+		}
+		begin = pos
+	}
+	if last := s.trim(span{start: begin, end: p.end, headings: p.headings}); last.start < last.end {
+		sentences = append(sentences, last)
+	}
+	return sentences
+}
+
+// hardCut splits a span into pieces of at most the budget, preferring to cut at a
+// space in the second half of a piece; CJK text is cut between any characters
+func (s *splitter) hardCut(p span) []span {
+	var pieces []span
+	for start := p.start; start < p.end; {
+		var counter sizeCounter
+		end, lastSpace := start, -1
+		for end < p.end {
+			r, size := utf8.DecodeRuneInString(s.text[end:])
+			counter.add(r)
+			if counter.size(s.opts.Unit) > s.budget && end > start {
+				break
+			}
+			if unicode.IsSpace(r) {
+				lastSpace = end
+			}
+			end += size
+		}
+		if end < p.end && lastSpace > start+(end-start)/2 {
+			end = lastSpace
+		}
+		if piece := s.trim(span{start: start, end: end, headings: p.headings}); piece.start < piece.end {
+			pieces = append(pieces, piece)
+		}
+		start = end
+	}
+	return pieces
+}
+
+// overlapStart returns where a chunk starts once the end of the previous chunk is
+// repeated, moved forward to a sentence or word boundary. The overlap stops at the
+// last heading or page marker so it never repeats a partial marker or another section.
+func (s *splitter) overlapStart(prev span, start int) int {
+	lower := prev.start
+	if i := sort.SearchInts(s.barriers, start+1); i > 0 && s.barriers[i-1] > lower {
+		lower = s.barriers[i-1]
+	}
+
+	var counter sizeCounter
+	pos := start
+	for pos > lower {
+		r, size := utf8.DecodeLastRuneInString(s.text[lower:pos])
+		counter.add(r)
+		if counter.size(s.opts.Unit) > s.opts.Overlap {
+			break
+		}
+		pos -= size
+	}
+	if pos == lower || s.sentenceStartsAt(pos) {
+		return s.skipSpace(pos, start)
+	}
+
+	// Move to the first sentence start in the overlap, else to the next word; the
+	// end of the previous chunk is not a boundary as nothing would be repeated
+	for i := pos; i < start; {
+		r, size := utf8.DecodeRuneInString(s.text[i:])
+		i += size
+		if isSentenceEnd(r) && s.sentenceStartsAt(i) {
+			if next := s.skipSpace(i, start); next < start {
+				return next
+			}
+		}
+	}
+	if r, _ := utf8.DecodeRuneInString(s.text[pos:]); isCJK(r) {
+		return pos
+	}
+	if i := strings.IndexFunc(s.text[pos:start], unicode.IsSpace); i >= 0 {
+		return s.skipSpace(pos+i, start)
+	}
+	return start
+}
+
+// sentenceStartsAt reports whether a sentence ends right before pos
+func (s *splitter) sentenceStartsAt(pos int) bool {
+	before := strings.TrimRightFunc(s.text[:pos], unicode.IsSpace)
+	if before == "" {
+		return true
+	}
+	r, _ := utf8.DecodeLastRuneInString(before)
+	if !isSentenceEnd(r) {
+		return false
+	}
+	if isCJK(r) || r == '\n' || len(before) < pos {
+		return true
+	}
+	// Latin punctuation only ends a sentence before a space
+	next, _ := utf8.DecodeRuneInString(s.text[pos:])
+	return unicode.IsSpace(next)
+}
+
+// skipSpace moves pos past whitespace, stopping at limit
+func (s *splitter) skipSpace(pos, limit int) int {
+	for pos < limit {
+		r, size := utf8.DecodeRuneInString(s.text[pos:])
+		if !unicode.IsSpace(r) {
+			break
+		}
+		pos += size
+	}
+	return pos
+}
+
+// trim removes leading and trailing whitespace from a span
+func (s *splitter) trim(p span) span {
+	p.start = s.skipSpace(p.start, p.end)
+	p.end = p.start + len(strings.TrimRightFunc(s.text[p.start:p.end], unicode.IsSpace))
+	return p
+}
+
+// fits reports whether a span, without page markers, fits the budget
+func (s *splitter) fits(p span) bool {
+	return Size(s.clean(p.start, p.end), s.opts.Unit) <= s.budget
+}
+
+// clean returns the text of a range without page marker lines and extra blank lines
+func (s *splitter) clean(start, end int) string {
+	lines := strings.Split(s.text[start:end], "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		if !pageMarker.MatchString(strings.TrimSpace(line)) {
+			kept = append(kept, line)
+		}
+	}
+	return strings.TrimSpace(blankLines.ReplaceAllString(strings.Join(kept, "\n"), "\n\n"))
+}
+
+// pageAt returns the page an offset belongs to, or 0 for text without page markers.
+// Text before the first marker belongs to the page before it.
+func (s *splitter) pageAt(offset int) int {
+	if len(s.pages) == 0 {
+		return 0
+	}
+	i := sort.Search(len(s.pages), func(i int) bool { return s.pages[i].offset > offset })
+	if i == 0 {
+		return max(s.pages[0].number-1, 1)
+	}
+	return s.pages[i-1].number
+}
+
+// isSentenceEnd reports whether a character can end a sentence
+func isSentenceEnd(r rune) bool {
+	switch r {
+	case '.', '!', '?', ';', '\n', '…', '。', '！', '？', '；', '｡':
+		return true
+	}
+	return false
+}
+
+// isCJK reports whether a rune is a Han, Hiragana, Katakana or Hangul character or
+// CJK punctuation
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r) ||
+		r >= 0x3000 && r <= 0x303F || r >= 0xFF00 && r <= 0xFFEF
+}
@@ -1,39 +1,70 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"strconv"
+	"strings"
 
+	"doc-to-text/pkg/cache"
+	"doc-to-text/pkg/chunk"
 	"doc-to-text/pkg/logger"
+	"doc-to-text/pkg/search"
 	"doc-to-text/pkg/types"
 	"doc-to-text/pkg/utils"
 )
 
 // Config holds application runtime configuration
 type Config struct {
-	OCRStrategy      types.OCRStrategy
-	LLMTemplate      string
-	ContentType      types.ContentType
-	SkipExisting     bool
-	MaxConcurrency   int
-	MinTextThreshold int
-	TimeoutMinutes   int
-	LogLevel         string
-	EnableVerbose    bool
+	OCRStrategy              types.OCRStrategy // "interactive" prompts when Interactive is set, otherwise picks an installed tool
+	LLMTemplate              string
+	OCRLanguages             []string // ISO 639 language hints; empty means auto-detect
+	ContentType              types.ContentType
+	DetectTables             bool                // Reconstruct tables on OCR pages as Markdown/CSV
+	FormulaCommand           string              // Formula-OCR command producing LaTeX (e.g. "texify"); empty disables formulas
+	FormulaTemplate          string              // llm-caller template locating formula regions; empty uses surya_layout
+	CorrectionTemplate       string              // llm-caller template post-correcting OCR page text; empty disables correction
+	CorrectionWithImage      bool                // Also send the page image to the correction template
+	CorrectionSkipConfidence float64             // Skip correction of pages whose OCR confidence reaches this value (0 never skips)
+	RemoveHeadersFooters     bool                // Strip running headers, footers and page numbers repeated across pages
+	Reflow                   bool                // Dehyphenate and merge hard-wrapped lines into paragraphs
+	ExtractorOrder           map[string][]string // Per-format extractor chains overriding the registry, e.g. "pdf" -> calibre, ocr
+	OutputFormat             types.OutputFormat  // Format of the written result (text, markdown, json or jsonl)
+	ChunkSize                int                 // Split the text into chunks of this size for RAG ingestion (0 disables chunking)
+	ChunkOverlap             int                 // Size of the text repeated from the previous chunk
+	ChunkUnit                types.ChunkUnit     // Unit of ChunkSize and ChunkOverlap (chars or tokens)
+	IndexDir                 string              // Search index directory, updated after each extraction once created (empty uses search.DefaultDir)
+	CacheDir                 string              // Root of the per-document work directories (empty uses cache.DefaultRoot, "local" places them next to the inputs)
+	SkipExisting             bool
+	RetryFailedPages         bool           // Recognize PDF pages that failed in an earlier run again instead of skipping them on resume
+	OnLocked                 types.LockMode // What to do when another process is extracting the same document
+	MaxConcurrency           int
+	MinTextThreshold         int
+	TimeoutMinutes           int
+	LogLevel                 string
+	EnableVerbose            bool
+	Interactive              bool // Allow prompting on stdin (CLI only; the library and server never prompt)
 }
 
 // NewConfig creates a new configuration with defaults
 func NewConfig() *Config {
 	return &Config{
-		OCRStrategy:      types.OCRStrategyInteractive,
-		LLMTemplate:      "",
-		ContentType:      types.ContentTypeImage,
-		SkipExisting:     true,
-		MaxConcurrency:   4,
-		MinTextThreshold: 10,
-		TimeoutMinutes:   30,
-		LogLevel:         "info",
-		EnableVerbose:    false,
+		OCRStrategy:              types.OCRStrategyInteractive,
+		LLMTemplate:              "",
+		ContentType:              types.ContentTypeImage,
+		DetectTables:             false,
+		CorrectionSkipConfidence: 0.95,
+		RemoveHeadersFooters:     false,
+		Reflow:                   false,
+		OutputFormat:             types.OutputFormatText,
+		ChunkUnit:                types.ChunkUnitTokens,
+		SkipExisting:             true,
+		OnLocked:                 types.LockModeWait,
+		MaxConcurrency:           4,
+		MinTextThreshold:         10,
+		TimeoutMinutes:           30,
+		LogLevel:                 "info",
+		EnableVerbose:            false,
 	}
 }
 
@@ -48,12 +79,77 @@ func LoadConfigWithEnvOverrides() *Config {
 	if value := os.Getenv("DOC_TEXT_LLM_TEMPLATE"); value != "" {
 		config.LLMTemplate = value
 	}
+	if value := os.Getenv("DOC_TEXT_OCR_LANGS"); value != "" {
+		config.OCRLanguages = utils.NormalizeLanguages([]string{value})
+	}
 	if value := os.Getenv("DOC_TEXT_CONTENT_TYPE"); value != "" {
 		config.ContentType = types.ContentType(value)
 	}
+	if value := os.Getenv("DOC_TEXT_DETECT_TABLES"); value != "" {
+		config.DetectTables = value == "true" || value == "1"
+	}
+	if value := os.Getenv("DOC_TEXT_FORMULA_CMD"); value != "" {
+		config.FormulaCommand = value
+	}
+	if value := os.Getenv("DOC_TEXT_FORMULA_TEMPLATE"); value != "" {
+		config.FormulaTemplate = value
+	}
+	if value := os.Getenv("DOC_TEXT_CORRECTION_TEMPLATE"); value != "" {
+		config.CorrectionTemplate = value
+	}
+	if value := os.Getenv("DOC_TEXT_CORRECTION_WITH_IMAGE"); value != "" {
+		config.CorrectionWithImage = value == "true" || value == "1"
+	}
+	if value := os.Getenv("DOC_TEXT_CORRECTION_SKIP_CONFIDENCE"); value != "" {
+		if floatVal, err := strconv.ParseFloat(value, 64); err == nil {
+			config.CorrectionSkipConfidence = floatVal
+		}
+	}
+	if value := os.Getenv("DOC_TEXT_REMOVE_HEADERS"); value != "" {
+		config.RemoveHeadersFooters = value == "true" || value == "1"
+	}
+	if value := os.Getenv("DOC_TEXT_REFLOW"); value != "" {
+		config.Reflow = value == "true" || value == "1"
+	}
+	if value := os.Getenv("DOC_TEXT_EXTRACTORS"); value != "" {
+		if order, err := ParseExtractorOrder(strings.Split(value, ";")); err == nil {
+			config.ExtractorOrder = order
+		}
+	}
+	if value := os.Getenv("DOC_TEXT_FORMAT"); value != "" {
+		if format, ok := types.ParseOutputFormat(value); ok {
+			config.OutputFormat = format
+		}
+	}
+	if value := os.Getenv("DOC_TEXT_CHUNK_SIZE"); value != "" {
+		if intVal, err := strconv.Atoi(value); err == nil && intVal >= 0 {
+			config.ChunkSize = intVal
+		}
+	}
+	if value := os.Getenv("DOC_TEXT_CHUNK_OVERLAP"); value != "" {
+		if intVal, err := strconv.Atoi(value); err == nil && intVal >= 0 {
+			config.ChunkOverlap = intVal
+		}
+	}
+	if value := os.Getenv("DOC_TEXT_CHUNK_UNIT"); value != "" {
+		if unit, ok := types.ParseChunkUnit(value); ok {
+			config.ChunkUnit = unit
+		}
+	}
+	if value := os.Getenv("DOC_TEXT_CACHE_DIR"); value != "" {
+		config.CacheDir = value
+	}
+	if value := os.Getenv("DOC_TEXT_INDEX_DIR"); value != "" {
+		config.IndexDir = value
+	}
 	if value := os.Getenv("DOC_TEXT_SKIP_EXISTING"); value != "" {
 		config.SkipExisting = value == "true" || value == "1"
 	}
+	if value := os.Getenv("DOC_TEXT_ON_LOCKED"); value != "" {
+		if mode, ok := types.ParseLockMode(value); ok {
+			config.OnLocked = mode
+		}
+	}
 	if value := os.Getenv("DOC_TEXT_MAX_CONCURRENCY"); value != "" {
 		if intVal, err := strconv.Atoi(value); err == nil && intVal > 0 {
 			config.MaxConcurrency = intVal
@@ -90,10 +186,110 @@ func (c *Config) Validate() error {
 	if c.TimeoutMinutes < 1 {
 		return utils.NewValidationError("timeout must be at least 1 minute", nil)
 	}
+	if c.FormulaCommand != "" && len(c.FormulaArgs()) == 0 {
+		return utils.NewValidationError("formula command is blank", nil)
+	}
+	if c.FormulaTemplate != "" && c.FormulaCommand == "" {
+		return utils.NewValidationError("formula template requires a formula command", nil)
+	}
+	if c.CorrectionSkipConfidence < 0 || c.CorrectionSkipConfidence > 1 {
+		return utils.NewValidationError("correction skip confidence must be between 0 and 1", nil)
+	}
+	if _, ok := types.ParseOutputFormat(string(c.OutputFormat)); !ok {
+		return utils.NewValidationError(fmt.Sprintf("invalid output format '%s' (expected text, markdown, json or jsonl)", c.OutputFormat), nil)
+	}
+	if _, ok := types.ParseLockMode(string(c.OnLocked)); !ok {
+		return utils.NewValidationError(fmt.Sprintf("invalid lock mode '%s' (expected wait, skip or piggyback)", c.OnLocked), nil)
+	}
+	if c.ChunkSize < 0 {
+		return utils.NewValidationError("chunk size must be non-negative", nil)
+	}
+	if c.ChunkSize > 0 {
+		if err := c.ChunkOptions().Validate(); err != nil {
+			return err
+		}
+	}
+	for format, names := range c.ExtractorOrder {
+		if format == "" || len(names) == 0 {
+			return utils.NewValidationError(fmt.Sprintf("invalid extractor order for '%s' (expected format=name,name)", format), nil)
+		}
+	}
+	for _, lang := range c.OCRLanguages {
+		if !utils.IsValidLanguageCode(lang) {
+			return utils.NewValidationError(fmt.Sprintf("invalid OCR language code '%s' (expected ISO 639 code such as 'en' or 'zh')", lang), nil)
+		}
+	}
 	return nil
 }
 
-// CreateFileManager creates a unified file manager
+// ParseExtractorOrder parses per-format extractor chains such as "pdf=calibre,ocr",
+// one entry per format
+func ParseExtractorOrder(specs []string) (map[string][]string, error) {
+	order := make(map[string][]string)
+	for _, spec := range specs {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+
+		format, list, found := strings.Cut(spec, "=")
+		format = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(format), "."))
+		if !found || format == "" || strings.Contains(format, ",") {
+			return nil, utils.NewValidationError(fmt.Sprintf("invalid extractor order '%s' (expected format=name,name, e.g. pdf=calibre,ocr)", spec), nil)
+		}
+
+		var names []string
+		for _, name := range strings.Split(list, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+		if len(names) == 0 {
+			return nil, utils.NewValidationError(fmt.Sprintf("extractor order for '%s' names no extractors", format), nil)
+		}
+		order[format] = names
+	}
+	return order, nil
+}
+
+// FormulaArgs splits the formula command into the program and its arguments, nil
+// when formulas are disabled
+func (c *Config) FormulaArgs() []string {
+	return strings.Fields(c.FormulaCommand)
+}
+
+// ChunkOptions returns the chunking options; chunking is enabled when ChunkSize is positive
+func (c *Config) ChunkOptions() chunk.Options {
+	return chunk.Options{Size: c.ChunkSize, Overlap: c.ChunkOverlap, Unit: c.ChunkUnit}
+}
+
+// SearchIndexDir returns the search index directory, falling back to the default location
+func (c *Config) SearchIndexDir() string {
+	if c.IndexDir != "" {
+		return c.IndexDir
+	}
+	return search.DefaultDir()
+}
+
+// CacheRoot returns the root of the work directories, or "" when they are placed
+// next to the inputs
+func (c *Config) CacheRoot() string {
+	switch c.CacheDir {
+	case "":
+		return cache.DefaultRoot()
+	case cache.Local:
+		return ""
+	default:
+		return c.CacheDir
+	}
+}
+
+// WorkDir returns the work directory holding the intermediate files of an input
+func (c *Config) WorkDir(inputFile, md5Hash string) string {
+	return cache.WorkDir(c.CacheRoot(), inputFile, md5Hash)
+}
+
+// CreateFileManager creates a unified file manager for the work directory of an input
 func (c *Config) CreateFileManager(inputFile, md5Hash string, log *logger.Logger) *utils.FileManager {
-	return utils.NewFileManager(inputFile, md5Hash, log)
+	return utils.NewFileManager(inputFile, md5Hash, c.WorkDir(inputFile, md5Hash), log)
 }
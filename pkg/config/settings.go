@@ -0,0 +1,98 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"doc-to-text/pkg/constants"
+	"doc-to-text/pkg/types"
+)
+
+// OCRPageSettings are the settings that shape the raw OCR text of a page; cached
+// page texts are only reused while they are unchanged
+var OCRPageSettings = []string{"ocr_strategy", "llm_template", "ocr_langs", "dpi"}
+
+// SettingChange is an extraction setting whose current value differs from the one
+// a cached result was built with
+type SettingChange struct {
+	Key      string
+	Recorded string
+	Current  string
+}
+
+// String formats a change as "key: old → new"
+func (c SettingChange) String() string {
+	return fmt.Sprintf("%s: %s → %s", c.Key, settingValue(c.Recorded), settingValue(c.Current))
+}
+
+// ExtractionSettings returns the settings that affect the text extracted from a
+// document of the given format, by name. OCR settings are only included when the
+// text comes from OCR.
+func (c *Config) ExtractionSettings(format string, ocr bool) map[string]string {
+	settings := map[string]string{
+		"reflow": strconv.FormatBool(c.Reflow),
+	}
+	if names, ok := c.ExtractorOrder[format]; ok && len(names) > 0 {
+		settings["extractors"] = strings.Join(names, ",")
+	} else if format == "pdf" {
+		settings["content_type"] = string(c.ContentType)
+	}
+	if !ocr {
+		return settings
+	}
+
+	settings["ocr_strategy"] = string(c.OCRStrategy)
+	settings["llm_template"] = c.LLMTemplate
+	settings["ocr_langs"] = strings.Join(c.OCRLanguages, ",")
+	settings["dpi"] = strconv.Itoa(constants.PDFRenderDPI)
+	settings["remove_headers"] = strconv.FormatBool(c.RemoveHeadersFooters)
+	settings["tables"] = strconv.FormatBool(c.DetectTables || c.OutputFormat == types.OutputFormatMarkdown)
+	settings["formula_cmd"] = strings.Join(c.FormulaArgs(), " ")
+	if c.FormulaCommand != "" {
+		settings["formula_template"] = c.FormulaTemplate
+	}
+	settings["correction_template"] = c.CorrectionTemplate
+	if c.CorrectionTemplate != "" {
+		settings["correction_with_image"] = strconv.FormatBool(c.CorrectionWithImage)
+		settings["correction_skip_confidence"] = strconv.FormatFloat(c.CorrectionSkipConfidence, 'g', -1, 64)
+	}
+	return settings
+}
+
+// SettingsChanges compares the settings a cached result was built with against the
+// current ones, sorted by name. OCR settings only count when the cached result came
+// from OCR, and an interactive OCR strategy accepts whichever engine was chosen.
+func (c *Config) SettingsChanges(recorded map[string]string, format string) []SettingChange {
+	_, ocr := recorded["ocr_strategy"]
+	current := c.ExtractionSettings(format, ocr)
+
+	keys := make(map[string]bool)
+	for key := range recorded {
+		keys[key] = true
+	}
+	for key := range current {
+		keys[key] = true
+	}
+
+	var changes []SettingChange
+	for key := range keys {
+		if key == "ocr_strategy" && c.OCRStrategy == types.OCRStrategyInteractive {
+			continue
+		}
+		if recorded[key] != current[key] {
+			changes = append(changes, SettingChange{Key: key, Recorded: recorded[key], Current: current[key]})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	return changes
+}
+
+// settingValue formats a setting value for display
+func settingValue(value string) string {
+	if value == "" {
+		return "(none)"
+	}
+	return value
+}
@@ -13,6 +13,17 @@ const (
 	DefaultDirPermission  = 0755
 	DefaultMaxRetries     = 3
 	DefaultTimeout        = 30 * time.Minute
+
+	// MaxInMemoryInput is the largest stream kept in memory; bigger streams are
+	// spooled to a temporary file
+	MaxInMemoryInput = 32 * 1024 * 1024
+
+	// SubprocessGracePeriod is how long an external tool may take to exit after
+	// being asked to stop before it is killed
+	SubprocessGracePeriod = 5 * time.Second
+
+	// ExitCodeInterrupted is the exit status of a run stopped by SIGINT or SIGTERM
+	ExitCodeInterrupted = 130
 )
 
 // OCR processing constants
@@ -20,6 +31,11 @@ const (
 	PDFPageFilePattern  = "page_%d.pdf"
 	PDFPageTextPattern  = "page_%d.txt"
 	PDFPageImagePattern = "page_%d.png"
+	PageStateFileName   = "state.json" // Page count and per-page status, in the pages directory
+	PageTableCSVPattern = "page_%d_table_%d.csv"
+
+	// PDFRenderDPI is the resolution PDF pages are rendered at for OCR
+	PDFRenderDPI = 300
 )
 
 // File type groups
@@ -0,0 +1,364 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"doc-to-text/pkg/cache"
+	"doc-to-text/pkg/config"
+	"doc-to-text/pkg/document"
+	"doc-to-text/pkg/interfaces"
+	"doc-to-text/pkg/logger"
+	"doc-to-text/pkg/metadata"
+	"doc-to-text/pkg/ocr"
+	"doc-to-text/pkg/types"
+	"doc-to-text/pkg/utils"
+)
+
+// cachedOutputPath returns the copy of the output kept in the work directory of an
+// input, or "" when work directories are next to the inputs, where the default
+// output already lives. Streams have no input path (inputFile is empty).
+func (p *DefaultFileProcessor) cachedOutputPath(inputFile string, fileInfo *types.FileInfo) string {
+	if p.config.CacheRoot() == "" {
+		return ""
+	}
+	return filepath.Join(p.workDir(inputFile, fileInfo), "text"+p.config.OutputFormat.Extension())
+}
+
+// workDir returns the work directory of an input, or "" for streams when work
+// directories are next to the inputs
+func (p *DefaultFileProcessor) workDir(inputFile string, fileInfo *types.FileInfo) string {
+	if inputFile != "" {
+		return p.config.WorkDir(inputFile, fileInfo.MD5Hash)
+	}
+	if root := p.config.CacheRoot(); root != "" {
+		return cache.EntryDir(root, fileInfo.MD5Hash)
+	}
+	return ""
+}
+
+// loadCachedResult reuses the output cached in the work directory, so the same
+// content is not extracted again for another output path, and copies it to outputFile
+func (p *DefaultFileProcessor) loadCachedResult(outputFile, inputFile, source string, fileInfo *types.FileInfo) (*interfaces.ExtractionResult, error) {
+	cached := p.cachedOutputPath(inputFile, fileInfo)
+	if cached == "" || samePath(cached, outputFile) {
+		return nil, utils.NewNotFoundError("cached result not found", nil)
+	}
+	result, err := p.readOutput(cached, source, fileInfo)
+	if err != nil {
+		return nil, err
+	}
+	p.logger.ProgressAlways("♻️", "Reusing cached text from: %s", cached)
+
+	if outputFile != "" {
+		if err := p.saveOutput(result, outputFile); err != nil {
+			return nil, err
+		}
+		p.recordCopy(inputFile, fileInfo, outputFile)
+	}
+	p.recordSource(inputFile, fileInfo)
+	return result, nil
+}
+
+// recordCopy notes an output copied from the cached one in the manifest, with the
+// settings the cached output was built with
+func (p *DefaultFileProcessor) recordCopy(inputFile string, fileInfo *types.FileInfo, outputFile string) {
+	workDir := p.workDir(inputFile, fileInfo)
+	manifest := cache.ReadManifest(workDir)
+	if manifest == nil {
+		return
+	}
+	cached := manifest.Outputs["text"+p.config.OutputFormat.Extension()]
+	if err := manifest.RecordCopy(outputFile, cached.Settings); err != nil {
+		p.logger.Warn("Could not update manifest: %v", err)
+		return
+	}
+	if err := cache.WriteManifest(workDir, manifest); err != nil {
+		p.logger.Warn("Could not update manifest: %v", err)
+	}
+}
+
+// saveCachedOutput keeps a copy of the output and its metadata in the work
+// directory unless the output was written there
+func (p *DefaultFileProcessor) saveCachedOutput(result *interfaces.ExtractionResult, inputFile string, fileInfo *types.FileInfo, outputFile string) error {
+	cached := p.cachedOutputPath(inputFile, fileInfo)
+	if cached == "" || samePath(cached, outputFile) {
+		return nil
+	}
+	if err := utils.EnsureDir(filepath.Dir(cached)); err != nil {
+		return utils.WrapError(err, utils.ErrorTypeIO, "failed to create cache directory")
+	}
+	content, err := RenderOutput(result, p.config.OutputFormat)
+	if err != nil {
+		return err
+	}
+	if err := p.saveToFileWithRetry(string(content), cached); err != nil {
+		return utils.WrapError(err, utils.ErrorTypeIO, "failed to save cached output")
+	}
+	p.logger.Progress("🗄️", "Text cached in: %s", cached)
+	return p.saveMetadata(result, cached)
+}
+
+// saveManifest records how the output written to the work directory was produced:
+// the input, settings, extractor attempts, page outcomes, tool versions and the
+// output checksum, so the cache can tell which engine produced it, verify it later
+// and extract again once the settings change
+func (p *DefaultFileProcessor) saveManifest(result *interfaces.ExtractionResult, inputFile string, fileInfo *types.FileInfo, outputFile string) {
+	workDir := p.workDir(inputFile, fileInfo)
+	if workDir == "" {
+		return
+	}
+	output := filepath.Join(workDir, "text"+p.config.OutputFormat.Extension())
+	if p.config.CacheRoot() == "" && !samePath(output, outputFile) {
+		// Local work directory and the output went elsewhere
+		return
+	}
+	if _, err := os.Stat(output); err != nil {
+		return
+	}
+
+	manifest := cache.ReadManifest(workDir)
+	if manifest == nil {
+		manifest = &cache.Manifest{}
+	}
+	manifest.Hash = fileInfo.MD5Hash
+	manifest.SHA256 = fileInfo.SHA256Hash
+	manifest.Format = fileInfo.Format
+	manifest.Size = fileInfo.Size
+	manifest.Extractor = result.ExtractorUsed
+	manifest.Engine = resultEngine(result)
+	manifest.CreatedAt = time.Now()
+	manifest.ProcessTime = result.ProcessTime
+	manifest.Settings = p.config.ExtractionSettings(fileInfo.Format, result.ExtractorUsed == "ocr" || manifest.Engine != "")
+	manifest.Attempts = nil
+	for _, attempt := range result.Attempts {
+		manifest.Attempts = append(manifest.Attempts, cache.Attempt(attempt))
+	}
+	manifest.Pages = pageStatuses(result)
+	manifest.Tools = nil
+	for _, tool := range result.Tools {
+		if manifest.Tools == nil {
+			manifest.Tools = make(map[string]string)
+		}
+		manifest.Tools[tool] = utils.ToolVersion(tool)
+	}
+	if err := manifest.RecordOutput(output); err != nil {
+		p.logger.Warn("Could not update manifest: %v", err)
+		return
+	}
+	if outputFile != "" && !samePath(filepath.Dir(outputFile), workDir) {
+		if err := manifest.RecordCopy(outputFile, manifest.Settings); err != nil {
+			p.logger.Warn("Could not update manifest: %v", err)
+			return
+		}
+	}
+	if err := cache.WriteManifest(workDir, manifest); err != nil {
+		p.logger.Warn("Could not update manifest: %v", err)
+	}
+}
+
+// changedSettings returns the settings that differ from those the result about to
+// be reused was built with: outputFile when it exists, otherwise the copy cached in
+// the work directory. Outputs the manifest knows nothing about, or that changed
+// since they were written, are trusted.
+func (p *DefaultFileProcessor) changedSettings(outputFile, inputFile string, fileInfo *types.FileInfo) []config.SettingChange {
+	workDir := p.workDir(inputFile, fileInfo)
+	if workDir == "" {
+		return nil
+	}
+	manifest := cache.ReadManifest(workDir)
+	if manifest == nil {
+		return nil
+	}
+
+	record, ok := manifest.Outputs["text"+p.config.OutputFormat.Extension()]
+	if _, err := os.Stat(outputFile); outputFile != "" && err == nil {
+		if samePath(filepath.Dir(outputFile), workDir) {
+			record, ok = manifest.Outputs[filepath.Base(outputFile)]
+		} else {
+			// Outputs elsewhere are known when this work directory wrote them and they are unchanged
+			absPath, _ := filepath.Abs(outputFile)
+			record, ok = manifest.Copies[absPath]
+			if ok {
+				sum, err := utils.CalculateFileSHA256(outputFile)
+				ok = err == nil && sum == record.SHA256
+			}
+		}
+	}
+	if !ok || record.Settings == nil {
+		return nil
+	}
+	return p.config.SettingsChanges(record.Settings, fileInfo.Format)
+}
+
+// formatChanges lists setting changes for a log message
+func formatChanges(changes []config.SettingChange) string {
+	parts := make([]string, len(changes))
+	for i, change := range changes {
+		parts[i] = change.String()
+	}
+	return strings.Join(parts, ", ")
+}
+
+// pageStatuses returns the outcome of every page of a result: pages with text,
+// pages that came out empty and pages the OCR extractor failed on
+func pageStatuses(result *interfaces.ExtractionResult) []cache.PageStatus {
+	if result.Document == nil {
+		return nil
+	}
+	byNumber := make(map[int]cache.PageStatus)
+	for _, page := range result.Document.PageTexts() {
+		status := cache.PageStatus{
+			Number:     page.Number,
+			Status:     cache.PageOK,
+			Method:     page.Method,
+			Engine:     page.Engine,
+			Confidence: page.Confidence,
+			Chars:      utf8.RuneCountInString(strings.TrimSpace(page.Text)),
+		}
+		if status.Chars == 0 {
+			status.Status = cache.PageEmpty
+		}
+		byNumber[page.Number] = status
+	}
+	if failures, ok := result.Metadata["failed_pages"].([]ocr.PageFailure); ok {
+		for _, failure := range failures {
+			byNumber[failure.Page] = cache.PageStatus{Number: failure.Page, Status: cache.PageFailed, Error: failure.Error}
+		}
+	}
+
+	// Paged sources drop pages without text; list them as empty
+	if result.Document.Paged {
+		last, _ := result.Metadata[metadata.KeyPageCount].(int)
+		for number := range byNumber {
+			last = max(last, number)
+		}
+		for number := 1; number <= last; number++ {
+			if _, ok := byNumber[number]; !ok {
+				byNumber[number] = cache.PageStatus{Number: number, Status: cache.PageEmpty}
+			}
+		}
+	}
+
+	pages := make([]cache.PageStatus, 0, len(byNumber))
+	for _, status := range byNumber {
+		pages = append(pages, status)
+	}
+	sort.Slice(pages, func(i, j int) bool { return pages[i].Number < pages[j].Number })
+	return pages
+}
+
+// resultEngine returns the OCR engine that recognized the pages of a result, if any
+func resultEngine(result *interfaces.ExtractionResult) string {
+	if result.Document == nil {
+		return ""
+	}
+	for _, page := range result.Document.Pages {
+		if page.Method == document.MethodOCR && page.Engine != "" {
+			return page.Engine
+		}
+	}
+	return ""
+}
+
+// recordSource remembers the input path in its work directory so cached entries
+// can be traced back to their files
+func (p *DefaultFileProcessor) recordSource(inputFile string, fileInfo *types.FileInfo) {
+	if inputFile == "" || fileInfo == nil {
+		return
+	}
+	absPath, err := filepath.Abs(inputFile)
+	if err != nil {
+		return
+	}
+	// Streams are named after their hint, which is not a file
+	if info, err := os.Stat(absPath); err != nil || !info.Mode().IsRegular() {
+		return
+	}
+	if err := cache.RecordSource(p.config.WorkDir(absPath, fileInfo.MD5Hash), absPath); err != nil {
+		p.logger.Debug("Could not record source: %v", err)
+	}
+}
+
+// MigrateWorkDirs moves the {md5} work directories found under dirs into the cache
+// root, recording the files beside each one as its sources. It returns the number
+// of work directories moved.
+func MigrateWorkDirs(root string, dirs []string, log *logger.Logger) (int, error) {
+	if root == "" {
+		return 0, utils.NewValidationError("work directories are kept next to the inputs (--cache-dir local), there is no cache to migrate to", nil)
+	}
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return 0, utils.WrapError(err, utils.ErrorTypeValidation, "error resolving cache directory path")
+	}
+
+	migrated := 0
+	sources := newSourceFinder()
+	for _, dir := range dirs {
+		start, err := filepath.Abs(dir)
+		if err != nil {
+			return migrated, utils.WrapError(err, utils.ErrorTypeValidation, "error resolving directory path")
+		}
+		if info, err := os.Stat(start); err != nil || !info.IsDir() {
+			return migrated, utils.NewNotFoundError("directory not found: "+dir, err)
+		}
+
+		// Collect first so moved directories do not disturb the walk
+		var workDirs []string
+		walkErr := filepath.WalkDir(start, func(path string, entry os.DirEntry, err error) error {
+			if err != nil {
+				log.Warn("Skipping %s: %v", path, err)
+				return nil
+			}
+			if !entry.IsDir() {
+				return nil
+			}
+			if path == absRoot {
+				return filepath.SkipDir
+			}
+			if workDirPattern.MatchString(entry.Name()) {
+				workDirs = append(workDirs, path)
+				return filepath.SkipDir
+			}
+			return nil
+		})
+		if walkErr != nil {
+			return migrated, utils.WrapError(walkErr, utils.ErrorTypeIO, "failed to scan "+start)
+		}
+
+		for _, workDir := range workDirs {
+			if holder := cache.ReadLock(workDir); holder != nil {
+				log.Warn("Not migrating %s, being processed by %s", workDir, holder)
+				continue
+			}
+			hash := filepath.Base(workDir)
+			for _, source := range sources.siblings(filepath.Dir(workDir), hash) {
+				if err := cache.RecordSource(workDir, source); err != nil {
+					log.Warn("Could not record source of %s: %v", workDir, err)
+				}
+			}
+			target := cache.EntryDir(absRoot, hash)
+			if err := cache.Move(workDir, target); err != nil {
+				log.Warn("Could not migrate %s: %v", workDir, err)
+				continue
+			}
+			log.Progress("🚚", "Migrated %s -> %s", workDir, target)
+			migrated++
+		}
+	}
+	return migrated, nil
+}
+
+// samePath reports whether two paths name the same file
+func samePath(a, b string) bool {
+	if a == "" || b == "" {
+		return false
+	}
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
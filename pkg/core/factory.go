@@ -2,29 +2,42 @@ package core
 
 import (
 	"fmt"
+	"mime"
+	"sort"
 	"strings"
 
 	"doc-to-text/pkg/config"
+	"doc-to-text/pkg/constants"
 	"doc-to-text/pkg/interfaces"
 	"doc-to-text/pkg/logger"
 	"doc-to-text/pkg/ocr"
 	"doc-to-text/pkg/providers"
 	"doc-to-text/pkg/types"
+	"doc-to-text/pkg/utils"
 )
 
-// DefaultExtractorFactory implements ExtractorFactory
+// Extractor priorities of the default registrations
+const (
+	PriorityPrimary  = 100 // Dedicated extractor for the format
+	PriorityBuiltin  = 50  // Built-in parser used when the dedicated tool is missing
+	PriorityFallback = 10  // Generic converter tried last
+)
+
+// DefaultExtractorFactory implements ExtractorFactory with a registry of extractors
+// that declare the formats they handle, a priority and whether they may act as a
+// fallback. Chains are computed from these declarations and the per-format order
+// configured in Config.ExtractorOrder.
 type DefaultExtractorFactory struct {
-	extractors map[string]interfaces.Extractor
-	config     *config.Config
-	logger     *logger.Logger
+	registrations []interfaces.ExtractorRegistration
+	config        *config.Config
+	logger        *logger.Logger
 }
 
 // NewExtractorFactory creates a new extractor factory
 func NewExtractorFactory(cfg *config.Config, log *logger.Logger) interfaces.ExtractorFactory {
 	factory := &DefaultExtractorFactory{
-		extractors: make(map[string]interfaces.Extractor),
-		config:     cfg,
-		logger:     log,
+		config: cfg,
+		logger: log,
 	}
 
 	// Register default extractors
@@ -33,124 +46,145 @@ func NewExtractorFactory(cfg *config.Config, log *logger.Logger) interfaces.Extr
 	return factory
 }
 
-// CreateExtractor creates an appropriate extractor for the file
-func (f *DefaultExtractorFactory) CreateExtractor(fileInfo *types.FileInfo) (interfaces.Extractor, error) {
-	f.logger.Debug("Looking for extractor for file type: %s (MIME: %s)", fileInfo.Extension, fileInfo.MimeType)
-
-	// Try to find a specific extractor that supports this file
-	for name, extractor := range f.extractors {
-		if extractor.SupportsFile(fileInfo) {
-			f.logger.Debug("Selected extractor '%s' for file type %s", extractor.Name(), fileInfo.Extension)
-			return extractor, nil
-		} else {
-			f.logger.Debug("Extractor '%s' does not support file type %s", name, fileInfo.Extension)
-		}
-	}
-
-	return nil, fmt.Errorf("no suitable extractor found for file type '%s' (MIME: %s)", fileInfo.Extension, fileInfo.MimeType)
-}
-
-// CreateExtractorWithFallbacks creates extractors with fallback options
+// CreateExtractorWithFallbacks creates the extraction chain for a file: the configured
+// order for its format if there is one, otherwise the extractors declaring the format
+// for the configured content type by priority, followed by fallback extractors
 func (f *DefaultExtractorFactory) CreateExtractorWithFallbacks(fileInfo *types.FileInfo) ([]interfaces.Extractor, error) {
-	var extractors []interfaces.Extractor
-	ext := strings.ToLower(fileInfo.Extension)
-
-	f.logger.Debug("Creating extractor chain with fallbacks for file type: %s", ext)
-
-	// Check if user explicitly chose a specific OCR strategy
-	userChosenOCR := f.config.OCRStrategy != types.OCRStrategyInteractive
+	format := strings.ToLower(fileInfo.Format)
+	f.logger.Debug("Creating extractor chain with fallbacks for file type: %s (extension: %s)", format, fileInfo.Extension)
 
-	// Define extraction strategy based on file type
-	switch {
-	case ext == "txt" || ext == "md" || ext == "json" || ext == "xml":
-		// Text files - only text extractor
-		if textExtractor, exists := f.extractors["text"]; exists {
-			extractors = append(extractors, textExtractor)
-			f.logger.Debug("Added text extractor for file type: %s", ext)
+	if names, ok := f.chainOverride(format); ok {
+		extractors := make([]interfaces.Extractor, 0, len(names))
+		for _, name := range names {
+			registration, found := f.lookup(name)
+			if !found {
+				return nil, utils.NewValidationError(fmt.Sprintf("unknown extractor '%s' configured for '%s' (available: %s)", name, format, strings.Join(f.ListExtractors(), ", ")), nil)
+			}
+			extractors = append(extractors, registration.Extractor)
 		}
+		f.logger.Info("Created configured extraction chain for filetype '%s': %v", format, names)
+		return extractors, nil
+	}
 
-	case ext == "html" || ext == "htm" || ext == "mhtml" || ext == "mht":
-		// HTML files - HTML extractor, then calibre fallback
-		if htmlExtractor, exists := f.extractors["html"]; exists {
-			extractors = append(extractors, htmlExtractor)
-			f.logger.Debug("Added HTML extractor for file type: %s", ext)
-		}
-		if calibreExtractor, exists := f.extractors["calibre"]; exists {
-			extractors = append(extractors, calibreExtractor)
-			f.logger.Debug("Added Calibre fallback extractor for file type: %s", ext)
+	var chain []interfaces.ExtractorRegistration
+	for _, registration := range f.byPriority() {
+		if !handles(registration, format, fileInfo.MimeType, f.config.ContentType) {
+			continue
 		}
-
-	case ext == "epub" || ext == "mobi":
-		// E-books - ebook extractor first
-		if ebookExtractor, exists := f.extractors["ebook"]; exists {
-			extractors = append(extractors, ebookExtractor)
-			f.logger.Debug("Added ebook extractor for file type: %s", ext)
+		// Only the best match and extractors marked as fallback form the chain
+		if len(chain) > 0 && !registration.Fallback {
+			continue
 		}
+		chain = append(chain, registration)
+	}
 
-	case ext == "pdf":
-		// PDF files - strategy depends on content type
-		if f.config.ContentType == types.ContentTypeText {
-			// Text content type: only use Calibre, no OCR fallback
-			f.logger.Debug("PDF with text content type: using Calibre only, no OCR fallback")
-			if calibreExtractor, exists := f.extractors["calibre"]; exists {
-				extractors = append(extractors, calibreExtractor)
-				f.logger.Debug("Added Calibre extractor for text-based PDF: %s", ext)
-			}
-		} else {
-			// Image content type (default): use OCR directly, no fallback
-			f.logger.Debug("PDF with image content type: using OCR directly")
-			if ocrExtractor, exists := f.extractors["ocr"]; exists {
-				extractors = append(extractors, ocrExtractor)
-				f.logger.Debug("Added OCR extractor for image-based PDF: %s", ext)
+	if len(chain) == 0 {
+		// Unknown types - try fallback extractors that accept the file
+		f.logger.Debug("Unknown file type %s, trying fallback extractors", format)
+		for _, registration := range f.byPriority() {
+			if registration.Fallback && registration.Extractor.SupportsFile(fileInfo) {
+				chain = append(chain, registration)
 			}
 		}
+	}
 
-	case ext == "jpg" || ext == "jpeg" || ext == "png" || ext == "gif" || ext == "bmp" || ext == "webp" || ext == "tiff" || ext == "tif":
-		// Images - only OCR, no fallback if user explicitly chose OCR strategy
-		if ocrExtractor, exists := f.extractors["ocr"]; exists {
-			extractors = append(extractors, ocrExtractor)
-			f.logger.Debug("Added OCR extractor for file type: %s", ext)
-		}
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("no suitable extractors found for file type '%s'", format)
+	}
 
-	default:
-		// Unknown types - try OCR first, then calibre fallback (only if not explicitly chosen OCR)
-		f.logger.Debug("Unknown file type %s, trying fallback extractors", ext)
-		if ocrExtractor, exists := f.extractors["ocr"]; exists {
-			extractors = append(extractors, ocrExtractor)
-			f.logger.Debug("Added OCR extractor as fallback for unknown type: %s", ext)
-		}
-		// Only add calibre fallback if user didn't explicitly choose an OCR strategy
-		if !userChosenOCR {
-			if calibreExtractor, exists := f.extractors["calibre"]; exists {
-				extractors = append(extractors, calibreExtractor)
-				f.logger.Debug("Added Calibre fallback extractor for unknown type: %s", ext)
+	extractors := make([]interfaces.Extractor, len(chain))
+	names := make([]string, len(chain))
+	for i, registration := range chain {
+		extractors[i] = registration.Extractor
+		names[i] = registration.Name
+	}
+	f.logger.Info("Created extraction chain for filetype '%s': %v", format, names)
+	return extractors, nil
+}
+
+// chainOverride returns the order configured for a format in Config.ExtractorOrder
+func (f *DefaultExtractorFactory) chainOverride(format string) ([]string, bool) {
+	names, ok := f.config.ExtractorOrder[format]
+	return names, ok && len(names) > 0
+}
+
+// validateExtractorOrder rejects configured chains naming unregistered extractors
+func validateExtractorOrder(cfg *config.Config, factory interfaces.ExtractorFactory) error {
+	registry, ok := factory.(*DefaultExtractorFactory)
+	if !ok {
+		return nil
+	}
+	for format, names := range cfg.ExtractorOrder {
+		for _, name := range names {
+			if _, found := registry.lookup(name); !found {
+				return utils.NewValidationError(fmt.Sprintf("unknown extractor '%s' configured for '%s' (available: %s)", name, format, strings.Join(registry.ListExtractors(), ", ")), nil)
 			}
-		} else {
-			f.logger.Debug("Skipping Calibre fallback since user explicitly chose OCR strategy: %s", f.config.OCRStrategy)
 		}
 	}
+	return nil
+}
 
-	if len(extractors) == 0 {
-		return nil, fmt.Errorf("no suitable extractors found for file type '%s'", ext)
+// Register adds an extractor to the registry, replacing one with the same name
+func (f *DefaultExtractorFactory) Register(registration interfaces.ExtractorRegistration) {
+	for i, existing := range f.registrations {
+		if existing.Name == registration.Name {
+			f.registrations[i] = registration
+			f.logger.Debug("Replaced extractor: %s", registration.Name)
+			return
+		}
 	}
+	f.registrations = append(f.registrations, registration)
+	f.logger.Debug("Registered extractor: %s (priority %d, formats %v)", registration.Name, registration.Priority, registration.Formats)
+}
 
-	f.logger.Info("Created extraction chain for filetype '%s' with %d extractors", ext, len(extractors))
-	return extractors, nil
+// ListExtractors returns the names of all registered extractors
+func (f *DefaultExtractorFactory) ListExtractors() []string {
+	names := make([]string, 0, len(f.registrations))
+	for _, registration := range f.registrations {
+		names = append(names, registration.Name)
+	}
+	return names
 }
 
-// RegisterExtractor registers a new extractor
-func (f *DefaultExtractorFactory) RegisterExtractor(name string, extractor interfaces.Extractor) {
-	f.extractors[name] = extractor
-	f.logger.Debug("Registered extractor: %s", name)
+// lookup finds a registration by name
+func (f *DefaultExtractorFactory) lookup(name string) (interfaces.ExtractorRegistration, bool) {
+	for _, registration := range f.registrations {
+		if registration.Name == name {
+			return registration, true
+		}
+	}
+	return interfaces.ExtractorRegistration{}, false
 }
 
-// ListExtractors returns all registered extractors
-func (f *DefaultExtractorFactory) ListExtractors() []string {
-	names := make([]string, 0, len(f.extractors))
-	for name := range f.extractors {
-		names = append(names, name)
+// byPriority returns the registrations ordered by descending priority; equal
+// priorities keep their registration order
+func (f *DefaultExtractorFactory) byPriority() []interfaces.ExtractorRegistration {
+	sorted := append([]interfaces.ExtractorRegistration(nil), f.registrations...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Priority > sorted[j].Priority
+	})
+	return sorted
+}
+
+// handles reports whether a registration declares the format or MIME type. A format
+// the registration restricts to a content type is only handled for that content type.
+func handles(registration interfaces.ExtractorRegistration, format, mimeType string, contentType types.ContentType) bool {
+	if required, ok := registration.ContentTypes[format]; ok && required != contentType {
+		return false
 	}
-	return names
+	for _, declared := range registration.Formats {
+		if declared == format {
+			return true
+		}
+	}
+	if mediaType, _, err := mime.ParseMediaType(mimeType); err == nil {
+		for _, declared := range registration.MimeTypes {
+			if declared == mediaType {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 // registerDefaultExtractors registers the default set of extractors
@@ -158,40 +192,73 @@ func (f *DefaultExtractorFactory) registerDefaultExtractors() {
 	f.logger.Debug("Registering default providers...")
 
 	// Text file extractor
-	f.RegisterExtractor("text", providers.NewTextFileExtractor())
+	f.Register(interfaces.ExtractorRegistration{
+		Name:      "text",
+		Extractor: providers.NewTextFileExtractor(),
+		Formats:   constants.TextExtensions,
+		MimeTypes: []string{"text/plain", "text/markdown", "text/csv", "application/json", "application/xml", "text/xml"},
+		Priority:  PriorityPrimary,
+	})
 
 	// HTML/MHTML extractor
-	f.RegisterExtractor("html", providers.NewHTMLExtractor())
+	f.Register(interfaces.ExtractorRegistration{
+		Name:      "html",
+		Extractor: providers.NewHTMLExtractor(),
+		Formats:   []string{"html", "htm", "mhtml", "mht"},
+		MimeTypes: []string{"text/html", "application/xhtml+xml", "multipart/related"},
+		Priority:  PriorityPrimary,
+	})
 
-	// OCR extractor for PDFs and images
-	f.RegisterExtractor("ocr", ocr.NewOCRExtractor(f.config, f.logger))
+	// OCR extractor for images, and for PDFs with image content
+	f.Register(interfaces.ExtractorRegistration{
+		Name:         "ocr",
+		Extractor:    ocr.NewOCRExtractor(f.config, f.logger),
+		Formats:      append([]string{"pdf"}, constants.ImageExtensions...),
+		MimeTypes:    []string{"application/pdf", "image/jpeg", "image/png", "image/gif", "image/bmp", "image/webp", "image/tiff"},
+		Priority:     PriorityPrimary,
+		ContentTypes: map[string]types.ContentType{"pdf": types.ContentTypeImage},
+	})
 
 	// E-book extractor
-	f.RegisterExtractor("ebook", providers.NewEbookExtractor(f.config, f.logger))
+	f.Register(interfaces.ExtractorRegistration{
+		Name:      "ebook",
+		Extractor: providers.NewEbookExtractor(f.config, f.logger),
+		Formats:   constants.EbookExtensions,
+		MimeTypes: []string{"application/epub+zip", "application/x-mobipocket-ebook"},
+		Priority:  PriorityPrimary,
+	})
 
-	// Calibre fallback extractor
-	f.RegisterExtractor("calibre", providers.NewCalibreFallbackExtractor(f.config, f.logger))
+	// Built-in EPUB parser when Calibre is missing
+	f.Register(interfaces.ExtractorRegistration{
+		Name:      "epub",
+		Extractor: providers.NewEPUBExtractor(),
+		Formats:   []string{"epub"},
+		MimeTypes: []string{"application/epub+zip"},
+		Priority:  PriorityBuiltin,
+		Fallback:  true,
+	})
 
-	f.logger.Info("Registered %d extractors: %v", len(f.extractors), f.ListExtractors())
-}
+	// Built-in DOCX parser mapping heading and list styles to document structure
+	f.Register(interfaces.ExtractorRegistration{
+		Name:      "docx",
+		Extractor: providers.NewDOCXExtractor(),
+		Formats:   []string{"docx"},
+		MimeTypes: []string{"application/vnd.openxmlformats-officedocument.wordprocessingml.document"},
+		Priority:  PriorityBuiltin,
+		Fallback:  true,
+	})
 
-// GetExtractorPriority returns the priority order for extractors based on file type
-func (f *DefaultExtractorFactory) GetExtractorPriority(fileInfo *types.FileInfo) []string {
-	ext := strings.ToLower(fileInfo.Extension)
-
-	switch {
-	case ext == "txt" || ext == "md" || ext == "json" || ext == "xml":
-		return []string{"text"}
-	case ext == "html" || ext == "htm" || ext == "mhtml" || ext == "mht":
-		return []string{"html"}
-	case ext == "epub" || ext == "mobi":
-		return []string{"ebook"}
-	case ext == "pdf":
-		return []string{"ocr"}
-	case ext == "jpg" || ext == "jpeg" || ext == "png" || ext == "gif" || ext == "bmp":
-		return []string{"ocr"}
-	default:
-		// Try OCR as fallback for unknown types
-		return []string{"ocr", "text"}
-	}
+	// Calibre: office documents, further e-book formats, PDFs with text content, and
+	// fallback for HTML and unknown types
+	f.Register(interfaces.ExtractorRegistration{
+		Name:         "calibre",
+		Extractor:    providers.NewCalibreFallbackExtractor(f.config, f.logger),
+		Formats:      []string{"pdf", "docx", "odt", "rtf", "azw", "azw3", "fb2", "lit", "lrf", "pdb", "html", "htm", "mhtml", "mht"},
+		MimeTypes:    []string{"application/vnd.openxmlformats-officedocument.wordprocessingml.document", "application/vnd.oasis.opendocument.text", "application/rtf"},
+		Priority:     PriorityFallback,
+		Fallback:     true,
+		ContentTypes: map[string]types.ContentType{"pdf": types.ContentTypeText},
+	})
+
+	f.logger.Info("Registered %d extractors: %v", len(f.registrations), f.ListExtractors())
 }
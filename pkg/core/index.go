@@ -0,0 +1,266 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"doc-to-text/pkg/cache"
+	"doc-to-text/pkg/document"
+	"doc-to-text/pkg/interfaces"
+	"doc-to-text/pkg/logger"
+	"doc-to-text/pkg/search"
+	"doc-to-text/pkg/types"
+	"doc-to-text/pkg/utils"
+)
+
+// workDirPattern matches the name of a work directory, the MD5 of its input
+var workDirPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// workDirOutputs are the output files looked for in a work directory, in order
+var workDirOutputs = []string{"text.txt", "text.md", "text.json", "text.jsonl"}
+
+// IndexStats counts what an index run did
+type IndexStats struct {
+	Added     int
+	Updated   int
+	Unchanged int
+	Removed   int
+	Failed    int
+}
+
+// OutputPages reads an output file in any output format and returns the text of its
+// pages; the format is taken from the extension
+func OutputPages(outputFile string) ([]document.PageText, bool, error) {
+	content, err := os.ReadFile(outputFile)
+	if err != nil {
+		return nil, false, utils.WrapError(err, utils.ErrorTypeIO, "failed to read output file")
+	}
+	format, ok := types.ParseOutputFormat(strings.TrimPrefix(filepath.Ext(outputFile), "."))
+	if !ok {
+		format = types.OutputFormatText
+	}
+	result, err := ParseOutput(content, format)
+	if err != nil {
+		return nil, false, err
+	}
+	doc := resultDocument(result)
+	return doc.PageTexts(), doc.Paged, nil
+}
+
+// IndexOutputs adds the outputs found in the work directories under dirs to the
+// index in indexDir. Outputs indexed before and unchanged since are not read again,
+// and documents whose output under dirs has disappeared are removed.
+func IndexOutputs(indexDir string, dirs []string, rebuild bool, log *logger.Logger) (IndexStats, error) {
+	var stats IndexStats
+	roots := make([]string, 0, len(dirs))
+	for _, dir := range dirs {
+		root, err := filepath.Abs(dir)
+		if err != nil {
+			return stats, utils.WrapError(err, utils.ErrorTypeValidation, "error resolving directory path")
+		}
+		if info, err := os.Stat(root); err != nil || !info.IsDir() {
+			return stats, utils.NewNotFoundError("directory not found: "+dir, err)
+		}
+		roots = append(roots, root)
+	}
+	indexRoot, _ := filepath.Abs(indexDir)
+
+	err := search.Update(indexDir, func(ix *search.Index) error {
+		if rebuild {
+			ix.Reset()
+		}
+		sources := newSourceFinder()
+		seen := make(map[string]bool)
+
+		for _, root := range roots {
+			walkErr := filepath.WalkDir(root, func(path string, entry os.DirEntry, err error) error {
+				if err != nil {
+					log.Warn("Skipping %s: %v", path, err)
+					return nil
+				}
+				if !entry.IsDir() {
+					return nil
+				}
+				if path == indexRoot {
+					return filepath.SkipDir
+				}
+				if !workDirPattern.MatchString(entry.Name()) {
+					return nil
+				}
+
+				for _, name := range workDirOutputs {
+					output := filepath.Join(path, name)
+					info, err := os.Stat(output)
+					if err != nil {
+						continue
+					}
+					hash := entry.Name()
+					seen[output] = true
+					indexOutput(ix, hash, output, info, sources, &stats, log)
+					break
+				}
+				// Work directories hold outputs and intermediate files, not other work directories
+				return filepath.SkipDir
+			})
+			if walkErr != nil {
+				return utils.WrapError(walkErr, utils.ErrorTypeIO, "failed to scan "+root)
+			}
+		}
+
+		// Outputs under the scanned directories that no longer exist
+		for _, doc := range ix.Documents() {
+			if !seen[doc.Output] && underAny(doc.Output, roots) {
+				if _, err := os.Stat(doc.Output); err != nil {
+					ix.Remove(doc.Hash)
+					stats.Removed++
+				}
+			}
+		}
+		return nil
+	})
+	return stats, err
+}
+
+// indexOutput adds one work directory output to the index
+func indexOutput(ix *search.Index, hash, output string, info os.FileInfo, sources *sourceFinder, stats *IndexStats, log *logger.Logger) {
+	known := ix.Lookup(hash)
+	if ix.Unchanged(hash, output, info) {
+		if len(known.Sources) == 0 {
+			for _, source := range sources.find(filepath.Dir(output), hash) {
+				ix.AddSource(hash, source)
+			}
+		}
+		stats.Unchanged++
+		return
+	}
+
+	pages, paged, err := OutputPages(output)
+	if err != nil {
+		log.Warn("Could not index %s: %v", output, err)
+		stats.Failed++
+		return
+	}
+	ix.Add(search.Document{
+		Hash:    hash,
+		Sources: sources.find(filepath.Dir(output), hash),
+		Output:  output,
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+		Paged:   paged,
+	}, pages)
+	log.Progress("📖", "Indexed %s", output)
+	if known != nil {
+		stats.Updated++
+	} else {
+		stats.Added++
+	}
+}
+
+// sourceFinder finds the input files of work directories: the sources recorded in
+// the work directory, and for work directories next to their inputs the files
+// beside it with the same MD5. Every directory is hashed at most once.
+type sourceFinder struct {
+	dirs map[string]map[string][]string
+}
+
+// newSourceFinder creates an empty source finder
+func newSourceFinder() *sourceFinder {
+	return &sourceFinder{dirs: make(map[string]map[string][]string)}
+}
+
+// find returns the input files of a work directory
+func (f *sourceFinder) find(workDir, hash string) []string {
+	return append(cache.Sources(workDir), f.siblings(filepath.Dir(workDir), hash)...)
+}
+
+// siblings returns the files in dir whose MD5 is hash
+func (f *sourceFinder) siblings(dir, hash string) []string {
+	hashes, ok := f.dirs[dir]
+	if !ok {
+		hashes = make(map[string][]string)
+		entries, _ := os.ReadDir(dir)
+		for _, entry := range entries {
+			if !entry.Type().IsRegular() || strings.HasPrefix(entry.Name(), ".") {
+				continue
+			}
+			path := filepath.Join(dir, entry.Name())
+			if sum, err := utils.CalculateFileMD5(path); err == nil {
+				hashes[sum] = append(hashes[sum], path)
+			}
+		}
+		f.dirs[dir] = hashes
+	}
+	return hashes[hash]
+}
+
+// underAny reports whether a path lies under one of the directories
+func underAny(path string, dirs []string) bool {
+	for _, dir := range dirs {
+		if rel, err := filepath.Rel(dir, path); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// SearchIndex runs a query against the index in indexDir; snippets are read from
+// the indexed output files
+func SearchIndex(indexDir, query string, opts search.Options) ([]search.Result, error) {
+	ix, err := search.Open(indexDir)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Pages == nil {
+		opts.Pages = func(doc *search.Document) ([]document.PageText, error) {
+			pages, _, err := OutputPages(doc.Output)
+			return pages, err
+		}
+	}
+	return ix.Search(query, opts), nil
+}
+
+// updateIndex adds a completed extraction to the search index when one has been
+// created, so searches cover new outputs without re-running the index command.
+// Index failures are logged and do not fail the extraction.
+func (p *DefaultFileProcessor) updateIndex(result *interfaces.ExtractionResult, outputFile string) {
+	indexDir := p.config.SearchIndexDir()
+	if outputFile == "" || result.SourceHash == "" || !search.Exists(indexDir) {
+		return
+	}
+	output, err := filepath.Abs(outputFile)
+	if err != nil {
+		return
+	}
+	info, err := os.Stat(output)
+	if err != nil {
+		return
+	}
+	source := result.Source
+	if abs, err := filepath.Abs(source); err == nil {
+		source = abs
+	}
+
+	err = search.Update(indexDir, func(ix *search.Index) error {
+		if ix.Unchanged(result.SourceHash, output, info) {
+			ix.AddSource(result.SourceHash, source)
+			return nil
+		}
+		doc := resultDocument(result)
+		ix.Add(search.Document{
+			Hash:    result.SourceHash,
+			Sources: []string{source},
+			Output:  output,
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+			Paged:   doc.Paged,
+		}, doc.PageTexts())
+		return nil
+	})
+	if err != nil {
+		p.logger.Warn("Could not update search index: %v", err)
+		return
+	}
+	p.logger.Progress("📖", "Search index updated: %s", indexDir)
+}
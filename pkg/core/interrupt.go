@@ -0,0 +1,117 @@
+package core
+
+import (
+	"context"
+	"path/filepath"
+	"time"
+
+	"doc-to-text/pkg/cache"
+	"doc-to-text/pkg/constants"
+	"doc-to-text/pkg/document"
+	"doc-to-text/pkg/interfaces"
+	"doc-to-text/pkg/types"
+	"doc-to-text/pkg/utils"
+)
+
+// stoppedError reports an extraction cancelled (interrupted) or timed out. The
+// extractor and the part it finished, if it reports one, are kept in the error
+// context under "extractor" and "partial".
+func stoppedError(ctx context.Context, extractor interfaces.Extractor) *utils.AppError {
+	message := "extraction interrupted"
+	if ctx.Err() == context.DeadlineExceeded {
+		message = "extraction timed out"
+	}
+	err := utils.WrapError(ctx.Err(), "", message).WithContext("extractor", extractor.Name())
+	if provider, ok := extractor.(interfaces.PartialResultProvider); ok {
+		if partial := provider.PartialResult(); partial != nil {
+			err.WithContext("partial", partial)
+		}
+	}
+	return err
+}
+
+// savePartialOutput keeps what a stopped extraction finished: the text of the pages
+// done goes to text.partial<ext> in the work directory, and incomplete.json marks
+// the directory, so the result is usable but never mistaken for a finished one.
+// Other errors are ignored.
+func (p *DefaultFileProcessor) savePartialOutput(err error, inputFile, source string, fileInfo *types.FileInfo) {
+	appErr, ok := err.(*utils.AppError)
+	if !ok || (appErr.Type != utils.ErrorTypeInterrupted && appErr.Type != utils.ErrorTypeTimeout) {
+		return
+	}
+	workDir := p.workDir(inputFile, fileInfo)
+	if workDir == "" {
+		return
+	}
+	if err := utils.EnsureDir(workDir); err != nil {
+		p.logger.Warn("Could not save partial output: %v", err)
+		return
+	}
+
+	extractor, _ := appErr.Context["extractor"].(string)
+	incomplete := &cache.Incomplete{
+		Reason:    string(appErr.Type),
+		StoppedAt: time.Now(),
+		Extractor: extractor,
+		Error:     appErr.Message,
+	}
+	if partial, ok := appErr.Context["partial"].(*interfaces.PartialResult); ok {
+		incomplete.PagesDone = partial.PagesDone
+		incomplete.TotalPages = partial.TotalPages
+		if partial.Text != "" {
+			name := cache.PartialOutputName(p.config.OutputFormat.Extension())
+			if err := p.writePartialOutput(partial.Text, filepath.Join(workDir, name), source, extractor, fileInfo); err != nil {
+				p.logger.Warn("Could not save partial output: %v", err)
+			} else {
+				incomplete.Output = name
+			}
+		}
+	}
+	if err := cache.WriteIncomplete(workDir, incomplete); err != nil {
+		p.logger.Warn("Could not mark the work directory as incomplete: %v", err)
+		return
+	}
+	p.recordSource(inputFile, fileInfo)
+
+	switch {
+	case incomplete.Output != "":
+		p.logger.ProgressAlways("⏸️", "Stopped after %d of %d pages, partial text saved to: %s", incomplete.PagesDone, incomplete.TotalPages, filepath.Join(workDir, incomplete.Output))
+	case incomplete.TotalPages > 0:
+		p.logger.ProgressAlways("⏸️", "Stopped after %d of %d pages, no text yet; finished pages are resumed next time", incomplete.PagesDone, incomplete.TotalPages)
+	default:
+		p.logger.ProgressAlways("⏸️", "Stopped before the extraction finished, marked incomplete in: %s", workDir)
+	}
+}
+
+// writePartialOutput renders the partial text in the output format and writes it
+func (p *DefaultFileProcessor) writePartialOutput(text, path, source, extractor string, fileInfo *types.FileInfo) error {
+	result := &interfaces.ExtractionResult{
+		Text:          text,
+		Source:        source,
+		SourceHash:    fileInfo.MD5Hash,
+		Format:        fileInfo.Format,
+		ExtractorUsed: extractor,
+		Error:         "incomplete",
+		Document:      document.FromText(text),
+	}
+	if p.config.OutputFormat == types.OutputFormatMarkdown {
+		result.Text = result.Document.Markdown()
+	}
+	content, err := RenderOutput(result, p.config.OutputFormat)
+	if err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(path, content, constants.DefaultFilePermission)
+}
+
+// clearIncomplete drops the incomplete marker and partial output of an earlier
+// stopped run once the extraction finished
+func (p *DefaultFileProcessor) clearIncomplete(inputFile string, fileInfo *types.FileInfo) {
+	workDir := p.workDir(inputFile, fileInfo)
+	if workDir == "" {
+		return
+	}
+	if err := cache.ClearIncomplete(workDir); err != nil {
+		p.logger.Warn("Could not clear incomplete marker: %v", err)
+	}
+}
@@ -0,0 +1,67 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"doc-to-text/pkg/cache"
+	"doc-to-text/pkg/types"
+	"doc-to-text/pkg/utils"
+)
+
+// lockPollInterval is how often a run waiting for a locked work directory checks it
+const lockPollInterval = time.Second
+
+// lockWorkDir locks the work directory of a document, so two processes never extract
+// the same document into it at once. When another process holds the lock, the run
+// skips the document or waits for it, depending on OnLocked; waited is the holder
+// waited for, or nil. The returned release must be called once the run is done.
+func (p *DefaultFileProcessor) lockWorkDir(ctx context.Context, inputFile string, fileInfo *types.FileInfo) (release func(), waited *cache.LockHolder, err error) {
+	release = func() {}
+	workDir := p.workDir(inputFile, fileInfo)
+	if workDir == "" {
+		return release, nil, nil
+	}
+	for {
+		// Created on every try, as a purge may remove it while this run waits
+		if err := utils.EnsureDir(workDir); err != nil {
+			p.logger.Warn("Could not lock work directory, continuing without lock: %v", err)
+			return release, waited, nil
+		}
+		lock, holder, err := cache.TryLock(workDir)
+		if err != nil {
+			p.logger.Warn("Could not lock work directory, continuing without lock: %v", err)
+			return release, waited, nil
+		}
+		if lock != nil {
+			return lock.Release, waited, nil
+		}
+		// Extractions of this process, e.g. of a document listed twice in a batch,
+		// are always waited for
+		if p.config.OnLocked == types.LockModeSkip && !holder.Current() {
+			return release, nil, utils.NewError(utils.ErrorTypeLocked, fmt.Sprintf("work directory %s is being processed by %s", workDir, holder), nil).
+				WithContext("work_dir", workDir)
+		}
+		if waited == nil {
+			p.logger.ProgressAlways("⏳", "Waiting for %s, which is processing this document", holder)
+		}
+		waited = holder
+
+		select {
+		case <-ctx.Done():
+			return release, waited, utils.WrapError(ctx.Err(), "", "stopped while waiting for the work directory lock")
+		case <-time.After(lockPollInterval):
+		}
+	}
+}
+
+// reuseResults reports whether an existing or cached result is used instead of
+// extracting: with SkipExisting, or when piggybacking on the process waited for
+func (p *DefaultFileProcessor) reuseResults(waited *cache.LockHolder) bool {
+	if waited != nil && p.config.OnLocked == types.LockModePiggyback {
+		p.logger.ProgressAlways("🤝", "%s finished, reusing its result", waited)
+		return true
+	}
+	return p.config.SkipExisting
+}
@@ -0,0 +1,102 @@
+package core
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"doc-to-text/pkg/constants"
+	"doc-to-text/pkg/interfaces"
+	"doc-to-text/pkg/metadata"
+	"doc-to-text/pkg/ocr"
+	"doc-to-text/pkg/utils"
+)
+
+// languageSampleSize is the number of characters of text used to detect the language
+const languageSampleSize = 4000
+
+// readProperties reads the properties embedded in a document. Unreadable properties
+// are not an error: the extracted text is still useful without them.
+func (p *DefaultFileProcessor) readProperties(format string, read func() (*metadata.Properties, error)) *metadata.Properties {
+	props, err := read()
+	if err != nil {
+		p.logger.Debug("Could not read %s document properties: %v", format, err)
+	}
+	if props == nil {
+		props = &metadata.Properties{}
+	}
+	return props
+}
+
+// addMetadata merges document properties into the result metadata. The page count
+// falls back to the pages of the document, the word count is taken from the text,
+// and the language is detected from the text when the document declares none.
+func addMetadata(result *interfaces.ExtractionResult, props *metadata.Properties) {
+	doc := resultDocument(result)
+	if props.PageCount == 0 && doc.Paged {
+		props.PageCount = len(doc.Pages)
+	}
+
+	props.WordCount = 0
+	for _, page := range doc.PageTexts() {
+		props.WordCount += metadata.CountWords(page.Text)
+	}
+
+	if props.Language == "" {
+		sample := []rune(result.Text)
+		if len(sample) > languageSampleSize {
+			sample = sample[:languageSampleSize]
+		}
+		if languages := ocr.DetectLanguages(string(sample)); len(languages) > 0 {
+			props.Language = languages[0]
+		}
+	}
+
+	// Extractor metadata is kept; the map is copied so the extractor's own is not modified
+	merged := make(map[string]interface{}, len(result.Metadata))
+	for key, value := range result.Metadata {
+		merged[key] = value
+	}
+	for key, value := range props.Map() {
+		if _, ok := merged[key]; !ok {
+			merged[key] = value
+		}
+	}
+	result.Metadata = merged
+}
+
+// metadataPath returns the sidecar next to an output file: text.txt -> text.metadata.json
+func metadataPath(outputFile string) string {
+	return strings.TrimSuffix(outputFile, filepath.Ext(outputFile)) + ".metadata.json"
+}
+
+// saveMetadata writes the result metadata to the sidecar of the output file
+func (p *DefaultFileProcessor) saveMetadata(result *interfaces.ExtractionResult, outputFile string) error {
+	if len(result.Metadata) == 0 {
+		return nil
+	}
+	data, err := json.MarshalIndent(result.Metadata, "", "  ")
+	if err != nil {
+		return utils.WrapError(err, utils.ErrorTypeSystem, "failed to encode metadata")
+	}
+	path := metadataPath(outputFile)
+	if err := utils.WriteFileAtomic(path, append(data, '\n'), constants.DefaultFilePermission); err != nil {
+		return utils.WrapError(err, utils.ErrorTypeIO, "failed to save metadata file")
+	}
+	p.logger.Progress("🏷️", "Metadata saved to: %s", path)
+	return nil
+}
+
+// loadMetadata reads the sidecar of an output file, returning nil when there is none
+func loadMetadata(outputFile string) map[string]interface{} {
+	data, err := os.ReadFile(metadataPath(outputFile))
+	if err != nil {
+		return nil
+	}
+	var values map[string]interface{}
+	if json.Unmarshal(data, &values) != nil {
+		return nil
+	}
+	return values
+}
@@ -0,0 +1,166 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"doc-to-text/pkg/chunk"
+	"doc-to-text/pkg/constants"
+	"doc-to-text/pkg/document"
+	"doc-to-text/pkg/interfaces"
+	"doc-to-text/pkg/types"
+	"doc-to-text/pkg/utils"
+)
+
+// jsonResult is the JSON output: the extraction result plus its pages as plain text
+type jsonResult struct {
+	*interfaces.ExtractionResult
+	Pages []document.PageText `json:"pages"`
+}
+
+// pageRecord is one line of JSONL output
+type pageRecord struct {
+	Source    string `json:"source"`
+	Format    string `json:"format,omitempty"`
+	Extractor string `json:"extractor"`
+	document.PageText
+}
+
+// RenderOutput renders a result in an output format: the text for text and Markdown,
+// the full result for JSON, one record per page for JSONL
+func RenderOutput(result *interfaces.ExtractionResult, format types.OutputFormat) ([]byte, error) {
+	switch format {
+	case types.OutputFormatJSON:
+		data, err := json.MarshalIndent(jsonResult{ExtractionResult: result, Pages: resultDocument(result).PageTexts()}, "", "  ")
+		if err != nil {
+			return nil, utils.WrapError(err, utils.ErrorTypeSystem, "failed to encode result as JSON")
+		}
+		return append(data, '\n'), nil
+
+	case types.OutputFormatJSONL:
+		var buffer bytes.Buffer
+		encoder := json.NewEncoder(&buffer)
+		encoder.SetEscapeHTML(false)
+		for _, page := range resultDocument(result).PageTexts() {
+			record := pageRecord{Source: result.Source, Format: result.Format, Extractor: result.ExtractorUsed, PageText: page}
+			if err := encoder.Encode(record); err != nil {
+				return nil, utils.WrapError(err, utils.ErrorTypeSystem, "failed to encode page record")
+			}
+		}
+		return buffer.Bytes(), nil
+
+	default:
+		return []byte(result.Text), nil
+	}
+}
+
+// RenderChunks splits the text of a result into chunks and renders them as JSONL,
+// one chunk per line. Offsets refer to the result text, i.e. the text output file.
+func RenderChunks(result *interfaces.ExtractionResult, opts chunk.Options) ([]byte, error) {
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	for _, record := range resultChunks(result, opts) {
+		if err := encoder.Encode(record); err != nil {
+			return nil, utils.WrapError(err, utils.ErrorTypeSystem, "failed to encode chunk record")
+		}
+	}
+	return buffer.Bytes(), nil
+}
+
+// resultChunks splits the text of a result, identifying chunks by the source hash
+func resultChunks(result *interfaces.ExtractionResult, opts chunk.Options) []chunk.Chunk {
+	prefix := result.SourceHash
+	if prefix == "" {
+		prefix = "chunk"
+	}
+	chunks := chunk.Split(result.Text, resultDocument(result), opts)
+	for i := range chunks {
+		chunks[i].ID = fmt.Sprintf("%s-%04d", prefix, chunks[i].Index)
+		chunks[i].Source = result.Source
+		chunks[i].SourceHash = result.SourceHash
+	}
+	return chunks
+}
+
+// ParseOutput reads an output file written by RenderOutput back into a result
+func ParseOutput(data []byte, format types.OutputFormat) (*interfaces.ExtractionResult, error) {
+	switch format {
+	case types.OutputFormatJSON:
+		var result interfaces.ExtractionResult
+		if err := json.Unmarshal(data, &result); err != nil {
+			return nil, utils.WrapError(err, utils.ErrorTypeValidation, "failed to parse JSON output")
+		}
+		if result.Document == nil {
+			result.Document = document.FromText(result.Text)
+		}
+		return &result, nil
+
+	case types.OutputFormatJSONL:
+		doc := &document.Document{}
+		result := &interfaces.ExtractionResult{Document: doc}
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+		for line := 1; scanner.Scan(); line++ {
+			if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+				continue
+			}
+			var record pageRecord
+			if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+				return nil, utils.WrapError(err, utils.ErrorTypeValidation, fmt.Sprintf("failed to parse JSONL output line %d", line))
+			}
+			result.Source, result.Format = record.Source, record.Format
+			doc.Pages = append(doc.Pages, document.Page{
+				Number:     record.Number,
+				Method:     record.Method,
+				Engine:     record.Engine,
+				Confidence: record.Confidence,
+				Blocks:     document.ParseBlocks(record.Text),
+			})
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, utils.WrapError(err, utils.ErrorTypeIO, "failed to read JSONL output")
+		}
+		doc.Paged = len(doc.Pages) > 1
+		result.Text = doc.Text()
+		return result, nil
+
+	default:
+		return &interfaces.ExtractionResult{Text: string(data), Document: document.FromText(string(data))}, nil
+	}
+}
+
+// resultDocument returns the document of a result, parsing the text when there is none
+func resultDocument(result *interfaces.ExtractionResult) *document.Document {
+	if result.Document != nil {
+		return result.Document
+	}
+	return document.FromText(result.Text)
+}
+
+// chunksPath returns the chunk sidecar next to an output file: text.txt -> text.chunks.jsonl
+func chunksPath(outputFile string) string {
+	return strings.TrimSuffix(outputFile, filepath.Ext(outputFile)) + ".chunks.jsonl"
+}
+
+// saveChunks writes the chunks of the result text to the sidecar of the output file
+// when chunking is enabled
+func (p *DefaultFileProcessor) saveChunks(result *interfaces.ExtractionResult, outputFile string) error {
+	if p.config.ChunkSize <= 0 {
+		return nil
+	}
+	content, err := RenderChunks(result, p.config.ChunkOptions())
+	if err != nil {
+		return err
+	}
+	path := chunksPath(outputFile)
+	if err := utils.WriteFileAtomic(path, content, constants.DefaultFilePermission); err != nil {
+		return utils.WrapError(err, utils.ErrorTypeIO, "failed to save chunks file")
+	}
+	p.logger.Progress("🧩", "Chunks saved to: %s", path)
+	return nil
+}
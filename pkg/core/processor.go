@@ -3,18 +3,25 @@ package core
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"time"
 
 	"doc-to-text/pkg/config"
 	"doc-to-text/pkg/constants"
+	"doc-to-text/pkg/document"
 	"doc-to-text/pkg/interfaces"
 	"doc-to-text/pkg/logger"
+	"doc-to-text/pkg/metadata"
+	"doc-to-text/pkg/postprocess"
 	"doc-to-text/pkg/types"
 	"doc-to-text/pkg/utils"
 )
 
+// extractFunc runs one extractor against the input being processed
+type extractFunc func(ctx context.Context, extractor interfaces.Extractor) (string, error)
+
 // DefaultFileProcessor implements FileProcessor interface
 type DefaultFileProcessor struct {
 	config       *config.Config
@@ -24,8 +31,8 @@ type DefaultFileProcessor struct {
 	errorHandler *utils.ErrorHandler
 }
 
-// NewFileProcessor creates a new file processor
-func NewFileProcessor(cfg *config.Config, log *logger.Logger) interfaces.FileProcessor {
+// NewFileProcessor creates a new file processor, returning an error for invalid configuration
+func NewFileProcessor(cfg *config.Config, log *logger.Logger) (interfaces.FileProcessor, error) {
 	processor := &DefaultFileProcessor{
 		config:       cfg,
 		logger:       log,
@@ -34,11 +41,14 @@ func NewFileProcessor(cfg *config.Config, log *logger.Logger) interfaces.FilePro
 
 	// Validate configuration
 	if err := cfg.Validate(); err != nil {
-		log.Fatal("Configuration validation failed: %v", err)
+		return nil, utils.WrapError(err, utils.ErrorTypeValidation, "configuration validation failed")
 	}
 
 	// Create and set default factory
 	processor.factory = NewExtractorFactory(cfg, log)
+	if err := validateExtractorOrder(cfg, processor.factory); err != nil {
+		return nil, err
+	}
 
 	// Setup error recovery strategies
 	processor.setupErrorRecovery()
@@ -46,11 +56,17 @@ func NewFileProcessor(cfg *config.Config, log *logger.Logger) interfaces.FilePro
 	log.Info("File processor initialized with configuration")
 	log.Info("Runtime settings applied from environment variables and command line")
 	log.Info("Skip existing: %v", cfg.SkipExisting)
-	log.Info("Output directory: using input file directory with MD5 hash")
+	log.Info("On locked: %s", cfg.OnLocked)
+	if root := cfg.CacheRoot(); root != "" {
+		log.Info("Work directories: %s", root)
+	} else {
+		log.Info("Work directories: {md5} directories next to the inputs")
+	}
 	log.Info("Max concurrency: %d", cfg.MaxConcurrency)
 	log.Info("Min text threshold: %d", cfg.MinTextThreshold)
+	log.Info("Reflow: %v", cfg.Reflow)
 
-	return processor
+	return processor, nil
 }
 
 // setupErrorRecovery configures error recovery strategies
@@ -99,6 +115,7 @@ func (p *DefaultFileProcessor) ProcessFile(ctx context.Context, inputFile, outpu
 
 	p.logger.Info("File analysis completed:")
 	p.logger.Info("  Extension: %s", fileInfo.Extension)
+	p.logger.Info("  Detected format: %s", fileInfo.Format)
 	p.logger.Info("  MIME type: %s", fileInfo.MimeType)
 	p.logger.Info("  Size: %d bytes", fileInfo.Size)
 	p.logger.Info("  MD5 hash: %s", fileInfo.MD5Hash)
@@ -112,11 +129,21 @@ func (p *DefaultFileProcessor) ProcessFile(ctx context.Context, inputFile, outpu
 		p.logger.Warn("Large file detected (%d MB), processing may take longer", fileInfo.Size/(1024*1024))
 	}
 
-	// Skip existing file if enabled
-	if p.config.SkipExisting {
-		if result, err := p.loadExistingResult(outputFile, inputFile); err == nil {
+	release, waited, err := p.lockWorkDir(ctx, inputFile, fileInfo)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	// Skip existing file if enabled, or reuse the result of the process waited for
+	if p.reuseResults(waited) {
+		if changes := p.changedSettings(outputFile, inputFile, fileInfo); len(changes) > 0 {
+			p.logger.ProgressAlways("🔁", "Cached result was built with other settings (%s), extracting again", formatChanges(changes))
+		} else if result, err := p.loadExistingResult(outputFile, inputFile, fileInfo); err == nil {
 			p.logger.ProgressAlways("⏭️", "Output file already exists, skipping extraction")
 			return result, nil
+		} else if result, err := p.loadCachedResult(outputFile, inputFile, inputFile, fileInfo); err == nil {
+			return result, nil
 		}
 	}
 
@@ -124,6 +151,117 @@ func (p *DefaultFileProcessor) ProcessFile(ctx context.Context, inputFile, outpu
 	return p.processWithResourceManagement(ctx, inputFile, outputFile, fileInfo, startTime)
 }
 
+// ProcessReader processes a document read from a stream. Extractors that can read
+// streams get it directly; the others get a copy spooled to a temporary directory,
+// which is removed afterwards. Intermediate files go to the work directory in the
+// cache, or with local work directories into the temporary directory as well.
+func (p *DefaultFileProcessor) ProcessReader(ctx context.Context, r io.Reader, hint types.SourceHint, outputFile string) (*interfaces.ExtractionResult, error) {
+	startTime := time.Now()
+
+	p.logger.Progress("📋", "=== Starting stream processing ===")
+	p.logger.Progress("📤", "Output file: %s", outputFile)
+
+	if r == nil {
+		return nil, utils.NewValidationError("input stream cannot be nil", nil)
+	}
+
+	spool, err := utils.NewSpooledInput(r, hint, constants.MaxInMemoryInput)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := spool.Cleanup(); err != nil {
+			p.logger.Warn("Failed to remove spooled input: %v", err)
+		}
+	}()
+
+	source := spool.Name()
+	fileInfo := spool.FileInfo()
+	p.logger.Progress("📂", "Input stream: %s", source)
+	p.logger.Info("Stream analysis completed:")
+	p.logger.Info("  Extension: %s", fileInfo.Extension)
+	p.logger.Info("  Detected format: %s", fileInfo.Format)
+	p.logger.Info("  MIME type: %s", fileInfo.MimeType)
+	p.logger.Info("  Size: %d bytes", fileInfo.Size)
+	p.logger.Info("  MD5 hash: %s", fileInfo.MD5Hash)
+	p.logger.Info("  Media type: %s", fileInfo.MediaType)
+
+	release, waited, err := p.lockWorkDir(ctx, "", fileInfo)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	// Skip existing file if enabled, or reuse the result of the process waited for
+	if p.reuseResults(waited) {
+		if changes := p.changedSettings(outputFile, "", fileInfo); len(changes) > 0 {
+			p.logger.ProgressAlways("🔁", "Cached result was built with other settings (%s), extracting again", formatChanges(changes))
+		} else if result, err := p.loadExistingResult(outputFile, source, fileInfo); err == nil {
+			p.logger.ProgressAlways("⏭️", "Output file already exists, skipping extraction")
+			return result, nil
+		} else if result, err := p.loadCachedResult(outputFile, "", source, fileInfo); err == nil {
+			return result, nil
+		}
+	}
+
+	p.logger.Debug("Creating extractor chain with fallback options...")
+	extractors, err := p.factory.CreateExtractorWithFallbacks(fileInfo)
+	if err != nil {
+		return nil, utils.WrapError(err, utils.ErrorTypeUnsupported, "no suitable extractor found")
+	}
+
+	result, err := p.attemptExtractionWithFallbacks(ctx, source, extractors, func(ctx context.Context, extractor interfaces.Extractor) (string, error) {
+		if readerExtractor, ok := extractor.(interfaces.ReaderExtractor); ok && readerExtractor.SupportsReader(fileInfo) {
+			reader, err := spool.Open()
+			if err != nil {
+				return "", err
+			}
+			defer reader.Close()
+			p.logger.Debug("Extractor '%s' reads the stream directly", extractor.Name())
+			return readerExtractor.ExtractReader(ctx, reader, fileInfo)
+		}
+
+		path, err := spool.Path()
+		if err != nil {
+			return "", err
+		}
+		p.logger.Debug("Extractor '%s' needs a file, spooled stream to %s", extractor.Name(), path)
+		return extractor.Extract(ctx, path)
+	})
+	if err != nil {
+		p.savePartialOutput(err, "", source, fileInfo)
+		return nil, err
+	}
+	result.SourceHash = fileInfo.MD5Hash
+	result.DetectedType = fileInfo.MimeType
+	result.Format = fileInfo.Format
+	addMetadata(result, p.readProperties(fileInfo.Format, func() (*metadata.Properties, error) {
+		reader, err := spool.OpenReaderAt()
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+		return metadata.Read(reader, fileInfo.Size, fileInfo.Format)
+	}))
+	result.ProcessTime = time.Since(startTime).Milliseconds()
+
+	if outputFile != "" {
+		if err := p.saveOutput(result, outputFile); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.saveCachedOutput(result, "", fileInfo, outputFile); err != nil {
+		return nil, err
+	}
+	p.saveManifest(result, "", fileInfo, outputFile)
+	p.clearIncomplete("", fileInfo)
+
+	p.logger.ProgressAlways("✅", "Text extraction completed successfully in %dms", result.ProcessTime)
+	p.logger.Progress("✅", "=== Stream processing completed ===")
+
+	return result, nil
+}
+
 // validateInputFile validates the input file
 func (p *DefaultFileProcessor) validateInputFile(inputFile string) error {
 	if inputFile == "" {
@@ -155,24 +293,46 @@ func (p *DefaultFileProcessor) validateInputFile(inputFile string) error {
 }
 
 // loadExistingResult loads existing processing results if available
-func (p *DefaultFileProcessor) loadExistingResult(outputFile, inputFile string) (*interfaces.ExtractionResult, error) {
-	if _, err := os.Stat(outputFile); err == nil {
-		p.logger.Info("Output file already exists, skipping extraction")
-		p.logger.Info("Loading existing content from: %s", outputFile)
+func (p *DefaultFileProcessor) loadExistingResult(outputFile, inputFile string, fileInfo *types.FileInfo) (*interfaces.ExtractionResult, error) {
+	result, err := p.readOutput(outputFile, inputFile, fileInfo)
+	if err != nil {
+		return nil, err
+	}
 
-		content, err := os.ReadFile(outputFile)
-		if err != nil {
-			return nil, utils.WrapError(err, utils.ErrorTypeIO, "failed to read existing output file")
-		}
+	// Chunk options may differ from the run that wrote the output
+	if err := p.saveChunks(result, outputFile); err != nil {
+		return nil, err
+	}
+	p.updateIndex(result, outputFile)
+	p.recordSource(inputFile, fileInfo)
+	return result, nil
+}
 
-		return &interfaces.ExtractionResult{
-			Text:          string(content),
-			Source:        inputFile,
-			ExtractorUsed: "cached",
-			ProcessTime:   0,
-		}, nil
+// readOutput reads an existing output file back into a result
+func (p *DefaultFileProcessor) readOutput(outputFile, inputFile string, fileInfo *types.FileInfo) (*interfaces.ExtractionResult, error) {
+	if _, err := os.Stat(outputFile); outputFile == "" || err != nil {
+		return nil, utils.NewNotFoundError("existing result not found", nil)
 	}
-	return nil, utils.NewNotFoundError("existing result not found", nil)
+	p.logger.Info("Output file already exists, skipping extraction")
+	p.logger.Info("Loading existing content from: %s", outputFile)
+
+	content, err := os.ReadFile(outputFile)
+	if err != nil {
+		return nil, utils.WrapError(err, utils.ErrorTypeIO, "failed to read existing output file")
+	}
+
+	result, err := ParseOutput(content, p.config.OutputFormat)
+	if err != nil {
+		return nil, err
+	}
+	if result.Metadata == nil {
+		result.Metadata = loadMetadata(outputFile)
+	}
+	result.Source = inputFile
+	result.SourceHash = fileInfo.MD5Hash
+	result.ExtractorUsed = "cached"
+	result.ProcessTime = 0
+	return result, nil
 }
 
 // processWithResourceManagement processes file with proper resource management
@@ -195,21 +355,36 @@ func (p *DefaultFileProcessor) processWithResourceManagement(ctx context.Context
 		p.logger.Info("Created extraction chain with %d extractors", len(extractors))
 
 		// Try each extractor in sequence with retry logic
-		extractionResult, err := p.attemptExtractionWithFallbacks(ctx, inputFile, extractors)
+		extractionResult, err := p.attemptExtractionWithFallbacks(ctx, inputFile, extractors, func(ctx context.Context, extractor interfaces.Extractor) (string, error) {
+			return extractor.Extract(ctx, inputFile)
+		})
 		if err != nil {
+			p.savePartialOutput(err, inputFile, inputFile, fileInfo)
 			return err
 		}
 
+		extractionResult.SourceHash = fileInfo.MD5Hash
+		extractionResult.DetectedType = fileInfo.MimeType
+		extractionResult.Format = fileInfo.Format
+		addMetadata(extractionResult, p.readProperties(fileInfo.Format, func() (*metadata.Properties, error) {
+			return metadata.ReadFile(inputFile, fileInfo.Format)
+		}))
+
+		// Set processing time
+		extractionResult.ProcessTime = time.Since(startTime).Milliseconds()
+
 		// Save to output file if specified
 		if outputFile != "" {
-			if err := p.saveToFileWithRetry(extractionResult.Text, outputFile); err != nil {
-				return utils.WrapError(err, utils.ErrorTypeIO, "failed to save output file")
+			if err := p.saveOutput(extractionResult, outputFile); err != nil {
+				return err
 			}
-			p.logger.ProgressAlways("💾", "Text saved to: %s", outputFile)
 		}
-
-		// Set processing time
-		extractionResult.ProcessTime = time.Since(startTime).Milliseconds()
+		if err := p.saveCachedOutput(extractionResult, inputFile, fileInfo, outputFile); err != nil {
+			return err
+		}
+		p.saveManifest(extractionResult, inputFile, fileInfo, outputFile)
+		p.clearIncomplete(inputFile, fileInfo)
+		p.recordSource(inputFile, fileInfo)
 		result = extractionResult
 		return nil
 	})
@@ -225,9 +400,10 @@ func (p *DefaultFileProcessor) processWithResourceManagement(ctx context.Context
 }
 
 // attemptExtractionWithFallbacks tries each extractor with fallback support
-func (p *DefaultFileProcessor) attemptExtractionWithFallbacks(ctx context.Context, inputFile string, extractors []interfaces.Extractor) (*interfaces.ExtractionResult, error) {
+func (p *DefaultFileProcessor) attemptExtractionWithFallbacks(ctx context.Context, inputFile string, extractors []interfaces.Extractor, extract extractFunc) (*interfaces.ExtractionResult, error) {
 	var lastError error
 	var attemptedExtractors []string
+	var attempts []interfaces.ExtractionAttempt
 	fallbackUsed := false
 
 	for i, extractor := range extractors {
@@ -244,9 +420,18 @@ func (p *DefaultFileProcessor) attemptExtractionWithFallbacks(ctx context.Contex
 		}
 
 		// Extract text with retry mechanism
-		extractResult, err := p.extractWithRetry(ctx, extractor, inputFile, extractorName)
+		extractStart := time.Now()
+		extractResult, err := p.extractWithRetry(ctx, extractor, extract, extractorName)
+		attempt := interfaces.ExtractionAttempt{Extractor: extractorName, DurationMs: time.Since(extractStart).Milliseconds()}
 		if err != nil {
 			lastError = err
+			attempt.Error = err.Error()
+			attempts = append(attempts, attempt)
+
+			// A stopped run does not fall back to the next extractor
+			if ctx.Err() != nil {
+				return nil, stoppedError(ctx, extractor)
+			}
 
 			// Handle specific error types with recovery strategies
 			if appErr, ok := err.(*utils.AppError); ok {
@@ -267,16 +452,41 @@ func (p *DefaultFileProcessor) attemptExtractionWithFallbacks(ctx context.Contex
 				nil,
 			)
 			p.logger.Warn("Extractor '%s' returned insufficient text (%d chars)", extractorName, len(extractResult))
+			attempt.Error = lastError.Error()
+			attempts = append(attempts, attempt)
 			continue
 		}
 
+		// Normalize line breaks into paragraphs when requested
+		if p.config.Reflow {
+			extractResult = postprocess.Reflow(extractResult)
+			p.logger.Progress("📝", "Reflowed text into paragraphs")
+		}
+
 		// Success! Create and return result
 		result := &interfaces.ExtractionResult{
 			Text:                extractResult,
 			Source:              inputFile,
 			ExtractorUsed:       extractorName,
+			ExtractionTime:      attempt.DurationMs,
 			FallbackUsed:        fallbackUsed,
 			AttemptedExtractors: attemptedExtractors,
+			Attempts:            append(attempts, attempt),
+		}
+		if provider, ok := extractor.(interfaces.ToolProvider); ok {
+			result.Tools = provider.ExtractionTools()
+		}
+
+		result.Document = p.extractionDocument(extractor, extractResult)
+		if p.config.OutputFormat == types.OutputFormatMarkdown {
+			result.Text = result.Document.Markdown()
+		}
+
+		// Collect metadata reported by the extractor
+		if provider, ok := extractor.(interfaces.MetadataProvider); ok {
+			if metadata := provider.ExtractionMetadata(); len(metadata) > 0 {
+				result.Metadata = metadata
+			}
 		}
 
 		// Log success
@@ -300,12 +510,37 @@ func (p *DefaultFileProcessor) attemptExtractionWithFallbacks(ctx context.Contex
 		fmt.Sprintf("all extractors failed for file: %s", inputFile))
 }
 
+// extractionDocument returns the structured document of a successful extraction. The
+// extractor's own document is preferred; otherwise (or when the text was reflowed) it
+// is parsed from the text, keeping the page provenance the extractor reported.
+func (p *DefaultFileProcessor) extractionDocument(extractor interfaces.Extractor, text string) *document.Document {
+	var doc *document.Document
+	if provider, ok := extractor.(interfaces.DocumentProvider); ok {
+		doc = provider.ExtractionDocument()
+	}
+	if doc == nil || p.config.Reflow {
+		parsed := document.FromText(text)
+		parsed.CopyProvenance(doc)
+		doc = parsed
+	}
+
+	method := document.MethodText
+	switch extractor.Name() {
+	case "calibre", "ebook":
+		method = document.MethodConversion
+	case "ocr":
+		method = document.MethodOCR
+	}
+	doc.SetProvenance(method, extractor.Name())
+	return doc
+}
+
 // extractWithRetry extracts text with retry mechanism
-func (p *DefaultFileProcessor) extractWithRetry(ctx context.Context, extractor interfaces.Extractor, inputFile, extractorName string) (string, error) {
+func (p *DefaultFileProcessor) extractWithRetry(ctx context.Context, extractor interfaces.Extractor, extract extractFunc, extractorName string) (string, error) {
 	var extractedText string
 
 	err := utils.WithRetry(func() error {
-		text, extractErr := extractor.Extract(ctx, inputFile)
+		text, extractErr := extract(ctx, extractor)
 		if extractErr != nil {
 			return utils.WrapError(extractErr, utils.ErrorTypeOCR, fmt.Sprintf("extractor '%s' failed", extractorName))
 		}
@@ -316,6 +551,27 @@ func (p *DefaultFileProcessor) extractWithRetry(ctx context.Context, extractor i
 	return extractedText, err
 }
 
+// saveOutput writes the result to the output file in the configured format, and its
+// metadata and chunks to the sidecars next to it, then adds it to the search index
+func (p *DefaultFileProcessor) saveOutput(result *interfaces.ExtractionResult, outputFile string) error {
+	content, err := RenderOutput(result, p.config.OutputFormat)
+	if err != nil {
+		return err
+	}
+	if err := p.saveToFileWithRetry(string(content), outputFile); err != nil {
+		return utils.WrapError(err, utils.ErrorTypeIO, "failed to save output file")
+	}
+	p.logger.ProgressAlways("💾", "Text saved to: %s", outputFile)
+	if err := p.saveMetadata(result, outputFile); err != nil {
+		return err
+	}
+	if err := p.saveChunks(result, outputFile); err != nil {
+		return err
+	}
+	p.updateIndex(result, outputFile)
+	return nil
+}
+
 // saveToFileWithRetry saves text content to a file with retry logic
 func (p *DefaultFileProcessor) saveToFileWithRetry(text, outputFile string) error {
 	return utils.WithRetry(func() error {
@@ -355,7 +611,7 @@ func (p *DefaultFileProcessor) saveToFile(text, outputFile string) error {
 
 	// Write text to file
 	p.logger.Debug("Writing %d characters to file: %s", len(text), outputFile)
-	if err := os.WriteFile(outputFile, []byte(text), constants.DefaultFilePermission); err != nil {
+	if err := utils.WriteFileAtomic(outputFile, []byte(text), constants.DefaultFilePermission); err != nil {
 		// Provide more detailed error information
 		errorMsg := fmt.Sprintf("failed to write file '%s'", outputFile)
 		if os.IsPermission(err) {
@@ -0,0 +1,97 @@
+package doctotext
+
+import (
+	"os"
+
+	"doc-to-text/pkg/cache"
+	"doc-to-text/pkg/core"
+	"doc-to-text/pkg/ocr"
+	"doc-to-text/pkg/utils"
+)
+
+// CacheDir returns the root of the work directories, or "" when they are kept next
+// to the inputs
+func (e *Extractor) CacheDir() string {
+	return e.config.CacheRoot()
+}
+
+// MigrateWorkDirs moves the {md5} work directories left next to inputs under dirs
+// into the cache, so outputs and intermediate files are reused from there. It
+// returns the number of work directories moved.
+func (e *Extractor) MigrateWorkDirs(dirs []string) (int, error) {
+	return core.MigrateWorkDirs(e.config.CacheRoot(), dirs, e.logger)
+}
+
+// CacheEntries lists the work directories in the cache, most recently written first
+func (e *Extractor) CacheEntries() ([]*cache.Entry, error) {
+	root, err := e.cacheRoot()
+	if err != nil {
+		return nil, err
+	}
+	return cache.List(root)
+}
+
+// CacheEntry returns the work directory of an input file, or of a hash or unique
+// hash prefix
+func (e *Extractor) CacheEntry(ref string) (*cache.Entry, error) {
+	if info, err := os.Stat(ref); err == nil && info.Mode().IsRegular() {
+		hash, err := utils.CalculateFileMD5(ref)
+		if err != nil {
+			return nil, utils.WrapError(err, utils.ErrorTypeIO, "failed to calculate MD5 hash")
+		}
+		dir := e.config.WorkDir(ref, hash)
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			return nil, utils.NewNotFoundError("no cache entry for "+ref, err)
+		}
+		return cache.Load(dir), nil
+	}
+	root, err := e.cacheRoot()
+	if err != nil {
+		return nil, err
+	}
+	return cache.Find(root, ref)
+}
+
+// FailedPages returns the PDF pages of an input file whose recognition failed in
+// an earlier run, as recorded in its work directory
+func (e *Extractor) FailedPages(path string) ([]ocr.PageState, error) {
+	hash, err := utils.CalculateFileMD5(path)
+	if err != nil {
+		return nil, utils.WrapError(err, utils.ErrorTypeIO, "failed to calculate MD5 hash")
+	}
+	fileManager := e.callConfig().CreateFileManager(path, hash, e.logger)
+	states := ocr.ReadPageStates(fileManager.GetPageStatePath())
+	if states == nil {
+		return nil, nil
+	}
+	return states.Failed(), nil
+}
+
+// PruneCache removes cache entries, or only their page images and other
+// rebuildable files, as selected by opts
+func (e *Extractor) PruneCache(opts cache.PruneOptions) (*cache.PruneResult, error) {
+	root, err := e.cacheRoot()
+	if err != nil {
+		return nil, err
+	}
+	return cache.Prune(root, opts)
+}
+
+// PurgeCache removes every entry in the cache
+func (e *Extractor) PurgeCache(dryRun bool) (*cache.PruneResult, error) {
+	root, err := e.cacheRoot()
+	if err != nil {
+		return nil, err
+	}
+	return cache.Purge(root, dryRun)
+}
+
+// cacheRoot returns the cache root, failing when work directories are kept next
+// to the inputs
+func (e *Extractor) cacheRoot() (string, error) {
+	root := e.config.CacheRoot()
+	if root == "" {
+		return "", utils.NewValidationError("work directories are kept next to the inputs (--cache-dir local), there is no cache", nil)
+	}
+	return root, nil
+}
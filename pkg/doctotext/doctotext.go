@@ -0,0 +1,364 @@
+// Package doctotext is the embeddable API of doc-to-text. It extracts text from
+// documents without exiting the process, prompting on stdin or writing to stdout:
+// errors are returned and log output goes to an injectable logger.
+//
+//	extractor, err := doctotext.New(
+//		doctotext.WithOCR(types.OCRStrategySuryaOCR, ""),
+//		doctotext.WithLogger(logger.NewLoggerWithOutput("info", true, os.Stderr)),
+//	)
+//	if err != nil {
+//		return err
+//	}
+//	result, err := extractor.ExtractFile(ctx, "scan.pdf")
+//
+// Documents held in memory or arriving over the network are read with
+// ExtractReader; a name or MIME type hint identifies their format.
+package doctotext
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"doc-to-text/pkg/chunk"
+	"doc-to-text/pkg/config"
+	"doc-to-text/pkg/constants"
+	"doc-to-text/pkg/core"
+	"doc-to-text/pkg/interfaces"
+	"doc-to-text/pkg/logger"
+	"doc-to-text/pkg/ocr"
+	"doc-to-text/pkg/types"
+	"doc-to-text/pkg/utils"
+)
+
+// Result is the outcome of one extraction
+type Result = interfaces.ExtractionResult
+
+// Extractor extracts text from documents. It is safe for concurrent use; every call
+// gets its own copy of the configuration and its own file processor. Call
+// ResolveOCRStrategy before processing files in parallel, so they share one OCR tool.
+type Extractor struct {
+	mu          sync.RWMutex // Guards config once the extractor is in use
+	config      *config.Config
+	logger      *logger.Logger
+	interactive bool
+}
+
+// Option configures an Extractor
+type Option func(*Extractor) error
+
+// New creates an Extractor; options are applied in order. Without options it uses
+// the defaults of config.NewConfig, picks an installed OCR tool when OCR is needed
+// and discards log output.
+func New(opts ...Option) (*Extractor, error) {
+	e := &Extractor{
+		config: config.NewConfig(),
+		logger: logger.Discard(),
+	}
+
+	for _, opt := range opts {
+		if err := opt(e); err != nil {
+			return nil, err
+		}
+	}
+
+	// Embedded use never prompts unless WithPrompts was given
+	e.config.Interactive = e.interactive
+
+	if err := e.config.Validate(); err != nil {
+		return nil, utils.WrapError(err, utils.ErrorTypeValidation, "configuration validation failed")
+	}
+	// Building a processor checks settings that depend on the extractor registry
+	if _, err := core.NewFileProcessor(e.config, logger.Discard()); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// WithConfig replaces the configuration. The extractor keeps its own copy.
+func WithConfig(cfg *config.Config) Option {
+	return func(e *Extractor) error {
+		if cfg == nil {
+			return utils.NewValidationError("config cannot be nil", nil)
+		}
+		copied := *cfg
+		e.config = &copied
+		return nil
+	}
+}
+
+// WithEnvOverrides applies the DOC_TEXT_* environment variables to the configuration
+func WithEnvOverrides() Option {
+	return func(e *Extractor) error {
+		e.config = config.LoadConfigWithEnvOverrides()
+		return nil
+	}
+}
+
+// WithLogger sets the logger receiving progress and diagnostic output
+func WithLogger(log *logger.Logger) Option {
+	return func(e *Extractor) error {
+		if log == nil {
+			return utils.NewValidationError("logger cannot be nil", nil)
+		}
+		e.logger = log
+		return nil
+	}
+}
+
+// WithPrompts allows asking the user on stdin, e.g. to pick an OCR tool. Only meant
+// for terminal programs such as the doc-to-text CLI.
+func WithPrompts() Option {
+	return func(e *Extractor) error {
+		e.interactive = true
+		return nil
+	}
+}
+
+// WithOCR selects the OCR tool; the LLM template is required for llm-caller
+func WithOCR(strategy types.OCRStrategy, llmTemplate string) Option {
+	return func(e *Extractor) error {
+		if strategy == types.OCRStrategyLLMCaller && llmTemplate == "" {
+			return utils.NewValidationError("LLM template is required when using llm-caller OCR strategy", nil)
+		}
+		e.config.OCRStrategy = strategy
+		e.config.LLMTemplate = llmTemplate
+		return nil
+	}
+}
+
+// WithContentType sets how PDFs are processed (text-first or OCR)
+func WithContentType(contentType types.ContentType) Option {
+	return func(e *Extractor) error {
+		e.config.ContentType = contentType
+		return nil
+	}
+}
+
+// WithLanguages sets OCR language hints as ISO 639 codes
+func WithLanguages(langs ...string) Option {
+	return func(e *Extractor) error {
+		e.config.OCRLanguages = utils.NormalizeLanguages(langs)
+		return nil
+	}
+}
+
+// WithOutputFormat selects the format of Result.Text and written output files
+func WithOutputFormat(format types.OutputFormat) Option {
+	return func(e *Extractor) error {
+		parsed, ok := types.ParseOutputFormat(string(format))
+		if !ok {
+			return utils.NewValidationError(fmt.Sprintf("invalid output format '%s' (expected text, markdown, json or jsonl)", format), nil)
+		}
+		e.config.OutputFormat = parsed
+		return nil
+	}
+}
+
+// WithChunking splits the text into chunks for RAG ingestion; written outputs get a
+// ".chunks.jsonl" sidecar. A size of 0 disables chunking.
+func WithChunking(size, overlap int, unit types.ChunkUnit) Option {
+	return func(e *Extractor) error {
+		e.config.ChunkSize = size
+		e.config.ChunkOverlap = overlap
+		e.config.ChunkUnit = unit
+		if size == 0 {
+			return nil
+		}
+		return e.config.ChunkOptions().Validate()
+	}
+}
+
+// WithIndexDir sets the search index directory. Once an index exists there, every
+// saved output is added to it.
+func WithIndexDir(dir string) Option {
+	return func(e *Extractor) error {
+		e.config.IndexDir = dir
+		return nil
+	}
+}
+
+// WithCacheDir sets the root of the work directories holding intermediate files and
+// cached outputs; cache.Local keeps them next to the inputs
+func WithCacheDir(dir string) Option {
+	return func(e *Extractor) error {
+		e.config.CacheDir = dir
+		return nil
+	}
+}
+
+// WithTimeout limits the time spent on one file
+func WithTimeout(timeout time.Duration) Option {
+	return func(e *Extractor) error {
+		minutes := int((timeout + time.Minute - 1) / time.Minute)
+		if minutes < 1 {
+			return utils.NewValidationError("timeout must be at least 1 minute", nil)
+		}
+		e.config.TimeoutMinutes = minutes
+		return nil
+	}
+}
+
+// WithSkipExisting reuses an existing output file instead of extracting again
+func WithSkipExisting(skip bool) Option {
+	return func(e *Extractor) error {
+		e.config.SkipExisting = skip
+		return nil
+	}
+}
+
+// WithRetryFailedPages recognizes PDF pages that failed in an earlier run again.
+// Without it a resumed run keeps them failed. Existing outputs are not reused, as
+// they lack the failed pages.
+func WithRetryFailedPages() Option {
+	return func(e *Extractor) error {
+		e.config.RetryFailedPages = true
+		e.config.SkipExisting = false
+		return nil
+	}
+}
+
+// WithOnLocked sets what happens when another process is extracting the same
+// document: wait for it and extract (the default), skip the document with an
+// utils.ErrorTypeLocked error, or wait and reuse its result
+func WithOnLocked(mode types.LockMode) Option {
+	return func(e *Extractor) error {
+		parsed, ok := types.ParseLockMode(string(mode))
+		if !ok {
+			return utils.NewValidationError(fmt.Sprintf("invalid lock mode '%s' (expected wait, skip or piggyback)", mode), nil)
+		}
+		e.config.OnLocked = parsed
+		return nil
+	}
+}
+
+// Render renders a result in an output format exactly as it is written to output
+// files: the text for text and Markdown, the full result for JSON, one record per
+// page for JSONL
+func Render(result *Result, format types.OutputFormat) ([]byte, error) {
+	return core.RenderOutput(result, format)
+}
+
+// RenderChunks splits the text of a result into chunks and renders them as JSONL,
+// exactly as the chunk sidecar of output files is written
+func RenderChunks(result *Result, opts chunk.Options) ([]byte, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	return core.RenderChunks(result, opts)
+}
+
+// Config returns a copy of the effective configuration
+func (e *Extractor) Config() config.Config {
+	return *e.callConfig()
+}
+
+// callConfig returns a copy of the configuration for one call, so what a call
+// settles on the way, such as an OCR tool picked for it, stays its own
+func (e *Extractor) callConfig() *config.Config {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	cfg := *e.config
+	return &cfg
+}
+
+// ResolveOCRStrategy settles an interactive OCR strategy once: with WithPrompts the
+// user picks one of the installed tools, otherwise the first installed one is used.
+// Later calls all use it; without it every call needing OCR picks a tool itself.
+func (e *Extractor) ResolveOCRStrategy() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.config.OCRStrategy != types.OCRStrategyInteractive {
+		return nil
+	}
+	strategy, err := ocr.SelectStrategy(e.config, e.logger)
+	if err != nil {
+		return err
+	}
+	e.config.OCRStrategy = strategy
+	return nil
+}
+
+// ExtractFile extracts the text of inputPath without writing an output file.
+// Intermediate files (page splits, OCR caches) and the text are still kept in the
+// document's work directory (under the cache root, see WithCacheDir), so repeated
+// calls are fast.
+func (e *Extractor) ExtractFile(ctx context.Context, inputPath string) (*Result, error) {
+	return e.ExtractFileTo(ctx, inputPath, "")
+}
+
+// ExtractFileTo extracts the text of inputPath and also saves it to outputPath.
+// Recoverable failures are retried; the configured timeout applies to the whole call.
+// When ctx is cancelled the error has type utils.ErrorTypeInterrupted, and the pages
+// finished so far are saved to text.partial<ext> in the work directory. Documents
+// skipped because another process is extracting them fail with
+// utils.ErrorTypeLocked, see WithOnLocked.
+func (e *Extractor) ExtractFileTo(ctx context.Context, inputPath, outputPath string) (*Result, error) {
+	cfg := e.callConfig()
+	processor, err := core.NewFileProcessor(cfg, e.logger)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(cfg.TimeoutMinutes)*time.Minute)
+	defer cancel()
+
+	var result *Result
+	err = utils.WithRetry(func() error {
+		var processErr error
+		result, processErr = processor.ProcessFile(ctx, inputPath, outputPath)
+		if utils.IsInterrupted(processErr) || utils.IsLocked(processErr) {
+			return processErr
+		}
+		if processErr != nil {
+			return utils.WrapError(processErr, utils.ErrorTypeOCR, "file processing failed")
+		}
+
+		if result.Error != "" {
+			return utils.NewOCRError(result.Error, nil)
+		}
+		return nil
+	}, constants.DefaultMaxRetries, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// ExtractReader extracts the text of a document read from r. The hint's name
+// (e.g. "scan.pdf") or MIME type selects the extractor. Text, HTML and EPUB are
+// parsed in memory; formats needing external tools are spooled to a temporary
+// file that is removed afterwards, while their intermediate files and the text are
+// kept in the cache under the stream's MD5.
+func (e *Extractor) ExtractReader(ctx context.Context, r io.Reader, hint types.SourceHint) (*Result, error) {
+	return e.ExtractReaderTo(ctx, r, hint, "")
+}
+
+// ExtractReaderTo is ExtractReader that also saves the text to outputPath. The
+// stream is read once, so the call is not retried as a whole; extractors still
+// retry recoverable failures on the buffered input.
+func (e *Extractor) ExtractReaderTo(ctx context.Context, r io.Reader, hint types.SourceHint, outputPath string) (*Result, error) {
+	cfg := e.callConfig()
+	processor, err := core.NewFileProcessor(cfg, e.logger)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(cfg.TimeoutMinutes)*time.Minute)
+	defer cancel()
+
+	result, err := processor.ProcessReader(ctx, r, hint, outputPath)
+	if utils.IsInterrupted(err) || utils.IsLocked(err) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, utils.WrapError(err, utils.ErrorTypeOCR, "stream processing failed")
+	}
+	if result.Error != "" {
+		return nil, utils.NewOCRError(result.Error, nil)
+	}
+	return result, nil
+}
@@ -0,0 +1,34 @@
+package doctotext
+
+import (
+	"doc-to-text/pkg/core"
+	"doc-to-text/pkg/search"
+	"doc-to-text/pkg/utils"
+)
+
+// IndexStats counts what an index run did
+type IndexStats = core.IndexStats
+
+// Index adds the outputs in the {md5} work directories under dirs to the search
+// index, mapping each hash to the input files recorded in or next to its work
+// directory. Without dirs the cache is indexed. Unchanged outputs are skipped;
+// rebuild starts from an empty index.
+func (e *Extractor) Index(dirs []string, rebuild bool) (IndexStats, error) {
+	if len(dirs) == 0 {
+		root := e.config.CacheRoot()
+		if root == "" {
+			return IndexStats{}, utils.NewValidationError("no directories given and work directories are kept next to the inputs (--cache-dir local)", nil)
+		}
+		if err := utils.EnsureDir(root); err != nil {
+			return IndexStats{}, utils.WrapError(err, utils.ErrorTypeIO, "failed to create cache directory")
+		}
+		dirs = []string{root}
+	}
+	return core.IndexOutputs(e.config.SearchIndexDir(), dirs, rebuild, e.logger)
+}
+
+// Search ranks the indexed pages matching a query with BM25 and returns the best
+// documents with their matching pages and snippets
+func (e *Extractor) Search(query string, opts search.Options) ([]search.Result, error) {
+	return core.SearchIndex(e.config.SearchIndexDir(), query, opts)
+}
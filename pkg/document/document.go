@@ -0,0 +1,87 @@
+// Package document is the structured form of an extraction result: pages made of
+// typed blocks (headings, paragraphs, list items, tables, captions) with per-page
+// provenance. Renderers turn a document into plain text, Markdown or JSON.
+package document
+
+// BlockType is the kind of a content block
+type BlockType string
+
+const (
+	BlockHeading   BlockType = "heading"
+	BlockParagraph BlockType = "paragraph"
+	BlockListItem  BlockType = "list_item"
+	BlockTable     BlockType = "table"
+	BlockCaption   BlockType = "caption"
+	BlockCode      BlockType = "code"
+	BlockQuote     BlockType = "quote"
+)
+
+// Page extraction methods
+const (
+	MethodText       = "text"       // Read from a text-based source (text, HTML, EPUB)
+	MethodOCR        = "ocr"        // Recognized from a page image
+	MethodConversion = "conversion" // Converted by an external tool such as Calibre
+)
+
+// Block is one unit of content on a page
+type Block struct {
+	Type    BlockType  `json:"type"`
+	Text    string     `json:"text,omitempty"`
+	Level   int        `json:"level,omitempty"`   // Heading level 1-6, or list nesting depth (0 is top level)
+	Ordered bool       `json:"ordered,omitempty"` // Numbered list item
+	Rows    [][]string `json:"rows,omitempty"`    // Table cells; the first row is the header
+	Links   []Link     `json:"links,omitempty"`   // Hyperlinks in Text, in order of appearance
+}
+
+// Link is a hyperlink whose anchor text appears in a block's text
+type Link struct {
+	Text string `json:"text"`
+	URL  string `json:"url"`
+}
+
+// Page is a page of the source (or the whole source when it has no pages)
+type Page struct {
+	Number     int     `json:"number"`
+	Method     string  `json:"method,omitempty"`
+	Engine     string  `json:"engine,omitempty"`
+	Confidence float64 `json:"confidence,omitempty"` // Mean OCR confidence, 0 when unknown
+	Blocks     []Block `json:"blocks"`
+}
+
+// Document is the structured content of an extracted file
+type Document struct {
+	Paged bool   `json:"paged,omitempty"` // Source has real pages (PDF); text output carries page markers
+	Pages []Page `json:"pages"`
+}
+
+// SetProvenance fills in the method and engine of pages that do not have one
+func (d *Document) SetProvenance(method, engine string) {
+	for i := range d.Pages {
+		if d.Pages[i].Method == "" {
+			d.Pages[i].Method = method
+		}
+		if d.Pages[i].Engine == "" {
+			d.Pages[i].Engine = engine
+		}
+	}
+}
+
+// CopyProvenance copies method, engine and confidence of pages with the same number
+// from another document, e.g. after rebuilding blocks from post-processed text
+func (d *Document) CopyProvenance(from *Document) {
+	if from == nil {
+		return
+	}
+	byNumber := make(map[int]Page, len(from.Pages))
+	for _, page := range from.Pages {
+		byNumber[page.Number] = page
+	}
+	for i := range d.Pages {
+		if source, ok := byNumber[d.Pages[i].Number]; ok {
+			d.Pages[i].Method = source.Method
+			d.Pages[i].Engine = source.Engine
+			d.Pages[i].Confidence = source.Confidence
+		}
+	}
+	d.Paged = d.Paged || from.Paged
+}
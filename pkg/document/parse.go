@@ -0,0 +1,162 @@
+package document
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	pageMarkerPattern = regexp.MustCompile(`(?m)^--- Page (\d+) ---[ \t]*$`)
+	headingPattern    = regexp.MustCompile(`^(#{1,6})\s+(.+?)\s*#*$`)
+	bulletPattern     = regexp.MustCompile(`^(\s*)[-*+•]\s+(.+)$`)
+	numberedPattern   = regexp.MustCompile(`^(\s*)\d{1,3}[.)]\s+(.+)$`)
+	tableRulePattern  = regexp.MustCompile(`^\|?\s*:?-{3,}:?\s*(\|\s*:?-{3,}:?\s*)*\|?$`)
+)
+
+// FromText builds a document from extracted text. "--- Page N ---" markers start
+// new pages; text without markers becomes a single page.
+func FromText(text string) *Document {
+	markers := pageMarkerPattern.FindAllStringSubmatchIndex(text, -1)
+	if len(markers) == 0 {
+		return &Document{Pages: []Page{{Number: 1, Blocks: ParseBlocks(text)}}}
+	}
+
+	doc := &Document{Paged: true}
+	if leading := strings.TrimSpace(text[:markers[0][0]]); leading != "" {
+		doc.Pages = append(doc.Pages, Page{Number: 0, Blocks: ParseBlocks(leading)})
+	}
+	for i, marker := range markers {
+		number, _ := strconv.Atoi(text[marker[2]:marker[3]])
+		end := len(text)
+		if i+1 < len(markers) {
+			end = markers[i+1][0]
+		}
+		doc.Pages = append(doc.Pages, Page{Number: number, Blocks: ParseBlocks(text[marker[1]:end])})
+	}
+	return doc
+}
+
+// ParseBlocks splits text into blocks at blank lines and recognizes Markdown-style
+// headings, list items, tables, code fences and quotes; everything else is a paragraph
+func ParseBlocks(text string) []Block {
+	var blocks []Block
+	var paragraph []string
+	var listIndents []int // Indents of the open list levels
+
+	flush := func() {
+		if len(paragraph) > 0 {
+			blocks = append(blocks, Block{Type: BlockParagraph, Text: strings.Join(paragraph, "\n")})
+			paragraph = nil
+		}
+	}
+
+	// listLevel returns the nesting depth of a list item relative to the open list levels
+	listLevel := func(indent string) int {
+		width := indentWidth(indent)
+		for len(listIndents) > 0 && listIndents[len(listIndents)-1] > width {
+			listIndents = listIndents[:len(listIndents)-1]
+		}
+		if len(listIndents) == 0 || listIndents[len(listIndents)-1] < width {
+			listIndents = append(listIndents, width)
+		}
+		return len(listIndents) - 1
+	}
+
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], " \t")
+		trimmed := strings.TrimSpace(line)
+		if !bulletPattern.MatchString(line) && !numberedPattern.MatchString(line) {
+			listIndents = nil
+		}
+
+		switch {
+		case trimmed == "":
+			flush()
+
+		case strings.HasPrefix(trimmed, "```"):
+			flush()
+			var code []string
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), "```"); i++ {
+				code = append(code, lines[i])
+			}
+			blocks = append(blocks, Block{Type: BlockCode, Text: strings.Join(code, "\n")})
+
+		case headingPattern.MatchString(trimmed):
+			flush()
+			match := headingPattern.FindStringSubmatch(trimmed)
+			blocks = append(blocks, Block{Type: BlockHeading, Level: len(match[1]), Text: match[2]})
+
+		case strings.HasPrefix(trimmed, "|") && i+1 < len(lines) && tableRulePattern.MatchString(strings.TrimSpace(lines[i+1])):
+			flush()
+			rows := [][]string{splitTableRow(trimmed)}
+			for i += 2; i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), "|"); i++ {
+				rows = append(rows, splitTableRow(strings.TrimSpace(lines[i])))
+			}
+			i--
+			blocks = append(blocks, Block{Type: BlockTable, Rows: rows})
+
+		case bulletPattern.MatchString(line):
+			flush()
+			match := bulletPattern.FindStringSubmatch(line)
+			blocks = append(blocks, Block{Type: BlockListItem, Level: listLevel(match[1]), Text: match[2]})
+
+		case numberedPattern.MatchString(line):
+			flush()
+			match := numberedPattern.FindStringSubmatch(line)
+			blocks = append(blocks, Block{Type: BlockListItem, Level: listLevel(match[1]), Ordered: true, Text: match[2]})
+
+		case strings.HasPrefix(trimmed, ">"):
+			flush()
+			var quote []string
+			for ; i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), ">"); i++ {
+				quote = append(quote, strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(lines[i]), ">")))
+			}
+			i--
+			blocks = append(blocks, Block{Type: BlockQuote, Text: strings.Join(quote, "\n")})
+
+		default:
+			paragraph = append(paragraph, trimmed)
+		}
+	}
+	flush()
+
+	return blocks
+}
+
+// splitTableRow splits a Markdown table row into unescaped cells
+func splitTableRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	line = strings.TrimSuffix(line, "|")
+
+	var cells []string
+	var cell strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && i+1 < len(line) && line[i+1] == '|':
+			cell.WriteByte('|')
+			i++
+		case line[i] == '|':
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(line[i])
+		}
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
+
+// indentWidth returns the width of leading whitespace, counting tabs as four columns
+func indentWidth(indent string) int {
+	width := 0
+	for _, r := range indent {
+		if r == '\t' {
+			width += 4
+		} else {
+			width++
+		}
+	}
+	return width
+}
@@ -0,0 +1,171 @@
+package document
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"doc-to-text/pkg/tables"
+)
+
+// Text renders the document as plain text. Paged documents keep the
+// "--- Page N ---" markers other tools rely on; tables stay Markdown tables.
+func (d *Document) Text() string {
+	return d.render(func(page Page) string {
+		return fmt.Sprintf("--- Page %d ---\n", page.Number)
+	}, renderTextBlocks)
+}
+
+// Markdown renders the document as Markdown. Page boundaries of paged documents
+// become HTML comments so they survive without affecting the rendering.
+func (d *Document) Markdown() string {
+	return d.render(func(page Page) string {
+		return fmt.Sprintf("<!-- Page %d -->\n\n", page.Number)
+	}, renderMarkdownBlocks)
+}
+
+// JSON renders the document as indented JSON
+func (d *Document) JSON() ([]byte, error) {
+	return json.MarshalIndent(d, "", "  ")
+}
+
+// render joins the pages, prefixing each with its marker when the document is paged
+func (d *Document) render(marker func(Page) string, blocks func([]Block) string) string {
+	var builder strings.Builder
+	for _, page := range d.Pages {
+		body := blocks(page.Blocks)
+		if d.Paged && page.Number > 0 {
+			builder.WriteString(marker(page))
+		} else if body == "" {
+			continue
+		}
+		builder.WriteString(body)
+		builder.WriteString("\n\n")
+	}
+	return strings.TrimSpace(builder.String())
+}
+
+// renderTextBlocks renders blocks as plain text; list items stay on consecutive lines
+func renderTextBlocks(blocks []Block) string {
+	return joinBlocks(blocks, func(block Block, number int) string {
+		switch block.Type {
+		case BlockListItem:
+			marker := "-"
+			if block.Ordered {
+				marker = fmt.Sprintf("%d.", number)
+			}
+			return strings.Repeat("  ", block.Level) + marker + " " + block.Text
+		case BlockTable:
+			return tables.NewTable(block.Rows).Markdown()
+		default:
+			return block.Text
+		}
+	})
+}
+
+// renderMarkdownBlocks renders blocks as Markdown
+func renderMarkdownBlocks(blocks []Block) string {
+	return joinBlocks(blocks, func(block Block, number int) string {
+		switch block.Type {
+		case BlockHeading:
+			level := block.Level
+			if level < 1 || level > 6 {
+				level = 1
+			}
+			return strings.Repeat("#", level) + " " + linkify(block)
+		case BlockListItem:
+			marker := "-"
+			if block.Ordered {
+				marker = fmt.Sprintf("%d.", number)
+			}
+			// Four spaces nest below both bullets and numbers
+			return strings.Repeat("    ", block.Level) + marker + " " + linkify(block)
+		case BlockTable:
+			return tables.NewTable(block.Rows).Markdown()
+		case BlockCode:
+			return "```\n" + block.Text + "\n```"
+		case BlockQuote:
+			return "> " + strings.ReplaceAll(linkify(block), "\n", "\n> ")
+		case BlockCaption:
+			return "*" + linkify(block) + "*"
+		default:
+			return linkify(block)
+		}
+	})
+}
+
+// linkify turns the anchor texts of a block's links into Markdown links, matching
+// them in order of appearance
+func linkify(block Block) string {
+	if len(block.Links) == 0 {
+		return block.Text
+	}
+
+	var builder strings.Builder
+	rest := block.Text
+	for _, link := range block.Links {
+		index := strings.Index(rest, link.Text)
+		if link.Text == "" || index < 0 {
+			continue
+		}
+		builder.WriteString(rest[:index])
+		builder.WriteString("[" + link.Text + "](" + link.URL + ")")
+		rest = rest[index+len(link.Text):]
+	}
+	builder.WriteString(rest)
+	return builder.String()
+}
+
+// joinBlocks separates blocks by blank lines and consecutive list items by single
+// line breaks. render receives the 1-based number of an item within its list level.
+func joinBlocks(blocks []Block, render func(block Block, number int) string) string {
+	var builder strings.Builder
+	counters := map[int]int{}
+	for i, block := range blocks {
+		if block.Type == BlockListItem {
+			counters[block.Level]++
+			// A shallower item ends the numbering of deeper levels
+			for level := range counters {
+				if level > block.Level {
+					delete(counters, level)
+				}
+			}
+		} else {
+			counters = map[int]int{}
+		}
+
+		if i > 0 {
+			if block.Type == BlockListItem && blocks[i-1].Type == BlockListItem {
+				builder.WriteString("\n")
+			} else {
+				builder.WriteString("\n\n")
+			}
+		}
+		builder.WriteString(render(block, counters[block.Level]))
+	}
+	return builder.String()
+}
+
+// PageText is the plain text of one page with its provenance
+type PageText struct {
+	Number     int     `json:"page"`
+	Method     string  `json:"method,omitempty"`
+	Engine     string  `json:"engine,omitempty"`
+	Confidence float64 `json:"confidence,omitempty"`
+	Text       string  `json:"text"`
+}
+
+// PageTexts renders every page as plain text
+func (d *Document) PageTexts() []PageText {
+	pages := make([]PageText, 0, len(d.Pages))
+	for _, page := range d.Pages {
+		pages = append(pages, PageText{
+			Number:     page.Number,
+			Method:     page.Method,
+			Engine:     page.Engine,
+			Confidence: page.Confidence,
+			Text:       renderTextBlocks(page.Blocks),
+		})
+	}
+	return pages
+}
@@ -2,7 +2,9 @@ package interfaces
 
 import (
 	"context"
+	"io"
 
+	"doc-to-text/pkg/document"
 	"doc-to-text/pkg/types"
 )
 
@@ -18,18 +20,48 @@ type Extractor interface {
 	Name() string
 }
 
+// ReaderExtractor 可直接从数据流提取文本的提取器（纯文本、HTML、基于zip的格式等）。
+// 未实现该接口的提取器需要文件路径，数据流会先写入受管理的临时目录
+type ReaderExtractor interface {
+	Extractor
+	// SupportsReader 检查该类型能否直接从数据流处理
+	SupportsReader(fileInfo *types.FileInfo) bool
+	// ExtractReader 从数据流中提取文本，fileInfo 描述数据的类型
+	ExtractReader(ctx context.Context, r io.Reader, fileInfo *types.FileInfo) (string, error)
+}
+
+// MetadataProvider 可在提取后提供附加元数据的提取器
+type MetadataProvider interface {
+	// ExtractionMetadata 返回最近一次提取产生的元数据
+	ExtractionMetadata() map[string]interface{}
+}
+
+// ExtractorRegistration 提取器注册信息：声明处理的格式、优先级以及能否作为备选
+type ExtractorRegistration struct {
+	Name      string    // 注册名称，用于配置覆盖（如 pdf=calibre,ocr）
+	Extractor Extractor // 提取器实例
+	Formats   []string  // 处理的格式（扩展名，不含点）
+	MimeTypes []string  // 处理的MIME类型
+	Priority  int       // 优先级，数值越大越先尝试
+	Fallback  bool      // 主提取器失败后可作为备选，也用于未知格式
+	// ContentTypes 仅在配置的内容类型匹配时才处理的格式（如 PDF 在 text 时交给 Calibre，在 image 时交给 OCR）
+	ContentTypes map[string]types.ContentType
+}
+
 // ExtractorFactory 提取器工厂接口
 type ExtractorFactory interface {
 	// CreateExtractorWithFallbacks 创建带备选的提取器链
 	CreateExtractorWithFallbacks(fileInfo *types.FileInfo) ([]Extractor, error)
-	// RegisterExtractor 注册新的提取器
-	RegisterExtractor(name string, extractor Extractor)
+	// Register 注册提取器，新增格式只需一次注册
+	Register(registration ExtractorRegistration)
 }
 
 // FileProcessor 文件处理器接口
 type FileProcessor interface {
 	// ProcessFile 处理文件并返回提取结果
 	ProcessFile(ctx context.Context, inputFile, outputFile string) (*ExtractionResult, error)
+	// ProcessReader 处理数据流并返回提取结果，hint 提供名称或MIME类型
+	ProcessReader(ctx context.Context, r io.Reader, hint types.SourceHint, outputFile string) (*ExtractionResult, error)
 }
 
 // === OCR相关接口 ===
@@ -48,6 +80,43 @@ type OCREngine interface {
 	GetDescription() string
 }
 
+// LanguageAwareOCREngine 支持语言提示的OCR引擎
+type LanguageAwareOCREngine interface {
+	OCREngine
+	// SetLanguages 设置语言提示（ISO 639代码），空值表示由引擎自行判断
+	SetLanguages(langs []string)
+}
+
+// DocumentProvider 可提供结构化文档的提取器
+type DocumentProvider interface {
+	// ExtractionDocument 返回最近一次提取生成的结构化文档，无法生成时返回nil
+	ExtractionDocument() *document.Document
+}
+
+// TextLineProvider 可提供带坐标文本行的OCR引擎
+type TextLineProvider interface {
+	// TextLines 返回指定输入文件已识别的文本行及其坐标
+	TextLines(inputPath string) ([]types.TextLine, error)
+}
+
+// PageSizeProvider 可报告文本行坐标所用页面尺寸的OCR引擎
+type PageSizeProvider interface {
+	// PageSize 返回识别指定输入文件时的页面尺寸（像素），即文本行坐标的参照系
+	PageSize(inputPath string) (width, height float64, err error)
+}
+
+// ToolProvider 调用外部工具的提取器，用于在清单中记录工具版本
+type ToolProvider interface {
+	// ExtractionTools 返回最近一次提取调用的外部命令
+	ExtractionTools() []string
+}
+
+// PartialResultProvider 可在提取中断时交付已完成部分的提取器
+type PartialResultProvider interface {
+	// PartialResult 返回最近一次被中断的提取已完成的部分，没有时返回nil
+	PartialResult() *PartialResult
+}
+
 // === 数据结构 ===
 
 // ExtractionResult 提取结果
@@ -55,9 +124,30 @@ type ExtractionResult struct {
 	Text                string                 `json:"text"`
 	Metadata            map[string]interface{} `json:"metadata,omitempty"`
 	Source              string                 `json:"source"`
+	SourceHash          string                 `json:"source_hash,omitempty"`   // 输入内容的MD5，与工作目录名一致
+	DetectedType        string                 `json:"detected_type,omitempty"` // 按内容检测的MIME类型
+	Format              string                 `json:"format,omitempty"`        // 用于选择提取器的格式
 	ExtractorUsed       string                 `json:"extractor_used"`
 	ProcessTime         int64                  `json:"process_time_ms"`
+	ExtractionTime      int64                  `json:"extraction_time_ms,omitempty"` // 成功的提取器耗时
 	Error               string                 `json:"error,omitempty"`
 	FallbackUsed        bool                   `json:"fallback_used,omitempty"`
 	AttemptedExtractors []string               `json:"attempted_extractors,omitempty"`
+	Attempts            []ExtractionAttempt    `json:"attempts,omitempty"` // 提取器链中每次尝试的耗时与错误
+	Tools               []string               `json:"-"`                  // 成功的提取器调用的外部命令
+	Document            *document.Document     `json:"document,omitempty"` // 结构化文档（页面与内容块）
+}
+
+// PartialResult 被中断的提取已完成的部分
+type PartialResult struct {
+	Text       string `json:"-"`
+	PagesDone  int    `json:"pages_done"`  // 已处理的页数
+	TotalPages int    `json:"total_pages"` // 总页数
+}
+
+// ExtractionAttempt 提取器链中的一次尝试
+type ExtractionAttempt struct {
+	Extractor  string `json:"extractor"`
+	DurationMs int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"` // 失败原因，成功时为空
 }
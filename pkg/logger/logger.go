@@ -2,8 +2,10 @@ package logger
 
 import (
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"sync"
 )
 
 // LogLevel represents different log levels
@@ -20,16 +22,29 @@ const (
 type Logger struct {
 	level   LogLevel
 	verbose bool
+	out     io.Writer
+	mu      sync.Mutex
 }
 
-// NewLogger creates a new logger with specified level and verbose mode
+// NewLogger creates a new logger with specified level and verbose mode writing to stdout
 func NewLogger(level string, verbose bool) *Logger {
+	return NewLoggerWithOutput(level, verbose, os.Stdout)
+}
+
+// NewLoggerWithOutput creates a logger writing to out, for embedding in other programs
+func NewLoggerWithOutput(level string, verbose bool, out io.Writer) *Logger {
 	return &Logger{
 		level:   parseLogLevel(level),
 		verbose: verbose,
+		out:     out,
 	}
 }
 
+// Discard returns a logger that drops all output
+func Discard() *Logger {
+	return NewLoggerWithOutput("error", false, io.Discard)
+}
+
 // Debug logs debug information (only in debug mode)
 func (l *Logger) Debug(format string, args ...interface{}) {
 	if l.level <= LevelDebug {
@@ -66,7 +81,7 @@ func (l *Logger) Error(format string, args ...interface{}) {
 // This is for important milestones that users should see regardless of verbose mode
 func (l *Logger) ProgressAlways(emoji, format string, args ...interface{}) {
 	message := fmt.Sprintf(format, args...)
-	fmt.Printf("%s %s\n", emoji, message)
+	l.write(fmt.Sprintf("%s %s\n", emoji, message))
 }
 
 // Progress logs detailed progress information (only in verbose mode)
@@ -74,13 +89,20 @@ func (l *Logger) ProgressAlways(emoji, format string, args ...interface{}) {
 func (l *Logger) Progress(emoji, format string, args ...interface{}) {
 	if l.verbose {
 		message := fmt.Sprintf(format, args...)
-		fmt.Printf("%s %s\n", emoji, message)
+		l.write(fmt.Sprintf("%s %s\n", emoji, message))
 	}
 }
 
 // log outputs formatted log messages
 func (l *Logger) log(level, message string) {
-	fmt.Printf("[%s] %s\n", level, message)
+	l.write(fmt.Sprintf("[%s] %s\n", level, message))
+}
+
+// write sends one complete line to the output so concurrent messages do not interleave
+func (l *Logger) write(line string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	io.WriteString(l.out, line)
 }
 
 // parseLogLevel converts string level to LogLevel
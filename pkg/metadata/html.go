@@ -0,0 +1,96 @@
+package metadata
+
+import (
+	"bytes"
+	"io"
+	"mime/quotedprintable"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// maxHTMLHead limits how much of an HTML document is searched for its <head>
+const maxHTMLHead = 1 << 20
+
+// readHTML reads <title>, <html lang> and the <meta> tags of the document head,
+// including OpenGraph (og:*, article:*) and Dublin Core (dc.*) properties
+func readHTML(r io.ReaderAt, size int64, props *Properties) error {
+	data := make([]byte, min(size, maxHTMLHead))
+	n, err := r.ReadAt(data, 0)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	data = data[:n]
+	// Web archives usually store the page quoted-printable
+	if bytes.Contains(bytes.ToLower(data), []byte("quoted-printable")) {
+		if decoded, err := io.ReadAll(quotedprintable.NewReader(bytes.NewReader(data))); len(decoded) > 0 {
+			data = decoded
+		} else if err != nil {
+			return err
+		}
+	}
+
+	tokenizer := html.NewTokenizer(bytes.NewReader(data))
+	inTitle := false
+	var title strings.Builder
+tokens:
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			break tokens
+		case html.TextToken:
+			if inTitle {
+				title.Write(tokenizer.Text())
+			}
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			switch atom.Lookup(name) {
+			case atom.Title:
+				inTitle = false
+			case atom.Head:
+				break tokens
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := tokenizer.TagName()
+			attrs := make(map[string]string)
+			for hasAttr {
+				var key, value []byte
+				key, value, hasAttr = tokenizer.TagAttr()
+				attrs[strings.ToLower(string(key))] = string(value)
+			}
+			switch atom.Lookup(name) {
+			case atom.Html:
+				props.addRaw("html", "lang", attrs["lang"])
+			case atom.Title:
+				inTitle = title.Len() == 0
+			case atom.Meta:
+				key := firstNonEmpty(attrs["name"], attrs["property"], attrs["http-equiv"], attrs["itemprop"])
+				props.addRaw("html", strings.ToLower(key), attrs["content"])
+			case atom.Body:
+				break tokens
+			}
+		}
+	}
+
+	props.addRaw("html", "title", strings.Join(strings.Fields(title.String()), " "))
+	raw := props.Raw["html"]
+	pick := func(keys ...string) string {
+		for _, key := range keys {
+			if raw[key] != "" {
+				return raw[key]
+			}
+		}
+		return ""
+	}
+
+	setField(&props.Title, pick("title", "og:title", "dc.title", "twitter:title"))
+	setField(&props.Author, pick("author", "article:author", "dc.creator", "byl"))
+	setField(&props.Subject, pick("description", "og:description", "dc.description", "dc.subject"))
+	setField(&props.Creator, pick("generator"))
+	setField(&props.Language, pick("lang", "content-language", "dc.language", "og:locale"))
+	setDate(&props.Created, pick("article:published_time", "dcterms.created", "dc.date", "date", "datepublished"))
+	setDate(&props.Modified, pick("article:modified_time", "og:updated_time", "dcterms.modified", "last-modified", "datemodified"))
+	props.setKeywords(pick("keywords", "news_keywords"))
+	return nil
+}
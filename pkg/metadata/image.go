@@ -0,0 +1,322 @@
+package metadata
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf16"
+)
+
+const (
+	maxTIFFPages   = 100000 // Most image file directories followed in a TIFF
+	maxEXIFValue   = 64 << 10
+	xmpJPEGHeader  = "http://ns.adobe.com/xap/1.0/\x00"
+	xmpPNGKeyword  = "XML:com.adobe.xmp"
+	exifIFDPointer = 0x8769
+)
+
+// exifTags are the TIFF and EXIF tags kept in the raw properties
+var exifTags = map[uint16]string{
+	0x010E: "ImageDescription",
+	0x010F: "Make",
+	0x0110: "Model",
+	0x0131: "Software",
+	0x0132: "DateTime",
+	0x013B: "Artist",
+	0x8298: "Copyright",
+	0x9003: "DateTimeOriginal",
+	0x9004: "DateTimeDigitized",
+	0x9C9B: "XPTitle",
+	0x9C9C: "XPComment",
+	0x9C9D: "XPAuthor",
+	0x9C9E: "XPKeywords",
+	0x9C9F: "XPSubject",
+}
+
+// readEXIF reads the EXIF properties of JPEG and TIFF images; TIFF page counts
+// come from the number of image file directories
+func readEXIF(r io.ReaderAt, size int64, props *Properties) error {
+	header := make([]byte, 4)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return err
+	}
+
+	if header[0] == 0xFF && header[1] == 0xD8 {
+		if err := readJPEGSegments(r, size, props); err != nil {
+			return err
+		}
+	} else {
+		pages, err := readTIFF(r, size, props)
+		if err != nil {
+			return err
+		}
+		props.PageCount = pages
+	}
+
+	raw := props.Raw["exif"]
+	setField(&props.Title, firstNonEmpty(raw["XPTitle"], raw["ImageDescription"]))
+	setField(&props.Author, firstNonEmpty(raw["Artist"], raw["XPAuthor"]))
+	setField(&props.Subject, raw["XPSubject"])
+	setField(&props.Creator, raw["Software"])
+	setDate(&props.Created, firstNonEmpty(raw["DateTimeOriginal"], raw["DateTimeDigitized"]))
+	setDate(&props.Modified, raw["DateTime"])
+	props.setKeywords(raw["XPKeywords"])
+	return nil
+}
+
+// readJPEGSegments reads the EXIF and XMP segments before the image data
+func readJPEGSegments(r io.ReaderAt, size int64, props *Properties) error {
+	marker := make([]byte, 4)
+	for offset := int64(2); offset+4 <= size; {
+		if _, err := r.ReadAt(marker, offset); err != nil {
+			return err
+		}
+		if marker[0] != 0xFF {
+			return fmt.Errorf("invalid JPEG marker at offset %d", offset)
+		}
+		// Start of scan: no more metadata segments
+		if marker[1] == 0xDA || marker[1] == 0xD9 {
+			return nil
+		}
+		length := int64(binary.BigEndian.Uint16(marker[2:]))
+		if marker[1] == 0xE1 && length > 2 {
+			segment := make([]byte, length-2)
+			if _, err := r.ReadAt(segment, offset+4); err != nil {
+				return err
+			}
+			switch {
+			case bytes.HasPrefix(segment, []byte("Exif\x00\x00")):
+				exif := segment[6:]
+				if _, err := readTIFF(bytes.NewReader(exif), int64(len(exif)), props); err != nil {
+					return err
+				}
+			case bytes.HasPrefix(segment, []byte(xmpJPEGHeader)):
+				readXMP(segment[len(xmpJPEGHeader):], props)
+			}
+		}
+		offset += 2 + length
+	}
+	return nil
+}
+
+// tiffReader reads image file directories of a TIFF structure
+type tiffReader struct {
+	r     io.ReaderAt
+	size  int64
+	order binary.ByteOrder
+}
+
+// readTIFF reads the tags of the first directory and its EXIF directory, and
+// returns the number of directories (pages)
+func readTIFF(r io.ReaderAt, size int64, props *Properties) (int, error) {
+	header := make([]byte, 8)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return 0, err
+	}
+	t := &tiffReader{r: r, size: size}
+	switch string(header[:2]) {
+	case "II":
+		t.order = binary.LittleEndian
+	case "MM":
+		t.order = binary.BigEndian
+	default:
+		return 0, fmt.Errorf("not a TIFF structure")
+	}
+
+	first := int64(t.order.Uint32(header[4:]))
+	next, err := t.readDirectory(first, props)
+	if err != nil {
+		return 0, err
+	}
+	pages := 1
+	seen := map[int64]bool{first: true}
+	for next != 0 && !seen[next] && pages < maxTIFFPages {
+		seen[next] = true
+		if next, err = t.nextDirectory(next); err != nil {
+			break
+		}
+		pages++
+	}
+	return pages, nil
+}
+
+// readDirectory records the known tags of a directory and follows its EXIF
+// pointer, returning the offset of the next directory
+func (t *tiffReader) readDirectory(offset int64, props *Properties) (int64, error) {
+	entries, next, err := t.entries(offset)
+	if err != nil {
+		return 0, err
+	}
+	for _, entry := range entries {
+		tag := t.order.Uint16(entry)
+		if tag == exifIFDPointer {
+			if _, err := t.readDirectory(int64(t.order.Uint32(entry[8:])), props); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		if name, ok := exifTags[tag]; ok {
+			props.addRaw("exif", name, t.value(entry))
+		}
+	}
+	return next, nil
+}
+
+// nextDirectory returns the offset of the directory after the one at offset
+func (t *tiffReader) nextDirectory(offset int64) (int64, error) {
+	_, next, err := t.entries(offset)
+	return next, err
+}
+
+// entries returns the 12-byte entries of a directory and the next directory offset
+func (t *tiffReader) entries(offset int64) ([][]byte, int64, error) {
+	count := make([]byte, 2)
+	if offset <= 0 || offset+2 > t.size {
+		return nil, 0, fmt.Errorf("invalid TIFF directory offset %d", offset)
+	}
+	if _, err := t.r.ReadAt(count, offset); err != nil {
+		return nil, 0, err
+	}
+	n := int64(t.order.Uint16(count))
+	data := make([]byte, n*12+4)
+	if _, err := t.r.ReadAt(data, offset+2); err != nil {
+		return nil, 0, err
+	}
+
+	entries := make([][]byte, n)
+	for i := range entries {
+		entries[i] = data[i*12 : i*12+12]
+	}
+	return entries, int64(t.order.Uint32(data[n*12:])), nil
+}
+
+// value decodes an ASCII, byte or UTF-16LE (XP*) entry as text
+func (t *tiffReader) value(entry []byte) string {
+	kind := t.order.Uint16(entry[2:])
+	count := int64(t.order.Uint32(entry[4:]))
+	if kind != 1 && kind != 2 && kind != 7 || count <= 0 || count > maxEXIFValue {
+		return ""
+	}
+
+	data := entry[8 : 8+min(count, 4)]
+	if count > 4 {
+		data = make([]byte, count)
+		if _, err := t.r.ReadAt(data, int64(t.order.Uint32(entry[8:]))); err != nil {
+			return ""
+		}
+	}
+
+	// Windows XP tags hold UTF-16LE regardless of the byte order
+	if tag := t.order.Uint16(entry); tag >= 0x9C9B && tag <= 0x9C9F {
+		units := make([]uint16, 0, len(data)/2)
+		for i := 0; i+1 < len(data); i += 2 {
+			units = append(units, binary.LittleEndian.Uint16(data[i:]))
+		}
+		return strings.TrimRight(string(utf16.Decode(units)), "\x00")
+	}
+	return strings.TrimSpace(strings.TrimRight(string(data), "\x00"))
+}
+
+// readPNG reads the tEXt, zTXt and iTXt chunks and embedded EXIF or XMP of a PNG
+func readPNG(r io.ReaderAt, size int64, props *Properties) error {
+	header := make([]byte, 8)
+	for offset := int64(8); offset+8 <= size; {
+		if _, err := r.ReadAt(header, offset); err != nil {
+			return err
+		}
+		length := int64(binary.BigEndian.Uint32(header))
+		kind := string(header[4:])
+		if kind == "IEND" {
+			break
+		}
+
+		if (kind == "tEXt" || kind == "zTXt" || kind == "iTXt" || kind == "eXIf") && length <= maxPartSize {
+			data := make([]byte, length)
+			if _, err := r.ReadAt(data, offset+8); err != nil {
+				return err
+			}
+			if kind == "eXIf" {
+				if _, err := readTIFF(bytes.NewReader(data), length, props); err != nil {
+					return err
+				}
+			} else if keyword, text, ok := pngText(kind, data); ok {
+				if keyword == xmpPNGKeyword {
+					readXMP([]byte(text), props)
+				} else {
+					props.addRaw("png", keyword, text)
+				}
+			}
+		}
+		// Length, type, data and CRC
+		offset += 12 + length
+	}
+
+	raw := props.Raw["png"]
+	setField(&props.Title, raw["Title"])
+	setField(&props.Author, raw["Author"])
+	setField(&props.Subject, raw["Description"])
+	setField(&props.Creator, raw["Software"])
+	setDate(&props.Created, raw["Creation Time"])
+	exif := props.Raw["exif"]
+	setField(&props.Creator, exif["Software"])
+	setDate(&props.Created, firstNonEmpty(exif["DateTimeOriginal"], exif["DateTime"]))
+	return nil
+}
+
+// pngText decodes a textual chunk into its keyword and text
+func pngText(kind string, data []byte) (string, string, bool) {
+	separator := bytes.IndexByte(data, 0)
+	if separator < 0 {
+		return "", "", false
+	}
+	keyword, rest := string(data[:separator]), data[separator+1:]
+
+	switch kind {
+	case "tEXt":
+		// Latin-1 text
+		runes := make([]rune, len(rest))
+		for i, c := range rest {
+			runes[i] = rune(c)
+		}
+		return keyword, string(runes), true
+	case "zTXt":
+		if len(rest) < 1 {
+			return "", "", false
+		}
+		text, err := inflate(rest[1:])
+		return keyword, text, err == nil
+	default:
+		// iTXt: compression flag, method, language tag, translated keyword, text
+		if len(rest) < 2 {
+			return "", "", false
+		}
+		compressed := rest[0] == 1
+		rest = rest[2:]
+		for i := 0; i < 2; i++ {
+			end := bytes.IndexByte(rest, 0)
+			if end < 0 {
+				return "", "", false
+			}
+			rest = rest[end+1:]
+		}
+		if compressed {
+			text, err := inflate(rest)
+			return keyword, text, err == nil
+		}
+		return keyword, string(rest), true
+	}
+}
+
+// inflate decompresses zlib data
+func inflate(data []byte) (string, error) {
+	reader, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+	text, err := io.ReadAll(io.LimitReader(reader, maxPartSize))
+	return string(text), err
+}
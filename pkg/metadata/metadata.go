@@ -0,0 +1,234 @@
+// Package metadata reads document properties (title, author, dates, page count...)
+// from PDF Info dictionaries and XMP, EPUB OPF, OOXML and ODF packages, HTML
+// <meta> tags and image EXIF, and normalizes them into common keys.
+package metadata
+
+import (
+	"io"
+	"os"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Normalized metadata keys
+const (
+	KeyTitle     = "title"
+	KeyAuthor    = "author"
+	KeySubject   = "subject"
+	KeyKeywords  = "keywords"
+	KeyCreated   = "created"
+	KeyModified  = "modified"
+	KeyProducer  = "producer"
+	KeyCreator   = "creator"
+	KeyPageCount = "page_count"
+	KeyLanguage  = "language"
+	KeyWordCount = "word_count"
+	KeyRaw       = "raw"
+)
+
+// Properties are the normalized properties of a document. Raw keeps the original
+// properties per source, e.g. "pdf_info", "xmp", "opf", "core", "html", "exif".
+type Properties struct {
+	Title     string                       `json:"title,omitempty"`
+	Author    string                       `json:"author,omitempty"`
+	Subject   string                       `json:"subject,omitempty"`
+	Keywords  []string                     `json:"keywords,omitempty"`
+	Created   string                       `json:"created,omitempty"`  // RFC 3339 when parseable
+	Modified  string                       `json:"modified,omitempty"` // RFC 3339 when parseable
+	Producer  string                       `json:"producer,omitempty"` // Software that produced the file
+	Creator   string                       `json:"creator,omitempty"`  // Application the document was authored in
+	PageCount int                          `json:"page_count,omitempty"`
+	Language  string                       `json:"language,omitempty"`
+	WordCount int                          `json:"word_count,omitempty"`
+	Raw       map[string]map[string]string `json:"raw,omitempty"`
+}
+
+// ReadFile reads the properties of a file in the given format (e.g. "pdf", "docx")
+func ReadFile(path, format string) (*Properties, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return Read(file, info.Size(), format)
+}
+
+// Read reads the properties of a document in the given format. Formats without
+// embedded properties yield empty properties.
+func Read(r io.ReaderAt, size int64, format string) (*Properties, error) {
+	props := &Properties{}
+	var err error
+
+	switch strings.ToLower(format) {
+	case "pdf":
+		err = readPDF(r, size, props)
+	case "epub":
+		err = readEPUB(r, size, props)
+	case "docx", "xlsx", "pptx":
+		err = readOOXML(r, size, props)
+	case "odt", "ods", "odp":
+		err = readODF(r, size, props)
+	case "html", "htm", "xhtml", "mhtml", "mht":
+		err = readHTML(r, size, props)
+	case "jpg", "jpeg", "tif", "tiff":
+		err = readEXIF(r, size, props)
+	case "png":
+		err = readPNG(r, size, props)
+	}
+
+	return props, err
+}
+
+// Map returns the non-empty properties under their normalized keys
+func (p *Properties) Map() map[string]interface{} {
+	values := make(map[string]interface{})
+	set := func(key, value string) {
+		if value != "" {
+			values[key] = value
+		}
+	}
+	set(KeyTitle, p.Title)
+	set(KeyAuthor, p.Author)
+	set(KeySubject, p.Subject)
+	set(KeyCreated, p.Created)
+	set(KeyModified, p.Modified)
+	set(KeyProducer, p.Producer)
+	set(KeyCreator, p.Creator)
+	set(KeyLanguage, p.Language)
+	if len(p.Keywords) > 0 {
+		values[KeyKeywords] = p.Keywords
+	}
+	if p.PageCount > 0 {
+		values[KeyPageCount] = p.PageCount
+	}
+	if p.WordCount > 0 {
+		values[KeyWordCount] = p.WordCount
+	}
+	if len(p.Raw) > 0 {
+		values[KeyRaw] = p.Raw
+	}
+	return values
+}
+
+// addRaw records an original property of a source
+func (p *Properties) addRaw(source, key, value string) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return
+	}
+	if p.Raw == nil {
+		p.Raw = make(map[string]map[string]string)
+	}
+	if p.Raw[source] == nil {
+		p.Raw[source] = make(map[string]string)
+	}
+	p.Raw[source][key] = value
+}
+
+// setField fills a normalized field unless an earlier source already set it
+func setField(field *string, value string) {
+	if value = strings.TrimSpace(value); *field == "" && value != "" {
+		*field = value
+	}
+}
+
+// setDate fills a normalized date field, converting it to RFC 3339 when possible
+func setDate(field *string, value string) {
+	setField(field, NormalizeDate(value))
+}
+
+// setKeywords fills the keywords from a comma or semicolon separated list
+func (p *Properties) setKeywords(value string) {
+	if len(p.Keywords) > 0 {
+		return
+	}
+	for _, keyword := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ';' }) {
+		if keyword = strings.TrimSpace(keyword); keyword != "" {
+			p.Keywords = append(p.Keywords, keyword)
+		}
+	}
+}
+
+// dateLayouts are the date formats found in document properties
+var dateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006:01:02 15:04:05", // EXIF
+	time.RFC1123Z,         // PNG "Creation Time"
+	time.RFC1123,
+	"20060102150405Z07'00'",
+	"20060102150405Z0700",
+	"20060102150405",
+	"200601021504",
+	"2006010215",
+	"20060102",
+	"200601",
+	"2006",
+}
+
+// NormalizeDate converts ISO 8601, PDF ("D:20240131120000+01'00'") and EXIF dates
+// to RFC 3339; date-only values become "2006-01-02". Unknown formats are returned unchanged.
+func NormalizeDate(value string) string {
+	value = strings.TrimSpace(value)
+	raw := strings.TrimPrefix(value, "D:")
+	// PDF time zones are written as +01'00' or Z00'00'
+	if strings.HasSuffix(raw, "'") {
+		raw = strings.TrimSuffix(raw, "'")
+	}
+	if strings.HasSuffix(raw, "Z00'00") {
+		raw = strings.TrimSuffix(raw, "00'00")
+	}
+	raw = strings.Replace(raw, "'", "", 1)
+
+	if len(raw) == len("2006-01-02") && strings.Count(raw, "-") == 2 {
+		if parsed, err := time.Parse("2006-01-02", raw); err == nil {
+			return parsed.Format("2006-01-02")
+		}
+	}
+	for _, layout := range dateLayouts {
+		if parsed, err := time.Parse(layout, raw); err == nil {
+			if layout == "20060102" || layout == "200601" || layout == "2006" {
+				return parsed.Format("2006-01-02")
+			}
+			return parsed.Format(time.RFC3339)
+		}
+	}
+	return value
+}
+
+// CountWords counts words in text; each CJK character counts as a word
+func CountWords(text string) int {
+	count := 0
+	inWord := false
+	for _, r := range text {
+		switch {
+		case isCJK(r):
+			count++
+			inWord = false
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '\'' && inWord:
+			if !inWord {
+				count++
+				inWord = true
+			}
+		default:
+			inWord = false
+		}
+	}
+	return count
+}
+
+// isCJK reports whether a rune is a Han, Hiragana, Katakana or Hangul character
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r)
+}
@@ -0,0 +1,361 @@
+package metadata
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// maxPartSize limits the XML parts read from packages
+const maxPartSize = 16 << 20
+
+// xmlPrefixes are the conventional prefixes of the namespaces used in raw keys
+var xmlPrefixes = map[string]string{
+	"http://purl.org/dc/elements/1.1/":                                        "dc",
+	"http://purl.org/dc/terms/":                                               "dcterms",
+	"http://ns.adobe.com/xap/1.0/":                                            "xmp",
+	"http://ns.adobe.com/pdf/1.3/":                                            "pdf",
+	"http://ns.adobe.com/photoshop/1.0/":                                      "photoshop",
+	"http://www.idpf.org/2007/opf":                                            "opf",
+	"http://schemas.openxmlformats.org/package/2006/metadata/core-properties": "cp",
+	"urn:oasis:names:tc:opendocument:xmlns:meta:1.0":                          "meta",
+}
+
+// xmlField is an element of a metadata section with its text and attributes
+type xmlField struct {
+	Key   string // prefix:local, or local for unknown namespaces
+	Local string
+	Text  string
+	Attrs map[string]string // Attribute local name -> value
+}
+
+// readXMLFields collects the elements below the first element with the given local
+// name; nested values such as rdf:li items are joined with "; "
+func readXMLFields(data []byte, section string) []xmlField {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = false
+
+	var fields []xmlField
+	var text strings.Builder
+	depth, sectionDepth := 0, -1
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return fields
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			depth++
+			if sectionDepth < 0 {
+				if t.Name.Local == section {
+					sectionDepth = depth
+				}
+				continue
+			}
+			text.Reset()
+			if depth == sectionDepth+1 {
+				field := xmlField{Key: xmlKey(t.Name), Local: t.Name.Local, Attrs: make(map[string]string)}
+				for _, attr := range t.Attr {
+					field.Attrs[attr.Name.Local] = attr.Value
+				}
+				fields = append(fields, field)
+			}
+		case xml.CharData:
+			if sectionDepth >= 0 {
+				text.Write(t)
+			}
+		case xml.EndElement:
+			if sectionDepth >= 0 && depth > sectionDepth && len(fields) > 0 {
+				if value := strings.TrimSpace(text.String()); value != "" {
+					field := &fields[len(fields)-1]
+					if field.Text != "" {
+						field.Text += "; "
+					}
+					field.Text += value
+				}
+				text.Reset()
+			}
+			if depth == sectionDepth {
+				return fields
+			}
+			depth--
+		}
+	}
+}
+
+// xmlKey returns the prefixed name of an element
+func xmlKey(name xml.Name) string {
+	if prefix, ok := xmlPrefixes[name.Space]; ok {
+		return prefix + ":" + name.Local
+	}
+	return name.Local
+}
+
+// readXMP reads the Dublin Core, XMP basic and PDF schemas of an XMP packet
+func readXMP(data []byte, props *Properties) {
+	values := make(map[string]string)
+	for _, description := range xmpDescriptions(data) {
+		for key, value := range description {
+			if values[key] == "" {
+				values[key] = value
+				props.addRaw("xmp", key, value)
+			}
+		}
+	}
+
+	setField(&props.Title, values["dc:title"])
+	setField(&props.Author, values["dc:creator"])
+	setField(&props.Subject, values["dc:description"])
+	setField(&props.Producer, values["pdf:Producer"])
+	setField(&props.Creator, values["xmp:CreatorTool"])
+	setField(&props.Language, values["dc:language"])
+	setDate(&props.Created, values["xmp:CreateDate"])
+	setDate(&props.Modified, values["xmp:ModifyDate"])
+	if subject := values["dc:subject"]; subject != "" {
+		props.setKeywords(subject)
+	}
+	props.setKeywords(values["pdf:Keywords"])
+}
+
+// xmpDescriptions returns the properties of each rdf:Description, written either
+// as attributes or as child elements
+func xmpDescriptions(data []byte) []map[string]string {
+	var descriptions []map[string]string
+	for {
+		start := bytes.Index(data, []byte("Description"))
+		if start < 0 {
+			return descriptions
+		}
+		// Back up to the opening "<rdf:" of the element
+		open := bytes.LastIndexByte(data[:start], '<')
+		if open < 0 || bytes.IndexByte(data[open:start], '/') >= 0 {
+			data = data[start+len("Description"):]
+			continue
+		}
+		description := make(map[string]string)
+		for _, field := range readXMLFields(wrapRDF(data[open:]), "Description") {
+			if field.Text != "" && strings.Contains(field.Key, ":") {
+				description[field.Key] = field.Text
+			}
+		}
+		for key, value := range descriptionAttrs(data[open:]) {
+			description[key] = value
+		}
+		descriptions = append(descriptions, description)
+		data = data[start+len("Description"):]
+	}
+}
+
+// wrapRDF declares the RDF namespace and the prefixes of the known schemas so a
+// description can be parsed on its own
+func wrapRDF(data []byte) []byte {
+	var buffer bytes.Buffer
+	buffer.WriteString(`<x xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#"`)
+	for space, prefix := range xmlPrefixes {
+		fmt.Fprintf(&buffer, ` xmlns:%s=%q`, prefix, space)
+	}
+	buffer.WriteString(">")
+	buffer.Write(data)
+	return buffer.Bytes()
+}
+
+// descriptionAttrs returns the schema properties written as attributes of an rdf:Description
+func descriptionAttrs(data []byte) map[string]string {
+	decoder := xml.NewDecoder(bytes.NewReader(wrapRDF(data)))
+	decoder.Strict = false
+	attrs := make(map[string]string)
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return attrs
+		}
+		if start, ok := token.(xml.StartElement); ok && start.Name.Local == "Description" {
+			for _, attr := range start.Attr {
+				if key := xmlKey(attr.Name); strings.Contains(key, ":") {
+					attrs[key] = strings.TrimSpace(attr.Value)
+				}
+			}
+			return attrs
+		}
+	}
+}
+
+// readEPUB reads the Dublin Core metadata of the OPF package document
+func readEPUB(r io.ReaderAt, size int64, props *Properties) error {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return err
+	}
+
+	container, err := readZipPart(archive, "META-INF/container.xml")
+	if err != nil {
+		return err
+	}
+	var rootfile struct {
+		Rootfiles []struct {
+			Path string `xml:"full-path,attr"`
+		} `xml:"rootfiles>rootfile"`
+	}
+	if err := xml.Unmarshal(container, &rootfile); err != nil || len(rootfile.Rootfiles) == 0 {
+		return fmt.Errorf("no package document in EPUB container")
+	}
+	opf, err := readZipPart(archive, path.Clean(rootfile.Rootfiles[0].Path))
+	if err != nil {
+		return err
+	}
+
+	var creators, subjects []string
+	for _, field := range readXMLFields(opf, "metadata") {
+		switch {
+		case field.Local == "meta":
+			// EPUB 3 <meta property="...">value</meta> and EPUB 2 <meta name="..." content="..."/>
+			if property := field.Attrs["property"]; property != "" && field.Attrs["refines"] == "" {
+				props.addRaw("opf", property, field.Text)
+				if property == "dcterms:modified" {
+					setDate(&props.Modified, field.Text)
+				}
+			} else if name := field.Attrs["name"]; name != "" {
+				props.addRaw("opf", name, field.Attrs["content"])
+				if name == "generator" {
+					setField(&props.Producer, field.Attrs["content"])
+				}
+			}
+		case field.Text == "":
+		case field.Local == "creator":
+			creators = append(creators, field.Text)
+		case field.Local == "subject":
+			subjects = append(subjects, field.Text)
+		default:
+			key := field.Key
+			if event := field.Attrs["event"]; event != "" {
+				key += ":" + event
+			}
+			if props.Raw["opf"][key] == "" {
+				props.addRaw("opf", key, field.Text)
+			}
+		}
+	}
+
+	props.addRaw("opf", "dc:creator", strings.Join(creators, "; "))
+	props.addRaw("opf", "dc:subject", strings.Join(subjects, "; "))
+	raw := props.Raw["opf"]
+	setField(&props.Title, raw["dc:title"])
+	setField(&props.Author, strings.Join(creators, "; "))
+	setField(&props.Subject, raw["dc:description"])
+	setField(&props.Language, raw["dc:language"])
+	setDate(&props.Created, firstNonEmpty(raw["dc:date:publication"], raw["dc:date:creation"], raw["dc:date"]))
+	setDate(&props.Modified, raw["dc:date:modification"])
+	props.Keywords = append(props.Keywords, subjects...)
+	return nil
+}
+
+// readOOXML reads the core (docProps/core.xml) and extended (docProps/app.xml)
+// properties of Word, Excel and PowerPoint documents
+func readOOXML(r io.ReaderAt, size int64, props *Properties) error {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return err
+	}
+
+	if core, err := readZipPart(archive, "docProps/core.xml"); err == nil {
+		for _, field := range readXMLFields(core, "coreProperties") {
+			props.addRaw("core", field.Key, field.Text)
+		}
+		raw := props.Raw["core"]
+		setField(&props.Title, raw["dc:title"])
+		setField(&props.Author, raw["dc:creator"])
+		setField(&props.Subject, firstNonEmpty(raw["dc:subject"], raw["dc:description"]))
+		setField(&props.Language, raw["dc:language"])
+		setDate(&props.Created, raw["dcterms:created"])
+		setDate(&props.Modified, raw["dcterms:modified"])
+		props.setKeywords(raw["cp:keywords"])
+	}
+
+	if app, err := readZipPart(archive, "docProps/app.xml"); err == nil {
+		for _, field := range readXMLFields(app, "Properties") {
+			// Skip vectors such as HeadingPairs and TitlesOfParts
+			if !strings.Contains(field.Text, "; ") {
+				props.addRaw("app", field.Local, field.Text)
+			}
+		}
+		raw := props.Raw["app"]
+		setField(&props.Creator, strings.TrimSpace(raw["Application"]+" "+raw["AppVersion"]))
+		for _, key := range []string{"Pages", "Slides"} {
+			if count, err := strconv.Atoi(raw[key]); err == nil && count > 0 && props.PageCount == 0 {
+				props.PageCount = count
+			}
+		}
+	}
+	return nil
+}
+
+// readODF reads meta.xml of OpenDocument files
+func readODF(r io.ReaderAt, size int64, props *Properties) error {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return err
+	}
+	meta, err := readZipPart(archive, "meta.xml")
+	if err != nil {
+		return err
+	}
+
+	var keywords []string
+	for _, field := range readXMLFields(meta, "meta") {
+		switch field.Key {
+		case "meta:keyword":
+			keywords = append(keywords, field.Text)
+		case "meta:document-statistic":
+			for name, value := range field.Attrs {
+				props.addRaw("odf_meta", "meta:"+name, value)
+			}
+		default:
+			props.addRaw("odf_meta", field.Key, field.Text)
+		}
+	}
+
+	raw := props.Raw["odf_meta"]
+	setField(&props.Title, raw["dc:title"])
+	setField(&props.Author, firstNonEmpty(raw["meta:initial-creator"], raw["dc:creator"]))
+	setField(&props.Subject, firstNonEmpty(raw["dc:subject"], raw["dc:description"]))
+	setField(&props.Language, raw["dc:language"])
+	setField(&props.Creator, raw["meta:generator"])
+	setDate(&props.Created, raw["meta:creation-date"])
+	setDate(&props.Modified, raw["dc:date"])
+	props.Keywords = append(props.Keywords, keywords...)
+	if count, err := strconv.Atoi(firstNonEmpty(raw["meta:page-count"], raw["meta:table-count"])); err == nil && count > 0 {
+		props.PageCount = count
+	}
+	return nil
+}
+
+// readZipPart reads a part of a package by name
+func readZipPart(archive *zip.Reader, name string) ([]byte, error) {
+	for _, file := range archive.File {
+		if file.Name != name {
+			continue
+		}
+		reader, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+		return io.ReadAll(io.LimitReader(reader, maxPartSize))
+	}
+	return nil, fmt.Errorf("%s not found in package", name)
+}
+
+// firstNonEmpty returns the first non-empty value
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if strings.TrimSpace(value) != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,718 @@
+package metadata
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+const (
+	pdfChunkSize   = 1 << 20  // Bytes scanned at a time for object headers
+	pdfOverlap     = 64       // Bytes shared by adjacent chunks so headers are not cut
+	pdfTailSize    = 64 << 10 // Bytes searched for the trailer at the end of the file
+	pdfMaxStream   = 64 << 20 // Largest decoded stream read for metadata
+	pdfMaxNesting  = 32       // Deepest nesting of arrays and dictionaries
+	pdfMaxPrevLink = 32       // Most trailers followed through /Prev
+)
+
+// errPDFTruncated reports that an object runs past the bytes read for it
+var errPDFTruncated = errors.New("truncated PDF object")
+
+// pdfInfoKeys are the standard entries of the document information dictionary
+var pdfInfoKeys = []string{"Title", "Author", "Subject", "Keywords", "Creator", "Producer", "CreationDate", "ModDate", "Trapped"}
+
+type (
+	pdfName string                 // A /Name
+	pdfDict map[string]interface{} // A << >> dictionary
+	pdfRef  struct{ num, gen int } // An indirect reference "N G R"
+)
+
+// pdfFile locates objects in a PDF without loading it into memory
+type pdfFile struct {
+	r          io.ReaderAt
+	size       int64
+	offsets    map[int]int64       // Object number -> offset of its last definition
+	objStreams []int               // Object streams not yet unpacked
+	compressed map[int]interface{} // Objects unpacked from object streams
+}
+
+// readPDF reads the Info dictionary, the XMP packet, the language and the page count
+func readPDF(r io.ReaderAt, size int64, props *Properties) error {
+	f := &pdfFile{r: r, size: size, compressed: make(map[int]interface{})}
+	trailer, err := f.trailer()
+	if err != nil {
+		return err
+	}
+	if err := f.scanObjects(); err != nil {
+		return err
+	}
+
+	// Strings of encrypted files cannot be read without the key
+	encrypted := trailer["Encrypt"] != nil
+	catalog, _ := f.resolve(trailer["Root"], 0).(pdfDict)
+	if catalog != nil {
+		if ref, ok := catalog["Metadata"].(pdfRef); ok && !encrypted {
+			if data := f.streamData(ref.num); data != nil {
+				readXMP(data, props)
+			}
+		}
+		if pages, ok := f.resolve(catalog["Pages"], 0).(pdfDict); ok {
+			if count, ok := f.resolve(pages["Count"], 0).(float64); ok && count > 0 {
+				props.PageCount = int(count)
+			}
+		}
+		if lang, ok := f.resolve(catalog["Lang"], 0).(string); ok && !encrypted {
+			props.addRaw("pdf_info", "Lang", decodePDFText(lang))
+			setField(&props.Language, decodePDFText(lang))
+		}
+	}
+
+	if info, ok := f.resolve(trailer["Info"], 0).(pdfDict); ok && !encrypted {
+		for _, key := range pdfInfoKeys {
+			switch value := f.resolve(info[key], 0).(type) {
+			case string:
+				props.addRaw("pdf_info", key, decodePDFText(value))
+			case pdfName:
+				props.addRaw("pdf_info", key, string(value))
+			}
+		}
+		raw := props.Raw["pdf_info"]
+		setField(&props.Title, raw["Title"])
+		setField(&props.Author, raw["Author"])
+		setField(&props.Subject, raw["Subject"])
+		setField(&props.Producer, raw["Producer"])
+		setField(&props.Creator, raw["Creator"])
+		setDate(&props.Created, raw["CreationDate"])
+		setDate(&props.Modified, raw["ModDate"])
+		props.setKeywords(raw["Keywords"])
+	}
+	return nil
+}
+
+// trailer returns the trailer dictionary, filling missing entries from earlier
+// trailers of incremental updates
+func (f *pdfFile) trailer() (pdfDict, error) {
+	tailSize := min(f.size, pdfTailSize)
+	tail := make([]byte, tailSize)
+	if _, err := f.r.ReadAt(tail, f.size-tailSize); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	trailer := pdfDict{}
+	if i := bytes.LastIndex(tail, []byte("startxref")); i >= 0 {
+		parser := &pdfParser{data: tail, pos: i + len("startxref")}
+		if value, err := parser.value(0); err == nil {
+			if offset, ok := value.(float64); ok {
+				f.mergeTrailers(trailer, int64(offset))
+			}
+		}
+	}
+	// Damaged cross-reference offsets: fall back to the last trailer keyword
+	if len(trailer) == 0 {
+		if i := bytes.LastIndex(tail, []byte("trailer")); i >= 0 {
+			parser := &pdfParser{data: tail, pos: i + len("trailer")}
+			if dict, ok := parser.dictValue(); ok {
+				trailer = dict
+			}
+		}
+	}
+	if len(trailer) == 0 {
+		return nil, fmt.Errorf("no PDF trailer found")
+	}
+	return trailer, nil
+}
+
+// mergeTrailers follows the /Prev chain from a cross-reference section
+func (f *pdfFile) mergeTrailers(trailer pdfDict, offset int64) {
+	for link := 0; link < pdfMaxPrevLink && offset >= 0 && offset < f.size; link++ {
+		dict := f.trailerAt(offset)
+		if dict == nil {
+			return
+		}
+		for key, value := range dict {
+			if _, ok := trailer[key]; !ok {
+				trailer[key] = value
+			}
+		}
+		prev, ok := dict["Prev"].(float64)
+		if !ok {
+			return
+		}
+		offset = int64(prev)
+	}
+}
+
+// trailerAt reads the trailer of a classic cross-reference table or the dictionary
+// of a cross-reference stream at an offset
+func (f *pdfFile) trailerAt(offset int64) pdfDict {
+	head := f.window(offset, 16)
+	if bytes.HasPrefix(bytes.TrimLeft(head, " \t\r\n"), []byte("xref")) {
+		position := f.indexFrom(offset, []byte("trailer"))
+		if position < 0 {
+			return nil
+		}
+		parser := &pdfParser{data: f.window(position+int64(len("trailer")), 64<<10)}
+		dict, _ := parser.dictValue()
+		return dict
+	}
+	value, _, err := f.objectAt(offset)
+	if err != nil {
+		return nil
+	}
+	dict, _ := value.(pdfDict)
+	return dict
+}
+
+// scanObjects records the offset of every "N G obj" header and every object stream
+func (f *pdfFile) scanObjects() error {
+	f.offsets = make(map[int]int64)
+	streams := make(map[int]bool)
+	buffer := make([]byte, pdfChunkSize+pdfOverlap)
+	keyword := []byte("obj")
+
+	for base := int64(0); base < f.size; base += pdfChunkSize {
+		n, err := f.r.ReadAt(buffer, base)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		chunk := buffer[:n]
+		for i := 0; i < n; {
+			j := bytes.Index(chunk[i:], keyword)
+			if j < 0 {
+				break
+			}
+			position := i + j
+			i = position + len(keyword)
+			num, start, ok := objectHeader(chunk, position)
+			// Headers starting in the overlap belong to the next chunk
+			if !ok || start >= pdfChunkSize {
+				continue
+			}
+			f.offsets[num] = base + int64(start)
+			if bytes.Contains(chunk[position:min(position+256, n)], []byte("/ObjStm")) {
+				streams[num] = true
+			} else {
+				delete(streams, num)
+			}
+		}
+	}
+
+	for num := range streams {
+		f.objStreams = append(f.objStreams, num)
+	}
+	return nil
+}
+
+// objectHeader parses the "N G" before an "obj" keyword, returning the object
+// number and the offset of the header
+func objectHeader(data []byte, keyword int) (int, int, bool) {
+	if end := keyword + 3; end < len(data) && isPDFRegular(data[end]) {
+		return 0, 0, false
+	}
+	i := keyword
+	digits := func() (int, bool) {
+		for i > 0 && isPDFSpace(data[i-1]) {
+			i--
+		}
+		end := i
+		for i > 0 && data[i-1] >= '0' && data[i-1] <= '9' {
+			i--
+		}
+		if i == end || end-i > 10 {
+			return 0, false
+		}
+		value, err := strconv.Atoi(string(data[i:end]))
+		return value, err == nil
+	}
+	if _, ok := digits(); !ok {
+		return 0, 0, false
+	}
+	num, ok := digits()
+	if !ok || i > 0 && isPDFRegular(data[i-1]) {
+		return 0, 0, false
+	}
+	return num, i, true
+}
+
+// resolve follows indirect references
+func (f *pdfFile) resolve(value interface{}, depth int) interface{} {
+	ref, ok := value.(pdfRef)
+	if !ok || depth > pdfMaxNesting {
+		return value
+	}
+	return f.resolve(f.object(ref.num), depth+1)
+}
+
+// object returns an object by number, looking into object streams when it has no header of its own
+func (f *pdfFile) object(num int) interface{} {
+	if offset, ok := f.offsets[num]; ok {
+		if value, _, err := f.objectAt(offset); err == nil {
+			return value
+		}
+	}
+	for {
+		if value, ok := f.compressed[num]; ok {
+			return value
+		}
+		if len(f.objStreams) == 0 {
+			return nil
+		}
+		stream := f.objStreams[0]
+		f.objStreams = f.objStreams[1:]
+		f.unpackObjectStream(stream)
+	}
+}
+
+// unpackObjectStream parses the objects stored in an object stream
+func (f *pdfFile) unpackObjectStream(num int) {
+	value, _, err := f.objectAt(f.offsets[num])
+	if err != nil {
+		return
+	}
+	dict, _ := value.(pdfDict)
+	count, _ := f.resolve(dict["N"], 0).(float64)
+	first, _ := f.resolve(dict["First"], 0).(float64)
+	data := f.streamData(num)
+	if data == nil || int(first) > len(data) {
+		return
+	}
+
+	header := &pdfParser{data: data[:int(first)]}
+	for i := 0; i < int(count); i++ {
+		objNum, err1 := header.value(0)
+		offset, err2 := header.value(0)
+		if err1 != nil || err2 != nil {
+			return
+		}
+		n, _ := objNum.(float64)
+		o, _ := offset.(float64)
+		parser := &pdfParser{data: data, pos: int(first) + int(o)}
+		if object, err := parser.value(0); err == nil {
+			if _, ok := f.compressed[int(n)]; !ok {
+				f.compressed[int(n)] = object
+			}
+		}
+	}
+}
+
+// objectAt parses the indirect object at an offset, returning its value and the
+// offset of its stream data (or -1)
+func (f *pdfFile) objectAt(offset int64) (interface{}, int64, error) {
+	for size := 4 << 10; ; size *= 16 {
+		data := f.window(offset, size)
+		parser := &pdfParser{data: data}
+		if !parser.objectHeader() {
+			return nil, -1, fmt.Errorf("no PDF object at offset %d", offset)
+		}
+		value, err := parser.value(0)
+		if errors.Is(err, errPDFTruncated) && len(data) == size && size < pdfMaxStream {
+			continue
+		}
+		if err != nil {
+			return nil, -1, err
+		}
+
+		streamStart := int64(-1)
+		parser.skipSpace()
+		if bytes.HasPrefix(data[parser.pos:], []byte("stream")) {
+			position := parser.pos + len("stream")
+			if position < len(data) && data[position] == '\r' {
+				position++
+			}
+			if position < len(data) && data[position] == '\n' {
+				position++
+			}
+			streamStart = offset + int64(position)
+		}
+		return value, streamStart, nil
+	}
+}
+
+// streamData returns the decoded data of a stream object; only unfiltered and
+// Flate-encoded streams are supported
+func (f *pdfFile) streamData(num int) []byte {
+	offset, ok := f.offsets[num]
+	if !ok {
+		return nil
+	}
+	value, start, err := f.objectAt(offset)
+	dict, _ := value.(pdfDict)
+	if err != nil || start < 0 || dict == nil {
+		return nil
+	}
+
+	length := f.size - start
+	if declared, ok := f.resolve(dict["Length"], 0).(float64); ok && int64(declared) <= length {
+		length = int64(declared)
+	}
+	section := io.NewSectionReader(f.r, start, length)
+
+	filter := f.resolve(dict["Filter"], 0)
+	if filters, ok := filter.([]interface{}); ok && len(filters) == 1 {
+		filter = filters[0]
+	}
+	var reader io.Reader
+	switch filter {
+	case nil:
+		reader = section
+	case pdfName("FlateDecode"):
+		inflater, err := zlib.NewReader(section)
+		if err != nil {
+			return nil
+		}
+		defer inflater.Close()
+		reader = inflater
+	default:
+		return nil
+	}
+
+	data, err := io.ReadAll(io.LimitReader(reader, pdfMaxStream))
+	if err != nil && len(data) == 0 {
+		return nil
+	}
+	return data
+}
+
+// window reads up to size bytes at an offset
+func (f *pdfFile) window(offset int64, size int) []byte {
+	data := make([]byte, max(0, min(int64(size), f.size-offset)))
+	n, _ := f.r.ReadAt(data, offset)
+	return data[:n]
+}
+
+// indexFrom returns the offset of the first occurrence of a token at or after an offset
+func (f *pdfFile) indexFrom(offset int64, token []byte) int64 {
+	for base := offset; base < f.size; base += pdfChunkSize {
+		chunk := f.window(base, pdfChunkSize+len(token))
+		if i := bytes.Index(chunk, token); i >= 0 {
+			return base + int64(i)
+		}
+	}
+	return -1
+}
+
+// pdfParser parses PDF objects from a byte slice
+type pdfParser struct {
+	data []byte
+	pos  int
+}
+
+// objectHeader consumes an "N G obj" header
+func (p *pdfParser) objectHeader() bool {
+	for i := 0; i < 2; i++ {
+		if value, err := p.value(0); err != nil {
+			return false
+		} else if _, ok := value.(float64); !ok {
+			return false
+		}
+	}
+	p.skipSpace()
+	if !bytes.HasPrefix(p.data[p.pos:], []byte("obj")) {
+		return false
+	}
+	p.pos += len("obj")
+	return true
+}
+
+// dictValue parses a value that must be a dictionary
+func (p *pdfParser) dictValue() (pdfDict, bool) {
+	value, err := p.value(0)
+	dict, ok := value.(pdfDict)
+	return dict, err == nil && ok
+}
+
+// value parses the next object
+func (p *pdfParser) value(depth int) (interface{}, error) {
+	if depth > pdfMaxNesting {
+		return nil, fmt.Errorf("PDF objects nested too deeply")
+	}
+	p.skipSpace()
+	if p.pos >= len(p.data) {
+		return nil, errPDFTruncated
+	}
+
+	switch c := p.data[p.pos]; {
+	case c == '/':
+		return p.name(), nil
+	case c == '(':
+		return p.literal()
+	case c == '<' && p.pos+1 < len(p.data) && p.data[p.pos+1] == '<':
+		return p.dict(depth)
+	case c == '<':
+		return p.hex()
+	case c == '[':
+		return p.array(depth)
+	case c == '+' || c == '-' || c == '.' || c >= '0' && c <= '9':
+		return p.number()
+	default:
+		start := p.pos
+		for p.pos < len(p.data) && isPDFRegular(p.data[p.pos]) {
+			p.pos++
+		}
+		switch keyword := string(p.data[start:p.pos]); keyword {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		default:
+			if p.pos == start {
+				p.pos++
+			}
+			return nil, fmt.Errorf("unexpected PDF token %q", keyword)
+		}
+	}
+}
+
+// dict parses a dictionary
+func (p *pdfParser) dict(depth int) (interface{}, error) {
+	p.pos += 2
+	dict := pdfDict{}
+	for {
+		p.skipSpace()
+		if p.pos+1 >= len(p.data) {
+			return nil, errPDFTruncated
+		}
+		if p.data[p.pos] == '>' && p.data[p.pos+1] == '>' {
+			p.pos += 2
+			return dict, nil
+		}
+		key, err := p.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		name, ok := key.(pdfName)
+		if !ok {
+			return nil, fmt.Errorf("PDF dictionary key is not a name")
+		}
+		value, err := p.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		dict[string(name)] = value
+	}
+}
+
+// array parses an array
+func (p *pdfParser) array(depth int) (interface{}, error) {
+	p.pos++
+	var values []interface{}
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.data) {
+			return nil, errPDFTruncated
+		}
+		if p.data[p.pos] == ']' {
+			p.pos++
+			return values, nil
+		}
+		value, err := p.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+}
+
+// number parses a number or an indirect reference "N G R"
+func (p *pdfParser) number() (interface{}, error) {
+	start := p.pos
+	p.pos++
+	for p.pos < len(p.data) && (p.data[p.pos] >= '0' && p.data[p.pos] <= '9' || p.data[p.pos] == '.') {
+		p.pos++
+	}
+	value, err := strconv.ParseFloat(string(p.data[start:p.pos]), 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PDF number %q", p.data[start:p.pos])
+	}
+
+	// An integer followed by another integer and "R" is a reference
+	end := p.pos
+	if gen, ok := p.integer(); ok {
+		p.skipSpace()
+		if p.pos < len(p.data) && p.data[p.pos] == 'R' && (p.pos+1 == len(p.data) || !isPDFRegular(p.data[p.pos+1])) {
+			p.pos++
+			return pdfRef{num: int(value), gen: gen}, nil
+		}
+	}
+	p.pos = end
+	return value, nil
+}
+
+// integer consumes an unsigned integer after whitespace
+func (p *pdfParser) integer() (int, bool) {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.data) && p.data[p.pos] >= '0' && p.data[p.pos] <= '9' {
+		p.pos++
+	}
+	if p.pos == start {
+		return 0, false
+	}
+	value, err := strconv.Atoi(string(p.data[start:p.pos]))
+	return value, err == nil
+}
+
+// name parses a /Name, decoding #xx escapes
+func (p *pdfParser) name() pdfName {
+	p.pos++
+	var name []byte
+	for p.pos < len(p.data) && isPDFRegular(p.data[p.pos]) {
+		c := p.data[p.pos]
+		if c == '#' && p.pos+2 < len(p.data) {
+			if value, err := strconv.ParseUint(string(p.data[p.pos+1:p.pos+3]), 16, 8); err == nil {
+				name = append(name, byte(value))
+				p.pos += 3
+				continue
+			}
+		}
+		name = append(name, c)
+		p.pos++
+	}
+	return pdfName(name)
+}
+
+// literal parses a (string) with escapes and balanced parentheses
+func (p *pdfParser) literal() (interface{}, error) {
+	p.pos++
+	var text []byte
+	for depth := 1; p.pos < len(p.data); p.pos++ {
+		c := p.data[p.pos]
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth == 0 {
+				p.pos++
+				return string(text), nil
+			}
+		case '\\':
+			p.pos++
+			if p.pos >= len(p.data) {
+				return nil, errPDFTruncated
+			}
+			switch e := p.data[p.pos]; e {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				// Line continuation
+				if p.pos+1 < len(p.data) && p.data[p.pos+1] == '\n' {
+					p.pos++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				if e >= '0' && e <= '7' {
+					value := 0
+					for i := 0; i < 3 && p.pos < len(p.data) && p.data[p.pos] >= '0' && p.data[p.pos] <= '7'; i++ {
+						value = value*8 + int(p.data[p.pos]-'0')
+						p.pos++
+					}
+					p.pos--
+					c = byte(value)
+				} else {
+					c = e
+				}
+			}
+		}
+		text = append(text, c)
+	}
+	return nil, errPDFTruncated
+}
+
+// hex parses a <hex string>
+func (p *pdfParser) hex() (interface{}, error) {
+	p.pos++
+	var digits []byte
+	for ; p.pos < len(p.data); p.pos++ {
+		c := p.data[p.pos]
+		if c == '>' {
+			p.pos++
+			if len(digits)%2 == 1 {
+				digits = append(digits, '0')
+			}
+			text := make([]byte, len(digits)/2)
+			for i := range text {
+				value, err := strconv.ParseUint(string(digits[2*i:2*i+2]), 16, 8)
+				if err != nil {
+					return nil, fmt.Errorf("invalid PDF hex string")
+				}
+				text[i] = byte(value)
+			}
+			return string(text), nil
+		}
+		if !isPDFSpace(c) {
+			digits = append(digits, c)
+		}
+	}
+	return nil, errPDFTruncated
+}
+
+// skipSpace skips whitespace and comments
+func (p *pdfParser) skipSpace() {
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		if c == '%' {
+			for p.pos < len(p.data) && p.data[p.pos] != '\n' && p.data[p.pos] != '\r' {
+				p.pos++
+			}
+			continue
+		}
+		if !isPDFSpace(c) {
+			return
+		}
+		p.pos++
+	}
+}
+
+// isPDFSpace reports whether a byte is PDF whitespace
+func isPDFSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f' || c == 0
+}
+
+// isPDFRegular reports whether a byte is neither whitespace nor a delimiter
+func isPDFRegular(c byte) bool {
+	switch c {
+	case '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return false
+	}
+	return !isPDFSpace(c)
+}
+
+// decodePDFText decodes a text string: UTF-16BE or UTF-8 with a byte order mark,
+// otherwise PDFDocEncoding (approximated by Latin-1)
+func decodePDFText(text string) string {
+	data := []byte(text)
+	switch {
+	case len(data) >= 2 && data[0] == 0xFE && data[1] == 0xFF:
+		units := make([]uint16, 0, len(data)/2)
+		for i := 2; i+1 < len(data); i += 2 {
+			units = append(units, uint16(data[i])<<8|uint16(data[i+1]))
+		}
+		text = string(utf16.Decode(units))
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		text = string(data[3:])
+	case !utf8.Valid(data):
+		runes := make([]rune, len(data))
+		for i, c := range data {
+			runes[i] = rune(c)
+		}
+		text = string(runes)
+	}
+	return string(bytes.TrimRight([]byte(text), "\x00"))
+}
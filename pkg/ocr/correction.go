@@ -0,0 +1,178 @@
+package ocr
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"doc-to-text/pkg/interfaces"
+	"doc-to-text/pkg/types"
+	"doc-to-text/pkg/utils"
+)
+
+// Correction statuses recorded per page
+const (
+	CorrectionStatusCorrected = "corrected"
+	CorrectionStatusCached    = "cached"
+	CorrectionStatusSkipped   = "skipped"
+	CorrectionStatusFailed    = "failed"
+)
+
+// PageCorrection records the LLM correction pass for one page
+type PageCorrection struct {
+	Page       int     `json:"page"`
+	Template   string  `json:"template"`
+	Status     string  `json:"status"`
+	Confidence float64 `json:"confidence,omitempty"`
+	Original   string  `json:"original_path,omitempty"`
+	Corrected  string  `json:"corrected_path,omitempty"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// correctPage sends a page's OCR text (and optionally its image) to the configured
+// llm-caller template and returns the corrected text. The original and corrected texts
+// are cached per page and template, and reused while the OCR text is unchanged; pages
+// whose OCR confidence already meets the configured threshold are skipped. Failures
+// keep the original text.
+func (e *OCRExtractor) correctPage(ctx context.Context, pageNum int, text, sourcePath, imagePath string, engine interfaces.OCREngine) string {
+	template := e.config.CorrectionTemplate
+	record := PageCorrection{Page: pageNum, Template: template}
+	e.useTool("llm-caller")
+	defer func() {
+		corrections, _ := e.metadata["corrections"].([]PageCorrection)
+		e.metadata["corrections"] = append(corrections, record)
+	}()
+
+	if strings.TrimSpace(text) == "" {
+		record.Status = CorrectionStatusSkipped
+		return text
+	}
+
+	// Skip pages the OCR engine is already confident about
+	if confidence, ok := pageConfidence(e.pageTextLines(pageNum, sourcePath, engine)); ok {
+		record.Confidence = confidence
+		if e.config.CorrectionSkipConfidence > 0 && confidence >= e.config.CorrectionSkipConfidence {
+			e.logger.Progress("⏭️", "Skipping correction of page %d (confidence %.2f)", pageNum, confidence)
+			record.Status = CorrectionStatusSkipped
+			return text
+		}
+	}
+
+	originalPath := e.fileManager.GetCorrectionPath(template, fmt.Sprintf("page_%d.original.txt", pageNum))
+	correctedPath := e.fileManager.GetCorrectionPath(template, fmt.Sprintf("page_%d.txt", pageNum))
+	record.Original = originalPath
+	record.Corrected = correctedPath
+
+	// A cached correction only applies to the text it was made from; the page may
+	// have been recognized again with other settings since
+	if original, err := os.ReadFile(originalPath); err == nil && string(original) == text {
+		if content, err := os.ReadFile(correctedPath); err == nil {
+			e.logger.Progress("⏭️", "Loaded cached correction for page %d", pageNum)
+			record.Status = CorrectionStatusCached
+			return string(content)
+		}
+	}
+	// The original is replaced below, so a stale correction must not outlive a failure
+	os.Remove(correctedPath)
+
+	corrected, err := e.runCorrection(ctx, template, text, originalPath, correctedPath, sourcePath, imagePath)
+	if err != nil {
+		e.logger.Warn("LLM correction failed for page %d, keeping OCR text: %v", pageNum, err)
+		record.Status = CorrectionStatusFailed
+		record.Error = err.Error()
+		return text
+	}
+
+	e.logger.Progress("✍️", "Corrected page %d with template %s", pageNum, template)
+	record.Status = CorrectionStatusCorrected
+	return corrected
+}
+
+// runCorrection invokes llm-caller with the page text as the "text" file variable
+// and, when enabled, the page image as "image_url"
+func (e *OCRExtractor) runCorrection(ctx context.Context, template, text, originalPath, correctedPath, sourcePath, imagePath string) (string, error) {
+	if err := os.MkdirAll(filepath.Dir(originalPath), 0755); err != nil {
+		return "", fmt.Errorf("failed to create correction directory: %w", err)
+	}
+	if err := utils.WriteFileAtomic(originalPath, []byte(text), 0644); err != nil {
+		return "", fmt.Errorf("failed to save original text: %w", err)
+	}
+
+	args := []string{"call", template, "--var", fmt.Sprintf("text:file:%s", originalPath)}
+
+	if e.config.CorrectionWithImage {
+		if err := e.ensurePageImage(ctx, sourcePath, imagePath); err != nil {
+			return "", fmt.Errorf("failed to render page image: %w", err)
+		}
+		imageData, err := os.ReadFile(imagePath)
+		if err != nil {
+			return "", fmt.Errorf("failed to read page image: %w", err)
+		}
+		imageFormat := strings.TrimPrefix(strings.ToLower(filepath.Ext(imagePath)), ".")
+		if imageFormat == "" {
+			imageFormat = "png"
+		}
+		args = append(args, "--var", fmt.Sprintf("image_url:text:data:image/%s;base64,%s",
+			imageFormat, base64.StdEncoding.EncodeToString(imageData)))
+	}
+
+	if len(e.languages) > 0 {
+		args = append(args, "--var", fmt.Sprintf("languages:text:%s", LLMLanguages(e.languages)))
+	}
+
+	// Write to a temporary file so an interrupted call never leaves a partial cache entry
+	tempPath := correctedPath + ".tmp"
+	args = append(args, "-o", tempPath)
+	defer os.Remove(tempPath)
+
+	llmCallerPath, err := findLLMCaller(e.logger)
+	if err != nil {
+		return "", err
+	}
+	cmd := utils.CommandContext(ctx, llmCallerPath, args...)
+	var stderrBuilder strings.Builder
+	cmd.Stderr = &stderrBuilder
+	if err := cmd.Run(); err != nil {
+		stderrOutput := strings.TrimSpace(stderrBuilder.String())
+		if stderrOutput != "" {
+			return "", fmt.Errorf("LLM caller execution failed (%v) with stderr: %s", err, stderrOutput)
+		}
+		return "", fmt.Errorf("LLM caller execution failed: %w", err)
+	}
+
+	content, err := os.ReadFile(tempPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read LLM results: %w", err)
+	}
+
+	corrected := strings.TrimSpace(stripCodeFence(string(content)))
+	if corrected == "" {
+		return "", fmt.Errorf("template returned empty text")
+	}
+
+	if err := utils.WriteFileAtomic(correctedPath, []byte(corrected), 0644); err != nil {
+		return "", fmt.Errorf("failed to save corrected text: %w", err)
+	}
+
+	return corrected, nil
+}
+
+// pageConfidence returns the text-length weighted mean confidence of a page's lines
+func pageConfidence(lines []types.TextLine) (float64, bool) {
+	var weighted, total float64
+	for _, line := range lines {
+		if line.Confidence <= 0 {
+			continue
+		}
+		weight := float64(len([]rune(line.Text)))
+		weighted += line.Confidence * weight
+		total += weight
+	}
+	if total == 0 {
+		return 0, false
+	}
+	return weighted / total, true
+}
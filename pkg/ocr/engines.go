@@ -6,13 +6,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 
 	"doc-to-text/pkg/config"
 	"doc-to-text/pkg/interfaces"
 	"doc-to-text/pkg/logger"
+	"doc-to-text/pkg/types"
 	"doc-to-text/pkg/utils"
 )
 
@@ -23,6 +23,7 @@ type LLMCallerEngine struct {
 	config      *config.Config
 	logger      *logger.Logger
 	fileManager *utils.FileManager
+	languages   []string
 }
 
 // NewLLMCallerEngine 创建LLM Caller引擎
@@ -46,16 +47,21 @@ func (e *LLMCallerEngine) SupportsDirectPDF() bool {
 	return true
 }
 
-func (e *LLMCallerEngine) ExtractTextFromPDF(ctx context.Context, pdfPath string) (string, error) {
-	// 检查缓存
-	if e.fileManager != nil {
-		outputPath := e.fileManager.GetOCRDataPath()
-		if content, err := os.ReadFile(outputPath); err == nil {
-			e.logger.Progress("⏭️", "Loading cached LLM results")
-			return string(content), nil
-		}
+// SetLanguages 设置语言提示，通过模板变量 languages 传递给 llm-caller
+func (e *LLMCallerEngine) SetLanguages(langs []string) {
+	e.languages = langs
+}
+
+// buildArgs 构建 llm-caller 命令参数
+func (e *LLMCallerEngine) buildArgs(template, variable, outputFile string) []string {
+	args := []string{"call", template, "--var", variable}
+	if len(e.languages) > 0 {
+		args = append(args, "--var", fmt.Sprintf("languages:text:%s", LLMLanguages(e.languages)))
 	}
+	return append(args, "-o", outputFile)
+}
 
+func (e *LLMCallerEngine) ExtractTextFromPDF(ctx context.Context, pdfPath string) (string, error) {
 	llmCallerPath, err := e.findLLMCallerPath()
 	if err != nil {
 		return "", err
@@ -75,10 +81,8 @@ func (e *LLMCallerEngine) ExtractTextFromPDF(ctx context.Context, pdfPath string
 
 	// 执行LLM Caller
 	outputFile := filepath.Join(outputDir, fmt.Sprintf("%s_output.txt", utils.SanitizeFileName(filepath.Base(pdfPath))))
-	cmd := exec.CommandContext(ctx, llmCallerPath,
-		"call", template,
-		"--var", fmt.Sprintf("file:file:%s", pdfPath),
-		"-o", outputFile)
+	cmd := utils.CommandContext(ctx, llmCallerPath,
+		e.buildArgs(template, fmt.Sprintf("file:file:%s", pdfPath), outputFile)...)
 
 	// 捕获标准错误输出
 	var stderrBuilder strings.Builder
@@ -100,24 +104,10 @@ func (e *LLMCallerEngine) ExtractTextFromPDF(ctx context.Context, pdfPath string
 
 	text := strings.TrimSpace(string(content))
 
-	// 保存缓存
-	if e.fileManager != nil {
-		os.WriteFile(e.fileManager.GetOCRDataPath(), []byte(text), 0644)
-	}
-
 	return text, nil
 }
 
 func (e *LLMCallerEngine) ExtractTextFromImage(ctx context.Context, imagePath string) (string, error) {
-	// 检查缓存
-	if e.fileManager != nil {
-		outputPath := e.fileManager.GetOCRDataPath()
-		if content, err := os.ReadFile(outputPath); err == nil {
-			e.logger.Progress("⏭️", "Loading cached LLM results")
-			return string(content), nil
-		}
-	}
-
 	llmCallerPath, err := e.findLLMCallerPath()
 	if err != nil {
 		return "", err
@@ -151,10 +141,8 @@ func (e *LLMCallerEngine) ExtractTextFromImage(ctx context.Context, imagePath st
 
 	// 执行LLM Caller
 	outputFile := filepath.Join(outputDir, fmt.Sprintf("%s_output.txt", utils.SanitizeFileName(filepath.Base(imagePath))))
-	cmd := exec.CommandContext(ctx, llmCallerPath,
-		"call", template,
-		"--var", fmt.Sprintf("image_url:text:%s", dataURL),
-		"-o", outputFile)
+	cmd := utils.CommandContext(ctx, llmCallerPath,
+		e.buildArgs(template, fmt.Sprintf("image_url:text:%s", dataURL), outputFile)...)
 
 	// 捕获标准错误输出
 	var stderrBuilder strings.Builder
@@ -176,16 +164,16 @@ func (e *LLMCallerEngine) ExtractTextFromImage(ctx context.Context, imagePath st
 
 	text := strings.TrimSpace(string(content))
 
-	// 保存缓存
-	if e.fileManager != nil {
-		os.WriteFile(e.fileManager.GetOCRDataPath(), []byte(text), 0644)
-	}
-
 	return text, nil
 }
 
 // findLLMCallerPath 查找LLM Caller路径
 func (e *LLMCallerEngine) findLLMCallerPath() (string, error) {
+	return findLLMCaller(e.logger)
+}
+
+// findLLMCaller 查找LLM Caller路径，供OCR、公式检测和校正共用
+func findLLMCaller(log *logger.Logger) (string, error) {
 	// Try to find llm-caller using shell detection
 	if utils.IsCommandAvailable("llm-caller") {
 		return "llm-caller", nil
@@ -201,7 +189,7 @@ func (e *LLMCallerEngine) findLLMCallerPath() (string, error) {
 
 	for _, path := range commonPaths {
 		if utils.IsCommandAvailable(path) {
-			e.logger.Debug("Found llm-caller at: %s", path)
+			log.Debug("Found llm-caller at: %s", path)
 			return path, nil
 		}
 	}
@@ -216,6 +204,7 @@ type SuryaOCREngine struct {
 	config      *config.Config
 	logger      *logger.Logger
 	fileManager *utils.FileManager
+	languages   []string
 }
 
 // SuryaOCRResult Surya OCR结果结构
@@ -256,16 +245,21 @@ func (e *SuryaOCREngine) SupportsDirectPDF() bool {
 	return true
 }
 
-func (e *SuryaOCREngine) ExtractTextFromPDF(ctx context.Context, pdfPath string) (string, error) {
-	// 检查缓存
-	if e.fileManager != nil {
-		outputPath := e.fileManager.GetOCRDataPath()
-		if content, err := os.ReadFile(outputPath); err == nil {
-			e.logger.Progress("⏭️", "Loading cached Surya results")
-			return string(content), nil
-		}
+// SetLanguages 设置语言提示，通过 --langs 参数传递给 Surya
+func (e *SuryaOCREngine) SetLanguages(langs []string) {
+	e.languages = langs
+}
+
+// buildArgs 构建 surya_ocr 命令参数
+func (e *SuryaOCREngine) buildArgs(inputPath, outputDir string) []string {
+	args := []string{inputPath, "--output_dir", outputDir}
+	if len(e.languages) > 0 {
+		args = append(args, "--langs", SuryaLanguages(e.languages))
 	}
+	return args
+}
 
+func (e *SuryaOCREngine) ExtractTextFromPDF(ctx context.Context, pdfPath string) (string, error) {
 	suryaPath, err := e.findSuryaOCRPath()
 	if err != nil {
 		return "", err
@@ -278,7 +272,7 @@ func (e *SuryaOCREngine) ExtractTextFromPDF(ctx context.Context, pdfPath string)
 	}
 
 	// 执行Surya OCR
-	cmd := exec.CommandContext(ctx, suryaPath, pdfPath, "--output_dir", outputDir)
+	cmd := utils.CommandContext(ctx, suryaPath, e.buildArgs(pdfPath, outputDir)...)
 
 	// 捕获标准错误输出和标准输出
 	var stderrBuilder strings.Builder
@@ -307,24 +301,10 @@ func (e *SuryaOCREngine) ExtractTextFromPDF(ctx context.Context, pdfPath string)
 		return "", fmt.Errorf("failed to parse Surya results: %w", err)
 	}
 
-	// 保存缓存
-	if e.fileManager != nil {
-		os.WriteFile(e.fileManager.GetOCRDataPath(), []byte(text), 0644)
-	}
-
 	return text, nil
 }
 
 func (e *SuryaOCREngine) ExtractTextFromImage(ctx context.Context, imagePath string) (string, error) {
-	// 检查缓存
-	if e.fileManager != nil {
-		outputPath := e.fileManager.GetOCRDataPath()
-		if content, err := os.ReadFile(outputPath); err == nil {
-			e.logger.Progress("⏭️", "Loading cached Surya results")
-			return string(content), nil
-		}
-	}
-
 	suryaPath, err := e.findSuryaOCRPath()
 	if err != nil {
 		return "", err
@@ -337,7 +317,7 @@ func (e *SuryaOCREngine) ExtractTextFromImage(ctx context.Context, imagePath str
 	}
 
 	// 执行Surya OCR
-	cmd := exec.CommandContext(ctx, suryaPath, imagePath, "--output_dir", outputDir)
+	cmd := utils.CommandContext(ctx, suryaPath, e.buildArgs(imagePath, outputDir)...)
 
 	// 捕获标准错误输出和标准输出
 	var stderrBuilder strings.Builder
@@ -366,11 +346,6 @@ func (e *SuryaOCREngine) ExtractTextFromImage(ctx context.Context, imagePath str
 		return "", fmt.Errorf("failed to parse Surya results: %w", err)
 	}
 
-	// 保存缓存
-	if e.fileManager != nil {
-		os.WriteFile(e.fileManager.GetOCRDataPath(), []byte(text), 0644)
-	}
-
 	return text, nil
 }
 
@@ -398,6 +373,77 @@ func (e *SuryaOCREngine) findSuryaOCRPath() (string, error) {
 	return "", fmt.Errorf("Surya OCR not found. Please install with: pip install surya-ocr")
 }
 
+// TextLines 读取已保存的Surya结果，返回带坐标的文本行
+func (e *SuryaOCREngine) TextLines(inputPath string) ([]types.TextLine, error) {
+	results, err := e.readResults(inputPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []types.TextLine
+	for _, pages := range results {
+		for _, page := range pages {
+			for _, line := range page.TextLines {
+				if line.Text == "" || len(line.Bbox) < 4 {
+					continue
+				}
+				lines = append(lines, types.TextLine{
+					Text:       line.Text,
+					BBox:       [4]float64{line.Bbox[0], line.Bbox[1], line.Bbox[2], line.Bbox[3]},
+					Confidence: line.Confidence,
+				})
+			}
+		}
+	}
+
+	return lines, nil
+}
+
+// PageSize 返回Surya识别时渲染的页面尺寸（image_bbox）
+func (e *SuryaOCREngine) PageSize(inputPath string) (float64, float64, error) {
+	results, err := e.readResults(inputPath)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, pages := range results {
+		for _, page := range pages {
+			if len(page.ImageBbox) >= 4 {
+				width, height := page.ImageBbox[2]-page.ImageBbox[0], page.ImageBbox[3]-page.ImageBbox[1]
+				if width > 0 && height > 0 {
+					return width, height, nil
+				}
+			}
+		}
+	}
+	return 0, 0, fmt.Errorf("no page size in results")
+}
+
+// readResults 读取指定输入文件已保存的Surya结果
+func (e *SuryaOCREngine) readResults(inputPath string) (SuryaOCRResult, error) {
+	if e.fileManager == nil {
+		return nil, fmt.Errorf("file manager not initialized")
+	}
+
+	// PDF结果保存在 surya_ocr_results 目录，图像结果保存在基础目录
+	outputDir := e.fileManager.GetBasePath()
+	if strings.EqualFold(filepath.Ext(inputPath), ".pdf") {
+		outputDir = e.fileManager.GetPath("surya_ocr_results")
+	}
+
+	fileName := utils.SanitizeFileName(filepath.Base(inputPath))
+	subDirName := strings.TrimSuffix(fileName, filepath.Ext(fileName))
+	data, err := os.ReadFile(filepath.Join(outputDir, subDirName, "results.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JSON results: %w", err)
+	}
+
+	var results SuryaOCRResult
+	if err := json.Unmarshal(data, &results); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON results: %w", err)
+	}
+	return results, nil
+}
+
 func (e *SuryaOCREngine) parseOCRResults(outputDir, fileName string) (string, error) {
 	// 查找JSON结果文件
 	// sub dir name is image name (no extension)
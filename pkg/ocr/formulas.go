@@ -0,0 +1,497 @@
+package ocr
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"doc-to-text/pkg/types"
+	"doc-to-text/pkg/utils"
+)
+
+// suryaLayoutCommand is Surya's layout analysis CLI
+const suryaLayoutCommand = "surya_layout"
+
+// formulaCropPadding is the margin in pixels added around formula regions before recognition
+const formulaCropPadding = 4
+
+// SuryaLayoutResult is the results.json layout written by surya_layout
+type SuryaLayoutResult map[string][]SuryaLayoutPage
+
+// SuryaLayoutPage is the layout analysis of one page
+type SuryaLayoutPage struct {
+	Bboxes []SuryaLayoutBox `json:"bboxes"`
+	Page   int              `json:"page"`
+}
+
+// SuryaLayoutBox is one labeled layout region
+type SuryaLayoutBox struct {
+	Bbox       []float64 `json:"bbox"`
+	Label      string    `json:"label"`
+	Confidence float64   `json:"confidence"`
+}
+
+// formulaRegion is a page region containing math
+type formulaRegion struct {
+	BBox   [4]float64 `json:"bbox"`
+	Inline bool       `json:"inline"`
+}
+
+// recognizeFormulas detects math regions on a page, converts them to LaTeX with the
+// configured formula command and inlines the result as $...$ or $$...$$.
+// Regions come from an llm-caller template when FormulaTemplate is set, otherwise from
+// surya_layout. Failures are logged and the input is returned unchanged.
+func (e *OCRExtractor) recognizeFormulas(ctx context.Context, pageNum int, pageText string, lines []types.TextLine, sourcePath, imagePath string) (string, []types.TextLine) {
+	if err := e.ensurePageImage(ctx, sourcePath, imagePath); err != nil {
+		e.logger.Warn("Formula recognition skipped for page %d: %v", pageNum, err)
+		return pageText, lines
+	}
+
+	regions, err := e.detectFormulaRegions(ctx, pageNum, imagePath)
+	if err != nil {
+		e.logger.Warn("Formula detection failed for page %d: %v", pageNum, err)
+		return pageText, lines
+	}
+	if len(regions) == 0 {
+		return pageText, lines
+	}
+
+	e.logger.Progress("🧮", "Detected %d formula region(s) on page %d", len(regions), pageNum)
+
+	formulasDir, err := e.fileManager.CreateIntermediateDir("formulas")
+	if err != nil {
+		e.logger.Warn("Failed to create formulas directory: %v", err)
+		return pageText, lines
+	}
+	if fields := e.config.FormulaArgs(); len(fields) > 0 {
+		e.useTool(fields[0])
+	}
+
+	var latex []string
+	for i, region := range regions {
+		formula, err := e.recognizeFormula(ctx, formulasDir, pageNum, i+1, imagePath, region)
+		if err != nil {
+			e.logger.Warn("Formula %d on page %d failed: %v", i+1, pageNum, err)
+			latex = append(latex, "")
+			continue
+		}
+		latex = append(latex, wrapLaTeX(formula, region.Inline))
+	}
+
+	if len(lines) == 0 {
+		// Without line positions the formulas cannot be placed, so append them after the page text
+		var builder strings.Builder
+		builder.WriteString(pageText)
+		for _, formula := range latex {
+			if formula != "" {
+				builder.WriteString("\n\n")
+				builder.WriteString(formula)
+			}
+		}
+		return builder.String(), lines
+	}
+
+	lines, unplaced := replaceLinesWithFormulas(lines, regions, latex)
+	var builder strings.Builder
+	for _, line := range lines {
+		builder.WriteString(line.Text)
+		builder.WriteString("\n")
+	}
+	text := strings.TrimSpace(builder.String())
+	// Formulas no line could take are kept after the page text rather than dropped
+	for _, formula := range unplaced {
+		text += "\n\n" + formula
+	}
+	return text, lines
+}
+
+// detectFormulaRegions finds math regions on the page image
+func (e *OCRExtractor) detectFormulaRegions(ctx context.Context, pageNum int, imagePath string) ([]formulaRegion, error) {
+	if e.config.FormulaTemplate != "" {
+		e.useTool("llm-caller")
+		return e.detectFormulaRegionsWithLLM(ctx, imagePath)
+	}
+	if !utils.IsCommandAvailable(suryaLayoutCommand) {
+		return nil, fmt.Errorf("%s not found; install surya-ocr or set a formula detection template", suryaLayoutCommand)
+	}
+	e.useTool(suryaLayoutCommand)
+	return e.detectFormulaRegionsWithLayout(ctx, pageNum, imagePath)
+}
+
+// detectFormulaRegionsWithLayout runs surya_layout and keeps regions labeled as math
+func (e *OCRExtractor) detectFormulaRegionsWithLayout(ctx context.Context, pageNum int, imagePath string) ([]formulaRegion, error) {
+	outputDir, err := e.fileManager.CreateIntermediateDir("surya_layout_results")
+	if err != nil {
+		return nil, err
+	}
+
+	fileName := utils.SanitizeFileName(filepath.Base(imagePath))
+	resultsFile := filepath.Join(outputDir, strings.TrimSuffix(fileName, filepath.Ext(fileName)), "results.json")
+
+	// Reuse earlier results so resumed runs do not repeat layout analysis
+	if _, err := os.Stat(resultsFile); os.IsNotExist(err) {
+		cmd := utils.CommandContext(ctx, suryaLayoutCommand, imagePath, "--output_dir", outputDir)
+		var stderrBuilder strings.Builder
+		cmd.Stderr = &stderrBuilder
+		if err := cmd.Run(); err != nil {
+			// A stopped tool may leave partial results behind, which must not be reused
+			os.Remove(resultsFile)
+			return nil, fmt.Errorf("surya_layout execution failed (%v): %s", err, strings.TrimSpace(stderrBuilder.String()))
+		}
+	}
+
+	data, err := os.ReadFile(resultsFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read layout results: %w", err)
+	}
+
+	var results SuryaLayoutResult
+	if err := json.Unmarshal(data, &results); err != nil {
+		os.Remove(resultsFile)
+		return nil, fmt.Errorf("failed to parse layout results: %w", err)
+	}
+
+	var regions []formulaRegion
+	for _, pages := range results {
+		for _, page := range pages {
+			for _, box := range page.Bboxes {
+				if len(box.Bbox) < 4 {
+					continue
+				}
+				label := strings.ToLower(strings.ReplaceAll(box.Label, "-", ""))
+				inline := strings.Contains(label, "inlinemath")
+				if !inline && !strings.Contains(label, "formula") && !strings.Contains(label, "equation") {
+					continue
+				}
+				regions = append(regions, formulaRegion{
+					BBox:   [4]float64{box.Bbox[0], box.Bbox[1], box.Bbox[2], box.Bbox[3]},
+					Inline: inline,
+				})
+			}
+		}
+	}
+
+	e.logger.Debug("surya_layout found %d formula region(s) on page %d", len(regions), pageNum)
+	return sortRegions(regions), nil
+}
+
+// detectFormulaRegionsWithLLM asks an llm-caller template for math regions.
+// The template receives the page as image_url and must answer with a JSON array
+// of {"bbox": [x1, y1, x2, y2], "inline": bool} objects in image pixels.
+func (e *OCRExtractor) detectFormulaRegionsWithLLM(ctx context.Context, imagePath string) ([]formulaRegion, error) {
+	imageData, err := os.ReadFile(imagePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read page image: %w", err)
+	}
+	imageFormat := strings.TrimPrefix(strings.ToLower(filepath.Ext(imagePath)), ".")
+	if imageFormat == "" {
+		imageFormat = "png"
+	}
+	dataURL := fmt.Sprintf("data:image/%s;base64,%s", imageFormat, base64.StdEncoding.EncodeToString(imageData))
+
+	outputDir, err := e.fileManager.CreateIntermediateDir("llm_formula_regions")
+	if err != nil {
+		return nil, err
+	}
+	// Named by the template too, so regions found by another template are never reused
+	outputFile := filepath.Join(outputDir, fmt.Sprintf("%s_%s_regions.json", utils.SanitizeFileName(filepath.Base(imagePath)), cacheKey(e.config.FormulaTemplate)))
+
+	if _, err := os.Stat(outputFile); os.IsNotExist(err) {
+		llmCallerPath, err := findLLMCaller(e.logger)
+		if err != nil {
+			return nil, err
+		}
+		cmd := utils.CommandContext(ctx, llmCallerPath,
+			"call", e.config.FormulaTemplate,
+			"--var", fmt.Sprintf("image_url:text:%s", dataURL),
+			"-o", outputFile)
+		var stderrBuilder strings.Builder
+		cmd.Stderr = &stderrBuilder
+		if err := cmd.Run(); err != nil {
+			// A stopped tool may leave partial results behind, which must not be reused
+			os.Remove(outputFile)
+			return nil, fmt.Errorf("LLM caller execution failed (%v): %s", err, strings.TrimSpace(stderrBuilder.String()))
+		}
+	}
+
+	content, err := os.ReadFile(outputFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read LLM results: %w", err)
+	}
+
+	var regions []formulaRegion
+	if err := json.Unmarshal([]byte(stripCodeFence(string(content))), &regions); err != nil {
+		return nil, fmt.Errorf("formula template did not return a JSON region list: %w", err)
+	}
+
+	return sortRegions(regions), nil
+}
+
+// recognizeFormula crops one region and converts it to LaTeX, caching the result under
+// a key of the formula command and the region, so other settings never reuse it
+func (e *OCRExtractor) recognizeFormula(ctx context.Context, formulasDir string, pageNum, index int, imagePath string, region formulaRegion) (string, error) {
+	fields := e.config.FormulaArgs()
+	if len(fields) == 0 {
+		return "", fmt.Errorf("no formula command configured")
+	}
+
+	key := cacheKey(append(append([]string{}, fields...), fmt.Sprint(region.BBox))...)
+	baseName := fmt.Sprintf("page_%d_formula_%d_%s", pageNum, index, key)
+	texPath := filepath.Join(formulasDir, baseName+".tex")
+	if content, err := os.ReadFile(texPath); err == nil {
+		return string(content), nil
+	}
+
+	cropPath := filepath.Join(formulasDir, baseName+".png")
+	if err := cropImage(imagePath, cropPath, region.BBox, formulaCropPadding); err != nil {
+		return "", err
+	}
+
+	// The command receives the crop path as last argument, or in place of {image}
+	args := make([]string, 0, len(fields))
+	substituted := false
+	for _, field := range fields[1:] {
+		if strings.Contains(field, "{image}") {
+			field = strings.ReplaceAll(field, "{image}", cropPath)
+			substituted = true
+		}
+		args = append(args, field)
+	}
+	if !substituted {
+		args = append(args, cropPath)
+	}
+
+	cmd := utils.CommandContext(ctx, fields[0], args...)
+	var stderrBuilder strings.Builder
+	cmd.Stderr = &stderrBuilder
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("formula command failed (%v): %s", err, strings.TrimSpace(stderrBuilder.String()))
+	}
+
+	formula := cleanLaTeX(string(output))
+	if formula == "" {
+		return "", fmt.Errorf("formula command returned no LaTeX")
+	}
+
+	if err := utils.WriteFileAtomic(texPath, []byte(formula), 0644); err != nil {
+		e.logger.Warn("Failed to cache formula %s: %v", texPath, err)
+	}
+
+	return formula, nil
+}
+
+// cacheKey returns a short hash of the settings a cached result was produced with
+func cacheKey(settings ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(settings, "\x00")))
+	return hex.EncodeToString(sum[:6])
+}
+
+// replaceLinesWithFormulas places the LaTeX of each region among the lines. A display
+// formula replaces the lines whose centre lies in its region, at the position of the
+// first of them; an inline formula replaces the words it overlaps in the line it sits
+// on. Formulas matching no line are returned separately.
+func replaceLinesWithFormulas(lines []types.TextLine, regions []formulaRegion, latex []string) ([]types.TextLine, []string) {
+	owner := make(map[int]int)
+	for i, line := range lines {
+		centerX := (line.BBox[0] + line.BBox[2]) / 2
+		centerY := (line.BBox[1] + line.BBox[3]) / 2
+		for r, region := range regions {
+			if latex[r] == "" || region.Inline {
+				continue
+			}
+			if centerX >= region.BBox[0] && centerX <= region.BBox[2] && centerY >= region.BBox[1] && centerY <= region.BBox[3] {
+				owner[i] = r
+				break
+			}
+		}
+	}
+
+	placed := make(map[int]bool)
+	inline := make(map[int][]int)
+	for r, region := range regions {
+		if latex[r] == "" || !region.Inline {
+			continue
+		}
+		if i := inlineLine(lines, region, owner); i >= 0 {
+			inline[i] = append(inline[i], r)
+			placed[r] = true
+		}
+	}
+
+	result := make([]types.TextLine, 0, len(lines))
+	for i, line := range lines {
+		r, ok := owner[i]
+		if !ok {
+			if spans := inline[i]; len(spans) > 0 {
+				line.Text = spliceFormulas(line, regions, latex, spans)
+			}
+			result = append(result, line)
+			continue
+		}
+		if placed[r] {
+			continue
+		}
+		placed[r] = true
+		result = append(result, types.TextLine{Text: latex[r], BBox: regions[r].BBox, Confidence: line.Confidence})
+	}
+
+	var unplaced []string
+	for r := range regions {
+		if latex[r] != "" && !placed[r] {
+			unplaced = append(unplaced, latex[r])
+		}
+	}
+	return result, unplaced
+}
+
+// inlineLine returns the line an inline region sits on: the one it overlaps
+// horizontally and shares most of its height with, or -1. Lines replaced by
+// display formulas are left out.
+func inlineLine(lines []types.TextLine, region formulaRegion, owner map[int]int) int {
+	best, bestOverlap := -1, 0.0
+	for i, line := range lines {
+		if _, replaced := owner[i]; replaced {
+			continue
+		}
+		if math.Min(line.BBox[2], region.BBox[2]) <= math.Max(line.BBox[0], region.BBox[0]) {
+			continue
+		}
+		overlap := math.Min(line.BBox[3], region.BBox[3]) - math.Max(line.BBox[1], region.BBox[1])
+		height := math.Min(line.BBox[3]-line.BBox[1], region.BBox[3]-region.BBox[1])
+		if overlap >= height/2 && overlap > bestOverlap {
+			best, bestOverlap = i, overlap
+		}
+	}
+	return best
+}
+
+// spliceFormulas replaces the words of a line covered by its inline regions with their
+// LaTeX. Character positions are estimated from the horizontal extent of the line and
+// widened to whole words.
+func spliceFormulas(line types.TextLine, regions []formulaRegion, latex []string, spans []int) string {
+	runes := []rune(line.Text)
+	width := line.BBox[2] - line.BBox[0]
+	if width <= 0 {
+		for _, r := range spans {
+			runes = append(append(runes, ' '), []rune(latex[r])...)
+		}
+		return string(runes)
+	}
+
+	position := func(x float64) int {
+		index := int(math.Round((x - line.BBox[0]) / width * float64(len(runes))))
+		return max(0, min(len(runes), index))
+	}
+
+	// Splice from right to left so the positions of the remaining spans stay valid
+	sort.Slice(spans, func(i, j int) bool { return regions[spans[i]].BBox[0] > regions[spans[j]].BBox[0] })
+	limit := len(runes)
+	for _, r := range spans {
+		start, end := position(regions[r].BBox[0]), min(position(regions[r].BBox[2]), limit)
+		for start > 0 && !unicode.IsSpace(runes[start-1]) {
+			start--
+		}
+		for end < limit && !unicode.IsSpace(runes[end]) {
+			end++
+		}
+		if start > end {
+			start = end
+		}
+		spliced := append(append(append([]rune{}, runes[:start]...), []rune(latex[r])...), runes[end:]...)
+		limit = start
+		runes = spliced
+	}
+	return string(runes)
+}
+
+// sortRegions orders regions top to bottom, left to right
+func sortRegions(regions []formulaRegion) []formulaRegion {
+	sort.SliceStable(regions, func(i, j int) bool {
+		if regions[i].BBox[1] != regions[j].BBox[1] {
+			return regions[i].BBox[1] < regions[j].BBox[1]
+		}
+		return regions[i].BBox[0] < regions[j].BBox[0]
+	})
+	return regions
+}
+
+// wrapLaTeX wraps a formula in inline or display math delimiters
+func wrapLaTeX(formula string, inline bool) string {
+	if inline {
+		return "$" + formula + "$"
+	}
+	return "$$" + formula + "$$"
+}
+
+// cleanLaTeX strips code fences and math delimiters that formula tools add around their output
+func cleanLaTeX(output string) string {
+	formula := strings.TrimSpace(stripCodeFence(output))
+	for _, pair := range [][2]string{{"$$", "$$"}, {`\[`, `\]`}, {`\(`, `\)`}, {"$", "$"}} {
+		if len(formula) > len(pair[0])+len(pair[1]) && strings.HasPrefix(formula, pair[0]) && strings.HasSuffix(formula, pair[1]) {
+			formula = strings.TrimSpace(formula[len(pair[0]) : len(formula)-len(pair[1])])
+			break
+		}
+	}
+	return formula
+}
+
+// stripCodeFence removes a surrounding Markdown code fence from model output
+func stripCodeFence(output string) string {
+	text := strings.TrimSpace(output)
+	if !strings.HasPrefix(text, "```") {
+		return text
+	}
+	text = strings.TrimPrefix(text, "```")
+	if newline := strings.Index(text, "\n"); newline >= 0 {
+		text = text[newline+1:]
+	}
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(text), "```"))
+}
+
+// cropImage writes the padded bbox region of an image to a new PNG file
+func cropImage(imagePath, outputPath string, bbox [4]float64, padding int) error {
+	file, err := os.Open(imagePath)
+	if err != nil {
+		return fmt.Errorf("failed to open page image: %w", err)
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return fmt.Errorf("failed to decode page image: %w", err)
+	}
+
+	rect := image.Rect(int(bbox[0])-padding, int(bbox[1])-padding, int(bbox[2])+padding, int(bbox[3])+padding).Intersect(img.Bounds())
+	if rect.Empty() {
+		return fmt.Errorf("formula region %v is outside the page image", bbox)
+	}
+
+	subImager, ok := img.(interface {
+		SubImage(r image.Rectangle) image.Image
+	})
+	if !ok {
+		return fmt.Errorf("page image does not support cropping")
+	}
+
+	output, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to create formula image: %w", err)
+	}
+	defer output.Close()
+
+	return png.Encode(output, subImager.SubImage(rect))
+}
@@ -0,0 +1,94 @@
+package ocr
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"doc-to-text/pkg/document"
+	"doc-to-text/pkg/types"
+)
+
+// Heading detection limits
+const (
+	headingMinLines  = 3   // Pages with fewer lines have no reliable body text size
+	headingMinRatio  = 1.3 // Line height relative to the median line height
+	headingMaxLength = 80  // Longer lines are body text set in a large font
+)
+
+// detectHeadings finds lines set noticeably larger than the body text of a page and
+// returns their heading level by text: 1 from twice the median height, 2 from 1.6 times,
+// otherwise 3
+func detectHeadings(lines []types.TextLine) map[string]int {
+	var heights []float64
+	for _, line := range lines {
+		if height := line.BBox[3] - line.BBox[1]; height > 0 {
+			heights = append(heights, height)
+		}
+	}
+	if len(heights) < headingMinLines {
+		return nil
+	}
+	sort.Float64s(heights)
+	median := heights[len(heights)/2]
+
+	headings := make(map[string]int)
+	for _, line := range lines {
+		text := strings.TrimSpace(line.Text)
+		ratio := (line.BBox[3] - line.BBox[1]) / median
+		if ratio < headingMinRatio || !looksLikeHeading(text) {
+			continue
+		}
+		switch {
+		case ratio >= 2:
+			headings[text] = 1
+		case ratio >= 1.6:
+			headings[text] = 2
+		default:
+			headings[text] = 3
+		}
+	}
+	return headings
+}
+
+// looksLikeHeading rejects lines that read like sentences or carry no letters
+func looksLikeHeading(text string) bool {
+	if text == "" || len([]rune(text)) > headingMaxLength || strings.ContainsAny(text[len(text)-1:], ".,;") {
+		return false
+	}
+	return strings.IndexFunc(text, unicode.IsLetter) >= 0
+}
+
+// applyHeadings turns paragraph lines recognized as headings into heading blocks,
+// splitting the surrounding paragraph
+func applyHeadings(blocks []document.Block, headings map[string]int) []document.Block {
+	if len(headings) == 0 {
+		return blocks
+	}
+
+	var result []document.Block
+	for _, block := range blocks {
+		if block.Type != document.BlockParagraph {
+			result = append(result, block)
+			continue
+		}
+
+		var paragraph []string
+		flush := func() {
+			if len(paragraph) > 0 {
+				result = append(result, document.Block{Type: document.BlockParagraph, Text: strings.Join(paragraph, "\n")})
+				paragraph = nil
+			}
+		}
+		for _, line := range strings.Split(block.Text, "\n") {
+			if level, ok := headings[strings.TrimSpace(line)]; ok {
+				flush()
+				result = append(result, document.Block{Type: document.BlockHeading, Level: level, Text: strings.TrimSpace(line)})
+				continue
+			}
+			paragraph = append(paragraph, line)
+		}
+		flush()
+	}
+	return result
+}
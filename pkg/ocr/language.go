@@ -0,0 +1,106 @@
+package ocr
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// minDetectionRunes is the minimum number of letters needed before detection is trusted
+const minDetectionRunes = 20
+
+// minScriptShare is the minimum share of letters a script needs to be reported
+const minScriptShare = 0.1
+
+// baseLanguage strips region or script subtags ("zh-hans" -> "zh")
+func baseLanguage(code string) string {
+	if idx := strings.Index(code, "-"); idx > 0 {
+		return code[:idx]
+	}
+	return code
+}
+
+// SuryaLanguages maps ISO codes to Surya's --langs value (ISO 639-1, comma separated)
+func SuryaLanguages(langs []string) string {
+	var codes []string
+	seen := make(map[string]bool)
+	for _, lang := range langs {
+		code := baseLanguage(lang)
+		if !seen[code] {
+			seen[code] = true
+			codes = append(codes, code)
+		}
+	}
+	return strings.Join(codes, ",")
+}
+
+// LLMLanguages maps ISO codes to the value passed to llm-caller templates
+func LLMLanguages(langs []string) string {
+	return strings.Join(langs, ",")
+}
+
+// scriptLanguages maps Unicode scripts to the language reported for them
+var scriptLanguages = []struct {
+	table *unicode.RangeTable
+	lang  string
+}{
+	{unicode.Hiragana, "ja"},
+	{unicode.Katakana, "ja"},
+	{unicode.Hangul, "ko"},
+	{unicode.Han, "zh"},
+	{unicode.Cyrillic, "ru"},
+	{unicode.Arabic, "ar"},
+	{unicode.Hebrew, "he"},
+	{unicode.Greek, "el"},
+	{unicode.Thai, "th"},
+	{unicode.Devanagari, "hi"},
+	{unicode.Latin, "en"},
+}
+
+// DetectLanguages guesses the languages of a text sample from the Unicode scripts it uses.
+// Latin script is reported as English since the script alone cannot tell European languages apart.
+// Returns nil when the sample is too short to be meaningful.
+func DetectLanguages(sample string) []string {
+	counts := make(map[string]int)
+	total := 0
+
+	for _, r := range sample {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		for _, script := range scriptLanguages {
+			if unicode.Is(script.table, r) {
+				counts[script.lang]++
+				total++
+				break
+			}
+		}
+	}
+
+	if total < minDetectionRunes {
+		return nil
+	}
+
+	// Japanese text mixes kana with Han characters, so do not report Chinese separately
+	if counts["ja"] > 0 && counts["zh"] > 0 {
+		counts["ja"] += counts["zh"]
+		delete(counts, "zh")
+	}
+
+	var langs []string
+	for lang, count := range counts {
+		if float64(count)/float64(total) >= minScriptShare {
+			langs = append(langs, lang)
+		}
+	}
+
+	// Most frequent script first, which engines treat as the primary language
+	sort.Slice(langs, func(i, j int) bool {
+		if counts[langs[i]] != counts[langs[j]] {
+			return counts[langs[i]] > counts[langs[j]]
+		}
+		return langs[i] < langs[j]
+	})
+
+	return langs
+}
@@ -3,17 +3,24 @@ package ocr
 import (
 	"bufio"
 	"context"
+	"encoding/json"
 	"fmt"
+	"image"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 
+	"doc-to-text/pkg/cache"
 	"doc-to-text/pkg/config"
 	"doc-to-text/pkg/constants"
+	"doc-to-text/pkg/document"
 	"doc-to-text/pkg/interfaces"
 	"doc-to-text/pkg/logger"
+	"doc-to-text/pkg/metadata"
+	"doc-to-text/pkg/postprocess"
 	"doc-to-text/pkg/types"
 	"doc-to-text/pkg/utils"
 )
@@ -24,6 +31,21 @@ type OCRExtractor struct {
 	config      *config.Config
 	logger      *logger.Logger
 	fileManager *utils.FileManager
+	metadata    map[string]interface{}
+	document    *document.Document        // Structured form of the last extraction
+	confidences map[int]float64           // Mean line confidence per page, when the engine reports it
+	headings    map[int]map[string]int    // Heading lines and their level per page, from line heights
+	tools       []string                  // External commands used by the last extraction
+	states      *PageStates               // Page count and per-page progress of the PDF being processed
+	reusePages  bool                      // Cached page texts were recognized with the current settings
+	languages   []string                  // Language hints: configured, or detected from the first recognized text
+	partial     *interfaces.PartialResult // Pages finished before the last extraction was stopped
+}
+
+// PageFailure records a page the OCR engine could not recognize
+type PageFailure struct {
+	Page  int    `json:"page"`
+	Error string `json:"error"`
 }
 
 // NewOCRExtractor creates a new OCR extractor
@@ -41,9 +63,23 @@ func (e *OCRExtractor) Extract(ctx context.Context, inputFile string) (string, e
 	if err := e.initialize(inputFile); err != nil {
 		return "", err
 	}
+	e.metadata = make(map[string]interface{})
+	e.document = nil
+	e.confidences = make(map[int]float64)
+	e.headings = make(map[int]map[string]int)
+	e.tools = nil
+	e.states = nil
+	e.partial = nil
+	e.languages = e.config.OCRLanguages
+
+	// Get file information
+	fileInfo, err := utils.GetFileInfo(inputFile)
+	if err != nil {
+		return "", utils.WrapError(err, utils.ErrorTypeIO, "failed to get file info")
+	}
 
 	// Check cache
-	if cachedText, found := e.checkCache(); found {
+	if cachedText, found := e.checkCache(fileInfo.Format); found {
 		return cachedText, nil
 	}
 
@@ -54,21 +90,28 @@ func (e *OCRExtractor) Extract(ctx context.Context, inputFile string) (string, e
 	}
 
 	e.logger.ProgressAlways("🔍", "Using OCR engine: %s", engine.Name())
+	e.useTool(string(e.config.OCRStrategy))
+	e.reusePages = e.pageCacheValid(fileInfo.Format)
 
-	// Get file information
-	fileInfo, err := utils.GetFileInfo(inputFile)
+	// Apply configured language hints
+	if len(e.languages) > 0 {
+		e.applyLanguageHints(engine, e.languages)
+	}
+
+	// OCR tools recognize inputs by extension, so hand them a correctly named file
+	sourcePath, err := e.fileManager.GetFormattedInputPath(fileInfo.Format)
 	if err != nil {
-		return "", utils.WrapError(err, utils.ErrorTypeIO, "failed to get file info")
+		return "", utils.WrapError(err, utils.ErrorTypeIO, "failed to prepare input file")
 	}
 
-	// Process based on file type
+	// Process based on detected file type
 	var text string
-	if fileInfo.Extension == "pdf" {
-		text, err = e.processPDF(ctx, inputFile, engine)
-	} else if utils.IsImageFile(fileInfo.Extension) {
-		text, err = e.processImage(ctx, inputFile, engine)
+	if fileInfo.Format == "pdf" {
+		text, err = e.processPDF(ctx, sourcePath, engine)
+	} else if utils.IsImageFile(fileInfo.Format) {
+		text, err = e.processImage(ctx, sourcePath, engine)
 	} else {
-		return "", fmt.Errorf("unsupported file type for OCR: %s", fileInfo.Extension)
+		return "", fmt.Errorf("unsupported file type for OCR: %s", fileInfo.Format)
 	}
 
 	if err != nil {
@@ -97,25 +140,65 @@ func (e *OCRExtractor) initialize(inputFile string) error {
 		return err
 	}
 
-	e.fileManager = utils.NewFileManager(inputFile, fileInfo.MD5Hash, e.logger)
+	e.fileManager = e.config.CreateFileManager(inputFile, fileInfo.MD5Hash, e.logger)
 	return e.fileManager.EnsureBaseDir()
 }
 
-// checkCache checks for cached results
-func (e *OCRExtractor) checkCache() (string, bool) {
+// checkCache checks for cached results, ignoring them when the manifest shows they
+// were built with other settings
+func (e *OCRExtractor) checkCache(format string) (string, bool) {
 	if !e.config.SkipExisting || e.fileManager == nil {
 		return "", false
 	}
 
 	textFilePath := e.fileManager.GetTextFilePath()
+	if manifest := cache.ReadManifest(e.fileManager.GetBasePath()); manifest != nil {
+		settings := manifest.Settings
+		if output, ok := manifest.Outputs[filepath.Base(textFilePath)]; ok {
+			settings = output.Settings
+		}
+		if settings != nil && len(e.config.SettingsChanges(settings, format)) > 0 {
+			e.logger.Debug("Cached OCR results were built with other settings, recognizing again")
+			return "", false
+		}
+	}
 	if content, err := os.ReadFile(textFilePath); err == nil {
 		e.logger.Progress("📄", "Loading cached OCR results from: %s", textFilePath)
+		e.loadRemovedLines()
 		return string(content), true
 	}
 
 	return "", false
 }
 
+// pageCacheValid reports whether cached page texts were recognized with the current
+// engine, template, languages and resolution, per the manifest of the last run
+func (e *OCRExtractor) pageCacheValid(format string) bool {
+	manifest := cache.ReadManifest(e.fileManager.GetBasePath())
+	if manifest == nil || manifest.Settings == nil {
+		return true
+	}
+	for _, change := range e.config.SettingsChanges(manifest.Settings, format) {
+		if slices.Contains(config.OCRPageSettings, change.Key) {
+			e.logger.Progress("🔁", "Cached page texts were recognized with other settings (%s), recognizing again", change)
+			return false
+		}
+	}
+	return true
+}
+
+// useTool records an external command used by the extraction
+func (e *OCRExtractor) useTool(command string) {
+	if command != "" && !slices.Contains(e.tools, command) {
+		e.tools = append(e.tools, command)
+	}
+}
+
+// ExtractionTools returns the external commands used by the last extraction
+func (e *OCRExtractor) ExtractionTools() []string {
+	return e.tools
+}
+
 // saveCache saves results to cache
 func (e *OCRExtractor) saveCache(text string) {
 	if e.fileManager == nil {
@@ -123,7 +206,7 @@ func (e *OCRExtractor) saveCache(text string) {
 	}
 
 	textFilePath := e.fileManager.GetTextFilePath()
-	if err := os.WriteFile(textFilePath, []byte(text), 0644); err != nil {
+	if err := utils.WriteFileAtomic(textFilePath, []byte(text), 0644); err != nil {
 		e.logger.Warn("Failed to save cache: %v", err)
 	}
 }
@@ -137,7 +220,15 @@ func (e *OCRExtractor) processPDF(ctx context.Context, inputFile string, engine
 
 // processImage processes image files
 func (e *OCRExtractor) processImage(ctx context.Context, inputFile string, engine interfaces.OCREngine) (string, error) {
-	return engine.ExtractTextFromImage(ctx, inputFile)
+	text, err := engine.ExtractTextFromImage(ctx, inputFile)
+	if err != nil {
+		return "", err
+	}
+
+	e.detectLanguageHints(engine, text)
+	text = e.applyPageStages(ctx, 1, text, inputFile, inputFile, engine)
+	e.document = e.buildDocument([]postprocess.Page{{Number: 1, Text: text}}, engine, false)
+	return text, nil
 }
 
 // processPDFByPages processes PDF page by page (simplified sequential version)
@@ -150,52 +241,57 @@ func (e *OCRExtractor) processPDFByPages(ctx context.Context, inputFile string,
 
 	e.logger.Progress("📂", "Created pages directory: %s", pagesDir)
 
-	// Check for existing split page files
-	existingPageCount := e.countPages(pagesDir)
-	var totalPages int
-	var err error
-
-	if existingPageCount > 0 {
-		e.logger.Progress("⏭️", "Found %d existing page files, resuming from there", existingPageCount)
-		totalPages = existingPageCount
-	} else {
-		// Split PDF into individual pages
-		e.logger.ProgressAlways("✂️", "Splitting PDF into individual pages...")
-		totalPages, err = e.splitPDFIntoPages(ctx, inputFile, pagesDir)
-		if err != nil {
-			return "", utils.WrapError(err, utils.ErrorTypeOCR, "failed to split PDF into pages")
-		}
-		e.logger.ProgressAlways("✅", "Successfully split PDF into %d pages", totalPages)
+	if err := e.preparePages(ctx, inputFile, pagesDir); err != nil {
+		return "", err
 	}
-
+	totalPages := e.states.TotalPages
 	if totalPages == 0 {
 		return "", utils.NewOCRError("no pages found in PDF", nil)
 	}
 
 	e.logger.ProgressAlways("🔄", "Processing %d pages with OCR engine: %s", totalPages, engine.Name())
 
+	// Pages that failed before are only retried on request, or once the OCR settings changed
+	retryFailed := e.config.RetryFailedPages || !e.reusePages
+
 	// Process each page sequentially
-	var allText strings.Builder
-	var errors []error
+	var pages []postprocess.Page
+	var failures []PageFailure
 	successCount := 0
 
 	for pageNum := 1; pageNum <= totalPages; pageNum++ {
+		// Stop between pages once the run is cancelled or timed out; finished pages stay cached
+		if err := ctx.Err(); err != nil {
+			return "", e.stopped(err, pages, pageNum-1, totalPages)
+		}
+
+		if state := e.states.Get(pageNum); state.Status == PageStateFailed && !retryFailed {
+			e.logger.Warn("Page %d failed in an earlier run (%s); use 'doc-to-text retry-failed' to try again", pageNum, state.Error)
+			failures = append(failures, PageFailure{Page: pageNum, Error: state.Error})
+			utils.ReportProgress(ctx, pageNum, totalPages)
+			continue
+		}
+
 		// Only show detailed page processing in verbose mode
 		e.logger.Progress("📄", "Processing page %d/%d", pageNum, totalPages)
 
 		pageText, err := e.processPageWithProgress(ctx, pageNum, totalPages, engine)
+		if err != nil && ctx.Err() != nil {
+			// The page was cut short by the cancellation, it is not a failure
+			return "", e.stopped(ctx.Err(), pages, pageNum-1, totalPages)
+		}
 		if err != nil {
 			e.logger.Warn("Failed to process page %d: %v", pageNum, err)
-			errors = append(errors, fmt.Errorf("page %d failed: %w", pageNum, err))
+			failures = append(failures, PageFailure{Page: pageNum, Error: err.Error()})
+			utils.ReportProgress(ctx, pageNum, totalPages)
 			continue
 		}
 
 		if pageText != "" {
-			allText.WriteString(fmt.Sprintf("--- Page %d ---\n", pageNum))
-			allText.WriteString(pageText)
-			allText.WriteString("\n\n")
+			pages = append(pages, postprocess.Page{Number: pageNum, Text: pageText})
 			successCount++
 		}
+		utils.ReportProgress(ctx, pageNum, totalPages)
 
 		// Show progress every 10 pages or at milestones
 		if pageNum%10 == 0 || pageNum == totalPages || pageNum == 1 {
@@ -208,8 +304,18 @@ func (e *OCRExtractor) processPDFByPages(ctx context.Context, inputFile string,
 	}
 
 	e.logger.ProgressAlways("📊", "Processing completed: %d/%d pages successful", successCount, totalPages)
+	if len(failures) > 0 {
+		e.metadata["failed_pages"] = failures
+	}
+
+	// Strip running headers, footers and page numbers repeated across pages
+	if e.config.RemoveHeadersFooters {
+		pages = e.removeRepeatedHeadersFooters(pages)
+	}
+
+	e.document = e.buildDocument(pages, engine, true)
 
-	finalText := strings.TrimSpace(allText.String())
+	finalText := joinPages(pages)
 	if finalText == "" {
 		return "", utils.NewOCRError("no text extracted from any page", nil)
 	}
@@ -219,11 +325,221 @@ func (e *OCRExtractor) processPDFByPages(ctx context.Context, inputFile string,
 	return finalText, nil
 }
 
+// stopped records the pages finished before OCR was cancelled or timed out as the
+// partial result, and returns the error reporting the stop
+func (e *OCRExtractor) stopped(err error, pages []postprocess.Page, done, totalPages int) error {
+	e.partial = &interfaces.PartialResult{Text: joinPages(pages), PagesDone: done, TotalPages: totalPages}
+	return utils.WrapError(err, "", fmt.Sprintf("OCR stopped after %d of %d pages", done, totalPages))
+}
+
+// joinPages joins page texts under "--- Page N ---" markers
+func joinPages(pages []postprocess.Page) string {
+	var allText strings.Builder
+	for _, page := range pages {
+		allText.WriteString(fmt.Sprintf("--- Page %d ---\n", page.Number))
+		allText.WriteString(page.Text)
+		allText.WriteString("\n\n")
+	}
+	return strings.TrimSpace(allText.String())
+}
+
+// removeRepeatedHeadersFooters strips boilerplate lines and records them in metadata and removed_lines.json
+func (e *OCRExtractor) removeRepeatedHeadersFooters(pages []postprocess.Page) []postprocess.Page {
+	cleaned, removed := postprocess.RemoveRepeatedHeadersFooters(pages)
+	if len(removed) == 0 {
+		return pages
+	}
+
+	e.logger.Progress("🧹", "Removed %d repeated header/footer lines across %d pages", len(removed), len(pages))
+	e.metadata["removed_lines"] = removed
+
+	data, err := json.MarshalIndent(removed, "", "  ")
+	if err == nil {
+		err = utils.WriteFileAtomic(e.fileManager.GetRemovedLinesPath(), data, 0644)
+	}
+	if err != nil {
+		e.logger.Warn("Failed to save removed lines: %v", err)
+	}
+
+	return cleaned
+}
+
+// loadRemovedLines restores the removed-lines record of a cached result into metadata
+func (e *OCRExtractor) loadRemovedLines() {
+	data, err := os.ReadFile(e.fileManager.GetRemovedLinesPath())
+	if err != nil {
+		return
+	}
+	var removed []postprocess.RemovedLine
+	if err := json.Unmarshal(data, &removed); err == nil && len(removed) > 0 {
+		e.metadata["removed_lines"] = removed
+	}
+}
+
+// buildDocument converts recognized pages into a structured document
+func (e *OCRExtractor) buildDocument(pages []postprocess.Page, engine interfaces.OCREngine, paged bool) *document.Document {
+	doc := &document.Document{Paged: paged}
+	for _, page := range pages {
+		doc.Pages = append(doc.Pages, document.Page{
+			Number:     page.Number,
+			Method:     document.MethodOCR,
+			Engine:     engine.Name(),
+			Confidence: e.confidences[page.Number],
+			Blocks:     applyHeadings(document.ParseBlocks(page.Text), e.headings[page.Number]),
+		})
+	}
+	return doc
+}
+
+// ExtractionDocument returns the structured document of the last extraction
+func (e *OCRExtractor) ExtractionDocument() *document.Document {
+	return e.document
+}
+
+// PartialResult returns the pages finished before the last extraction was stopped
+func (e *OCRExtractor) PartialResult() *interfaces.PartialResult {
+	return e.partial
+}
+
+// ExtractionMetadata returns metadata collected during the last extraction
+func (e *OCRExtractor) ExtractionMetadata() map[string]interface{} {
+	return e.metadata
+}
+
 // processPageWithProgress processes a single page with progress tracking
 func (e *OCRExtractor) processPageWithProgress(ctx context.Context, pageNum, totalPages int, engine interfaces.OCREngine) (string, error) {
+	text, err := e.recognizePage(ctx, pageNum, totalPages, engine)
+	if err != nil {
+		return "", err
+	}
+	e.detectLanguageHints(engine, text)
+
+	sourcePath := e.fileManager.GetPagePDFPath(pageNum)
+	if !engine.SupportsDirectPDF() {
+		sourcePath = e.fileManager.GetPageImagePath(pageNum)
+	}
+
+	return e.applyPageStages(ctx, pageNum, text, sourcePath, e.fileManager.GetPageImagePath(pageNum), engine), nil
+}
+
+// applyPageStages runs the optional per-page stages: layout recognition, then LLM correction
+func (e *OCRExtractor) applyPageStages(ctx context.Context, pageNum int, text, sourcePath, imagePath string, engine interfaces.OCREngine) string {
+	lines := e.pageTextLines(pageNum, sourcePath, engine)
+	if confidence, ok := pageConfidence(lines); ok {
+		e.confidences[pageNum] = confidence
+	}
+	e.headings[pageNum] = detectHeadings(lines)
+
+	text = e.applyLayoutStages(ctx, pageNum, text, sourcePath, imagePath, engine)
+
+	if e.config.CorrectionTemplate != "" {
+		text = e.correctPage(ctx, pageNum, text, sourcePath, imagePath, engine)
+	}
+
+	return text
+}
+
+// applyLayoutStages runs the optional layout-aware stages (formulas, then tables) on a page.
+// sourcePath is the file the OCR engine read; imagePath is the rendered page image.
+func (e *OCRExtractor) applyLayoutStages(ctx context.Context, pageNum int, text, sourcePath, imagePath string, engine interfaces.OCREngine) string {
+	detectFormulas := len(e.config.FormulaArgs()) > 0
+	detectTables := e.config.DetectTables || e.config.OutputFormat == types.OutputFormatMarkdown
+	if !detectFormulas && !detectTables {
+		return text
+	}
+
+	// Line positions let stages replace recognized regions in place; the regions are
+	// found on the page image, so the lines are brought to its coordinates
+	lines := e.pageTextLines(pageNum, sourcePath, engine)
+	if sourcePath != imagePath && len(lines) > 0 {
+		lines = e.linesOnPageImage(ctx, pageNum, lines, sourcePath, imagePath, engine)
+	}
+
+	if detectFormulas {
+		text, lines = e.recognizeFormulas(ctx, pageNum, text, lines, sourcePath, imagePath)
+	}
+
+	if detectTables {
+		text = e.recognizeTables(ctx, pageNum, text, lines, sourcePath, imagePath)
+	}
+
+	return text
+}
+
+// pageTextLines returns the positioned text lines of a page when the engine provides them
+func (e *OCRExtractor) pageTextLines(pageNum int, sourcePath string, engine interfaces.OCREngine) []types.TextLine {
+	provider, ok := engine.(interfaces.TextLineProvider)
+	if !ok {
+		return nil
+	}
+	lines, err := provider.TextLines(sourcePath)
+	if err != nil {
+		e.logger.Debug("No text line positions for page %d: %v", pageNum, err)
+		return nil
+	}
+	return lines
+}
+
+// linesOnPageImage scales the lines an engine recognized on its own rendering of a
+// page PDF to the rendered page image. Without the sizes of both, the lines are
+// returned unchanged.
+func (e *OCRExtractor) linesOnPageImage(ctx context.Context, pageNum int, lines []types.TextLine, sourcePath, imagePath string, engine interfaces.OCREngine) []types.TextLine {
+	provider, ok := engine.(interfaces.PageSizeProvider)
+	if !ok {
+		return lines
+	}
+	pageWidth, pageHeight, err := provider.PageSize(sourcePath)
+	if err != nil {
+		e.logger.Debug("No page size for page %d: %v", pageNum, err)
+		return lines
+	}
+	if err := e.ensurePageImage(ctx, sourcePath, imagePath); err != nil {
+		e.logger.Debug("No page image for page %d: %v", pageNum, err)
+		return lines
+	}
+	imageWidth, imageHeight, err := imageSize(imagePath)
+	if err != nil {
+		e.logger.Debug("Could not read the size of page image %d: %v", pageNum, err)
+		return lines
+	}
+
+	scaleX, scaleY := float64(imageWidth)/pageWidth, float64(imageHeight)/pageHeight
+	scaled := make([]types.TextLine, len(lines))
+	for i, line := range lines {
+		line.BBox = [4]float64{line.BBox[0] * scaleX, line.BBox[1] * scaleY, line.BBox[2] * scaleX, line.BBox[3] * scaleY}
+		scaled[i] = line
+	}
+	return scaled
+}
+
+// imageSize returns the pixel size of an image file
+func imageSize(path string) (int, int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer file.Close()
+	config, _, err := image.DecodeConfig(file)
+	if err != nil {
+		return 0, 0, err
+	}
+	return config.Width, config.Height, nil
+}
+
+// ensurePageImage renders the page image when the OCR engine read the PDF directly
+func (e *OCRExtractor) ensurePageImage(ctx context.Context, sourcePath, imagePath string) error {
+	if _, err := os.Stat(imagePath); err == nil {
+		return nil
+	}
+	return e.convertPDFToImage(ctx, sourcePath, imagePath)
+}
+
+// recognizePage returns the OCR text of a single page, using the page cache when
+// available, and records the page's progress in the page states
+func (e *OCRExtractor) recognizePage(ctx context.Context, pageNum, totalPages int, engine interfaces.OCREngine) (string, error) {
 	// Check for cached page text first
 	pageTextPath := e.fileManager.GetPageTextPath(pageNum)
-	if content, err := os.ReadFile(pageTextPath); err == nil {
+	if content, err := os.ReadFile(pageTextPath); err == nil && e.reusePages {
 		e.logger.Progress("⏭️", "Loaded cached text for page %d/%d", pageNum, totalPages)
 		return string(content), nil
 	}
@@ -241,93 +557,301 @@ func (e *OCRExtractor) processPageWithProgress(ctx context.Context, pageNum, tot
 	if engine.SupportsDirectPDF() {
 		text, err = engine.ExtractTextFromPDF(ctx, pagePDFPath)
 	} else {
-		// Convert PDF page to image first
+		// Convert PDF page to image first, unless an earlier run rendered it with the same settings
 		pageImagePath := e.fileManager.GetPageImagePath(pageNum)
-		e.logger.Progress("🖼️", "Converting page %d/%d to image", pageNum, totalPages)
-
-		if err := e.convertPDFToImage(ctx, pagePDFPath, pageImagePath); err != nil {
-			return "", utils.WrapError(err, utils.ErrorTypeConversion,
-				fmt.Sprintf("failed to convert page %d to image", pageNum))
+		if _, statErr := os.Stat(pageImagePath); statErr != nil || !e.reusePages {
+			e.logger.Progress("🖼️", "Converting page %d/%d to image", pageNum, totalPages)
+			if err := e.convertPDFToImage(ctx, pagePDFPath, pageImagePath); err != nil {
+				e.pageFailed(ctx, pageNum, err)
+				return "", utils.WrapError(err, utils.ErrorTypeConversion,
+					fmt.Sprintf("failed to convert page %d to image", pageNum))
+			}
+			e.setPageState(pageNum, PageStateRendered, "")
 		}
 
 		text, err = engine.ExtractTextFromImage(ctx, pageImagePath)
 	}
 
 	if err != nil {
+		e.pageFailed(ctx, pageNum, err)
 		return "", utils.WrapError(err, utils.ErrorTypeOCR,
 			fmt.Sprintf("failed to extract text from page %d", pageNum))
 	}
 
-	// Save page text to cache
-	if text != "" {
-		if writeErr := os.WriteFile(pageTextPath, []byte(text), 0644); writeErr != nil {
-			e.logger.Warn("Failed to cache page %d text: %v", pageNum, writeErr)
-		}
+	// Save page text to cache, empty pages included so they are not recognized again
+	if writeErr := utils.WriteFileAtomic(pageTextPath, []byte(text), constants.DefaultFilePermission); writeErr != nil {
+		e.logger.Warn("Failed to cache page %d text: %v", pageNum, writeErr)
 	}
+	e.setPageState(pageNum, PageStateOCRDone, "")
 
 	e.logger.Progress("✅", "Completed page %d/%d, extracted %d characters", pageNum, totalPages, len(text))
 
 	return text, nil
 }
 
-// splitPDFIntoPages splits a PDF into individual page files
-func (e *OCRExtractor) splitPDFIntoPages(ctx context.Context, inputFile, outputDir string) (int, error) {
+// pageFailed records a page whose recognition failed, unless the run was cancelled
+func (e *OCRExtractor) pageFailed(ctx context.Context, pageNum int, err error) {
+	if ctx.Err() == nil {
+		e.setPageState(pageNum, PageStateFailed, err.Error())
+	}
+}
+
+// setPageState records the progress of a page; a state that cannot be saved only
+// costs the ability to resume
+func (e *OCRExtractor) setPageState(pageNum int, status, reason string) {
+	if e.states == nil {
+		return
+	}
+	if err := e.states.Set(pageNum, status, reason); err != nil {
+		e.logger.Warn("Failed to save state of page %d: %v", pageNum, err)
+	}
+}
+
+// applyLanguageHints passes language hints to engines that support them
+func (e *OCRExtractor) applyLanguageHints(engine interfaces.OCREngine, langs []string) {
+	if langAware, ok := engine.(interfaces.LanguageAwareOCREngine); ok {
+		langAware.SetLanguages(langs)
+		e.logger.Info("OCR language hints: %s", strings.Join(langs, ", "))
+	} else {
+		e.logger.Debug("OCR engine %s does not support language hints", engine.Name())
+	}
+}
+
+// detectLanguageHints makes the languages detected from the first recognized text the
+// hints for the rest of the document and its correction, unless hints were configured
+func (e *OCRExtractor) detectLanguageHints(engine interfaces.OCREngine, text string) {
+	if len(e.languages) > 0 {
+		return
+	}
+	if detected := DetectLanguages(text); len(detected) > 0 {
+		e.logger.Progress("🌐", "Detected languages: %s", strings.Join(detected, ", "))
+		e.languages = detected
+		e.applyLanguageHints(engine, detected)
+	}
+}
+
+// preparePages makes sure every page of the PDF has its own file. The page count
+// is determined up front and kept in the page state file with the progress of each
+// page, so a resumed run only splits the pages that are missing.
+func (e *OCRExtractor) preparePages(ctx context.Context, inputFile, pagesDir string) error {
+	statePath := e.fileManager.GetPageStatePath()
+	e.states = ReadPageStates(statePath)
+	if e.states == nil {
+		e.states = newPageStates(statePath, e.pdfPageCount(ctx, inputFile))
+		// Keep complete pages split by earlier versions, which kept no state
+		for pageNum := 1; pageNum <= e.states.TotalPages; pageNum++ {
+			if completePDF(e.fileManager.GetPagePDFPath(pageNum)) {
+				e.states.mark(pageNum, PageStateSplit, "")
+			}
+		}
+	}
+
+	// Without a known page count, a complete split tells
+	if e.states.TotalPages == 0 {
+		e.logger.ProgressAlways("✂️", "Splitting PDF into individual pages...")
+		count, err := e.splitPDFIntoPages(ctx, inputFile, pagesDir, 0, 0)
+		if err != nil {
+			return utils.WrapError(err, utils.ErrorTypeOCR, "failed to split PDF into pages")
+		}
+		e.states.resize(count)
+		for pageNum := 1; pageNum <= count; pageNum++ {
+			e.states.mark(pageNum, PageStateSplit, "")
+		}
+		e.logger.ProgressAlways("✅", "Successfully split PDF into %d pages", count)
+		return e.states.save()
+	}
+
+	missing := e.missingPages()
+	totalPages := e.states.TotalPages
+	switch {
+	case len(missing) == 0:
+		e.logger.Progress("⏭️", "Found all %d page files, resuming from there", totalPages)
+		return e.states.save()
+	case len(missing) == totalPages:
+		e.logger.ProgressAlways("✂️", "Splitting PDF into individual pages...")
+	default:
+		e.logger.ProgressAlways("✂️", "Splitting %d missing of %d pages...", len(missing), totalPages)
+	}
+
+	for _, pageRange := range pageRanges(missing) {
+		first, last := pageRange[0], pageRange[1]
+		if _, err := e.splitPDFIntoPages(ctx, inputFile, pagesDir, first, last); err != nil {
+			return utils.WrapError(err, utils.ErrorTypeOCR, "failed to split PDF into pages")
+		}
+		for pageNum := first; pageNum <= last; pageNum++ {
+			if e.states.Get(pageNum).Status == PageStatePending {
+				e.states.mark(pageNum, PageStateSplit, "")
+			}
+		}
+	}
+	e.logger.ProgressAlways("✅", "Successfully split PDF into %d pages", totalPages)
+	return e.states.save()
+}
+
+// missingPages returns the pages not split yet, or whose page file is gone
+func (e *OCRExtractor) missingPages() []int {
+	var missing []int
+	for _, page := range e.states.Pages {
+		if _, err := os.Stat(e.fileManager.GetPagePDFPath(page.Number)); err != nil || page.Status == PageStatePending {
+			missing = append(missing, page.Number)
+		}
+	}
+	return missing
+}
+
+// pageRanges groups sorted page numbers into ranges of consecutive pages
+func pageRanges(pages []int) [][2]int {
+	var ranges [][2]int
+	for _, page := range pages {
+		if n := len(ranges); n > 0 && ranges[n-1][1] == page-1 {
+			ranges[n-1][1] = page
+			continue
+		}
+		ranges = append(ranges, [2]int{page, page})
+	}
+	return ranges
+}
+
+// pdfPageCount returns the page count from the PDF's page tree, asking Ghostscript
+// when the file cannot be parsed, or 0 when neither knows
+func (e *OCRExtractor) pdfPageCount(ctx context.Context, pdfPath string) int {
+	if props, err := metadata.ReadFile(pdfPath, "pdf"); err == nil && props.PageCount > 0 {
+		return props.PageCount
+	}
+
+	gsPath, err := e.findGhostscriptPath()
+	if err != nil {
+		return 0
+	}
+	script := fmt.Sprintf("(%s) (r) file runpdfbegin pdfpagecount = quit", postScriptString(pdfPath))
+	output, err := utils.CommandContext(ctx, gsPath, "-q", "-dNODISPLAY", "-dSAFER", "-dBATCH", "-dNOPAUSE",
+		"--permit-file-read="+pdfPath, "-c", script).Output()
+	if err != nil {
+		e.logger.Debug("Ghostscript could not count the pages of %s: %v", pdfPath, err)
+		return 0
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(string(output)))
+	if err != nil || count < 0 {
+		return 0
+	}
+	return count
+}
+
+// postScriptString escapes a string for a PostScript string literal
+func postScriptString(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "(", `\(`, ")", `\)`).Replace(value)
+}
+
+// completePDF reports whether a PDF file ends with its end-of-file marker, which
+// a split interrupted while writing the file does not
+func completePDF(path string) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	tail := make([]byte, min(info.Size(), 1024))
+	if _, err := file.ReadAt(tail, info.Size()-int64(len(tail))); err != nil {
+		return false
+	}
+	return strings.Contains(string(tail), "%%EOF")
+}
+
+// splitPDFIntoPages splits pages first to last of a PDF (all pages when first is 0)
+// into page files in outputDir and returns the number of pages written. Ghostscript
+// writes into a scratch directory whose files are moved into place once it succeeds,
+// so an interrupted split never leaves partial pages behind.
+func (e *OCRExtractor) splitPDFIntoPages(ctx context.Context, inputFile, outputDir string, first, last int) (int, error) {
 	gsPath, err := e.findGhostscriptPath()
 	if err != nil {
 		return 0, utils.WrapError(err, utils.ErrorTypeSystem, "Ghostscript not found")
 	}
+	e.useTool(gsPath)
+
+	splitDir := filepath.Join(outputDir, "split.tmp")
+	os.RemoveAll(splitDir)
+	if err := utils.EnsureDir(splitDir); err != nil {
+		return 0, utils.WrapError(err, utils.ErrorTypeIO, "failed to create split directory")
+	}
+	defer os.RemoveAll(splitDir)
 
 	// Use Ghostscript to split PDF into pages
-	cmd := exec.CommandContext(ctx, gsPath,
-		"-sDEVICE=pdfwrite",
-		"-dNOPAUSE",
-		"-dBATCH",
-		"-dSAFER",
-		fmt.Sprintf("-sOutputFile=%s", filepath.Join(outputDir, constants.PDFPageFilePattern)),
-		inputFile)
+	args := []string{"-sDEVICE=pdfwrite", "-dNOPAUSE", "-dBATCH", "-dSAFER"}
+	if first > 0 {
+		args = append(args, fmt.Sprintf("-dFirstPage=%d", first), fmt.Sprintf("-dLastPage=%d", last))
+	}
+	args = append(args, fmt.Sprintf("-sOutputFile=%s", filepath.Join(splitDir, constants.PDFPageFilePattern)), inputFile)
+	cmd := utils.CommandContext(ctx, gsPath, args...)
 
 	if err := cmd.Run(); err != nil {
 		return 0, utils.WrapError(err, utils.ErrorTypeConversion, "failed to split PDF with Ghostscript")
 	}
 
-	// Count the generated pages
-	pageCount := e.countPages(outputDir)
+	// Ghostscript numbers the files it writes from 1
+	pageCount := countPages(splitDir)
+	offset := 0
+	if first > 0 {
+		if pageCount != last-first+1 {
+			return 0, utils.NewError(utils.ErrorTypeConversion,
+				fmt.Sprintf("Ghostscript wrote %d files for pages %d-%d", pageCount, first, last), nil)
+		}
+		offset = first - 1
+	}
+	for i := 1; i <= pageCount; i++ {
+		from := filepath.Join(splitDir, fmt.Sprintf(constants.PDFPageFilePattern, i))
+		to := filepath.Join(outputDir, fmt.Sprintf(constants.PDFPageFilePattern, i+offset))
+		if err := os.Rename(from, to); err != nil {
+			return 0, utils.WrapError(err, utils.ErrorTypeIO, "failed to move split page")
+		}
+	}
 	return pageCount, nil
 }
 
-// convertPDFToImage converts a PDF page to an image
+// convertPDFToImage converts a PDF page to an image, renaming it into place once complete
 func (e *OCRExtractor) convertPDFToImage(ctx context.Context, pdfPath, imagePath string) error {
 	gsPath, err := e.findGhostscriptPath()
 	if err != nil {
 		return utils.WrapError(err, utils.ErrorTypeSystem, "Ghostscript not found")
 	}
+	e.useTool(gsPath)
 
-	cmd := exec.CommandContext(ctx, gsPath,
+	tempPath := imagePath + ".tmp"
+	defer os.Remove(tempPath)
+	cmd := utils.CommandContext(ctx, gsPath,
 		"-sDEVICE=png16m",
 		"-dNOPAUSE",
 		"-dBATCH",
 		"-dSAFER",
-		"-r300", // 300 DPI for good quality
-		fmt.Sprintf("-sOutputFile=%s", imagePath),
+		fmt.Sprintf("-r%d", constants.PDFRenderDPI),
+		fmt.Sprintf("-sOutputFile=%s", tempPath),
 		pdfPath)
 
 	if err := cmd.Run(); err != nil {
 		return utils.WrapError(err, utils.ErrorTypeConversion,
 			fmt.Sprintf("failed to convert PDF to image: %s", pdfPath))
 	}
+	if err := os.Rename(tempPath, imagePath); err != nil {
+		return utils.WrapError(err, utils.ErrorTypeIO, "failed to save page image")
+	}
 
 	return nil
 }
 
-// countPages counts existing page files in a directory
-func (e *OCRExtractor) countPages(dir string) int {
+// countPages counts the page files in a directory
+func countPages(dir string) int {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0
+	}
 	count := 0
-	for i := 1; i <= 10000; i++ { // Reasonable upper limit
-		pageFile := filepath.Join(dir, fmt.Sprintf(constants.PDFPageFilePattern, i))
-		if _, err := os.Stat(pageFile); err == nil {
+	for _, entry := range entries {
+		var pageNum int
+		if _, err := fmt.Sscanf(entry.Name(), constants.PDFPageFilePattern, &pageNum); err == nil && pageNum > 0 {
 			count++
-		} else {
-			break
 		}
 	}
 	return count
@@ -344,20 +868,34 @@ func (e *OCRExtractor) findGhostscriptPath() (string, error) {
 	return "", fmt.Errorf("Ghostscript not found. Please install Ghostscript")
 }
 
-// selectOCREngine selects an appropriate OCR engine
-func (e *OCRExtractor) selectOCREngine() (interfaces.OCREngine, error) {
-	// If strategy is set, use it directly
-	if e.config.OCRStrategy != types.OCRStrategyInteractive {
-		return e.createOCREngine(e.config.OCRStrategy)
-	}
+// selectionMu serializes OCR tool prompts when files are processed in parallel
+var selectionMu sync.Mutex
 
-	// Interactive selection
-	strategy, err := e.promptUserSelection()
-	if err != nil {
-		return nil, err
+// SelectStrategy picks the OCR tool of an interactive strategy: the user chooses
+// among the installed tools when cfg allows prompting, otherwise the first
+// installed one is used
+func SelectStrategy(cfg *config.Config, log *logger.Logger) (types.OCRStrategy, error) {
+	selectionMu.Lock()
+	defer selectionMu.Unlock()
+
+	selector := &OCRExtractor{config: cfg, logger: log}
+	if cfg.Interactive {
+		return selector.promptUserSelection()
 	}
+	return selector.autoSelectStrategy()
+}
 
-	return e.createOCREngine(strategy)
+// selectOCREngine selects an appropriate OCR engine. An interactive strategy not
+// settled before the run is chosen here, for this extractor's configuration only.
+func (e *OCRExtractor) selectOCREngine() (interfaces.OCREngine, error) {
+	if e.config.OCRStrategy == types.OCRStrategyInteractive {
+		strategy, err := SelectStrategy(e.config, e.logger)
+		if err != nil {
+			return nil, err
+		}
+		e.config.OCRStrategy = strategy
+	}
+	return e.createOCREngine(e.config.OCRStrategy)
 }
 
 // promptUserSelection prompts user to select OCR strategy
@@ -423,6 +961,18 @@ func (e *OCRExtractor) promptForLLMTemplate(reader *bufio.Reader) (string, error
 	return template, nil
 }
 
+// autoSelectStrategy picks the first installed OCR tool that needs no further input
+func (e *OCRExtractor) autoSelectStrategy() (types.OCRStrategy, error) {
+	for _, strategy := range e.getAvailableStrategies() {
+		if strategy == types.OCRStrategyLLMCaller && e.config.LLMTemplate == "" {
+			continue
+		}
+		e.logger.Progress("🔍", "Selected OCR tool: %s", strategy)
+		return strategy, nil
+	}
+	return "", utils.NewNotFoundError("no OCR tool available (install surya_ocr, or llm-caller with an LLM template)", nil)
+}
+
 // getAvailableStrategies returns list of available OCR strategies
 func (e *OCRExtractor) getAvailableStrategies() []types.OCRStrategy {
 	var strategies []types.OCRStrategy
@@ -461,7 +1011,7 @@ func (e *OCRExtractor) isSuryaOCRAvailable() bool {
 
 // SupportsFile checks if this extractor supports the file type
 func (e *OCRExtractor) SupportsFile(fileInfo *types.FileInfo) bool {
-	return fileInfo.Extension == "pdf" || utils.IsImageFile(fileInfo.Extension)
+	return fileInfo.Format == "pdf" || utils.IsImageFile(fileInfo.Format)
 }
 
 // Name returns the extractor name
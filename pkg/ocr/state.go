@@ -0,0 +1,108 @@
+package ocr
+
+import (
+	"encoding/json"
+	"os"
+
+	"doc-to-text/pkg/constants"
+	"doc-to-text/pkg/utils"
+)
+
+// Page states recorded in pages/state.json
+const (
+	PageStatePending  = "pending"  // Not split yet
+	PageStateSplit    = "split"    // Page PDF written
+	PageStateRendered = "rendered" // Page image written
+	PageStateOCRDone  = "ocr_done" // Page text recognized and cached
+	PageStateFailed   = "failed"   // Recognition failed; retried by 'doc-to-text retry-failed'
+)
+
+// PageState is the progress of one page
+type PageState struct {
+	Number int    `json:"number"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// PageStates tracks the pages of a PDF across runs, so an interrupted run only
+// redoes what is missing. The file is replaced atomically on every change.
+type PageStates struct {
+	TotalPages int         `json:"total_pages"` // 0 until the page count is known
+	Pages      []PageState `json:"pages"`
+	path       string
+}
+
+// ReadPageStates reads a page state file, returning nil when there is none
+func ReadPageStates(path string) *PageStates {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var states PageStates
+	if json.Unmarshal(data, &states) != nil || len(states.Pages) != states.TotalPages {
+		return nil
+	}
+	states.path = path
+	return &states
+}
+
+// newPageStates tracks a PDF of totalPages pages, all pending
+func newPageStates(path string, totalPages int) *PageStates {
+	states := &PageStates{path: path}
+	states.resize(totalPages)
+	return states
+}
+
+// resize sets the page count, adding pending pages as needed
+func (s *PageStates) resize(totalPages int) {
+	for number := len(s.Pages) + 1; number <= totalPages; number++ {
+		s.Pages = append(s.Pages, PageState{Number: number, Status: PageStatePending})
+	}
+	s.Pages = s.Pages[:totalPages]
+	s.TotalPages = totalPages
+}
+
+// Get returns the state of a page
+func (s *PageStates) Get(pageNum int) PageState {
+	if pageNum < 1 || pageNum > len(s.Pages) {
+		return PageState{Number: pageNum, Status: PageStatePending}
+	}
+	return s.Pages[pageNum-1]
+}
+
+// Set records the status of a page and saves the states
+func (s *PageStates) Set(pageNum int, status, reason string) error {
+	s.mark(pageNum, status, reason)
+	return s.save()
+}
+
+// Failed returns the pages whose recognition failed
+func (s *PageStates) Failed() []PageState {
+	var failed []PageState
+	for _, page := range s.Pages {
+		if page.Status == PageStateFailed {
+			failed = append(failed, page)
+		}
+	}
+	return failed
+}
+
+// mark records the status of a page without saving
+func (s *PageStates) mark(pageNum int, status, reason string) {
+	if pageNum < 1 || pageNum > len(s.Pages) {
+		return
+	}
+	s.Pages[pageNum-1] = PageState{Number: pageNum, Status: status, Error: reason}
+}
+
+// save writes the states to their file
+func (s *PageStates) save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return utils.WrapError(err, utils.ErrorTypeSystem, "failed to encode page states")
+	}
+	if err := utils.WriteFileAtomic(s.path, append(data, '\n'), constants.DefaultFilePermission); err != nil {
+		return utils.WrapError(err, utils.ErrorTypeIO, "failed to save page states")
+	}
+	return nil
+}
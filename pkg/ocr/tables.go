@@ -0,0 +1,222 @@
+package ocr
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"doc-to-text/pkg/tables"
+	"doc-to-text/pkg/types"
+	"doc-to-text/pkg/utils"
+)
+
+// suryaTableCommand is Surya's table recognition CLI
+const suryaTableCommand = "surya_table"
+
+// SuryaTableResult is the results.json layout written by surya_table
+type SuryaTableResult map[string][]SuryaTablePage
+
+// SuryaTablePage is one recognized table
+type SuryaTablePage struct {
+	Cells []SuryaTableCell `json:"cells"`
+	Bbox  []float64        `json:"bbox"` // table position on the page, when reported
+	Page  int              `json:"page"`
+}
+
+// SuryaTableCell is one cell of a recognized table
+type SuryaTableCell struct {
+	Text  string    `json:"text"`
+	Bbox  []float64 `json:"bbox"`
+	RowID int       `json:"row_id"`
+	ColID int       `json:"col_id"`
+}
+
+// recognizeTables detects tables on an OCR page, writes them as CSV side files
+// and returns the page text with the tables rendered as Markdown.
+// Surya's table recognizer is used when installed, otherwise text-line bboxes are clustered.
+// Failures are logged and the original text is returned unchanged.
+func (e *OCRExtractor) recognizeTables(ctx context.Context, pageNum int, pageText string, lines []types.TextLine, sourcePath, imagePath string) string {
+	var detections []tables.Detection
+	if utils.IsCommandAvailable(suryaTableCommand) {
+		e.useTool(suryaTableCommand)
+		found, err := e.runSuryaTable(ctx, pageNum, sourcePath, imagePath, lines)
+		if err != nil {
+			e.logger.Warn("Surya table recognition failed for page %d: %v", pageNum, err)
+		}
+		detections = found
+	}
+	if len(detections) == 0 && len(lines) > 0 {
+		detections = tables.DetectFromLines(lines)
+	}
+
+	if len(detections) == 0 {
+		return pageText
+	}
+
+	e.logger.Progress("📊", "Detected %d table(s) on page %d", len(detections), pageNum)
+	e.saveTableCSVs(pageNum, detections)
+
+	if len(lines) == 0 {
+		// Without line positions the flattened text cannot be replaced, so append the tables
+		var builder strings.Builder
+		builder.WriteString(pageText)
+		for _, detection := range detections {
+			builder.WriteString("\n\n")
+			builder.WriteString(detection.Table.Markdown())
+		}
+		return builder.String()
+	}
+
+	return tables.ComposeText(lines, detections)
+}
+
+// runSuryaTable runs surya_table on the page image and converts its cells into tables
+func (e *OCRExtractor) runSuryaTable(ctx context.Context, pageNum int, sourcePath, imagePath string, lines []types.TextLine) ([]tables.Detection, error) {
+	// surya_table works on images, so render the page if the OCR engine read the PDF directly
+	if err := e.ensurePageImage(ctx, sourcePath, imagePath); err != nil {
+		return nil, err
+	}
+
+	outputDir, err := e.fileManager.CreateIntermediateDir("surya_table_results")
+	if err != nil {
+		return nil, err
+	}
+
+	fileName := utils.SanitizeFileName(filepath.Base(imagePath))
+	resultsFile := filepath.Join(outputDir, strings.TrimSuffix(fileName, filepath.Ext(fileName)), "results.json")
+
+	// Reuse earlier results so resumed runs do not repeat recognition
+	if _, err := os.Stat(resultsFile); os.IsNotExist(err) {
+		cmd := utils.CommandContext(ctx, suryaTableCommand, imagePath, "--output_dir", outputDir)
+		var stderrBuilder strings.Builder
+		cmd.Stderr = &stderrBuilder
+		if err := cmd.Run(); err != nil {
+			// A stopped tool may leave partial results behind, which must not be reused
+			os.Remove(resultsFile)
+			return nil, fmt.Errorf("surya_table execution failed (%v): %s", err, strings.TrimSpace(stderrBuilder.String()))
+		}
+	}
+
+	data, err := os.ReadFile(resultsFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read table results: %w", err)
+	}
+
+	var results SuryaTableResult
+	if err := json.Unmarshal(data, &results); err != nil {
+		os.Remove(resultsFile)
+		return nil, fmt.Errorf("failed to parse table results: %w", err)
+	}
+
+	var detections []tables.Detection
+	for _, pages := range results {
+		for _, page := range pages {
+			if detection, ok := suryaTableDetection(page, lines); ok {
+				detections = append(detections, detection)
+			}
+		}
+	}
+
+	e.logger.Debug("surya_table found %d table(s) on page %d", len(detections), pageNum)
+	return detections, nil
+}
+
+// suryaTableDetection builds a table from surya_table cells, filling empty cells
+// from OCR lines whose centers fall inside the cell box
+func suryaTableDetection(page SuryaTablePage, lines []types.TextLine) (tables.Detection, bool) {
+	if len(page.Cells) == 0 {
+		return tables.Detection{}, false
+	}
+
+	// Cell boxes are relative to the table crop when the table position is reported
+	var offsetX, offsetY float64
+	if len(page.Bbox) >= 4 {
+		offsetX, offsetY = page.Bbox[0], page.Bbox[1]
+	}
+
+	rowCount, colCount := 0, 0
+	for _, cell := range page.Cells {
+		if cell.RowID+1 > rowCount {
+			rowCount = cell.RowID + 1
+		}
+		if cell.ColID+1 > colCount {
+			colCount = cell.ColID + 1
+		}
+	}
+
+	grid := make([][]string, rowCount)
+	for i := range grid {
+		grid[i] = make([]string, colCount)
+	}
+
+	coveredSet := make(map[int]bool)
+	for _, cell := range page.Cells {
+		if cell.RowID < 0 || cell.ColID < 0 {
+			continue
+		}
+		text := strings.TrimSpace(cell.Text)
+		if len(cell.Bbox) >= 4 {
+			box := [4]float64{cell.Bbox[0] + offsetX, cell.Bbox[1] + offsetY, cell.Bbox[2] + offsetX, cell.Bbox[3] + offsetY}
+			var parts []string
+			for i, line := range lines {
+				centerX := (line.BBox[0] + line.BBox[2]) / 2
+				centerY := (line.BBox[1] + line.BBox[3]) / 2
+				if centerX >= box[0] && centerX <= box[2] && centerY >= box[1] && centerY <= box[3] {
+					coveredSet[i] = true
+					parts = append(parts, strings.TrimSpace(line.Text))
+				}
+			}
+			if text == "" {
+				text = strings.Join(parts, " ")
+			}
+		}
+		if grid[cell.RowID][cell.ColID] != "" {
+			text = grid[cell.RowID][cell.ColID] + " " + text
+		}
+		grid[cell.RowID][cell.ColID] = strings.TrimSpace(text)
+	}
+
+	table := tables.NewTable(grid)
+	if table.IsEmpty() || table.ColumnCount() < 2 {
+		return tables.Detection{}, false
+	}
+
+	covered := make([]int, 0, len(coveredSet))
+	for index := range coveredSet {
+		covered = append(covered, index)
+	}
+	sort.Ints(covered)
+
+	// Tables that cannot be tied to any OCR line would duplicate the page text
+	if len(lines) > 0 && len(covered) == 0 {
+		return tables.Detection{}, false
+	}
+
+	return tables.Detection{Table: table, LineIndex: covered}, true
+}
+
+// saveTableCSVs writes each detected table as a CSV side file
+func (e *OCRExtractor) saveTableCSVs(pageNum int, detections []tables.Detection) {
+	if err := utils.EnsureDir(e.fileManager.GetTablesDir()); err != nil {
+		e.logger.Warn("Failed to create tables directory: %v", err)
+		return
+	}
+
+	for i, detection := range detections {
+		data, err := detection.Table.CSV()
+		if err != nil {
+			e.logger.Warn("Failed to render table %d on page %d as CSV: %v", i+1, pageNum, err)
+			continue
+		}
+		csvPath := e.fileManager.GetPageTableCSVPath(pageNum, i+1)
+		if err := utils.WriteFileAtomic(csvPath, data, 0644); err != nil {
+			e.logger.Warn("Failed to save table CSV %s: %v", csvPath, err)
+			continue
+		}
+		e.logger.Debug("Saved table CSV: %s", csvPath)
+	}
+}
@@ -0,0 +1,144 @@
+package postprocess
+
+import (
+	"math"
+	"regexp"
+	"strings"
+)
+
+// Thresholds for running header/footer detection
+const (
+	minPagesForBoilerplate = 3   // documents with fewer pages are left untouched
+	boilerplateEdgeLines   = 2   // lines inspected at the top and bottom of each page
+	boilerplatePageShare   = 0.4 // share of pages a line must repeat on (alternating headers repeat on ~50%)
+	maxPageNumberDigitRuns = 2   // lines with more digit runs (e.g. table rows) must repeat verbatim
+)
+
+// Line positions reported for removed lines
+const (
+	PositionHeader = "header"
+	PositionFooter = "footer"
+)
+
+var (
+	digitRunPattern   = regexp.MustCompile(`\d+`)
+	whitespacePattern = regexp.MustCompile(`\s+`)
+)
+
+// Page is the text of a single numbered page
+type Page struct {
+	Number int
+	Text   string
+}
+
+// RemovedLine records a line stripped as a running header, footer or page number
+type RemovedLine struct {
+	Page     int    `json:"page"`
+	Position string `json:"position"`
+	Text     string `json:"text"`
+}
+
+// RemoveRepeatedHeadersFooters strips lines that repeat at the top or bottom of many
+// pages. Digits are ignored when comparing lines so page numbers ("Page 3 of 10", "- 4 -")
+// are recognized as repeating. Returns the cleaned pages and every line that was removed.
+func RemoveRepeatedHeadersFooters(pages []Page) ([]Page, []RemovedLine) {
+	if len(pages) < minPagesForBoilerplate {
+		return pages, nil
+	}
+
+	// Count on how many pages each normalized edge line appears
+	headerCounts := make(map[string]int)
+	footerCounts := make(map[string]int)
+	for _, page := range pages {
+		lines := strings.Split(page.Text, "\n")
+		countEdgeKeys(headerCounts, lines, topEdge(lines))
+		countEdgeKeys(footerCounts, lines, bottomEdge(lines))
+	}
+
+	threshold := int(math.Ceil(float64(len(pages)) * boilerplatePageShare))
+	if threshold < minPagesForBoilerplate {
+		threshold = minPagesForBoilerplate
+	}
+
+	var removed []RemovedLine
+	cleaned := make([]Page, 0, len(pages))
+
+	for _, page := range pages {
+		lines := strings.Split(page.Text, "\n")
+		drop := make(map[int]string)
+
+		for _, index := range topEdge(lines) {
+			if headerCounts[normalizeBoilerplate(lines[index])] >= threshold {
+				drop[index] = PositionHeader
+			}
+		}
+		for _, index := range bottomEdge(lines) {
+			if _, ok := drop[index]; !ok && footerCounts[normalizeBoilerplate(lines[index])] >= threshold {
+				drop[index] = PositionFooter
+			}
+		}
+
+		if len(drop) == 0 {
+			cleaned = append(cleaned, page)
+			continue
+		}
+
+		kept := make([]string, 0, len(lines))
+		for i, line := range lines {
+			if position, ok := drop[i]; ok {
+				removed = append(removed, RemovedLine{Page: page.Number, Position: position, Text: strings.TrimSpace(line)})
+				continue
+			}
+			kept = append(kept, line)
+		}
+
+		cleaned = append(cleaned, Page{Number: page.Number, Text: strings.TrimSpace(strings.Join(kept, "\n"))})
+	}
+
+	return cleaned, removed
+}
+
+// countEdgeKeys counts each distinct normalized edge line once per page
+func countEdgeKeys(counts map[string]int, lines []string, indices []int) {
+	seen := make(map[string]bool)
+	for _, index := range indices {
+		key := normalizeBoilerplate(lines[index])
+		if key != "" && !seen[key] {
+			seen[key] = true
+			counts[key]++
+		}
+	}
+}
+
+// topEdge returns the indices of the first non-empty lines of a page
+func topEdge(lines []string) []int {
+	var indices []int
+	for i := 0; i < len(lines) && len(indices) < boilerplateEdgeLines; i++ {
+		if strings.TrimSpace(lines[i]) != "" {
+			indices = append(indices, i)
+		}
+	}
+	return indices
+}
+
+// bottomEdge returns the indices of the last non-empty lines of a page
+func bottomEdge(lines []string) []int {
+	var indices []int
+	for i := len(lines) - 1; i >= 0 && len(indices) < boilerplateEdgeLines; i-- {
+		if strings.TrimSpace(lines[i]) != "" {
+			indices = append(indices, i)
+		}
+	}
+	return indices
+}
+
+// normalizeBoilerplate lowercases a line and collapses whitespace. Digit runs are replaced
+// with '#' so page numbers match, unless the line has too many numbers to be a page label.
+func normalizeBoilerplate(line string) string {
+	key := strings.ToLower(strings.TrimSpace(line))
+	key = whitespacePattern.ReplaceAllString(key, " ")
+	if len(digitRunPattern.FindAllStringIndex(key, -1)) > maxPageNumberDigitRuns {
+		return key
+	}
+	return digitRunPattern.ReplaceAllString(key, "#")
+}
@@ -0,0 +1,255 @@
+package postprocess
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Thresholds for paragraph reflow
+const (
+	fullLineRatio    = 0.75 // lines at least this share of the typical width are wrapped, not paragraph ends
+	headingLineRatio = 0.5  // short unpunctuated lines below this share are kept as headings
+	minReflowLines   = 3    // fewer wrapped lines than this give no reliable typical width
+)
+
+var (
+	wordPattern        = regexp.MustCompile(`[\p{L}]+(?:-[\p{L}]+)*`)
+	listItemPattern    = regexp.MustCompile(`^\s*(?:[-*•‣◦▪–]\s+|\(?\d{1,3}[.)]\s+|\(?[a-zA-Z][.)]\s+|\(?[ivxlcIVXLC]+[.)]\s+|[（(]?[一二三四五六七八九十]+[、.)）]\s*)`)
+	pageMarkerPattern  = regexp.MustCompile(`^--- Page \d+ ---$`)
+	codeSymbolsPattern = regexp.MustCompile(`[{};=<>\[\]()]`)
+)
+
+// Reflow joins words hyphenated across line breaks and merges hard-wrapped lines into
+// paragraphs. Lists, headings, tables, page markers and code-like blocks are preserved.
+// Lines are joined without a space when either side is CJK text.
+func Reflow(text string) string {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	frequencies := wordFrequencies(text)
+	typicalWidth := typicalLineWidth(lines)
+
+	var output []string
+	var paragraph string
+	inFence := false
+
+	flush := func() {
+		if paragraph != "" {
+			output = append(output, paragraph)
+			paragraph = ""
+		}
+	}
+
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+
+		// Fenced code blocks are copied verbatim
+		if strings.HasPrefix(trimmed, "```") || inFence {
+			flush()
+			output = append(output, line)
+			if strings.HasPrefix(trimmed, "```") {
+				inFence = !inFence
+			}
+			continue
+		}
+
+		if trimmed == "" {
+			flush()
+			output = append(output, "")
+			continue
+		}
+
+		if isStructuralLine(line, trimmed) {
+			flush()
+			output = append(output, strings.TrimRight(line, " \t"))
+			continue
+		}
+
+		if paragraph == "" {
+			paragraph = trimmed
+		} else {
+			paragraph = joinLines(paragraph, trimmed, frequencies)
+		}
+
+		// Decide whether the paragraph continues on the next line
+		next := ""
+		if i+1 < len(lines) {
+			next = strings.TrimSpace(lines[i+1])
+		}
+		if next == "" || isStructuralLine(lines[i+1], next) || endsParagraph(trimmed, next, typicalWidth) {
+			flush()
+		}
+	}
+	flush()
+
+	return strings.Join(output, "\n")
+}
+
+// isStructuralLine reports lines that must keep their own line: headings, list items,
+// table rows, page markers, display math and indented or symbol-heavy code
+func isStructuralLine(line, trimmed string) bool {
+	switch {
+	case pageMarkerPattern.MatchString(trimmed):
+		return true
+	case strings.HasPrefix(trimmed, "#"), strings.HasPrefix(trimmed, "|"), strings.HasPrefix(trimmed, ">"):
+		return true
+	case strings.HasPrefix(trimmed, "$$"):
+		return true
+	case listItemPattern.MatchString(line):
+		return true
+	case strings.HasPrefix(line, "    "), strings.HasPrefix(line, "\t"):
+		return true
+	}
+
+	// Code-like lines are dense in brackets, braces and operators
+	symbols := len(codeSymbolsPattern.FindAllStringIndex(trimmed, -1))
+	return symbols >= 3 && float64(symbols)/float64(utf8.RuneCountInString(trimmed)) > 0.1
+}
+
+// endsParagraph decides whether a line ends its paragraph
+func endsParagraph(line, next string, typicalWidth int) bool {
+	width := displayWidth(line)
+	if typicalWidth == 0 {
+		return true
+	}
+
+	// A word hyphenated across the line break always continues
+	if strings.HasSuffix(line, "-") && trailingWord(strings.TrimSuffix(line, "-")) != "" {
+		return false
+	}
+
+	// Lines close to the typical width were wrapped by the layout
+	if float64(width) >= float64(typicalWidth)*fullLineRatio {
+		return false
+	}
+
+	last, _ := utf8.DecodeLastRuneInString(line)
+	if isSentenceEnd(last) {
+		return true
+	}
+
+	// Short lines without punctuation followed by a capitalized line are headings
+	first, _ := utf8.DecodeRuneInString(next)
+	if float64(width) < float64(typicalWidth)*headingLineRatio && unicode.IsUpper(first) {
+		return true
+	}
+
+	return false
+}
+
+// joinLines appends the next line to a paragraph, resolving hyphenated line breaks
+func joinLines(paragraph, next string, frequencies map[string]int) string {
+	if strings.HasSuffix(paragraph, "-") && !strings.HasSuffix(paragraph, "--") {
+		head := trailingWord(strings.TrimSuffix(paragraph, "-"))
+		tail := leadingWord(next)
+		if head != "" && tail != "" {
+			if keepHyphen(head, tail, frequencies) {
+				return paragraph + next
+			}
+			return strings.TrimSuffix(paragraph, "-") + next
+		}
+	}
+
+	last, _ := utf8.DecodeLastRuneInString(paragraph)
+	first, _ := utf8.DecodeRuneInString(next)
+	if isCJK(last) || isCJK(first) {
+		return paragraph + next
+	}
+	return paragraph + " " + next
+}
+
+// keepHyphen decides between "infor-mation" and "information" using how often each
+// form occurs elsewhere in the document, defaulting to joining lowercase continuations
+func keepHyphen(head, tail string, frequencies map[string]int) bool {
+	joined := strings.ToLower(head + tail)
+	hyphenated := strings.ToLower(head + "-" + tail)
+
+	joinedCount := frequencies[joined]
+	hyphenatedCount := frequencies[hyphenated]
+	if joinedCount != hyphenatedCount {
+		return hyphenatedCount > joinedCount
+	}
+
+	// Capitalized continuations ("Anglo-Saxon") are real compounds
+	first, _ := utf8.DecodeRuneInString(tail)
+	return unicode.IsUpper(first)
+}
+
+// wordFrequencies counts lowercase words (including hyphenated compounds) in the text
+func wordFrequencies(text string) map[string]int {
+	frequencies := make(map[string]int)
+	for _, word := range wordPattern.FindAllString(text, -1) {
+		frequencies[strings.ToLower(word)]++
+	}
+	return frequencies
+}
+
+// typicalLineWidth estimates the wrap width as the 80th percentile of line widths
+func typicalLineWidth(lines []string) int {
+	var widths []int
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || isStructuralLine(line, trimmed) {
+			continue
+		}
+		widths = append(widths, displayWidth(trimmed))
+	}
+	if len(widths) < minReflowLines {
+		return 0
+	}
+	sort.Ints(widths)
+	return widths[len(widths)*4/5]
+}
+
+// trailingWord returns the letters at the end of s
+func trailingWord(s string) string {
+	end := len(s)
+	start := end
+	for start > 0 {
+		r, size := utf8.DecodeLastRuneInString(s[:start])
+		if !unicode.IsLetter(r) {
+			break
+		}
+		start -= size
+	}
+	return s[start:end]
+}
+
+// leadingWord returns the letters at the start of s
+func leadingWord(s string) string {
+	end := 0
+	for end < len(s) {
+		r, size := utf8.DecodeRuneInString(s[end:])
+		if !unicode.IsLetter(r) {
+			break
+		}
+		end += size
+	}
+	return s[:end]
+}
+
+// displayWidth counts runes, with CJK characters taking two columns
+func displayWidth(s string) int {
+	width := 0
+	for _, r := range s {
+		if isCJK(r) {
+			width += 2
+		} else {
+			width++
+		}
+	}
+	return width
+}
+
+// isCJK reports Han, kana and CJK punctuation, which are written without spaces
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana) ||
+		(r >= 0x3000 && r <= 0x303F) || // CJK symbols and punctuation
+		(r >= 0xFF00 && r <= 0xFFEF) // full-width forms
+}
+
+// isSentenceEnd reports punctuation that usually ends a paragraph line
+func isSentenceEnd(r rune) bool {
+	return strings.ContainsRune(".!?:;\"'”’)。！？：；」』）", r)
+}
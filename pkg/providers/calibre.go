@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"os/exec"
 	"strings"
 
 	"doc-to-text/pkg/config"
@@ -21,6 +20,7 @@ type CalibreFallbackExtractor struct {
 	config      *config.Config
 	logger      *logger.Logger
 	fileManager *utils.FileManager
+	tools       []string // Commands run by the last extraction
 }
 
 // NewCalibreFallbackExtractor creates a new Calibre fallback extractor
@@ -56,6 +56,7 @@ func (e *CalibreFallbackExtractor) findCalibrePath() (string, error) {
 // Extract implements interfaces.Extractor
 func (e *CalibreFallbackExtractor) Extract(ctx context.Context, inputFile string) (string, error) {
 	e.logger.ProgressAlways("📚", "Attempting Calibre extraction for: %s", inputFile)
+	e.tools = nil
 
 	// Initialize file manager
 	fileInfo, err := utils.GetFileInfo(inputFile)
@@ -63,16 +64,23 @@ func (e *CalibreFallbackExtractor) Extract(ctx context.Context, inputFile string
 		return "", utils.WrapError(err, utils.ErrorTypeIO, "failed to get file info")
 	}
 
-	e.fileManager = utils.NewFileManager(inputFile, fileInfo.MD5Hash, e.logger)
+	e.fileManager = e.config.CreateFileManager(inputFile, fileInfo.MD5Hash, e.logger)
 	if err := e.fileManager.EnsureBaseDir(); err != nil {
 		return "", utils.WrapError(err, utils.ErrorTypeIO, "failed to create base directory")
 	}
 
+	// Calibre picks the input format from the extension
+	sourcePath, err := e.fileManager.GetFormattedInputPath(fileInfo.Format)
+	if err != nil {
+		return "", utils.WrapError(err, utils.ErrorTypeIO, "failed to prepare input file")
+	}
+
 	// Find Calibre
 	calibrePath, err := e.findCalibrePath()
 	if err != nil {
 		return "", utils.WrapError(err, utils.ErrorTypeSystem, "Calibre not found")
 	}
+	e.tools = []string{calibrePath}
 
 	// Create temporary output file
 	tempOutputFile, err := e.fileManager.CreateTempFile("calibre_output_", ".txt")
@@ -80,8 +88,12 @@ func (e *CalibreFallbackExtractor) Extract(ctx context.Context, inputFile string
 		return "", utils.WrapError(err, utils.ErrorTypeIO, "failed to create temporary file")
 	}
 
-	// Build and execute Calibre command
-	cmd := exec.CommandContext(ctx, calibrePath, inputFile, tempOutputFile)
+	// Build and execute Calibre command; Markdown output keeps headings, lists and links
+	args := []string{sourcePath, tempOutputFile}
+	if e.config.OutputFormat == types.OutputFormatMarkdown {
+		args = append(args, "--txt-output-formatting=markdown")
+	}
+	cmd := utils.CommandContext(ctx, calibrePath, args...)
 	e.logger.Debug("Running Calibre command: %s", cmd.String())
 
 	// Execute command
@@ -111,7 +123,7 @@ func (e *CalibreFallbackExtractor) Extract(ctx context.Context, inputFile string
 
 // SupportsFile checks if this extractor supports the given file type
 func (e *CalibreFallbackExtractor) SupportsFile(fileInfo *types.FileInfo) bool {
-	ext := strings.ToLower(fileInfo.Extension)
+	ext := strings.ToLower(fileInfo.Format)
 
 	// Support e-books, PDFs, and HTML files for Calibre fallback
 	supportedExts := []string{
@@ -133,3 +145,8 @@ func (e *CalibreFallbackExtractor) SupportsFile(fileInfo *types.FileInfo) bool {
 func (e *CalibreFallbackExtractor) Name() string {
 	return e.name
 }
+
+// ExtractionTools returns the Calibre command run by the last extraction
+func (e *CalibreFallbackExtractor) ExtractionTools() []string {
+	return e.tools
+}
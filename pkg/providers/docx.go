@@ -0,0 +1,375 @@
+package providers
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"doc-to-text/pkg/document"
+	"doc-to-text/pkg/interfaces"
+	"doc-to-text/pkg/types"
+)
+
+// headingStylePattern matches built-in heading style names such as "heading 2"
+var headingStylePattern = regexp.MustCompile(`^heading\s*([1-9])$`)
+
+// xmlNode is a generic XML element used to walk WordprocessingML
+type xmlNode struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Content string     `xml:",chardata"`
+	Nodes   []xmlNode  `xml:",any"`
+}
+
+// attr returns the value of an attribute by local name
+func (n *xmlNode) attr(local string) string {
+	for _, attr := range n.Attrs {
+		if attr.Name.Local == local {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// child returns the first child element with the local name, or nil
+func (n *xmlNode) child(local string) *xmlNode {
+	for i := range n.Nodes {
+		if n.Nodes[i].XMLName.Local == local {
+			return &n.Nodes[i]
+		}
+	}
+	return nil
+}
+
+// docxParser holds the style, numbering and relationship tables of a DOCX package
+type docxParser struct {
+	headingLevels map[string]int             // Style ID -> heading level
+	styleNames    map[string]string          // Style ID -> lower-case style name
+	ordered       map[string]map[string]bool // Numbering ID -> list level -> numbered
+	links         map[string]string          // Relationship ID -> external target
+}
+
+// DOCXExtractor reads Word documents with the built-in parser, mapping heading and
+// list styles to document structure
+type DOCXExtractor struct {
+	name     string
+	document *document.Document // Structured form of the last extraction
+}
+
+// NewDOCXExtractor creates a new built-in DOCX extractor
+func NewDOCXExtractor() interfaces.Extractor {
+	return &DOCXExtractor{
+		name: "docx",
+	}
+}
+
+// Extract extracts the text of a DOCX file
+func (e *DOCXExtractor) Extract(ctx context.Context, inputFile string) (string, error) {
+	data, err := os.ReadFile(inputFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+	return e.extract(ctx, data)
+}
+
+// ExtractReader extracts the text of a DOCX stream
+func (e *DOCXExtractor) ExtractReader(ctx context.Context, r io.Reader, fileInfo *types.FileInfo) (string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", fmt.Errorf("failed to read stream: %w", err)
+	}
+	return e.extract(ctx, data)
+}
+
+// extract parses the package and renders its document as text
+func (e *DOCXExtractor) extract(ctx context.Context, data []byte) (string, error) {
+	e.document = nil
+	doc, err := extractDOCX(ctx, data)
+	if err != nil {
+		return "", err
+	}
+	e.document = doc
+	return doc.Text(), nil
+}
+
+// ExtractionDocument returns the structured document of the last extraction
+func (e *DOCXExtractor) ExtractionDocument() *document.Document {
+	return e.document
+}
+
+// SupportsReader reports whether the document can be parsed from a stream
+func (e *DOCXExtractor) SupportsReader(fileInfo *types.FileInfo) bool {
+	return e.SupportsFile(fileInfo)
+}
+
+// SupportsFile checks if this extractor supports the given file type
+func (e *DOCXExtractor) SupportsFile(fileInfo *types.FileInfo) bool {
+	return strings.ToLower(fileInfo.Format) == "docx"
+}
+
+// Name returns the name of the extractor
+func (e *DOCXExtractor) Name() string {
+	return e.name
+}
+
+// extractDOCX converts word/document.xml into a single-page document
+func extractDOCX(ctx context.Context, data []byte) (*document.Document, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("not a zip archive: %w", err)
+	}
+
+	files := make(map[string]*zip.File, len(archive.File))
+	for _, file := range archive.File {
+		files[file.Name] = file
+	}
+
+	var body xmlNode
+	if err := readZipXML(files, "word/document.xml", &body); err != nil {
+		return nil, err
+	}
+
+	parser := &docxParser{
+		headingLevels: make(map[string]int),
+		styleNames:    make(map[string]string),
+		ordered:       make(map[string]map[string]bool),
+		links:         make(map[string]string),
+	}
+	// Styles, numbering and relationships are optional parts
+	var styles, numbering, rels xmlNode
+	if readZipXML(files, "word/styles.xml", &styles) == nil {
+		parser.loadStyles(&styles)
+	}
+	if readZipXML(files, "word/numbering.xml", &numbering) == nil {
+		parser.loadNumbering(&numbering)
+	}
+	if readZipXML(files, "word/_rels/document.xml.rels", &rels) == nil {
+		parser.loadRelationships(&rels)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	page := document.Page{Number: 1, Method: document.MethodText, Engine: "docx"}
+	if content := body.child("body"); content != nil {
+		page.Blocks = parser.blocks(content)
+	}
+	if len(page.Blocks) == 0 {
+		return nil, fmt.Errorf("document contains no text")
+	}
+	return &document.Document{Pages: []document.Page{page}}, nil
+}
+
+// loadStyles records heading levels from style names ("heading 1", "Title") and outline levels
+func (p *docxParser) loadStyles(styles *xmlNode) {
+	for i := range styles.Nodes {
+		style := &styles.Nodes[i]
+		if style.XMLName.Local != "style" {
+			continue
+		}
+		id := style.attr("styleId")
+		name := ""
+		if node := style.child("name"); node != nil {
+			name = strings.ToLower(node.attr("val"))
+		}
+		p.styleNames[id] = name
+
+		switch {
+		case name == "title":
+			p.headingLevels[id] = 1
+		case headingStylePattern.MatchString(name):
+			level, _ := strconv.Atoi(headingStylePattern.FindStringSubmatch(name)[1])
+			p.headingLevels[id] = level
+		default:
+			if properties := style.child("pPr"); properties != nil {
+				if outline := properties.child("outlineLvl"); outline != nil {
+					if level, err := strconv.Atoi(outline.attr("val")); err == nil && level < 9 {
+						p.headingLevels[id] = level + 1
+					}
+				}
+			}
+		}
+	}
+}
+
+// loadNumbering records which list levels are numbered rather than bulleted
+func (p *docxParser) loadNumbering(numbering *xmlNode) {
+	abstract := make(map[string]map[string]bool)
+	for i := range numbering.Nodes {
+		node := &numbering.Nodes[i]
+		if node.XMLName.Local != "abstractNum" {
+			continue
+		}
+		levels := make(map[string]bool)
+		for j := range node.Nodes {
+			level := &node.Nodes[j]
+			if level.XMLName.Local != "lvl" {
+				continue
+			}
+			if format := level.child("numFmt"); format != nil {
+				value := format.attr("val")
+				levels[level.attr("ilvl")] = value != "bullet" && value != "none"
+			}
+		}
+		abstract[node.attr("abstractNumId")] = levels
+	}
+
+	for i := range numbering.Nodes {
+		node := &numbering.Nodes[i]
+		if node.XMLName.Local != "num" {
+			continue
+		}
+		if ref := node.child("abstractNumId"); ref != nil {
+			p.ordered[node.attr("numId")] = abstract[ref.attr("val")]
+		}
+	}
+}
+
+// loadRelationships records hyperlink targets
+func (p *docxParser) loadRelationships(rels *xmlNode) {
+	for i := range rels.Nodes {
+		rel := &rels.Nodes[i]
+		if strings.HasSuffix(rel.attr("Type"), "/hyperlink") {
+			p.links[rel.attr("Id")] = rel.attr("Target")
+		}
+	}
+}
+
+// blocks converts the paragraphs and tables of a body into blocks
+func (p *docxParser) blocks(body *xmlNode) []document.Block {
+	var blocks []document.Block
+	for i := range body.Nodes {
+		node := &body.Nodes[i]
+		switch node.XMLName.Local {
+		case "p":
+			if block, ok := p.paragraph(node); ok {
+				blocks = append(blocks, block)
+			}
+		case "tbl":
+			if rows := p.table(node); len(rows) > 0 {
+				blocks = append(blocks, document.Block{Type: document.BlockTable, Rows: rows})
+			}
+		case "sdt":
+			// Content controls wrap ordinary paragraphs and tables
+			if content := node.child("sdtContent"); content != nil {
+				blocks = append(blocks, p.blocks(content)...)
+			}
+		}
+	}
+	return blocks
+}
+
+// paragraph converts a paragraph using its style and numbering properties
+func (p *docxParser) paragraph(node *xmlNode) (document.Block, bool) {
+	var builder strings.Builder
+	var links []document.Link
+	p.collectRuns(node, &builder, &links)
+	text := strings.TrimSpace(builder.String())
+	if text == "" {
+		return document.Block{}, false
+	}
+
+	block := document.Block{Type: document.BlockParagraph, Text: text, Links: links}
+	properties := node.child("pPr")
+	if properties == nil {
+		return block, true
+	}
+
+	styleID := ""
+	if style := properties.child("pStyle"); style != nil {
+		styleID = style.attr("val")
+	}
+	if level, ok := p.headingLevels[styleID]; ok {
+		block.Type = document.BlockHeading
+		block.Level = min(level, 6)
+		return block, true
+	}
+
+	if numbering := properties.child("numPr"); numbering != nil {
+		numID, level := "", "0"
+		if node := numbering.child("numId"); node != nil {
+			numID = node.attr("val")
+		}
+		if node := numbering.child("ilvl"); node != nil {
+			level = node.attr("val")
+		}
+		if numID != "" && numID != "0" {
+			block.Type = document.BlockListItem
+			block.Level, _ = strconv.Atoi(level)
+			block.Ordered = p.ordered[numID][level]
+			return block, true
+		}
+	}
+
+	switch name := p.styleNames[styleID]; {
+	case name == "caption":
+		block.Type = document.BlockCaption
+	case strings.Contains(name, "quote"):
+		block.Type = document.BlockQuote
+	}
+	return block, true
+}
+
+// collectRuns gathers the text of runs below a node, recording hyperlinks
+func (p *docxParser) collectRuns(node *xmlNode, builder *strings.Builder, links *[]document.Link) {
+	for i := range node.Nodes {
+		child := &node.Nodes[i]
+		switch child.XMLName.Local {
+		case "t":
+			builder.WriteString(child.Content)
+		case "tab":
+			builder.WriteString("\t")
+		case "br", "cr":
+			builder.WriteString("\n")
+		case "hyperlink":
+			var anchor strings.Builder
+			p.collectRuns(child, &anchor, links)
+			text := strings.TrimSpace(anchor.String())
+			if target := p.links[child.attr("id")]; target != "" && text != "" {
+				*links = append(*links, document.Link{Text: text, URL: target})
+			}
+			builder.WriteString(anchor.String())
+		case "pPr", "rPr", "del", "instrText", "fldChar":
+			// Properties, deleted revisions and field codes are not content
+		default:
+			p.collectRuns(child, builder, links)
+		}
+	}
+}
+
+// table collects the cell texts of a table row by row
+func (p *docxParser) table(node *xmlNode) [][]string {
+	var rows [][]string
+	for i := range node.Nodes {
+		row := &node.Nodes[i]
+		if row.XMLName.Local != "tr" {
+			continue
+		}
+		var cells []string
+		for j := range row.Nodes {
+			cell := &row.Nodes[j]
+			if cell.XMLName.Local != "tc" {
+				continue
+			}
+			var parts []string
+			for _, block := range p.blocks(cell) {
+				if block.Text != "" {
+					parts = append(parts, block.Text)
+				}
+			}
+			cells = append(cells, strings.Join(parts, " "))
+		}
+		if len(cells) > 0 {
+			rows = append(rows, cells)
+		}
+	}
+	return rows
+}
@@ -4,10 +4,10 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"os/exec"
 
 	"doc-to-text/pkg/config"
 	"doc-to-text/pkg/constants"
+	"doc-to-text/pkg/document"
 	"doc-to-text/pkg/interfaces"
 	"doc-to-text/pkg/logger"
 	"doc-to-text/pkg/types"
@@ -20,6 +20,8 @@ type EbookExtractor struct {
 	config      *config.Config
 	logger      *logger.Logger
 	fileManager *utils.FileManager
+	document    *document.Document // Structured form of the last in-memory EPUB extraction
+	tools       []string           // Commands run by the last extraction
 }
 
 // NewEbookExtractor creates a new e-book extractor
@@ -55,6 +57,8 @@ func (e *EbookExtractor) findCalibrePath() (string, error) {
 // Extract implements interfaces.Extractor
 func (e *EbookExtractor) Extract(ctx context.Context, inputFile string) (string, error) {
 	e.logger.ProgressAlways("📖", "Extracting e-book: %s", inputFile)
+	e.document = nil
+	e.tools = nil
 
 	// Initialize file manager
 	fileInfo, err := utils.GetFileInfo(inputFile)
@@ -62,16 +66,23 @@ func (e *EbookExtractor) Extract(ctx context.Context, inputFile string) (string,
 		return "", utils.WrapError(err, utils.ErrorTypeIO, "failed to get file info")
 	}
 
-	e.fileManager = utils.NewFileManager(inputFile, fileInfo.MD5Hash, e.logger)
+	e.fileManager = e.config.CreateFileManager(inputFile, fileInfo.MD5Hash, e.logger)
 	if err := e.fileManager.EnsureBaseDir(); err != nil {
 		return "", utils.WrapError(err, utils.ErrorTypeIO, "failed to create base directory")
 	}
 
+	// Calibre picks the input format from the extension
+	sourcePath, err := e.fileManager.GetFormattedInputPath(fileInfo.Format)
+	if err != nil {
+		return "", utils.WrapError(err, utils.ErrorTypeIO, "failed to prepare input file")
+	}
+
 	// Find Calibre
 	calibrePath, err := e.findCalibrePath()
 	if err != nil {
 		return "", utils.WrapError(err, utils.ErrorTypeSystem, "Calibre not found")
 	}
+	e.tools = []string{calibrePath}
 
 	// Create temporary output file
 	tempOutputFile, err := e.fileManager.CreateTempFile("ebook_output_", ".txt")
@@ -79,8 +90,12 @@ func (e *EbookExtractor) Extract(ctx context.Context, inputFile string) (string,
 		return "", utils.WrapError(err, utils.ErrorTypeIO, "failed to create temporary file")
 	}
 
-	// Build and execute Calibre command
-	cmd := exec.CommandContext(ctx, calibrePath, inputFile, tempOutputFile)
+	// Build and execute Calibre command; Markdown output keeps headings, lists and links
+	args := []string{sourcePath, tempOutputFile}
+	if e.config.OutputFormat == types.OutputFormatMarkdown {
+		args = append(args, "--txt-output-formatting=markdown")
+	}
+	cmd := utils.CommandContext(ctx, calibrePath, args...)
 	e.logger.Debug("Running Calibre command: %s", cmd.String())
 
 	// Execute command
@@ -110,10 +125,15 @@ func (e *EbookExtractor) Extract(ctx context.Context, inputFile string) (string,
 
 // SupportsFile checks if this extractor supports the given file type
 func (e *EbookExtractor) SupportsFile(fileInfo *types.FileInfo) bool {
-	return utils.IsEbookFile(fileInfo.Extension, fileInfo.MimeType)
+	return utils.IsEbookFile(fileInfo.Format, fileInfo.MimeType)
 }
 
 // Name returns the name of the extractor
 func (e *EbookExtractor) Name() string {
 	return e.name
 }
+
+// ExtractionTools returns the Calibre command run by the last extraction
+func (e *EbookExtractor) ExtractionTools() []string {
+	return e.tools
+}
@@ -0,0 +1,227 @@
+package providers
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"doc-to-text/pkg/document"
+	"doc-to-text/pkg/interfaces"
+	"doc-to-text/pkg/types"
+	"doc-to-text/pkg/utils"
+)
+
+// epubContainer is META-INF/container.xml, pointing to the OPF package document
+type epubContainer struct {
+	Rootfiles []struct {
+		FullPath string `xml:"full-path,attr"`
+	} `xml:"rootfiles>rootfile"`
+}
+
+// epubPackage is the part of the OPF package document needed for the reading order
+type epubPackage struct {
+	Manifest []struct {
+		ID        string `xml:"id,attr"`
+		Href      string `xml:"href,attr"`
+		MediaType string `xml:"media-type,attr"`
+	} `xml:"manifest>item"`
+	Spine []struct {
+		IDRef string `xml:"idref,attr"`
+	} `xml:"spine>itemref"`
+}
+
+// EPUBExtractor reads EPUB files with the built-in parser, without Calibre
+type EPUBExtractor struct {
+	name     string
+	document *document.Document // Structured form of the last extraction
+}
+
+// NewEPUBExtractor creates a new built-in EPUB extractor
+func NewEPUBExtractor() interfaces.Extractor {
+	return &EPUBExtractor{
+		name: "epub",
+	}
+}
+
+// Extract extracts the text of an EPUB file in reading order
+func (e *EPUBExtractor) Extract(ctx context.Context, inputFile string) (string, error) {
+	data, err := os.ReadFile(inputFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+	return e.extract(ctx, data)
+}
+
+// ExtractReader extracts the text of an EPUB stream in reading order
+func (e *EPUBExtractor) ExtractReader(ctx context.Context, r io.Reader, fileInfo *types.FileInfo) (string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", fmt.Errorf("failed to read stream: %w", err)
+	}
+	return e.extract(ctx, data)
+}
+
+// extract parses the EPUB and keeps its structured document
+func (e *EPUBExtractor) extract(ctx context.Context, data []byte) (string, error) {
+	text, doc, err := extractEPUB(ctx, data)
+	e.document = doc
+	return text, err
+}
+
+// ExtractionDocument returns the structured document of the last extraction
+func (e *EPUBExtractor) ExtractionDocument() *document.Document {
+	return e.document
+}
+
+// SupportsReader reports whether the EPUB can be parsed from a stream
+func (e *EPUBExtractor) SupportsReader(fileInfo *types.FileInfo) bool {
+	return e.SupportsFile(fileInfo)
+}
+
+// SupportsFile checks if this extractor supports the given file type
+func (e *EPUBExtractor) SupportsFile(fileInfo *types.FileInfo) bool {
+	return strings.ToLower(fileInfo.Format) == "epub" || strings.Contains(fileInfo.MimeType, "epub")
+}
+
+// Name returns the name of the extractor
+func (e *EPUBExtractor) Name() string {
+	return e.name
+}
+
+// SupportsReader reports whether the e-book can be read from a stream. EPUB is a
+// zip archive of XHTML and is parsed in memory; other formats need Calibre and a file.
+func (e *EbookExtractor) SupportsReader(fileInfo *types.FileInfo) bool {
+	return strings.ToLower(fileInfo.Format) == "epub" || strings.Contains(fileInfo.MimeType, "epub")
+}
+
+// ExtractReader extracts the text of an EPUB stream in reading order
+func (e *EbookExtractor) ExtractReader(ctx context.Context, r io.Reader, fileInfo *types.FileInfo) (string, error) {
+	e.logger.ProgressAlways("📖", "Extracting e-book from stream")
+
+	e.document = nil
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", utils.WrapError(err, utils.ErrorTypeIO, "failed to read stream")
+	}
+
+	text, doc, err := extractEPUB(ctx, data)
+	if err != nil {
+		return "", utils.WrapError(err, utils.ErrorTypeConversion, "EPUB parsing failed")
+	}
+
+	// Validate text length
+	if len(text) < e.config.MinTextThreshold {
+		return "", utils.NewValidationError(fmt.Sprintf("extracted text too short: %d characters (minimum: %d)", len(text), e.config.MinTextThreshold), nil)
+	}
+
+	e.document = doc
+	e.logger.Progress("✅", "E-book extraction successful: %d characters", len(text))
+	return text, nil
+}
+
+// ExtractionDocument returns the structured document of the last in-memory EPUB extraction
+func (e *EbookExtractor) ExtractionDocument() *document.Document {
+	return e.document
+}
+
+// extractEPUB follows container.xml to the OPF spine and extracts each XHTML chapter.
+// The document holds the blocks of all chapters on a single unpaged page.
+func extractEPUB(ctx context.Context, data []byte) (string, *document.Document, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", nil, fmt.Errorf("not a zip archive: %w", err)
+	}
+
+	files := make(map[string]*zip.File, len(archive.File))
+	for _, file := range archive.File {
+		files[file.Name] = file
+	}
+
+	var container epubContainer
+	if err := readZipXML(files, "META-INF/container.xml", &container); err != nil {
+		return "", nil, err
+	}
+	if len(container.Rootfiles) == 0 || container.Rootfiles[0].FullPath == "" {
+		return "", nil, fmt.Errorf("container.xml names no package document")
+	}
+	opfPath := container.Rootfiles[0].FullPath
+
+	var pkg epubPackage
+	if err := readZipXML(files, opfPath, &pkg); err != nil {
+		return "", nil, err
+	}
+
+	hrefs := make(map[string]string, len(pkg.Manifest))
+	for _, item := range pkg.Manifest {
+		hrefs[item.ID] = item.Href
+	}
+
+	htmlExtractor := &HTMLExtractor{name: "html"}
+	baseDir := path.Dir(opfPath)
+	var chapters []string
+	page := document.Page{Number: 1, Method: document.MethodText, Engine: "epub"}
+	for _, itemRef := range pkg.Spine {
+		if err := ctx.Err(); err != nil {
+			return "", nil, err
+		}
+
+		href, ok := hrefs[itemRef.IDRef]
+		if !ok {
+			continue
+		}
+		// Manifest hrefs are relative to the package document and may be URL-escaped
+		href = strings.SplitN(href, "#", 2)[0]
+		if unescaped, err := url.PathUnescape(href); err == nil {
+			href = unescaped
+		}
+		chapterPath := path.Clean(path.Join(baseDir, href))
+		content, err := readZipFile(files, chapterPath)
+		if err != nil {
+			return "", nil, err
+		}
+
+		text, err := htmlExtractor.extractContent(string(content), false)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to parse chapter %s: %w", chapterPath, err)
+		}
+		if text != "" {
+			chapters = append(chapters, text)
+			page.Blocks = append(page.Blocks, htmlExtractor.document.Pages[0].Blocks...)
+		}
+	}
+
+	return strings.Join(chapters, "\n\n"), &document.Document{Pages: []document.Page{page}}, nil
+}
+
+// readZipXML decodes an XML file of the archive into v
+func readZipXML(files map[string]*zip.File, name string, v interface{}) error {
+	content, err := readZipFile(files, name)
+	if err != nil {
+		return err
+	}
+	if err := xml.Unmarshal(content, v); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	return nil
+}
+
+// readZipFile returns the content of a file in the archive
+func readZipFile(files map[string]*zip.File, name string) ([]byte, error) {
+	file, ok := files[name]
+	if !ok {
+		return nil, fmt.Errorf("missing %s in archive", name)
+	}
+	reader, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", name, err)
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
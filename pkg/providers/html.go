@@ -3,6 +3,7 @@ package providers
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"regexp"
 	"strings"
@@ -10,13 +11,16 @@ import (
 	"golang.org/x/net/html"
 	"golang.org/x/net/html/atom"
 
+	"doc-to-text/pkg/document"
 	"doc-to-text/pkg/interfaces"
+	"doc-to-text/pkg/tables"
 	"doc-to-text/pkg/types"
 )
 
 // HTMLExtractor handles HTML and MHTML files
 type HTMLExtractor struct {
-	name string
+	name     string
+	document *document.Document // Structured form of the last extraction
 }
 
 // NewHTMLExtractor creates a new HTML extractor
@@ -40,10 +44,35 @@ func (e *HTMLExtractor) Extract(ctx context.Context, inputFile string) (string,
 		return "", fmt.Errorf("failed to read file: %w", err)
 	}
 
-	htmlContent := string(content)
-
 	// Check if it's MHTML and extract HTML content
-	if strings.Contains(strings.ToLower(inputFile), "mhtml") || strings.Contains(strings.ToLower(inputFile), "mht") {
+	isMHTML := strings.Contains(strings.ToLower(inputFile), "mhtml") || strings.Contains(strings.ToLower(inputFile), "mht")
+	return e.extractContent(string(content), isMHTML)
+}
+
+// ExtractReader extracts text from an HTML/MHTML stream
+func (e *HTMLExtractor) ExtractReader(ctx context.Context, r io.Reader, fileInfo *types.FileInfo) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return "", fmt.Errorf("failed to read stream: %w", err)
+	}
+
+	ext := strings.ToLower(fileInfo.Format)
+	return e.extractContent(string(content), ext == "mhtml" || ext == "mht")
+}
+
+// SupportsReader reports whether the document can be parsed from a stream
+func (e *HTMLExtractor) SupportsReader(fileInfo *types.FileInfo) bool {
+	return e.SupportsFile(fileInfo)
+}
+
+// extractContent extracts text from HTML content, unpacking MHTML first
+func (e *HTMLExtractor) extractContent(htmlContent string, isMHTML bool) (string, error) {
+	e.document = nil
+	if isMHTML {
 		htmlContent = e.extractHTMLFromMHTML(htmlContent)
 	}
 
@@ -58,7 +87,7 @@ func (e *HTMLExtractor) Extract(ctx context.Context, inputFile string) (string,
 
 // SupportsFile checks if this extractor supports the given file type
 func (e *HTMLExtractor) SupportsFile(fileInfo *types.FileInfo) bool {
-	ext := strings.ToLower(fileInfo.Extension)
+	ext := strings.ToLower(fileInfo.Format)
 	return ext == "html" || ext == "htm" || ext == "mhtml" || ext == "mht"
 }
 
@@ -67,6 +96,11 @@ func (e *HTMLExtractor) Name() string {
 	return e.name
 }
 
+// ExtractionDocument returns the structured document of the last extraction
+func (e *HTMLExtractor) ExtractionDocument() *document.Document {
+	return e.document
+}
+
 // extractTextFromHTML extracts readable text from HTML content
 func (e *HTMLExtractor) extractTextFromHTML(htmlContent string) (string, error) {
 	doc, err := html.Parse(strings.NewReader(htmlContent))
@@ -77,6 +111,13 @@ func (e *HTMLExtractor) extractTextFromHTML(htmlContent string) (string, error)
 	var textBuilder strings.Builder
 	e.extractTextFromNode(doc, &textBuilder)
 
+	e.document = &document.Document{Pages: []document.Page{{
+		Number: 1,
+		Method: document.MethodText,
+		Engine: e.name,
+		Blocks: e.buildHTMLBlocks(doc),
+	}}}
+
 	text := textBuilder.String()
 	text = e.cleanupText(text)
 
@@ -91,6 +132,16 @@ func (e *HTMLExtractor) extractTextFromNode(node *html.Node, textBuilder *string
 			return
 		}
 
+		// Render tables through the shared table renderer instead of flattening cells
+		if node.DataAtom == atom.Table {
+			if table := e.extractTable(node); table != nil {
+				textBuilder.WriteString("\n\n")
+				textBuilder.WriteString(table.Markdown())
+				textBuilder.WriteString("\n\n")
+				return
+			}
+		}
+
 		// Add spacing before block elements
 		if e.isBlockElement(node.DataAtom) {
 			textBuilder.WriteString("\n")
@@ -127,6 +178,64 @@ func (e *HTMLExtractor) extractTextFromNode(node *html.Node, textBuilder *string
 	}
 }
 
+// extractTable collects the rows of an HTML table; returns nil for empty or single-column layout tables
+func (e *HTMLExtractor) extractTable(tableNode *html.Node) *tables.Table {
+	var rows [][]string
+
+	var collectRows func(node *html.Node)
+	collectRows = func(node *html.Node) {
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type != html.ElementNode {
+				continue
+			}
+			switch child.DataAtom {
+			case atom.Table:
+				// Nested tables are flattened into their parent cell
+				continue
+			case atom.Tr:
+				var cells []string
+				for cell := child.FirstChild; cell != nil; cell = cell.NextSibling {
+					if cell.Type == html.ElementNode && (cell.DataAtom == atom.Td || cell.DataAtom == atom.Th) {
+						var cellBuilder strings.Builder
+						e.collectCellText(cell, &cellBuilder)
+						cells = append(cells, cellBuilder.String())
+					}
+				}
+				if len(cells) > 0 {
+					rows = append(rows, cells)
+				}
+			default:
+				collectRows(child)
+			}
+		}
+	}
+	collectRows(tableNode)
+
+	table := tables.NewTable(rows)
+	if table.IsEmpty() || table.ColumnCount() < 2 {
+		return nil
+	}
+	return table
+}
+
+// collectCellText gathers the text of a table cell on a single line
+func (e *HTMLExtractor) collectCellText(node *html.Node, builder *strings.Builder) {
+	if node.Type == html.ElementNode && (node.DataAtom == atom.Script || node.DataAtom == atom.Style) {
+		return
+	}
+	if node.Type == html.TextNode {
+		if text := strings.TrimSpace(node.Data); text != "" {
+			if builder.Len() > 0 {
+				builder.WriteString(" ")
+			}
+			builder.WriteString(text)
+		}
+	}
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		e.collectCellText(child, builder)
+	}
+}
+
 // isBlockElement checks if an HTML element is a block-level element
 func (e *HTMLExtractor) isBlockElement(atomType atom.Atom) bool {
 	blockElements := map[atom.Atom]bool{
@@ -0,0 +1,232 @@
+package providers
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+
+	"doc-to-text/pkg/document"
+)
+
+// htmlDocumentBuilder turns an HTML tree into document blocks
+type htmlDocumentBuilder struct {
+	extractor *HTMLExtractor
+	blocks    []document.Block
+	inline    inlineContent // Loose inline content waiting to become a paragraph
+}
+
+// inlineContent collects the text and links below block elements
+type inlineContent struct {
+	text  strings.Builder
+	links []document.Link
+}
+
+// buildHTMLBlocks converts parsed HTML into headings, paragraphs, list items, tables,
+// captions, code and quotes
+func (e *HTMLExtractor) buildHTMLBlocks(root *html.Node) []document.Block {
+	builder := &htmlDocumentBuilder{extractor: e}
+	builder.walk(root)
+	builder.flush()
+	return builder.blocks
+}
+
+// walk visits a node, emitting blocks for block elements and collecting inline content
+func (b *htmlDocumentBuilder) walk(node *html.Node) {
+	switch node.Type {
+	case html.TextNode:
+		b.inline.text.WriteString(node.Data)
+		return
+	case html.ElementNode:
+	default:
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			b.walk(child)
+		}
+		return
+	}
+
+	switch node.DataAtom {
+	case atom.Script, atom.Style, atom.Head, atom.Noscript, atom.Template:
+		return
+	case atom.Br, atom.A:
+		b.inline.collect(node)
+		return
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		b.flush()
+		level := int(node.Data[1] - '0')
+		b.add(inlineBlock(node, document.Block{Type: document.BlockHeading, Level: level}))
+		return
+	case atom.P:
+		b.flush()
+		b.add(inlineBlock(node, document.Block{Type: document.BlockParagraph}))
+		return
+	case atom.Ul, atom.Ol:
+		b.flush()
+		b.walkList(node, 0)
+		return
+	case atom.Table:
+		b.flush()
+		if table := b.extractor.extractTable(node); table != nil {
+			b.blocks = append(b.blocks, document.Block{Type: document.BlockTable, Rows: table.Rows})
+			return
+		}
+	case atom.Pre:
+		b.flush()
+		if code := strings.Trim(rawText(node), "\n"); strings.TrimSpace(code) != "" {
+			b.blocks = append(b.blocks, document.Block{Type: document.BlockCode, Text: code})
+		}
+		return
+	case atom.Blockquote:
+		b.flush()
+		b.add(inlineBlock(node, document.Block{Type: document.BlockQuote}))
+		return
+	case atom.Figcaption, atom.Caption:
+		b.flush()
+		b.add(inlineBlock(node, document.Block{Type: document.BlockCaption}))
+		return
+	}
+
+	block := b.extractor.isBlockElement(node.DataAtom)
+	if block {
+		b.flush()
+	}
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		b.walk(child)
+	}
+	if block {
+		b.flush()
+	}
+}
+
+// walkList emits the items of a list; nested lists become deeper items
+func (b *htmlDocumentBuilder) walkList(list *html.Node, level int) {
+	ordered := list.DataAtom == atom.Ol
+	for item := list.FirstChild; item != nil; item = item.NextSibling {
+		if item.Type != html.ElementNode || item.DataAtom != atom.Li {
+			continue
+		}
+
+		var nested []*html.Node
+		var content inlineContent
+		for child := item.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type == html.ElementNode && (child.DataAtom == atom.Ul || child.DataAtom == atom.Ol) {
+				nested = append(nested, child)
+				continue
+			}
+			content.collect(child)
+		}
+
+		b.add(content.block(document.Block{Type: document.BlockListItem, Level: level, Ordered: ordered}))
+		for _, child := range nested {
+			b.walkList(child, level+1)
+		}
+	}
+}
+
+// flush turns collected loose inline content into a paragraph
+func (b *htmlDocumentBuilder) flush() {
+	b.add(b.inline.block(document.Block{Type: document.BlockParagraph}))
+	b.inline = inlineContent{}
+}
+
+// add appends a block unless it has no text
+func (b *htmlDocumentBuilder) add(block document.Block) {
+	if block.Text != "" {
+		b.blocks = append(b.blocks, block)
+	}
+}
+
+// inlineBlock fills a block with the inline content of a node
+func inlineBlock(node *html.Node, block document.Block) document.Block {
+	var content inlineContent
+	content.collect(node)
+	return content.block(block)
+}
+
+// block sets the normalized text and links of a block
+func (c *inlineContent) block(block document.Block) document.Block {
+	block.Text = normalizeInline(c.text.String())
+	block.Links = c.links
+	return block
+}
+
+// collect gathers the text and links below a node, turning <br> and block children into line breaks
+func (c *inlineContent) collect(node *html.Node) {
+	switch node.Type {
+	case html.TextNode:
+		c.text.WriteString(node.Data)
+		return
+	case html.ElementNode:
+		switch node.DataAtom {
+		case atom.Script, atom.Style:
+			return
+		case atom.Br:
+			c.text.WriteString("\n")
+			return
+		case atom.A:
+			var anchor inlineContent
+			for child := node.FirstChild; child != nil; child = child.NextSibling {
+				anchor.collect(child)
+			}
+			text := normalizeInline(anchor.text.String())
+			if href := linkTarget(node); href != "" && text != "" {
+				c.links = append(c.links, document.Link{Text: text, URL: href})
+			} else {
+				c.links = append(c.links, anchor.links...)
+			}
+			c.text.WriteString(anchor.text.String())
+			return
+		case atom.P, atom.Div, atom.Li:
+			if c.text.Len() > 0 {
+				c.text.WriteString("\n")
+			}
+		}
+	}
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		c.collect(child)
+	}
+}
+
+// linkTarget returns the href of an anchor unless it is empty or a script
+func linkTarget(node *html.Node) string {
+	for _, attr := range node.Attr {
+		if attr.Key == "href" {
+			href := strings.TrimSpace(attr.Val)
+			if strings.HasPrefix(strings.ToLower(href), "javascript:") {
+				return ""
+			}
+			return href
+		}
+	}
+	return ""
+}
+
+// rawText returns the text below a node with whitespace preserved
+func rawText(node *html.Node) string {
+	var builder strings.Builder
+	var collect func(*html.Node)
+	collect = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			builder.WriteString(n.Data)
+		}
+		if n.Type == html.ElementNode && n.DataAtom == atom.Br {
+			builder.WriteString("\n")
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			collect(child)
+		}
+	}
+	collect(node)
+	return builder.String()
+}
+
+// normalizeInline collapses runs of spaces within lines and drops empty lines
+func normalizeInline(text string) string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -3,6 +3,7 @@ package providers
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 
 	"doc-to-text/pkg/interfaces"
@@ -39,9 +40,28 @@ func (e *TextFileExtractor) Extract(ctx context.Context, inputFile string) (stri
 	return string(content), nil
 }
 
+// ExtractReader reads plain text straight from a stream
+func (e *TextFileExtractor) ExtractReader(ctx context.Context, r io.Reader, fileInfo *types.FileInfo) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return "", fmt.Errorf("failed to read stream: %w", err)
+	}
+
+	return string(content), nil
+}
+
+// SupportsReader reports whether the text can be read from a stream
+func (e *TextFileExtractor) SupportsReader(fileInfo *types.FileInfo) bool {
+	return e.SupportsFile(fileInfo)
+}
+
 // SupportsFile checks if this extractor supports the given file type
 func (e *TextFileExtractor) SupportsFile(fileInfo *types.FileInfo) bool {
-	return utils.IsTextFile(fileInfo.Extension, fileInfo.MimeType)
+	return utils.IsTextFile(fileInfo.Format, fileInfo.MimeType)
 }
 
 // Name returns the name of the extractor
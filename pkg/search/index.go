@@ -0,0 +1,325 @@
+// Package search maintains a local inverted index over extracted outputs and answers
+// ranked queries against it. Every page is indexed separately so hits point to pages.
+package search
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"doc-to-text/pkg/constants"
+	"doc-to-text/pkg/document"
+	"doc-to-text/pkg/utils"
+)
+
+const (
+	// indexFileName is the index file inside the index directory
+	indexFileName = "index.gob"
+	// lockFileName guards the index against concurrent writers
+	lockFileName = "index.lock"
+	// formatVersion changes whenever the index file layout changes
+	formatVersion = 1
+
+	lockWait  = 30 * time.Second // Longest wait for another writer
+	lockStale = 5 * time.Minute  // Age after which a lock left by a crashed writer is removed
+)
+
+// Document is an indexed extraction output
+type Document struct {
+	Hash    string    // MD5 of the input, the name of its work directory
+	Sources []string  // Input files known to have this content
+	Output  string    // Output file the text was read from
+	Size    int64     // Size of the output file when indexed
+	ModTime time.Time // Modification time of the output file when indexed
+	Paged   bool      // Whether the text has page markers
+	Pages   []Page
+}
+
+// Page is an indexed page of a document
+type Page struct {
+	Number int // Page number; 0 for text before the first page marker
+	Length int // Number of terms on the page
+}
+
+// Posting lists the positions of a term on one page
+type Posting struct {
+	Doc       uint32
+	Page      int32 // Index into Document.Pages
+	Positions []int32
+}
+
+// indexData is the persisted index
+type indexData struct {
+	Version    int
+	NextID     uint32
+	Documents  map[uint32]*Document
+	Terms      map[string][]Posting
+	PageCount  int
+	TokenCount int64
+}
+
+// Index is an inverted index of page terms
+type Index struct {
+	dir    string
+	data   indexData
+	byHash map[string]uint32
+}
+
+// DefaultDir returns the index directory used when none is configured:
+// $XDG_DATA_HOME/doc-to-text/index, or ~/.local/share/doc-to-text/index
+func DefaultDir() string {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, "doc-to-text", "index")
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".local", "share", "doc-to-text", "index")
+	}
+	return ".doc-to-text-index"
+}
+
+// Exists reports whether an index has been created in dir
+func Exists(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, indexFileName))
+	return err == nil
+}
+
+// Open loads the index in dir for reading; a missing index is empty
+func Open(dir string) (*Index, error) {
+	ix := &Index{dir: dir}
+	if err := ix.load(); err != nil {
+		return nil, err
+	}
+	return ix, nil
+}
+
+// Update locks the index in dir, applies fn and saves the result. The index is
+// created when missing; nothing is saved when fn fails.
+func Update(dir string, fn func(ix *Index) error) error {
+	if err := utils.EnsureDir(dir); err != nil {
+		return utils.WrapError(err, utils.ErrorTypeIO, "failed to create index directory")
+	}
+	unlock, err := lock(dir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	ix, err := Open(dir)
+	if err != nil {
+		return err
+	}
+	if err := fn(ix); err != nil {
+		return err
+	}
+	return ix.save()
+}
+
+// Reset removes every document from the index
+func (ix *Index) Reset() {
+	ix.data = indexData{Version: formatVersion, Documents: make(map[uint32]*Document), Terms: make(map[string][]Posting)}
+	ix.byHash = make(map[string]uint32)
+}
+
+// Dir returns the index directory
+func (ix *Index) Dir() string {
+	return ix.dir
+}
+
+// Lookup returns the document indexed for a hash, or nil
+func (ix *Index) Lookup(hash string) *Document {
+	if id, ok := ix.byHash[hash]; ok {
+		return ix.data.Documents[id]
+	}
+	return nil
+}
+
+// Documents returns the indexed documents ordered by hash
+func (ix *Index) Documents() []*Document {
+	docs := make([]*Document, 0, len(ix.data.Documents))
+	for _, doc := range ix.data.Documents {
+		docs = append(docs, doc)
+	}
+	sort.Slice(docs, func(i, j int) bool { return docs[i].Hash < docs[j].Hash })
+	return docs
+}
+
+// Add indexes the pages of a document, replacing an earlier version with the same
+// hash. Sources of the earlier version that still exist are kept.
+func (ix *Index) Add(doc Document, pages []document.PageText) {
+	if old := ix.Lookup(doc.Hash); old != nil {
+		doc.Sources = append(old.Sources, doc.Sources...)
+		ix.Remove(doc.Hash)
+	}
+	doc.Sources = existingPaths(doc.Sources)
+	doc.Pages = make([]Page, 0, len(pages))
+
+	id := ix.data.NextID
+	ix.data.NextID++
+	for i, page := range pages {
+		positions := make(map[string][]int32)
+		tokens := Tokenize(page.Text)
+		for position, token := range tokens {
+			positions[token.Term] = append(positions[token.Term], int32(position))
+		}
+		for term, list := range positions {
+			ix.data.Terms[term] = append(ix.data.Terms[term], Posting{Doc: id, Page: int32(i), Positions: list})
+		}
+		doc.Pages = append(doc.Pages, Page{Number: page.Number, Length: len(tokens)})
+		ix.data.TokenCount += int64(len(tokens))
+	}
+	ix.data.PageCount += len(pages)
+	ix.data.Documents[id] = &doc
+	ix.byHash[doc.Hash] = id
+}
+
+// AddSource records another input file for an indexed document
+func (ix *Index) AddSource(hash, source string) {
+	doc := ix.Lookup(hash)
+	if doc == nil || source == "" {
+		return
+	}
+	doc.Sources = existingPaths(append(doc.Sources, source))
+}
+
+// Remove drops a document from the index
+func (ix *Index) Remove(hash string) {
+	id, ok := ix.byHash[hash]
+	if !ok {
+		return
+	}
+	doc := ix.data.Documents[id]
+	for term, postings := range ix.data.Terms {
+		kept := postings[:0]
+		for _, posting := range postings {
+			if posting.Doc != id {
+				kept = append(kept, posting)
+			}
+		}
+		if len(kept) == 0 {
+			delete(ix.data.Terms, term)
+		} else {
+			ix.data.Terms[term] = kept
+		}
+	}
+	for _, page := range doc.Pages {
+		ix.data.TokenCount -= int64(page.Length)
+	}
+	ix.data.PageCount -= len(doc.Pages)
+	delete(ix.data.Documents, id)
+	delete(ix.byHash, hash)
+}
+
+// Unchanged reports whether a document is indexed from the given output file as it
+// is now, so it need not be read again
+func (ix *Index) Unchanged(hash, output string, info os.FileInfo) bool {
+	doc := ix.Lookup(hash)
+	return doc != nil && doc.Output == output && doc.Size == info.Size() && doc.ModTime.Equal(info.ModTime())
+}
+
+// load reads the index file; a missing file yields an empty index
+func (ix *Index) load() error {
+	ix.Reset()
+	file, err := os.Open(filepath.Join(ix.dir, indexFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return utils.WrapError(err, utils.ErrorTypeIO, "failed to open search index")
+	}
+	defer file.Close()
+
+	var data indexData
+	if err := gob.NewDecoder(file).Decode(&data); err != nil {
+		return utils.WrapError(err, utils.ErrorTypeValidation, fmt.Sprintf("search index in %s is unreadable, rebuild it with 'doc-to-text index --rebuild'", ix.dir))
+	}
+	if data.Version != formatVersion {
+		return utils.NewValidationError(fmt.Sprintf("search index in %s has an old format, rebuild it with 'doc-to-text index --rebuild'", ix.dir), nil)
+	}
+	ix.data = data
+	if ix.data.Documents == nil {
+		ix.data.Documents = make(map[uint32]*Document)
+	}
+	if ix.data.Terms == nil {
+		ix.data.Terms = make(map[string][]Posting)
+	}
+	for id, doc := range ix.data.Documents {
+		ix.byHash[doc.Hash] = id
+	}
+	return nil
+}
+
+// save writes the index to a temporary file and renames it over the old one, so
+// readers never see a partial index
+func (ix *Index) save() error {
+	file, err := os.CreateTemp(ix.dir, indexFileName+".*.tmp")
+	if err != nil {
+		return utils.WrapError(err, utils.ErrorTypeIO, "failed to create search index file")
+	}
+	tempPath := file.Name()
+	defer os.Remove(tempPath)
+
+	if err := gob.NewEncoder(file).Encode(&ix.data); err != nil {
+		file.Close()
+		return utils.WrapError(err, utils.ErrorTypeIO, "failed to write search index")
+	}
+	if err := file.Close(); err != nil {
+		return utils.WrapError(err, utils.ErrorTypeIO, "failed to write search index")
+	}
+	if err := os.Chmod(tempPath, constants.DefaultFilePermission); err != nil {
+		return utils.WrapError(err, utils.ErrorTypeIO, "failed to write search index")
+	}
+	if err := os.Rename(tempPath, filepath.Join(ix.dir, indexFileName)); err != nil {
+		return utils.WrapError(err, utils.ErrorTypeIO, "failed to replace search index")
+	}
+	return nil
+}
+
+// lock creates the lock file of an index directory, waiting while another writer
+// holds it. Locks older than lockStale are left by crashed writers and removed.
+func lock(dir string) (func(), error) {
+	path := filepath.Join(dir, lockFileName)
+	deadline := time.Now().Add(lockWait)
+	for {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, constants.DefaultFilePermission)
+		if err == nil {
+			file.WriteString(strconv.Itoa(os.Getpid()))
+			file.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, utils.WrapError(err, utils.ErrorTypeIO, "failed to lock search index")
+		}
+		if info, statErr := os.Stat(path); statErr == nil && time.Since(info.ModTime()) > lockStale {
+			os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, utils.NewError(utils.ErrorTypeTimeout, fmt.Sprintf("search index is locked by another process (%s)", path), nil)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// existingPaths removes duplicates and paths that no longer exist
+func existingPaths(paths []string) []string {
+	seen := make(map[string]bool, len(paths))
+	kept := paths[:0:0]
+	for _, path := range paths {
+		path = strings.TrimSpace(path)
+		if path == "" || seen[path] {
+			continue
+		}
+		seen[path] = true
+		if _, err := os.Stat(path); err == nil {
+			kept = append(kept, path)
+		}
+	}
+	sort.Strings(kept)
+	return kept
+}
@@ -0,0 +1,353 @@
+package search
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"doc-to-text/pkg/document"
+)
+
+// BM25 parameters
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+const (
+	defaultLimit       = 10
+	defaultPagesPerDoc = 3
+	snippetBefore      = 60  // Characters shown before the first match
+	snippetLength      = 220 // Characters in a snippet
+)
+
+// Options configure a search
+type Options struct {
+	Limit       int                                              // Largest number of documents returned (default 10)
+	PagesPerDoc int                                              // Largest number of page hits per document (default 3)
+	Pages       func(doc *Document) ([]document.PageText, error) // Reads the page texts for snippets; nil leaves snippets empty
+}
+
+// Result is a document matching a query with its best pages
+type Result struct {
+	Hash    string    `json:"hash"`
+	Sources []string  `json:"sources,omitempty"`
+	Output  string    `json:"output"`
+	Paged   bool      `json:"paged"`
+	Score   float64   `json:"score"`
+	Hits    int       `json:"hits"` // Matching pages
+	Pages   []PageHit `json:"pages"`
+}
+
+// PageHit is a matching page
+type PageHit struct {
+	Page    int     `json:"page"`
+	Score   float64 `json:"score"`
+	Matches int     `json:"matches"`
+	Snippet string  `json:"snippet,omitempty"` // Text around the first match, matches wrapped in **
+}
+
+// clause is a term or a phrase whose terms must appear in order
+type clause struct {
+	terms []string
+}
+
+// pageKey identifies a page of a document
+type pageKey struct {
+	doc  uint32
+	page int32
+}
+
+// Search ranks the pages matching a query with BM25 and groups them by document,
+// best document first. Words match any page containing them; "quoted phrases" and
+// CJK runs, which are tokenized into bigrams, match only in order.
+func (ix *Index) Search(query string, opts Options) []Result {
+	if opts.Limit <= 0 {
+		opts.Limit = defaultLimit
+	}
+	if opts.PagesPerDoc <= 0 {
+		opts.PagesPerDoc = defaultPagesPerDoc
+	}
+	clauses := parseQuery(query)
+	if len(clauses) == 0 || ix.data.PageCount == 0 {
+		return nil
+	}
+
+	// Score every matching page
+	scores := make(map[pageKey]float64)
+	matches := make(map[pageKey]int)
+	pages := float64(ix.data.PageCount)
+	avgLength := float64(ix.data.TokenCount) / pages
+	for _, c := range clauses {
+		counts := ix.match(c)
+		idf := math.Log(1 + (pages-float64(len(counts))+0.5)/(float64(len(counts))+0.5))
+		for key, count := range counts {
+			length := float64(ix.data.Documents[key.doc].Pages[key.page].Length)
+			tf := float64(count)
+			scores[key] += idf * tf * (bm25K1 + 1) / (tf + bm25K1*(1-bm25B+bm25B*length/math.Max(avgLength, 1)))
+			matches[key] += count
+		}
+	}
+
+	// Group pages by document
+	byDoc := make(map[uint32][]PageHit)
+	for key, score := range scores {
+		doc := ix.data.Documents[key.doc]
+		byDoc[key.doc] = append(byDoc[key.doc], PageHit{Page: doc.Pages[key.page].Number, Score: score, Matches: matches[key]})
+	}
+	results := make([]Result, 0, len(byDoc))
+	for id, hits := range byDoc {
+		sort.Slice(hits, func(i, j int) bool {
+			if hits[i].Score != hits[j].Score {
+				return hits[i].Score > hits[j].Score
+			}
+			return hits[i].Page < hits[j].Page
+		})
+		doc := ix.data.Documents[id]
+		results = append(results, Result{
+			Hash:    doc.Hash,
+			Sources: doc.Sources,
+			Output:  doc.Output,
+			Paged:   doc.Paged,
+			Score:   hits[0].Score,
+			Hits:    len(hits),
+			Pages:   hits[:min(len(hits), opts.PagesPerDoc)],
+		})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		if results[i].Hits != results[j].Hits {
+			return results[i].Hits > results[j].Hits
+		}
+		return results[i].Hash < results[j].Hash
+	})
+	if len(results) > opts.Limit {
+		results = results[:opts.Limit]
+	}
+
+	if opts.Pages != nil {
+		for i := range results {
+			addSnippets(&results[i], ix.Lookup(results[i].Hash), clauses, opts.Pages)
+		}
+	}
+	return results
+}
+
+// parseQuery splits a query into words and "quoted phrases"
+func parseQuery(query string) []clause {
+	var clauses []clause
+	seen := make(map[string]bool)
+	add := func(text string) {
+		var terms []string
+		for _, token := range Tokenize(text) {
+			terms = append(terms, token.Term)
+		}
+		if key := strings.Join(terms, " "); len(terms) > 0 && !seen[key] {
+			seen[key] = true
+			clauses = append(clauses, clause{terms: terms})
+		}
+	}
+
+	for rest := strings.TrimSpace(query); rest != ""; rest = strings.TrimSpace(rest) {
+		if strings.HasPrefix(rest, `"`) {
+			phrase, after, _ := strings.Cut(rest[1:], `"`)
+			add(phrase)
+			rest = after
+			continue
+		}
+		end := strings.IndexFunc(rest, func(r rune) bool { return unicode.IsSpace(r) || r == '"' })
+		if end < 0 {
+			end = len(rest)
+		}
+		add(rest[:end])
+		rest = rest[end:]
+	}
+	return clauses
+}
+
+// match counts the occurrences of a clause on every page containing it
+func (ix *Index) match(c clause) map[pageKey]int {
+	counts := make(map[pageKey]int)
+	if len(c.terms) == 1 {
+		for _, term := range ix.expand(c.terms[0]) {
+			for _, posting := range ix.data.Terms[term] {
+				counts[pageKey{posting.Doc, posting.Page}] += len(posting.Positions)
+			}
+		}
+		return counts
+	}
+
+	// Later terms of a phrase must follow at consecutive positions
+	following := make([]map[pageKey][]int32, len(c.terms))
+	for i, term := range c.terms[1:] {
+		following[i+1] = make(map[pageKey][]int32)
+		for _, posting := range ix.data.Terms[term] {
+			following[i+1][pageKey{posting.Doc, posting.Page}] = posting.Positions
+		}
+	}
+	for _, posting := range ix.data.Terms[c.terms[0]] {
+		key := pageKey{posting.Doc, posting.Page}
+		for _, position := range posting.Positions {
+			found := true
+			for i := 1; i < len(c.terms) && found; i++ {
+				found = containsPosition(following[i][key], position+int32(i))
+			}
+			if found {
+				counts[key]++
+			}
+		}
+	}
+	return counts
+}
+
+// expand returns the indexed terms matching a query term. A single CJK character
+// is only indexed inside bigrams, so it matches the bigrams starting with it.
+func (ix *Index) expand(term string) []string {
+	r, size := utf8.DecodeRuneInString(term)
+	if size != len(term) || !isCJK(r) {
+		return []string{term}
+	}
+	var terms []string
+	for indexed := range ix.data.Terms {
+		if strings.HasPrefix(indexed, term) {
+			terms = append(terms, indexed)
+		}
+	}
+	return terms
+}
+
+// containsPosition reports whether sorted positions contain a position
+func containsPosition(positions []int32, position int32) bool {
+	i := sort.Search(len(positions), func(i int) bool { return positions[i] >= position })
+	return i < len(positions) && positions[i] == position
+}
+
+// addSnippets fills the snippets of the page hits of a result from the page texts
+func addSnippets(result *Result, doc *Document, clauses []clause, read func(doc *Document) ([]document.PageText, error)) {
+	if doc == nil {
+		return
+	}
+	pages, err := read(doc)
+	if err != nil {
+		return
+	}
+	for i := range result.Pages {
+		for _, page := range pages {
+			if page.Number == result.Pages[i].Page {
+				result.Pages[i].Snippet = snippet(page.Text, matchRanges(Tokenize(page.Text), clauses))
+				break
+			}
+		}
+	}
+}
+
+// matchRanges returns the byte ranges of the clause occurrences in tokenized text
+func matchRanges(tokens []Token, clauses []clause) [][2]int {
+	var ranges [][2]int
+	for _, c := range clauses {
+		single := len(c.terms) == 1 && utf8.RuneCountInString(c.terms[0]) == 1
+		for i := 0; i+len(c.terms) <= len(tokens); i++ {
+			found := true
+			for k, term := range c.terms {
+				if single {
+					found = strings.HasPrefix(tokens[i].Term, term)
+				} else if tokens[i+k].Term != term {
+					found = false
+					break
+				}
+			}
+			if !found {
+				continue
+			}
+			end := tokens[i+len(c.terms)-1].End
+			if single {
+				_, size := utf8.DecodeRuneInString(tokens[i].Term)
+				end = tokens[i].Start + size
+			}
+			ranges = append(ranges, [2]int{tokens[i].Start, end})
+		}
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i][0] < ranges[j][0] })
+
+	// Merge overlapping ranges, e.g. of adjacent CJK bigrams
+	merged := ranges[:0]
+	for _, r := range ranges {
+		if n := len(merged); n > 0 && r[0] <= merged[n-1][1] {
+			merged[n-1][1] = max(merged[n-1][1], r[1])
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// snippet returns the text around the first match with whitespace collapsed and
+// the matches inside it wrapped in **
+func snippet(text string, ranges [][2]int) string {
+	if len(ranges) == 0 {
+		return ""
+	}
+	start := ranges[0][0]
+	for i := 0; i < snippetBefore && start > 0; i++ {
+		_, size := utf8.DecodeLastRuneInString(text[:start])
+		start -= size
+	}
+	if start > 0 {
+		// Start at a word when one begins close to the cut
+		if space := strings.IndexFunc(text[start:ranges[0][0]], unicode.IsSpace); space >= 0 && space < snippetBefore/3 {
+			start += space
+		}
+	}
+	end := start
+	for i := 0; i < snippetLength && end < len(text); i++ {
+		_, size := utf8.DecodeRuneInString(text[end:])
+		end += size
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	pos := start
+	for _, r := range ranges {
+		if r[0] >= end {
+			break
+		}
+		if r[0] < pos {
+			continue
+		}
+		b.WriteString(collapseSpace(text[pos:r[0]]))
+		b.WriteString("**" + collapseSpace(text[r[0]:min(r[1], end)]) + "**")
+		pos = min(r[1], end)
+	}
+	b.WriteString(collapseSpace(text[pos:end]))
+	if end < len(text) {
+		b.WriteString("…")
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// collapseSpace replaces runs of whitespace, including line breaks, with one space
+func collapseSpace(text string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range text {
+		if unicode.IsSpace(r) {
+			space = true
+			continue
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteRune(r)
+	}
+	if space {
+		b.WriteByte(' ')
+	}
+	return b.String()
+}
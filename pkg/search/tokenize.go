@@ -0,0 +1,82 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxTermLength is the longest term indexed, in bytes; longer runs are usually
+// encoded data rather than words
+const maxTermLength = 64
+
+// Token is a term and its byte range in the tokenized text
+type Token struct {
+	Term  string
+	Start int
+	End   int
+}
+
+// Tokenize splits text into lowercase terms: runs of letters and digits form one
+// term, and runs of CJK characters, which are written without spaces, are split
+// into overlapping bigrams ("文本处理" -> "文本", "本处", "处理"). A single CJK
+// character between other characters is a term of its own.
+func Tokenize(text string) []Token {
+	var tokens []Token
+	for pos := 0; pos < len(text); {
+		r, size := utf8.DecodeRuneInString(text[pos:])
+		switch {
+		case isCJK(r):
+			end := pos
+			var starts []int
+			for end < len(text) {
+				next, nextSize := utf8.DecodeRuneInString(text[end:])
+				if !isCJK(next) {
+					break
+				}
+				starts = append(starts, end)
+				end += nextSize
+			}
+			if len(starts) == 1 {
+				tokens = append(tokens, Token{Term: text[pos:end], Start: pos, End: end})
+			}
+			for i := 0; i+1 < len(starts); i++ {
+				bigramEnd := end
+				if i+2 < len(starts) {
+					bigramEnd = starts[i+2]
+				}
+				tokens = append(tokens, Token{Term: text[starts[i]:bigramEnd], Start: starts[i], End: bigramEnd})
+			}
+			pos = end
+
+		case isWordRune(r):
+			end := pos + size
+			for end < len(text) {
+				next, nextSize := utf8.DecodeRuneInString(text[end:])
+				if !isWordRune(next) || isCJK(next) {
+					break
+				}
+				end += nextSize
+			}
+			if end-pos <= maxTermLength {
+				tokens = append(tokens, Token{Term: strings.ToLower(text[pos:end]), Start: pos, End: end})
+			}
+			pos = end
+
+		default:
+			pos += size
+		}
+	}
+	return tokens
+}
+
+// isWordRune reports whether a rune belongs to a word
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r)
+}
+
+// isCJK reports whether a rune is a Han, Hiragana, Katakana or Hangul character
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r)
+}
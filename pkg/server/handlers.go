@@ -0,0 +1,351 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"doc-to-text/pkg/constants"
+	"doc-to-text/pkg/document"
+	"doc-to-text/pkg/utils"
+)
+
+// pathJobRequest is the JSON body of a job referencing a server-side file
+type pathJobRequest struct {
+	Path string `json:"path"`
+	JobOptions
+}
+
+// errorResponse is the JSON body of failed requests
+type errorResponse struct {
+	Error     string          `json:"error"`
+	ErrorType utils.ErrorType `json:"error_type,omitempty"`
+}
+
+// Handler returns the HTTP routes of the server
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", s.handleHealth)
+	mux.HandleFunc("GET /readyz", s.handleReady)
+	mux.HandleFunc("POST /v1/jobs", s.handleCreateJob)
+	mux.HandleFunc("GET /v1/jobs", s.handleListJobs)
+	mux.HandleFunc("GET /v1/jobs/{id}", s.handleGetJob)
+	mux.HandleFunc("GET /v1/jobs/{id}/result", s.handleGetResult)
+	mux.HandleFunc("DELETE /v1/jobs/{id}", s.handleCancelJob)
+	if s.opts.Token == "" {
+		return mux
+	}
+	return s.requireToken(mux)
+}
+
+// requireToken rejects requests to the /v1 routes without the configured bearer token;
+// health and readiness checks stay open for probes
+func (s *Server) requireToken(next http.Handler) http.Handler {
+	expected := []byte("Bearer " + s.opts.Token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/v1/") &&
+			subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, utils.NewPermissionError("missing or invalid bearer token", nil))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleHealth reports that the process is alive
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// handleReady reports whether jobs can be accepted and which external tools are installed
+func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
+	tools := ToolAvailability()
+
+	ready := true
+	var problems []string
+	testFile := filepath.Join(s.opts.JobsDir, ".write_test")
+	if err := os.WriteFile(testFile, []byte("test"), constants.DefaultFilePermission); err != nil {
+		ready = false
+		problems = append(problems, fmt.Sprintf("jobs directory not writable: %v", err))
+	} else {
+		os.Remove(testFile)
+	}
+	if len(s.queue) >= cap(s.queue) {
+		ready = false
+		problems = append(problems, "job queue is full")
+	}
+
+	status := http.StatusOK
+	if !ready {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, map[string]interface{}{
+		"ready":          ready,
+		"problems":       problems,
+		"tools":          tools,
+		"ocr_strategy":   s.config.OCRStrategy,
+		"queue_length":   len(s.queue),
+		"queue_capacity": cap(s.queue),
+		"workers":        s.opts.Workers,
+	})
+}
+
+// handleCreateJob accepts a multipart upload ("file" field) or a JSON body with a server-side path
+func (s *Server) handleCreateJob(w http.ResponseWriter, r *http.Request) {
+	id, err := newJobID()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, utils.NewSystemError("failed to create job id", err))
+		return
+	}
+	job := &Job{ID: id, Status: JobQueued, CreatedAt: time.Now(), dir: filepath.Join(s.opts.JobsDir, id)}
+	if err := utils.EnsureDir(job.dir); err != nil {
+		writeError(w, http.StatusInternalServerError, utils.WrapError(err, utils.ErrorTypeIO, "failed to create job directory"))
+		return
+	}
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		err = s.acceptUpload(w, r, job)
+	} else {
+		err = s.acceptPath(r, job)
+	}
+	if err == nil {
+		_, err = s.jobConfig(job.Options)
+	}
+	if err != nil {
+		os.RemoveAll(job.dir)
+		writeError(w, statusForError(err), err)
+		return
+	}
+
+	if err := s.submit(job); err != nil {
+		if errors.Is(err, errQueueFull) {
+			w.Header().Set("Retry-After", "30")
+			writeError(w, http.StatusServiceUnavailable, err)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	snapshot, _ := s.snapshot(job.ID)
+	w.Header().Set("Location", "/v1/jobs/"+job.ID)
+	writeJSON(w, http.StatusAccepted, snapshot)
+}
+
+// acceptUpload stores the uploaded file in the job directory
+func (s *Server) acceptUpload(w http.ResponseWriter, r *http.Request, job *Job) error {
+	r.Body = http.MaxBytesReader(w, r.Body, s.opts.MaxUploadMB*1024*1024)
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		return utils.NewValidationError(fmt.Sprintf("missing or too large 'file' upload (max %d MB)", s.opts.MaxUploadMB), err)
+	}
+	defer file.Close()
+
+	name := utils.SanitizeFileName(filepath.Base(header.Filename))
+	if name == "" || name == "." {
+		return utils.NewValidationError("uploaded file has no name", nil)
+	}
+
+	inputDir := filepath.Join(job.dir, inputDirName)
+	if err := utils.EnsureDir(inputDir); err != nil {
+		return utils.WrapError(err, utils.ErrorTypeIO, "failed to create input directory")
+	}
+	job.InputPath = filepath.Join(inputDir, name)
+	job.Source = header.Filename
+
+	out, err := os.Create(job.InputPath)
+	if err != nil {
+		return utils.WrapError(err, utils.ErrorTypeIO, "failed to store upload")
+	}
+	defer out.Close()
+	if _, err := io.Copy(out, file); err != nil {
+		return utils.WrapError(err, utils.ErrorTypeIO, "failed to store upload")
+	}
+
+	job.Options = JobOptions{
+		ContentType: r.FormValue("content_type"),
+		OCRStrategy: r.FormValue("ocr"),
+		LLMTemplate: r.FormValue("llm_template"),
+	}
+	if langs := r.Form["lang"]; len(langs) > 0 {
+		job.Options.Languages = langs
+	}
+	return nil
+}
+
+// acceptPath validates a server-side path against the allowed roots
+func (s *Server) acceptPath(r *http.Request, job *Job) error {
+	if len(s.opts.AllowedRoots) == 0 {
+		return utils.NewPermissionError("server-side paths are disabled (start the server with --allow-path)", nil)
+	}
+
+	var request pathJobRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&request); err != nil {
+		return utils.NewValidationError("invalid JSON body", err)
+	}
+	if request.Path == "" {
+		return utils.NewValidationError("'path' is required", nil)
+	}
+
+	absPath, err := filepath.Abs(request.Path)
+	if err != nil {
+		return utils.WrapError(err, utils.ErrorTypeValidation, "error resolving path")
+	}
+	// Resolve symlinks so a link inside an allowed root cannot point outside it
+	if resolved, err := filepath.EvalSymlinks(absPath); err == nil {
+		absPath = resolved
+	}
+	if !s.isAllowedPath(absPath) {
+		return utils.NewPermissionError(fmt.Sprintf("path '%s' is outside the allowed directories", request.Path), nil)
+	}
+
+	info, err := os.Stat(absPath)
+	if os.IsNotExist(err) {
+		return utils.NewNotFoundError(fmt.Sprintf("file not found: %s", request.Path), err)
+	}
+	if err != nil {
+		return utils.WrapError(err, utils.ErrorTypeIO, "failed to access path")
+	}
+	if info.IsDir() {
+		return utils.NewValidationError(fmt.Sprintf("path '%s' is a directory, please specify a file", request.Path), nil)
+	}
+
+	job.InputPath = absPath
+	job.Source = request.Path
+	job.Options = request.JobOptions
+	return nil
+}
+
+// handleListJobs lists all jobs, newest first
+func (s *Server) handleListJobs(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	jobs := make([]Job, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		jobs = append(jobs, *job)
+	}
+	s.mu.RUnlock()
+
+	if status := r.URL.Query().Get("status"); status != "" {
+		filtered := jobs[:0]
+		for _, job := range jobs {
+			if string(job.Status) == status {
+				filtered = append(filtered, job)
+			}
+		}
+		jobs = filtered
+	}
+
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].CreatedAt.After(jobs[j].CreatedAt) })
+	writeJSON(w, http.StatusOK, map[string]interface{}{"jobs": jobs})
+}
+
+// handleGetJob returns the status and progress of a job
+func (s *Server) handleGetJob(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.snapshot(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, utils.NewNotFoundError("job not found", nil))
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+// handleGetResult returns the result of a finished job as text, json or markdown (?format=)
+func (s *Server) handleGetResult(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.snapshot(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, utils.NewNotFoundError("job not found", nil))
+		return
+	}
+	if job.Status != JobSucceeded {
+		writeError(w, http.StatusConflict, utils.NewValidationError(fmt.Sprintf("job is %s, no result available", job.Status), nil))
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	switch format {
+	case "", "text":
+		text, err := os.ReadFile(job.textPath())
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, utils.WrapError(err, utils.ErrorTypeIO, "failed to read result"))
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		w.Write(text)
+	case "markdown", "md":
+		result, err := job.loadResult()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		doc := result.Document
+		if doc == nil {
+			doc = document.FromText(result.Text)
+		}
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(doc.Markdown()))
+	case "json":
+		result, err := job.loadResult()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"job": job, "result": result})
+	default:
+		writeError(w, http.StatusBadRequest, utils.NewValidationError(fmt.Sprintf("unsupported format '%s' (expected text, json or markdown)", format), nil))
+	}
+}
+
+// handleCancelJob cancels a queued or running job
+func (s *Server) handleCancelJob(w http.ResponseWriter, r *http.Request) {
+	if _, err := s.cancelJob(r.PathValue("id")); err != nil {
+		writeError(w, statusForError(err), err)
+		return
+	}
+	job, _ := s.snapshot(r.PathValue("id"))
+	writeJSON(w, http.StatusOK, job)
+}
+
+// statusForError maps application error types to HTTP status codes
+func statusForError(err error) int {
+	switch utils.GetErrorType(err) {
+	case utils.ErrorTypeValidation, utils.ErrorTypeUnsupported:
+		return http.StatusBadRequest
+	case utils.ErrorTypeNotFound:
+		return http.StatusNotFound
+	case utils.ErrorTypePermission:
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// writeJSON writes v as an indented JSON response
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(v)
+}
+
+// writeError writes an error response
+func writeError(w http.ResponseWriter, status int, err error) {
+	response := errorResponse{Error: err.Error()}
+	var appErr *utils.AppError
+	if errors.As(err, &appErr) {
+		response.Error = appErr.Message
+		response.ErrorType = appErr.Type
+	}
+	writeJSON(w, status, response)
+}
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"doc-to-text/pkg/constants"
+	"doc-to-text/pkg/interfaces"
+	"doc-to-text/pkg/utils"
+)
+
+// JobStatus is the lifecycle state of a job
+type JobStatus string
+
+const (
+	JobQueued    JobStatus = "queued"
+	JobRunning   JobStatus = "running"
+	JobSucceeded JobStatus = "succeeded"
+	JobFailed    JobStatus = "failed"
+	JobCancelled JobStatus = "cancelled"
+)
+
+// Files inside a job directory
+const (
+	jobFileName    = "job.json"
+	resultFileName = "result.json"
+	textFileName   = "text.txt"
+	inputDirName   = "input"
+)
+
+// JobOptions are per-job overrides of the server configuration
+type JobOptions struct {
+	ContentType string   `json:"content_type,omitempty"`
+	OCRStrategy string   `json:"ocr,omitempty"`
+	LLMTemplate string   `json:"llm_template,omitempty"`
+	Languages   []string `json:"lang,omitempty"`
+}
+
+// JobProgress reports completed units (pages) of a running job
+type JobProgress struct {
+	Done    int     `json:"done"`
+	Total   int     `json:"total"`
+	Percent float64 `json:"percent"`
+}
+
+// Job is one extraction request, persisted as job.json in its directory
+type Job struct {
+	ID            string          `json:"id"`
+	Status        JobStatus       `json:"status"`
+	Source        string          `json:"source"`     // uploaded file name or server-side path
+	InputPath     string          `json:"input_path"` // file processed by the job
+	Options       JobOptions      `json:"options"`
+	Progress      JobProgress     `json:"progress"`
+	ExtractorUsed string          `json:"extractor_used,omitempty"`
+	Error         string          `json:"error,omitempty"`
+	ErrorType     utils.ErrorType `json:"error_type,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+	StartedAt     *time.Time      `json:"started_at,omitempty"`
+	FinishedAt    *time.Time      `json:"finished_at,omitempty"`
+
+	dir string
+}
+
+// newJobID returns a random job identifier
+func newJobID() (string, error) {
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// isFinished reports whether the job reached a final state
+func (j *Job) isFinished() bool {
+	return j.Status == JobSucceeded || j.Status == JobFailed || j.Status == JobCancelled
+}
+
+// textPath returns the path of the extracted text
+func (j *Job) textPath() string {
+	return filepath.Join(j.dir, textFileName)
+}
+
+// resultPath returns the path of the full extraction result
+func (j *Job) resultPath() string {
+	return filepath.Join(j.dir, resultFileName)
+}
+
+// loadResult reads the extraction result saved for a finished job
+func (j *Job) loadResult() (*interfaces.ExtractionResult, error) {
+	data, err := os.ReadFile(j.resultPath())
+	if err != nil {
+		return nil, utils.WrapError(err, utils.ErrorTypeIO, "failed to read result")
+	}
+	var result interfaces.ExtractionResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, utils.WrapError(err, utils.ErrorTypeIO, "failed to parse result")
+	}
+	return &result, nil
+}
+
+// save writes job.json atomically so a crash never leaves a truncated record
+func (j *Job) save() error {
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+	tempPath := filepath.Join(j.dir, jobFileName+".tmp")
+	if err := os.WriteFile(tempPath, data, constants.DefaultFilePermission); err != nil {
+		return err
+	}
+	return os.Rename(tempPath, filepath.Join(j.dir, jobFileName))
+}
+
+// loadJob reads a job directory written by an earlier server run
+func loadJob(dir string) (*Job, error) {
+	data, err := os.ReadFile(filepath.Join(dir, jobFileName))
+	if err != nil {
+		return nil, err
+	}
+	var job Job
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, err
+	}
+	job.dir = dir
+	return &job, nil
+}
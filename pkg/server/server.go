@@ -0,0 +1,435 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"doc-to-text/pkg/batch"
+	"doc-to-text/pkg/config"
+	"doc-to-text/pkg/constants"
+	"doc-to-text/pkg/doctotext"
+	"doc-to-text/pkg/interfaces"
+	"doc-to-text/pkg/logger"
+	"doc-to-text/pkg/types"
+	"doc-to-text/pkg/utils"
+)
+
+// retentionSweepInterval is how often finished jobs are checked against the retention
+const retentionSweepInterval = 10 * time.Minute
+
+// Options configure the HTTP server
+type Options struct {
+	Address       string        // listen address, e.g. "127.0.0.1:8080"
+	Token         string        // bearer token required on /v1 routes; empty disables authentication
+	Public        bool          // allow a non-loopback address without a token
+	JobsDir       string        // persisted job directories
+	QueueSize     int           // queued jobs accepted before new ones are rejected
+	Workers       int           // jobs processed in parallel
+	MaxUploadMB   int64         // largest accepted upload
+	AllowedRoots  []string      // directories server-side paths may point into; empty disables path jobs
+	Retention     time.Duration // finished jobs and their uploads are deleted this long after finishing; 0 keeps them
+	ShutdownGrace time.Duration
+}
+
+// Server runs extraction jobs submitted over HTTP
+type Server struct {
+	config  *config.Config
+	logger  *logger.Logger
+	opts    Options
+	queue   chan *Job
+	mu      sync.RWMutex
+	jobs    map[string]*Job
+	cancels map[string]context.CancelFunc
+}
+
+// NewServer creates a server and restores the jobs persisted in the jobs directory
+func NewServer(cfg *config.Config, log *logger.Logger, opts Options) (*Server, error) {
+	if opts.QueueSize < 1 {
+		opts.QueueSize = 1
+	}
+	if opts.Workers < 1 {
+		opts.Workers = 1
+	}
+	if opts.Token == "" && !opts.Public && !isLoopback(opts.Address) {
+		return nil, utils.NewValidationError(fmt.Sprintf("listening on '%s' exposes the API to the network; set a token or allow public access explicitly", opts.Address), nil)
+	}
+	if err := utils.EnsureDir(opts.JobsDir); err != nil {
+		return nil, utils.WrapError(err, utils.ErrorTypePermission, fmt.Sprintf("failed to create jobs directory '%s'", opts.JobsDir))
+	}
+
+	// Jobs run unattended and must never prompt
+	cfg.Interactive = false
+
+	s := &Server{
+		config:  cfg,
+		logger:  log,
+		opts:    opts,
+		queue:   make(chan *Job, opts.QueueSize),
+		jobs:    make(map[string]*Job),
+		cancels: make(map[string]context.CancelFunc),
+	}
+
+	if err := s.restoreJobs(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// ListenAndServe starts the workers and serves HTTP until ctx is cancelled
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	workerCtx, stopWorkers := context.WithCancel(ctx)
+	defer stopWorkers()
+
+	var wg sync.WaitGroup
+	for i := 0; i < s.opts.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.worker(workerCtx)
+		}()
+	}
+
+	if s.opts.Retention > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.expireJobs(workerCtx)
+		}()
+	}
+
+	httpServer := &http.Server{Addr: s.opts.Address, Handler: s.Handler()}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- httpServer.ListenAndServe()
+	}()
+
+	s.logger.ProgressAlways("🌐", "Listening on %s (jobs: %s, workers: %d, queue: %d)",
+		s.opts.Address, s.opts.JobsDir, s.opts.Workers, s.opts.QueueSize)
+
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, http.ErrServerClosed) {
+			return utils.WrapError(err, utils.ErrorTypeNetwork, "HTTP server failed")
+		}
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), s.opts.ShutdownGrace)
+		defer cancel()
+		httpServer.Shutdown(shutdownCtx)
+	}
+
+	// Running jobs are cancelled; they stay "running" on disk and are requeued on the next start
+	stopWorkers()
+	wg.Wait()
+	return nil
+}
+
+// isLoopback reports whether a listen address only accepts local connections
+func isLoopback(address string) bool {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil || host == "" {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// expireJobs deletes finished jobs older than the retention until ctx is cancelled
+func (s *Server) expireJobs(ctx context.Context) {
+	ticker := time.NewTicker(retentionSweepInterval)
+	defer ticker.Stop()
+	for {
+		s.removeExpiredJobs(time.Now().Add(-s.opts.Retention))
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// removeExpiredJobs deletes the directories, including uploads, of jobs finished before cutoff
+func (s *Server) removeExpiredJobs(cutoff time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := 0
+	for id, job := range s.jobs {
+		if !job.isFinished() || job.FinishedAt == nil || job.FinishedAt.After(cutoff) {
+			continue
+		}
+		if err := os.RemoveAll(job.dir); err != nil {
+			s.logger.Warn("Failed to remove expired job %s: %v", id, err)
+			continue
+		}
+		delete(s.jobs, id)
+		removed++
+	}
+	if removed > 0 {
+		s.logger.ProgressAlways("🧹", "Removed %d job(s) finished more than %s ago", removed, s.opts.Retention)
+	}
+}
+
+// restoreJobs loads persisted jobs and requeues those that never finished
+func (s *Server) restoreJobs() error {
+	entries, err := os.ReadDir(s.opts.JobsDir)
+	if err != nil {
+		return utils.WrapError(err, utils.ErrorTypeIO, "failed to read jobs directory")
+	}
+
+	var pending []*Job
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		job, err := loadJob(filepath.Join(s.opts.JobsDir, entry.Name()))
+		if err != nil {
+			s.logger.Warn("Skipping unreadable job %s: %v", entry.Name(), err)
+			continue
+		}
+		s.jobs[job.ID] = job
+		if !job.isFinished() {
+			job.Status = JobQueued
+			job.StartedAt = nil
+			pending = append(pending, job)
+		}
+	}
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	sort.Slice(pending, func(i, j int) bool { return pending[i].CreatedAt.Before(pending[j].CreatedAt) })
+	s.logger.ProgressAlways("🔄", "Requeuing %d unfinished job(s)", len(pending))
+
+	// Restored jobs may exceed the queue size, so feed them in the background
+	go func() {
+		for _, job := range pending {
+			s.queue <- job
+		}
+	}()
+	return nil
+}
+
+// submit registers and queues a new job. The job directory is removed when the queue is full.
+func (s *Server) submit(job *Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := job.save(); err != nil {
+		return utils.WrapError(err, utils.ErrorTypeIO, "failed to save job")
+	}
+
+	select {
+	case s.queue <- job:
+		s.jobs[job.ID] = job
+		s.logger.ProgressAlways("📥", "Queued job %s (%s)", job.ID, job.Source)
+		return nil
+	default:
+		os.RemoveAll(job.dir)
+		return errQueueFull
+	}
+}
+
+// errQueueFull is returned when the bounded queue cannot take another job
+var errQueueFull = errors.New("job queue is full")
+
+// worker runs queued jobs until ctx is cancelled
+func (s *Server) worker(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case job := <-s.queue:
+			s.runJob(ctx, job)
+		}
+	}
+}
+
+// runJob processes one job through the file processor
+func (s *Server) runJob(ctx context.Context, job *Job) {
+	cfg, err := s.jobConfig(job.Options)
+
+	s.mu.Lock()
+	if job.Status != JobQueued {
+		// Cancelled while waiting in the queue
+		s.mu.Unlock()
+		return
+	}
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	s.cancels[job.ID] = cancel
+	now := time.Now()
+	job.Status = JobRunning
+	job.StartedAt = &now
+	job.save()
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.cancels, job.ID)
+		s.mu.Unlock()
+	}()
+
+	if err != nil {
+		s.finishJob(ctx, job, nil, err)
+		return
+	}
+
+	s.logger.ProgressAlways("⚙️", "Running job %s (%s)", job.ID, job.Source)
+
+	progressCtx := utils.WithProgress(jobCtx, func(done, total int) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		job.Progress = JobProgress{Done: done, Total: total, Percent: float64(done) / float64(total) * 100}
+	})
+
+	var result *interfaces.ExtractionResult
+	extractor, err := doctotext.New(doctotext.WithConfig(cfg), doctotext.WithLogger(s.logger))
+	if err == nil {
+		result, err = extractor.ExtractFileTo(progressCtx, job.InputPath, job.textPath())
+	}
+
+	if err == nil {
+		data, marshalErr := json.MarshalIndent(result, "", "  ")
+		if marshalErr == nil {
+			marshalErr = os.WriteFile(job.resultPath(), data, constants.DefaultFilePermission)
+		}
+		if marshalErr != nil {
+			err = utils.WrapError(marshalErr, utils.ErrorTypeIO, "failed to save result")
+		}
+	}
+
+	s.finishJob(ctx, job, result, err)
+}
+
+// finishJob records the final state of a job
+func (s *Server) finishJob(serverCtx context.Context, job *Job, result *interfaces.ExtractionResult, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Jobs interrupted by shutdown stay "running" on disk and are requeued on restart
+	if serverCtx.Err() != nil {
+		return
+	}
+	// Cancelled jobs keep the state recorded by cancelJob
+	if job.Status == JobCancelled {
+		return
+	}
+
+	now := time.Now()
+	job.FinishedAt = &now
+	if err != nil {
+		job.Status = JobFailed
+		job.Error = err.Error()
+		job.ErrorType = errorTypeOf(err)
+		s.logger.Error("Job %s failed: %v", job.ID, err)
+	} else {
+		job.Status = JobSucceeded
+		job.ExtractorUsed = result.ExtractorUsed
+		if job.Progress.Total > 0 {
+			job.Progress.Done = job.Progress.Total
+			job.Progress.Percent = 100
+		}
+		s.logger.ProgressAlways("✅", "Job %s succeeded (%s)", job.ID, result.ExtractorUsed)
+	}
+
+	if saveErr := job.save(); saveErr != nil {
+		s.logger.Error("Failed to save job %s: %v", job.ID, saveErr)
+	}
+}
+
+// jobConfig applies the job's overrides to a copy of the server configuration
+func (s *Server) jobConfig(opts JobOptions) (*config.Config, error) {
+	cfg := *s.config
+	// Jobs keep plain text; other formats are rendered from result.json on request
+	cfg.OutputFormat = types.OutputFormatText
+	if opts.ContentType != "" {
+		cfg.ContentType = types.ContentType(opts.ContentType)
+		if cfg.ContentType != types.ContentTypeText && cfg.ContentType != types.ContentTypeImage {
+			return nil, utils.NewValidationError(fmt.Sprintf("invalid content type '%s' (expected text or image)", opts.ContentType), nil)
+		}
+	}
+	if opts.OCRStrategy != "" {
+		cfg.OCRStrategy = types.OCRStrategy(opts.OCRStrategy)
+		if cfg.OCRStrategy != types.OCRStrategyLLMCaller && cfg.OCRStrategy != types.OCRStrategySuryaOCR {
+			return nil, utils.NewValidationError(fmt.Sprintf("invalid OCR strategy '%s' (expected llm-caller or surya_ocr)", opts.OCRStrategy), nil)
+		}
+	}
+	if opts.LLMTemplate != "" {
+		cfg.LLMTemplate = opts.LLMTemplate
+	}
+	if cfg.OCRStrategy == types.OCRStrategyLLMCaller && cfg.LLMTemplate == "" {
+		return nil, utils.NewValidationError("LLM template is required when using llm-caller OCR strategy", nil)
+	}
+	if len(opts.Languages) > 0 {
+		cfg.OCRLanguages = utils.NormalizeLanguages(opts.Languages)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// cancelJob cancels a queued or running job
+func (s *Server) cancelJob(id string) (*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok {
+		return nil, utils.NewNotFoundError(fmt.Sprintf("job %s not found", id), nil)
+	}
+	if job.isFinished() {
+		return nil, utils.NewValidationError(fmt.Sprintf("job %s already %s", id, job.Status), nil)
+	}
+
+	if cancel, running := s.cancels[id]; running {
+		cancel()
+	}
+	now := time.Now()
+	job.Status = JobCancelled
+	job.FinishedAt = &now
+	job.save()
+	s.logger.ProgressAlways("🛑", "Cancelled job %s", id)
+	return job, nil
+}
+
+// snapshot returns a copy of a job safe to encode outside the lock
+func (s *Server) snapshot(id string) (Job, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+// isAllowedPath reports whether a server-side path lies inside one of the allowed roots
+func (s *Server) isAllowedPath(path string) bool {
+	for _, root := range s.opts.AllowedRoots {
+		rel, err := filepath.Rel(root, path)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// errorTypeOf returns the root cause type of a job error
+func errorTypeOf(err error) utils.ErrorType {
+	return batch.RootErrorType(err)
+}
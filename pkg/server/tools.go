@@ -0,0 +1,39 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+
+	"doc-to-text/pkg/constants"
+	"doc-to-text/pkg/utils"
+)
+
+// ToolAvailability reports which external tools used by the extractors are installed
+func ToolAvailability() map[string]bool {
+	platformConfig := constants.GetPlatformConfig()
+	return map[string]bool{
+		"llm-caller":    utils.IsCommandAvailable("llm-caller"),
+		"surya_ocr":     utils.IsCommandAvailable("surya_ocr"),
+		"surya_table":   utils.IsCommandAvailable("surya_table"),
+		"surya_layout":  utils.IsCommandAvailable("surya_layout"),
+		"ebook-convert": anyToolAvailable(platformConfig.CalibrePaths),
+		"ghostscript":   anyToolAvailable(platformConfig.GhostscriptPaths),
+		"pandoc":        anyToolAvailable(platformConfig.PandocPaths),
+	}
+}
+
+// anyToolAvailable checks command names in PATH and absolute paths on disk
+func anyToolAvailable(paths []string) bool {
+	for _, path := range paths {
+		if filepath.IsAbs(path) {
+			if _, err := os.Stat(path); err == nil {
+				return true
+			}
+			continue
+		}
+		if utils.IsCommandAvailable(path) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,278 @@
+package tables
+
+import (
+	"sort"
+	"strings"
+
+	"doc-to-text/pkg/types"
+)
+
+// Clustering thresholds for table detection over OCR text lines
+const (
+	minTableRows       = 2    // rows needed before a run of aligned lines counts as a table
+	minTableColumns    = 2    // columns needed before a run of aligned lines counts as a table
+	rowOverlapRatio    = 0.5  // vertical overlap (of the shorter line) for two lines to share a row
+	maxRowGapFactor    = 2.5  // maximum gap between table rows, in median line heights
+	minAlignedRowShare = 0.6  // share of rows that must span at least two columns
+	maxProseCellLength = 40.0 // average cell length above which two "columns" are treated as prose
+)
+
+// Detection is a table found among OCR text lines
+type Detection struct {
+	Table     *Table
+	LineIndex []int // indices of the input lines covered by the table
+}
+
+// row is a group of line indices sharing the same vertical band
+type row struct {
+	lines  []int
+	top    float64
+	bottom float64
+}
+
+// interval is a horizontal span on the page
+type interval struct {
+	start float64
+	end   float64
+}
+
+// DetectFromLines clusters OCR text lines by their bounding boxes into tables.
+// Lines are grouped into rows by vertical overlap; consecutive rows with several
+// horizontally separated cells whose x-ranges line up form a table.
+func DetectFromLines(lines []types.TextLine) []Detection {
+	rows := groupRows(lines)
+	if len(rows) < minTableRows {
+		return nil
+	}
+
+	lineHeight := medianLineHeight(lines)
+	var detections []Detection
+
+	start := 0
+	for start < len(rows) {
+		if len(rows[start].lines) < minTableColumns {
+			start++
+			continue
+		}
+
+		// Extend the run while rows keep having multiple cells and stay close together
+		end := start + 1
+		for end < len(rows) &&
+			len(rows[end].lines) >= minTableColumns &&
+			rows[end].top-rows[end-1].bottom <= lineHeight*maxRowGapFactor {
+			end++
+		}
+
+		if end-start >= minTableRows {
+			if detection, ok := buildDetection(lines, rows[start:end]); ok {
+				detections = append(detections, detection)
+			}
+		}
+		start = end
+	}
+
+	return detections
+}
+
+// ComposeText rebuilds page text from lines in their original order, replacing
+// the lines covered by each detection with the table rendered as Markdown
+func ComposeText(lines []types.TextLine, detections []Detection) string {
+	tableAt := make(map[int]*Table)
+	covered := make(map[int]bool)
+	for _, detection := range detections {
+		first := detection.LineIndex[0]
+		for _, index := range detection.LineIndex {
+			covered[index] = true
+			if index < first {
+				first = index
+			}
+		}
+		tableAt[first] = detection.Table
+	}
+
+	var builder strings.Builder
+	for i, line := range lines {
+		if table, ok := tableAt[i]; ok {
+			builder.WriteString("\n")
+			builder.WriteString(table.Markdown())
+			builder.WriteString("\n\n")
+			continue
+		}
+		if covered[i] || line.Text == "" {
+			continue
+		}
+		builder.WriteString(line.Text)
+		builder.WriteString("\n")
+	}
+
+	return strings.TrimSpace(builder.String())
+}
+
+// groupRows groups lines into rows by vertical overlap, ordered top to bottom
+func groupRows(lines []types.TextLine) []row {
+	var indices []int
+	for i, line := range lines {
+		if strings.TrimSpace(line.Text) != "" && line.BBox[3] > line.BBox[1] {
+			indices = append(indices, i)
+		}
+	}
+
+	sort.SliceStable(indices, func(a, b int) bool {
+		return centerY(lines[indices[a]]) < centerY(lines[indices[b]])
+	})
+
+	var rows []row
+	for _, index := range indices {
+		line := lines[index]
+		if len(rows) > 0 {
+			current := &rows[len(rows)-1]
+			overlap := minFloat(current.bottom, line.BBox[3]) - maxFloat(current.top, line.BBox[1])
+			shorter := minFloat(current.bottom-current.top, line.BBox[3]-line.BBox[1])
+			if shorter > 0 && overlap >= shorter*rowOverlapRatio {
+				current.lines = append(current.lines, index)
+				current.top = minFloat(current.top, line.BBox[1])
+				current.bottom = maxFloat(current.bottom, line.BBox[3])
+				continue
+			}
+		}
+		rows = append(rows, row{lines: []int{index}, top: line.BBox[1], bottom: line.BBox[3]})
+	}
+
+	for i := range rows {
+		cells := rows[i].lines
+		sort.SliceStable(cells, func(a, b int) bool {
+			return lines[cells[a]].BBox[0] < lines[cells[b]].BBox[0]
+		})
+	}
+
+	return rows
+}
+
+// buildDetection turns a run of rows into a table if their cells align into columns
+func buildDetection(lines []types.TextLine, rows []row) (Detection, bool) {
+	columns := columnIntervals(lines, rows)
+	if len(columns) < minTableColumns {
+		return Detection{}, false
+	}
+
+	grid := make([][]string, 0, len(rows))
+	var covered []int
+	alignedRows := 0
+	totalCellLength := 0
+	cellCount := 0
+
+	for _, r := range rows {
+		cells := make([]string, len(columns))
+		used := make(map[int]bool)
+		for _, index := range r.lines {
+			line := lines[index]
+			column := columnFor(columns, line)
+			used[column] = true
+			if cells[column] != "" {
+				cells[column] += " "
+			}
+			cells[column] += strings.TrimSpace(line.Text)
+			covered = append(covered, index)
+			totalCellLength += len([]rune(strings.TrimSpace(line.Text)))
+			cellCount++
+		}
+		if len(used) >= minTableColumns {
+			alignedRows++
+		}
+		grid = append(grid, cells)
+	}
+
+	if float64(alignedRows)/float64(len(rows)) < minAlignedRowShare {
+		return Detection{}, false
+	}
+
+	// Two long "columns" are far more likely a two-column text layout than a table
+	if len(columns) == 2 && cellCount > 0 && float64(totalCellLength)/float64(cellCount) > maxProseCellLength {
+		return Detection{}, false
+	}
+
+	return Detection{Table: NewTable(grid), LineIndex: covered}, true
+}
+
+// columnIntervals merges the x-ranges of all cells into column intervals
+func columnIntervals(lines []types.TextLine, rows []row) []interval {
+	var spans []interval
+	for _, r := range rows {
+		for _, index := range r.lines {
+			spans = append(spans, interval{start: lines[index].BBox[0], end: lines[index].BBox[2]})
+		}
+	}
+
+	sort.Slice(spans, func(a, b int) bool { return spans[a].start < spans[b].start })
+
+	var merged []interval
+	for _, span := range spans {
+		if len(merged) > 0 && span.start <= merged[len(merged)-1].end {
+			merged[len(merged)-1].end = maxFloat(merged[len(merged)-1].end, span.end)
+			continue
+		}
+		merged = append(merged, span)
+	}
+	return merged
+}
+
+// columnFor returns the column interval a line falls into
+func columnFor(columns []interval, line types.TextLine) int {
+	center := (line.BBox[0] + line.BBox[2]) / 2
+	for i, column := range columns {
+		if center >= column.start && center <= column.end {
+			return i
+		}
+	}
+	// Fall back to the nearest column
+	best := 0
+	bestDistance := -1.0
+	for i, column := range columns {
+		distance := minFloat(absFloat(center-column.start), absFloat(center-column.end))
+		if bestDistance < 0 || distance < bestDistance {
+			best = i
+			bestDistance = distance
+		}
+	}
+	return best
+}
+
+// medianLineHeight returns the median height of non-empty lines
+func medianLineHeight(lines []types.TextLine) float64 {
+	var heights []float64
+	for _, line := range lines {
+		if height := line.BBox[3] - line.BBox[1]; height > 0 {
+			heights = append(heights, height)
+		}
+	}
+	if len(heights) == 0 {
+		return 0
+	}
+	sort.Float64s(heights)
+	return heights[len(heights)/2]
+}
+
+func centerY(line types.TextLine) float64 {
+	return (line.BBox[1] + line.BBox[3]) / 2
+}
+
+func minFloat(a, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxFloat(a, b float64) float64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func absFloat(a float64) float64 {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
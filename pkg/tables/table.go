@@ -0,0 +1,105 @@
+package tables
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+)
+
+// Table is a reconstructed table; the first row is treated as the header
+type Table struct {
+	Rows [][]string
+}
+
+// NewTable creates a table from rows, padding short rows to a uniform width
+func NewTable(rows [][]string) *Table {
+	width := 0
+	for _, row := range rows {
+		if len(row) > width {
+			width = len(row)
+		}
+	}
+
+	normalized := make([][]string, 0, len(rows))
+	for _, row := range rows {
+		cells := make([]string, width)
+		for i, cell := range row {
+			cells[i] = normalizeCell(cell)
+		}
+		normalized = append(normalized, cells)
+	}
+
+	return &Table{Rows: normalized}
+}
+
+// ColumnCount returns the number of columns
+func (t *Table) ColumnCount() int {
+	if len(t.Rows) == 0 {
+		return 0
+	}
+	return len(t.Rows[0])
+}
+
+// IsEmpty reports whether the table has no usable content
+func (t *Table) IsEmpty() bool {
+	for _, row := range t.Rows {
+		for _, cell := range row {
+			if cell != "" {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Markdown renders the table as a GitHub-flavored Markdown table
+func (t *Table) Markdown() string {
+	if len(t.Rows) == 0 || t.ColumnCount() == 0 {
+		return ""
+	}
+
+	var builder strings.Builder
+	writeRow := func(cells []string) {
+		builder.WriteString("|")
+		for _, cell := range cells {
+			builder.WriteString(" ")
+			builder.WriteString(escapeMarkdownCell(cell))
+			builder.WriteString(" |")
+		}
+		builder.WriteString("\n")
+	}
+
+	writeRow(t.Rows[0])
+
+	separator := make([]string, t.ColumnCount())
+	for i := range separator {
+		separator[i] = "---"
+	}
+	builder.WriteString("|" + strings.Join(separator, "|") + "|\n")
+
+	for _, row := range t.Rows[1:] {
+		writeRow(row)
+	}
+
+	return strings.TrimRight(builder.String(), "\n")
+}
+
+// CSV renders the table as RFC 4180 CSV
+func (t *Table) CSV() ([]byte, error) {
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+	if err := writer.WriteAll(t.Rows); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// normalizeCell collapses whitespace inside a cell
+func normalizeCell(cell string) string {
+	return strings.Join(strings.Fields(cell), " ")
+}
+
+// escapeMarkdownCell escapes characters that would break a Markdown table row
+func escapeMarkdownCell(cell string) string {
+	return strings.ReplaceAll(cell, "|", "\\|")
+}
@@ -1,5 +1,7 @@
 package types
 
+import "strings"
+
 // MediaType represents different types of media files
 type MediaType string
 
@@ -27,12 +29,104 @@ const (
 	ContentTypeImage ContentType = "image" // Document contains image content, use OCR directly
 )
 
+// OutputFormat is the format of the written extraction result
+type OutputFormat string
+
+const (
+	OutputFormatText     OutputFormat = "text"     // Plain text with page markers
+	OutputFormatMarkdown OutputFormat = "markdown" // Markdown rendered from the document structure
+	OutputFormatJSON     OutputFormat = "json"     // Full extraction result with per-page entries
+	OutputFormatJSONL    OutputFormat = "jsonl"    // One JSON record per page
+)
+
+// Extension returns the file extension for output in this format
+func (f OutputFormat) Extension() string {
+	switch f {
+	case OutputFormatMarkdown:
+		return ".md"
+	case OutputFormatJSON:
+		return ".json"
+	case OutputFormatJSONL:
+		return ".jsonl"
+	default:
+		return ".txt"
+	}
+}
+
+// ParseOutputFormat parses an output format name; "txt", "md" and "ndjson" are accepted as aliases
+func ParseOutputFormat(value string) (OutputFormat, bool) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "text", "txt":
+		return OutputFormatText, true
+	case "markdown", "md":
+		return OutputFormatMarkdown, true
+	case "json":
+		return OutputFormatJSON, true
+	case "jsonl", "ndjson":
+		return OutputFormatJSONL, true
+	}
+	return "", false
+}
+
+// ChunkUnit is the unit chunk sizes and overlaps are measured in
+type ChunkUnit string
+
+const (
+	ChunkUnitChars  ChunkUnit = "chars"  // Unicode characters
+	ChunkUnitTokens ChunkUnit = "tokens" // Approximate tokens: one per CJK character, one per four other characters
+)
+
+// ParseChunkUnit parses a chunk unit name; "characters" and "token" are accepted as aliases
+func ParseChunkUnit(value string) (ChunkUnit, bool) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "chars", "char", "characters":
+		return ChunkUnitChars, true
+	case "tokens", "token":
+		return ChunkUnitTokens, true
+	}
+	return "", false
+}
+
+// LockMode is what a run does when another process is extracting the same document
+type LockMode string
+
+const (
+	LockModeWait      LockMode = "wait"      // Wait for the other process, then extract as usual
+	LockModeSkip      LockMode = "skip"      // Skip the document
+	LockModePiggyback LockMode = "piggyback" // Wait for the other process and reuse its result
+)
+
+// ParseLockMode parses a lock mode name
+func ParseLockMode(value string) (LockMode, bool) {
+	switch mode := LockMode(strings.ToLower(strings.TrimSpace(value))); mode {
+	case LockModeWait, LockModeSkip, LockModePiggyback:
+		return mode, true
+	}
+	return "", false
+}
+
 // FileInfo contains basic information about a file
 type FileInfo struct {
 	MD5Hash    string    `json:"md5_hash"`
 	SHA256Hash string    `json:"sha256_hash"`
 	Extension  string    `json:"extension"`
+	Format     string    `json:"format"` // detected from the content, falling back to the extension
 	MimeType   string    `json:"mime_type"`
 	Size       int64     `json:"size"`
 	MediaType  MediaType `json:"media_type"`
 }
+
+// TextLine is a recognized line of text with its position on the page
+type TextLine struct {
+	Text       string     `json:"text"`
+	BBox       [4]float64 `json:"bbox"` // x1, y1, x2, y2 in page pixels
+	Confidence float64    `json:"confidence,omitempty"`
+}
+
+// SourceHint describes a document read from a stream. Name (e.g. "scan.pdf") and
+// MimeType are both optional; the type is detected from the content when neither
+// identifies it.
+type SourceHint struct {
+	Name     string `json:"name,omitempty"`
+	MimeType string `json:"mime_type,omitempty"`
+}
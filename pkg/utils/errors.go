@@ -21,6 +21,8 @@ const (
 	ErrorTypeTimeout     ErrorType = "timeout"
 	ErrorTypePermission  ErrorType = "permission"
 	ErrorTypeNotFound    ErrorType = "not_found"
+	ErrorTypeInterrupted ErrorType = "interrupted" // Cancelled, e.g. by SIGINT or SIGTERM
+	ErrorTypeLocked      ErrorType = "locked"      // Skipped while another process works on the same document
 )
 
 // AppError represents an application-specific error with context
@@ -149,8 +151,10 @@ func classifyError(err error) ErrorType {
 	errStr := strings.ToLower(err.Error())
 
 	switch {
-	case errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled):
+	case errors.Is(err, context.DeadlineExceeded):
 		return ErrorTypeTimeout
+	case errors.Is(err, context.Canceled):
+		return ErrorTypeInterrupted
 	case strings.Contains(errStr, "permission denied") || strings.Contains(errStr, "access denied"):
 		return ErrorTypePermission
 	case strings.Contains(errStr, "no such file") || strings.Contains(errStr, "not found"):
@@ -186,6 +190,17 @@ func IsRecoverable(err error) bool {
 	}
 }
 
+// IsInterrupted reports whether an error comes from a cancelled operation
+func IsInterrupted(err error) bool {
+	return GetErrorType(err) == ErrorTypeInterrupted
+}
+
+// IsLocked reports whether a document was skipped because another process is
+// extracting it
+func IsLocked(err error) bool {
+	return GetErrorType(err) == ErrorTypeLocked
+}
+
 // GetErrorType extracts the error type from an error
 func GetErrorType(err error) ErrorType {
 	if appErr, ok := err.(*AppError); ok {
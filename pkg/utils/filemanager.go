@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 
 	"doc-to-text/pkg/constants"
@@ -11,13 +12,26 @@ import (
 )
 
 // FileManager统一管理中间文件和临时文件
-// 目录结构: {input_file_dir}/{md5_hash}/
+// 目录结构: {cache_root}/{md5前两位}/{md5_hash}/，本地模式下为 {input_file_dir}/{md5_hash}/
 //
-//	├── text.txt           # 最终输出文本
+//	├── text.txt           # 最终输出文本（缓存副本）
+//	├── text.partial.txt   # 中断时已完成页面的文本，完成后删除
+//	├── incomplete.json    # 中断标记（原因及已完成页数）
+//	├── lock.json          # 处理中进程的锁（PID、主机），定期刷新
+//	├── sources.json       # 内容相同的输入文件路径
+//	├── manifest.json      # 提取器、OCR引擎及输出文件校验和
 //	├── pages/             # PDF页面文件
+//	│   ├── state.json     # 总页数及每页状态
 //	│   ├── page_1.pdf
 //	│   └── page_1.txt
-//	├── ocr_data.json      # OCR结果数据
+//	├── tables/            # 识别出的表格（CSV）
+//	│   └── page_1_table_1.csv
+//	├── corrections/       # LLM校正结果（按模板分目录）
+//	│   └── {template}/
+//	│       ├── page_1.original.txt
+//	│       └── page_1.txt
+//	├── removed_lines.json # 移除的页眉页脚及页码
+//	├── source.{format}    # 扩展名与内容不符时的输入副本
 //	└── temp/              # 临时文件
 type FileManager struct {
 	inputFile  string
@@ -30,10 +44,11 @@ type FileManager struct {
 	cleanupFns []func() error
 }
 
-// NewFileManager 创建新的文件管理器
-func NewFileManager(inputFile, md5Hash string, log *logger.Logger) *FileManager {
-	inputDir := filepath.Dir(inputFile)
-	baseDir := filepath.Join(inputDir, md5Hash)
+// NewFileManager 创建新的文件管理器，baseDir 为工作目录，为空时使用输入文件旁的 {md5_hash} 目录
+func NewFileManager(inputFile, md5Hash, baseDir string, log *logger.Logger) *FileManager {
+	if baseDir == "" {
+		baseDir = filepath.Join(filepath.Dir(inputFile), md5Hash)
+	}
 
 	return &FileManager{
 		inputFile: NormalizePath(inputFile),
@@ -81,9 +96,9 @@ func (fm *FileManager) GetTextFilePath() string {
 	return fm.GetPath("text.txt")
 }
 
-// GetOCRDataPath 返回OCR数据文件路径
-func (fm *FileManager) GetOCRDataPath() string {
-	return fm.GetPath("ocr_data.json")
+// GetRemovedLinesPath 返回移除的页眉页脚记录路径
+func (fm *FileManager) GetRemovedLinesPath() string {
+	return fm.GetPath("removed_lines.json")
 }
 
 // GetPagesDir 返回页面文件目录
@@ -96,6 +111,11 @@ func (fm *FileManager) GetPagePDFPath(pageNum int) string {
 	return fm.GetPath(filepath.Join("pages", fmt.Sprintf(constants.PDFPageFilePattern, pageNum)))
 }
 
+// GetPageStatePath 返回页面状态文件路径
+func (fm *FileManager) GetPageStatePath() string {
+	return fm.GetPath(filepath.Join("pages", constants.PageStateFileName))
+}
+
 // GetPageTextPath 返回指定页面文本路径
 func (fm *FileManager) GetPageTextPath(pageNum int) string {
 	return fm.GetPath(filepath.Join("pages", fmt.Sprintf(constants.PDFPageTextPattern, pageNum)))
@@ -106,6 +126,46 @@ func (fm *FileManager) GetPageImagePath(pageNum int) string {
 	return fm.GetPath(filepath.Join("pages", fmt.Sprintf(constants.PDFPageImagePattern, pageNum)))
 }
 
+// GetTablesDir 返回表格文件目录
+func (fm *FileManager) GetTablesDir() string {
+	return fm.GetPath("tables")
+}
+
+// GetPageTableCSVPath 返回指定页面第N个表格的CSV路径
+func (fm *FileManager) GetPageTableCSVPath(pageNum, tableNum int) string {
+	return fm.GetPath(filepath.Join("tables", fmt.Sprintf(constants.PageTableCSVPattern, pageNum, tableNum)))
+}
+
+// GetCorrectionPath 返回指定校正模板下的文件路径
+func (fm *FileManager) GetCorrectionPath(template, fileName string) string {
+	return fm.GetPath(filepath.Join("corrections", SanitizeFileName(template), fileName))
+}
+
+// GetFormattedInputPath 返回扩展名与检测格式一致的输入文件路径。
+// 扩展名不符时（如名为 scan.bin 的PDF）在基础目录中创建 source.{format} 链接或副本，
+// 供依赖扩展名判断格式的外部工具使用
+func (fm *FileManager) GetFormattedInputPath(format string) (string, error) {
+	if format == "" || strings.EqualFold(filepath.Ext(fm.inputFile), "."+format) {
+		return fm.inputFile, nil
+	}
+
+	if err := fm.EnsureBaseDir(); err != nil {
+		return "", err
+	}
+	target := fm.GetPath("source." + format)
+	if _, err := os.Stat(target); err == nil {
+		return target, nil
+	}
+
+	if err := os.Link(fm.inputFile, target); err != nil {
+		if err := CopyFile(fm.inputFile, target); err != nil {
+			return "", fmt.Errorf("failed to create %s copy of input: %w", format, err)
+		}
+	}
+	fm.logger.Debug("Input detected as %s, using %s", format, target)
+	return target, nil
+}
+
 // === 临时文件管理 ===
 
 // CreateTempDir 创建临时目录
@@ -229,6 +289,6 @@ func (fm *FileManager) CleanupTemp() error {
 
 // Cleanup 清理所有文件（包括中间文件）
 func (fm *FileManager) Cleanup() error {
-	fm.logger.Debug("Intermediate file cleanup skipped (files preserved for caching)")
+	fm.logger.Debug("Intermediate file cleanup skipped (files preserved for caching, see 'doc-to-text cache prune')")
 	return fm.CleanupTemp()
 }
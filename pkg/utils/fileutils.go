@@ -1,16 +1,20 @@
 package utils
 
 import (
+	"context"
 	"crypto/md5"
+	"crypto/sha256"
 	"fmt"
 	"io"
-	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
 	"runtime"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"doc-to-text/pkg/constants"
 	"doc-to-text/pkg/types"
@@ -42,6 +46,47 @@ func IsCommandAvailable(command string) bool {
 	return err == nil
 }
 
+// CommandContext is exec.CommandContext for external tools, stopping them gently:
+// once ctx is done the tool gets SIGTERM (it is killed right away on Windows) and
+// is killed when it has not exited within constants.SubprocessGracePeriod
+func CommandContext(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Cancel = func() error {
+		if constants.IsWindows() {
+			return cmd.Process.Kill()
+		}
+		return cmd.Process.Signal(syscall.SIGTERM)
+	}
+	cmd.WaitDelay = constants.SubprocessGracePeriod
+	return cmd
+}
+
+// toolVersions caches the versions reported by external tools
+var toolVersions sync.Map
+
+// ToolVersion returns the first line printed by "command --version" (or "command
+// version"), or "" when the tool does not report one. Results are cached.
+func ToolVersion(command string) string {
+	if cached, ok := toolVersions.Load(command); ok {
+		return cached.(string)
+	}
+	version := ""
+	for _, arg := range []string{"--version", "version"} {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		output, err := exec.CommandContext(ctx, command, arg).Output()
+		cancel()
+		if err != nil {
+			continue
+		}
+		if line, _, _ := strings.Cut(strings.TrimSpace(string(output)), "\n"); line != "" {
+			version = strings.TrimSpace(line)
+			break
+		}
+	}
+	toolVersions.Store(command, version)
+	return version
+}
+
 // ValidatePath validates file path
 func ValidatePath(path string) error {
 	if path == "" {
@@ -61,6 +106,60 @@ func ValidatePath(path string) error {
 	return nil
 }
 
+// CopyFile copies source to target through a temporary file
+func CopyFile(source, target string) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp := target + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, constants.DefaultFilePermission)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, target)
+}
+
+// WriteFileAtomic writes data to a temporary file next to path and renames it into
+// place, so readers never see a partially written file, even when the process is
+// interrupted or another process writes the same file
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmp := file.Name()
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Chmod(tmp, perm); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
 // SanitizeFileName cleans filename for cross-platform compatibility
 func SanitizeFileName(filename string) string {
 	if runtime.GOOS == "windows" {
@@ -77,14 +176,15 @@ func SanitizeFileName(filename string) string {
 	return filename
 }
 
-// GetFileInfo gets comprehensive file information
+// GetFileInfo gets comprehensive file information. The format is detected from the
+// content first and the extension second.
 func GetFileInfo(filePath string) (*types.FileInfo, error) {
 	stat, err := os.Stat(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get file stats: %w", err)
 	}
 
-	md5Hash, err := CalculateFileMD5(filePath)
+	md5Hash, sha256Hash, err := calculateFileHashes(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to calculate file hash: %w", err)
 	}
@@ -94,20 +194,38 @@ func GetFileInfo(filePath string) (*types.FileInfo, error) {
 		extension = extension[1:]
 	}
 
-	mimeType, err := getMimeType(filePath)
+	mimeType, detected, err := detectFileFormat(filePath, extension, stat.Size())
 	if err != nil {
 		mimeType = "application/octet-stream"
 	}
+	format := resolveFormat(extension, detected)
 
 	return &types.FileInfo{
-		MD5Hash:   md5Hash,
-		Extension: extension,
-		MimeType:  mimeType,
-		Size:      stat.Size(),
-		MediaType: determineMediaType(extension, mimeType),
+		MD5Hash:    md5Hash,
+		SHA256Hash: sha256Hash,
+		Extension:  extension,
+		Format:     format,
+		MimeType:   mimeType,
+		Size:       stat.Size(),
+		MediaType:  determineMediaType(format, mimeType),
 	}, nil
 }
 
+// calculateFileHashes calculates the MD5 and SHA-256 hashes of a file in one pass
+func calculateFileHashes(filePath string) (string, string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", "", err
+	}
+	defer file.Close()
+
+	md5Hash, sha256Hash := md5.New(), sha256.New()
+	if _, err := io.Copy(io.MultiWriter(md5Hash, sha256Hash), file); err != nil {
+		return "", "", err
+	}
+	return fmt.Sprintf("%x", md5Hash.Sum(nil)), fmt.Sprintf("%x", sha256Hash.Sum(nil)), nil
+}
+
 // CalculateFileMD5 calculates MD5 hash of file
 func CalculateFileMD5(filePath string) (string, error) {
 	file, err := os.Open(filePath)
@@ -124,21 +242,32 @@ func CalculateFileMD5(filePath string) (string, error) {
 	return fmt.Sprintf("%x", hash.Sum(nil)), nil
 }
 
-// getMimeType detects MIME type from file content
-func getMimeType(filePath string) (string, error) {
+// CalculateFileSHA256 calculates SHA-256 hash of file
+func CalculateFileSHA256(filePath string) (string, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
-		return "", err
+		return "", fmt.Errorf("failed to open file for SHA-256 calculation: %w", err)
 	}
 	defer file.Close()
 
-	buffer := make([]byte, 512)
-	n, err := file.Read(buffer)
-	if err != nil && err != io.EOF {
-		return "", err
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", fmt.Errorf("failed to calculate SHA-256 hash: %w", err)
+	}
+
+	return fmt.Sprintf("%x", hash.Sum(nil)), nil
+}
+
+// detectFileFormat detects the MIME type and format of a file from its content
+func detectFileFormat(filePath, extension string, size int64) (string, string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", "", err
 	}
+	defer file.Close()
 
-	return http.DetectContentType(buffer[:n]), nil
+	mimeType, format := detectFormat(file, size, extension)
+	return mimeType, format, nil
 }
 
 // IsTextFile checks if file is a text file
@@ -165,6 +294,17 @@ func IsEbookFile(extension, mimeType string) bool {
 	return strings.Contains(mimeType, "epub") || strings.Contains(mimeType, "mobi")
 }
 
+// isDocumentFile checks if the extension is an office or web document
+func isDocumentFile(extension string) bool {
+	ext := strings.ToLower(extension)
+	for _, documentExt := range constants.DocumentExtensions {
+		if ext == documentExt {
+			return true
+		}
+	}
+	return false
+}
+
 // IsImageFile checks if file is an image
 func IsImageFile(extension string) bool {
 	ext := strings.ToLower(extension)
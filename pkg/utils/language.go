@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// languageCodePattern matches ISO 639-1/639-2 codes with an optional region or script subtag
+var languageCodePattern = regexp.MustCompile(`^[a-z]{2,3}(-[a-z0-9]{2,8})?$`)
+
+// NormalizeLanguages lowercases, trims and de-duplicates language codes.
+// Comma or plus separated values are split so "zh,en" and "zh+en" both work.
+func NormalizeLanguages(langs []string) []string {
+	seen := make(map[string]bool)
+	var normalized []string
+
+	for _, value := range langs {
+		for _, part := range strings.FieldsFunc(value, func(r rune) bool {
+			return r == ',' || r == '+' || unicode.IsSpace(r)
+		}) {
+			code := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(part), "_", "-"))
+			if code == "" || seen[code] {
+				continue
+			}
+			seen[code] = true
+			normalized = append(normalized, code)
+		}
+	}
+
+	return normalized
+}
+
+// IsValidLanguageCode checks whether a code looks like an ISO 639 language code
+func IsValidLanguageCode(code string) bool {
+	return languageCodePattern.MatchString(code)
+}
@@ -0,0 +1,21 @@
+package utils
+
+import "context"
+
+// ProgressFunc receives the number of completed and total units (e.g. pages) of a file
+type ProgressFunc func(done, total int)
+
+// progressKey is the context key holding a ProgressFunc
+type progressKey struct{}
+
+// WithProgress returns a context whose extractors report progress to fn
+func WithProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// ReportProgress forwards progress to the ProgressFunc attached to ctx, if any
+func ReportProgress(ctx context.Context, done, total int) {
+	if fn, ok := ctx.Value(progressKey{}).(ProgressFunc); ok && fn != nil {
+		fn(done, total)
+	}
+}
@@ -0,0 +1,236 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// sniffLength is the number of leading bytes examined for signatures
+const sniffLength = 64 * 1024
+
+// zipMimetypes maps the "mimetype" entry of ODF and EPUB containers to a format
+var zipMimetypes = map[string]string{
+	"application/epub+zip":                            "epub",
+	"application/vnd.oasis.opendocument.text":         "odt",
+	"application/vnd.oasis.opendocument.spreadsheet":  "ods",
+	"application/vnd.oasis.opendocument.presentation": "odp",
+}
+
+// ooxmlParts maps the main part directory of an OOXML package to its format and MIME type
+var ooxmlParts = []struct {
+	prefix, format, mimeType string
+}{
+	{"word/", "docx", "application/vnd.openxmlformats-officedocument.wordprocessingml.document"},
+	{"xl/", "xlsx", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"},
+	{"ppt/", "pptx", "application/vnd.openxmlformats-officedocument.presentationml.presentation"},
+}
+
+// oleStreams maps OLE2 stream names (UTF-16LE in the directory) to legacy Office formats
+var oleStreams = []struct {
+	name, format, mimeType string
+}{
+	{"WordDocument", "doc", "application/msword"},
+	{"Workbook", "xls", "application/vnd.ms-excel"},
+	{"PowerPoint Document", "ppt", "application/vnd.ms-powerpoint"},
+}
+
+// bmpHeaderSizes are the sizes of the known BMP DIB headers (core, info, v2/v3, v4, v5)
+var bmpHeaderSizes = map[uint32]bool{12: true, 40: true, 56: true, 108: true, 124: true}
+
+// DetectFormat identifies a document from its content. It returns the MIME type and
+// the format as a file extension (e.g. "pdf", "docx"); format is "" when the content
+// has no recognizable signature. r must cover the whole content so zip directories
+// can be read.
+func DetectFormat(r io.ReaderAt, size int64) (mimeType, format string) {
+	return detectFormat(r, size, "")
+}
+
+// detectFormat is DetectFormat for content named with an extension. PDFs preceded by
+// junk (a "%PDF-" header later in the first 1 KB) are only recognized when the
+// extension is not a text type, so text that merely mentions the header stays text.
+func detectFormat(r io.ReaderAt, size int64, extension string) (mimeType, format string) {
+	headLen := int64(sniffLength)
+	if size < headLen {
+		headLen = size
+	}
+	head := make([]byte, headLen)
+	n, _ := r.ReadAt(head, 0)
+	head = head[:n]
+
+	switch {
+	case bytes.HasPrefix(head, []byte("%PDF-")):
+		return "application/pdf", "pdf"
+	case !isTextType(extension) && bytes.Contains(head[:min(len(head), 1024)], []byte("%PDF-")):
+		return "application/pdf", "pdf"
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")):
+		return detectZipFormat(r, size)
+	case bytes.HasPrefix(head, []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}):
+		return detectOLEFormat(head)
+	case bytes.HasPrefix(head, []byte(`{\rtf`)):
+		return "application/rtf", "rtf"
+	case bytes.HasPrefix(head, []byte{0xFF, 0xD8, 0xFF}):
+		return "image/jpeg", "jpg"
+	case bytes.HasPrefix(head, []byte("\x89PNG\r\n\x1a\n")):
+		return "image/png", "png"
+	case bytes.HasPrefix(head, []byte("GIF87a")), bytes.HasPrefix(head, []byte("GIF89a")):
+		return "image/gif", "gif"
+	case bytes.HasPrefix(head, []byte("II*\x00")), bytes.HasPrefix(head, []byte("MM\x00*")):
+		return "image/tiff", "tiff"
+	case len(head) >= 12 && bytes.HasPrefix(head, []byte("RIFF")) && string(head[8:12]) == "WEBP":
+		return "image/webp", "webp"
+	case isBMP(head, size):
+		return "image/bmp", "bmp"
+	case len(head) >= 68 && string(head[60:68]) == "BOOKMOBI":
+		return "application/x-mobipocket-ebook", "mobi"
+	}
+
+	// No binary signature: let the standard sniffer tell text from HTML and XML. It
+	// matches bare signatures such as "BM", so text it takes for binary stays text.
+	mimeType = http.DetectContentType(head)
+	if !strings.HasPrefix(mimeType, "text/") && looksLikeText(head) {
+		mimeType = "text/plain; charset=utf-8"
+	}
+	switch {
+	case strings.HasPrefix(mimeType, "text/html"):
+		return mimeType, "html"
+	case strings.HasPrefix(mimeType, "text/xml"):
+		return mimeType, "xml"
+	case strings.HasPrefix(mimeType, "text/plain"):
+		return mimeType, "txt"
+	}
+	return mimeType, ""
+}
+
+// looksLikeText reports whether content is UTF-8 text without control characters
+// other than whitespace. A character cut off at the end of the head is ignored.
+func looksLikeText(head []byte) bool {
+	if len(head) == 0 {
+		return false
+	}
+	for i := 0; i < 3 && len(head) > 0 && !utf8.Valid(head); i++ {
+		head = head[:len(head)-1]
+	}
+	if !utf8.Valid(head) {
+		return false
+	}
+	for _, b := range head {
+		if b < 0x20 && b != '\t' && b != '\n' && b != '\r' && b != '\f' || b == 0x7F {
+			return false
+		}
+	}
+	return true
+}
+
+// isTextType reports whether an extension names text or markup, which may quote
+// signatures without being that format
+func isTextType(extension string) bool {
+	switch strings.ToLower(extension) {
+	case "html", "htm", "xhtml", "mhtml", "mht":
+		return true
+	}
+	return IsTextFile(extension, "")
+}
+
+// isBMP validates a BMP file header: the declared file size matches, the reserved
+// bytes are zero and the DIB header has a known size. "BM" alone starts many texts.
+func isBMP(head []byte, size int64) bool {
+	if len(head) < 18 || !bytes.HasPrefix(head, []byte("BM")) {
+		return false
+	}
+	return int64(binary.LittleEndian.Uint32(head[2:6])) == size &&
+		binary.LittleEndian.Uint32(head[6:10]) == 0 &&
+		bmpHeaderSizes[binary.LittleEndian.Uint32(head[14:18])]
+}
+
+// detectZipFormat tells EPUB, ODF and OOXML packages from plain zip archives
+func detectZipFormat(r io.ReaderAt, size int64) (string, string) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return "application/zip", "zip"
+	}
+
+	var hasContentTypes bool
+	for _, file := range archive.File {
+		switch file.Name {
+		case "mimetype":
+			if content, err := readZipEntry(file, 256); err == nil {
+				declared := strings.TrimSpace(string(content))
+				if format, ok := zipMimetypes[declared]; ok {
+					return declared, format
+				}
+			}
+		case "[Content_Types].xml":
+			hasContentTypes = true
+		}
+	}
+
+	if hasContentTypes {
+		for _, part := range ooxmlParts {
+			for _, file := range archive.File {
+				if strings.HasPrefix(file.Name, part.prefix) {
+					return part.mimeType, part.format
+				}
+			}
+		}
+	}
+	return "application/zip", "zip"
+}
+
+// readZipEntry reads at most limit bytes of a zip entry
+func readZipEntry(file *zip.File, limit int64) ([]byte, error) {
+	reader, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(io.LimitReader(reader, limit))
+}
+
+// detectOLEFormat looks for the stream names of legacy Office formats in an OLE2 file
+func detectOLEFormat(head []byte) (string, string) {
+	for _, stream := range oleStreams {
+		if bytes.Contains(head, utf16LE(stream.name)) {
+			return stream.mimeType, stream.format
+		}
+	}
+	return "application/x-ole-storage", ""
+}
+
+// utf16LE encodes an ASCII string as UTF-16LE
+func utf16LE(s string) []byte {
+	encoded := make([]byte, 0, len(s)*2)
+	for i := 0; i < len(s); i++ {
+		encoded = append(encoded, s[i], 0)
+	}
+	return encoded
+}
+
+// resolveFormat picks the format used for routing: a binary signature wins over the
+// file extension, while generic text, XML and zip only apply when the extension is
+// unknown (so .md, .csv or .mhtml keep their meaning)
+func resolveFormat(extension, detected string) string {
+	switch detected {
+	case "":
+		return extension
+	case "txt", "xml", "zip":
+		if extension != "" && IsSupportedFormat(extension) {
+			return extension
+		}
+	case "html":
+		// MHTML wraps HTML, and markup inside text files (.md, .xml) stays text
+		if extension == "mhtml" || extension == "mht" || IsTextFile(extension, "") {
+			return extension
+		}
+	}
+	return detected
+}
+
+// IsSupportedFormat reports whether a format extension belongs to a known file type group
+func IsSupportedFormat(format string) bool {
+	return IsTextFile(format, "") || IsImageFile(format) || IsEbookFile(format, "") || isDocumentFile(format)
+}
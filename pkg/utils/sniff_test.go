@@ -0,0 +1,155 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// bmpFile builds a minimal BMP with a valid file header and the given DIB header size
+func bmpFile(dibSize uint32) []byte {
+	data := make([]byte, 14+int(dibSize)+4)
+	copy(data, "BM")
+	binary.LittleEndian.PutUint32(data[2:6], uint32(len(data)))
+	binary.LittleEndian.PutUint32(data[10:14], 14+dibSize)
+	binary.LittleEndian.PutUint32(data[14:18], dibSize)
+	return data
+}
+
+// zipFile builds a zip archive holding the named entries
+func zipFile(t *testing.T, entries ...string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	writer := zip.NewWriter(&buf)
+	for i := 0; i+1 < len(entries); i += 2 {
+		entry, err := writer.Create(entries[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		entry.Write([]byte(entries[i+1]))
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDetectFormat(t *testing.T) {
+	badSize := bmpFile(40)
+	binary.LittleEndian.PutUint32(badSize[2:6], 12345)
+	badReserved := bmpFile(40)
+	badReserved[6] = 1
+	badDIB := bmpFile(40)
+	binary.LittleEndian.PutUint32(badDIB[14:18], 41)
+
+	ole := append([]byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}, make([]byte, 512)...)
+	ole = append(ole, utf16LE("WordDocument")...)
+
+	mobi := make([]byte, 80)
+	copy(mobi[60:], "BOOKMOBI")
+
+	tests := []struct {
+		name      string
+		content   []byte
+		extension string
+		format    string
+		mimeType  string
+	}{
+		{"pdf", []byte("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n1 0 obj"), "", "pdf", "application/pdf"},
+		{"pdf after junk", []byte("\x00\x00garbage\n%PDF-1.4\n1 0 obj"), "pdf", "pdf", "application/pdf"},
+		{"pdf after junk without extension", []byte("\x00\x00garbage\n%PDF-1.4\n1 0 obj"), "", "pdf", "application/pdf"},
+		{"markdown quoting pdf header", []byte("# Spec\n\nFiles start with %PDF-1.7 followed by objects.\n"), "md", "txt", "text/plain; charset=utf-8"},
+		{"text quoting pdf header", []byte("The header %PDF-1.4 marks a PDF.\n"), "txt", "txt", "text/plain; charset=utf-8"},
+		{"html quoting pdf header", []byte("<html><body><p>%PDF-1.4</p></body></html>"), "html", "html", "text/html; charset=utf-8"},
+		{"text starting with BM", []byte("BMW annual report 2024\nRevenue grew.\n"), "txt", "txt", "text/plain; charset=utf-8"},
+		{"bmp info header", bmpFile(40), "", "bmp", "image/bmp"},
+		{"bmp core header", bmpFile(12), "", "bmp", "image/bmp"},
+		{"bmp v5 header", bmpFile(124), "", "bmp", "image/bmp"},
+		{"bmp wrong file size", badSize, "", "", ""},
+		{"bmp reserved bytes set", badReserved, "", "", ""},
+		{"bmp unknown dib size", badDIB, "", "", ""},
+		{"png", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), "", "png", "image/png"},
+		{"jpeg", []byte{0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x10, 'J', 'F', 'I', 'F'}, "", "jpg", "image/jpeg"},
+		{"gif", []byte("GIF89a\x01\x00\x01\x00"), "", "gif", "image/gif"},
+		{"tiff", []byte("II*\x00\x08\x00\x00\x00"), "", "tiff", "image/tiff"},
+		{"webp", []byte("RIFF\x24\x00\x00\x00WEBPVP8 "), "", "webp", "image/webp"},
+		{"rtf", []byte(`{\rtf1\ansi Hello}`), "", "rtf", "application/rtf"},
+		{"mobi", mobi, "", "mobi", "application/x-mobipocket-ebook"},
+		{"word 97", ole, "", "doc", "application/msword"},
+		{"docx", zipFile(t, "[Content_Types].xml", "<Types/>", "word/document.xml", "<w:document/>"), "", "docx", "application/vnd.openxmlformats-officedocument.wordprocessingml.document"},
+		{"xlsx", zipFile(t, "[Content_Types].xml", "<Types/>", "xl/workbook.xml", "<workbook/>"), "", "xlsx", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"},
+		{"epub", zipFile(t, "mimetype", "application/epub+zip", "META-INF/container.xml", "<container/>"), "", "epub", "application/epub+zip"},
+		{"odt", zipFile(t, "mimetype", "application/vnd.oasis.opendocument.text"), "", "odt", "application/vnd.oasis.opendocument.text"},
+		{"plain zip", zipFile(t, "a.txt", "hello"), "", "zip", "application/zip"},
+		{"html", []byte("<!DOCTYPE html><html><head><title>x</title></head></html>"), "", "html", "text/html; charset=utf-8"},
+		{"xml", []byte(`<?xml version="1.0"?><root/>`), "", "xml", "text/xml; charset=utf-8"},
+		{"plain text", []byte("just some words\n"), "", "txt", "text/plain; charset=utf-8"},
+		{"binary", []byte{0x00, 0x01, 0x02, 0x03, 0xFE}, "", "", "application/octet-stream"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mimeType, format := detectFormat(bytes.NewReader(test.content), int64(len(test.content)), test.extension)
+			if format != test.format {
+				t.Errorf("format = %q, want %q", format, test.format)
+			}
+			if test.mimeType != "" && mimeType != test.mimeType {
+				t.Errorf("mime type = %q, want %q", mimeType, test.mimeType)
+			}
+		})
+	}
+}
+
+func TestResolveFormat(t *testing.T) {
+	tests := []struct {
+		extension, detected, want string
+	}{
+		{"pdf", "", "pdf"},
+		{"txt", "pdf", "pdf"},
+		{"bin", "pdf", "pdf"},
+		{"md", "txt", "md"},
+		{"csv", "txt", "csv"},
+		{"", "txt", "txt"},
+		{"xyz", "txt", "txt"},
+		{"md", "html", "md"},
+		{"mhtml", "html", "mhtml"},
+		{"docx", "zip", "docx"},
+		{"doc", "docx", "docx"},
+	}
+	for _, test := range tests {
+		if got := resolveFormat(test.extension, test.detected); got != test.want {
+			t.Errorf("resolveFormat(%q, %q) = %q, want %q", test.extension, test.detected, got, test.want)
+		}
+	}
+}
+
+func TestGetFileInfoKeepsTextFiles(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name, content, format string
+	}{
+		{"notes.txt", "BMW annual report 2024\nRevenue grew in every region.\n", "txt"},
+		{"spec.md", "# PDF notes\n\nEvery file starts with %PDF-1.7 and ends with %%EOF.\n", "md"},
+		{"renamed.txt", "%PDF-1.4\n1 0 obj\n<<>>\nendobj\n", "pdf"},
+	}
+	for _, test := range tests {
+		path := filepath.Join(dir, test.name)
+		if err := os.WriteFile(path, []byte(test.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		info, err := GetFileInfo(path)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if info.Format != test.format {
+			t.Errorf("%s: format = %q (%s), want %q", test.name, info.Format, info.MimeType, test.format)
+		}
+		if test.format != "pdf" && !strings.HasPrefix(info.MimeType, "text/") {
+			t.Errorf("%s: mime type = %q, want text", test.name, info.MimeType)
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"doc-to-text/pkg/constants"
+	"doc-to-text/pkg/types"
+)
+
+// mimeExtensions maps MIME types of supported formats to their usual extension
+var mimeExtensions = map[string]string{
+	"application/pdf":                "pdf",
+	"text/html":                      "html",
+	"application/xhtml+xml":          "html",
+	"multipart/related":              "mhtml",
+	"message/rfc822":                 "mht",
+	"application/epub+zip":           "epub",
+	"application/x-mobipocket-ebook": "mobi",
+	"text/plain":                     "txt",
+	"text/markdown":                  "md",
+	"application/json":               "json",
+	"text/xml":                       "xml",
+	"application/xml":                "xml",
+	"text/csv":                       "csv",
+	"image/jpeg":                     "jpg",
+	"image/png":                      "png",
+	"image/gif":                      "gif",
+	"image/bmp":                      "bmp",
+	"image/webp":                     "webp",
+	"image/tiff":                     "tiff",
+	"application/msword":             "doc",
+	"application/rtf":                "rtf",
+	"text/rtf":                       "rtf",
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document": "docx",
+	"application/vnd.oasis.opendocument.text":                                 "odt",
+}
+
+// ExtensionForMimeType returns the file extension (without dot) for a MIME type,
+// or "" when unknown
+func ExtensionForMimeType(mimeType string) string {
+	mediaType, _, err := mime.ParseMediaType(mimeType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(mimeType))
+	}
+	if ext, ok := mimeExtensions[mediaType]; ok {
+		return ext
+	}
+	if exts, err := mime.ExtensionsByType(mediaType); err == nil && len(exts) > 0 {
+		return strings.TrimPrefix(exts[0], ".")
+	}
+	return ""
+}
+
+// SpooledInput is a document read from a stream. Streams up to the memory limit
+// stay in memory; extractors needing a file get one written on demand into a
+// private temporary directory, which Cleanup removes.
+type SpooledInput struct {
+	hint     types.SourceHint
+	info     *types.FileInfo
+	fileName string
+	data     []byte // nil once the stream was spilled to disk
+	dir      string
+	path     string
+}
+
+// NewSpooledInput reads r completely. Streams larger than memoryLimit bytes are
+// written to a temporary file while reading.
+func NewSpooledInput(r io.Reader, hint types.SourceHint, memoryLimit int64) (*SpooledInput, error) {
+	if memoryLimit <= 0 {
+		memoryLimit = constants.MaxInMemoryInput
+	}
+
+	s := &SpooledInput{hint: hint}
+	md5Hash := md5.New()
+	sha256Hash := sha256.New()
+	reader := io.TeeReader(r, io.MultiWriter(md5Hash, sha256Hash))
+
+	var buffer bytes.Buffer
+	size, err := io.Copy(&buffer, io.LimitReader(reader, memoryLimit+1))
+	if err != nil {
+		return nil, WrapError(err, ErrorTypeIO, "failed to read input stream")
+	}
+	s.fileName = spoolFileName(hint, "")
+
+	if size > memoryLimit {
+		// Too large for memory: continue reading straight into the spool file
+		file, err := s.createSpoolFile()
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		if _, err := file.Write(buffer.Bytes()); err != nil {
+			s.Cleanup()
+			return nil, WrapError(err, ErrorTypeIO, "failed to spool input stream")
+		}
+		rest, err := io.Copy(file, reader)
+		if err != nil {
+			s.Cleanup()
+			return nil, WrapError(err, ErrorTypeIO, "failed to spool input stream")
+		}
+		size += rest
+		s.info = streamFileInfo(hint, file, size)
+	} else {
+		s.data = append([]byte{}, buffer.Bytes()...)
+		s.info = streamFileInfo(hint, bytes.NewReader(s.data), size)
+	}
+
+	// Name the spool file after the detected format so external tools recognize it
+	if fileName := spoolFileName(hint, s.info.Format); fileName != s.fileName {
+		s.fileName = fileName
+		if s.path != "" {
+			renamed := filepath.Join(s.dir, fileName)
+			if err := os.Rename(s.path, renamed); err != nil {
+				s.Cleanup()
+				return nil, WrapError(err, ErrorTypeIO, "failed to rename spool file")
+			}
+			s.path = renamed
+		}
+	}
+
+	s.info.Size = size
+	s.info.MD5Hash = fmt.Sprintf("%x", md5Hash.Sum(nil))
+	s.info.SHA256Hash = fmt.Sprintf("%x", sha256Hash.Sum(nil))
+	return s, nil
+}
+
+// streamFileInfo derives the file type from the content, then the MIME type and
+// name given in the hint
+func streamFileInfo(hint types.SourceHint, content io.ReaderAt, size int64) *types.FileInfo {
+	extension := strings.TrimPrefix(strings.ToLower(filepath.Ext(hint.Name)), ".")
+	if extension == "" && hint.MimeType != "" {
+		extension = ExtensionForMimeType(hint.MimeType)
+	}
+	mimeType, detected := detectFormat(content, size, extension)
+
+	// A declared MIME type is more specific than generic text or zip content
+	if hint.MimeType != "" && (detected == "" || resolveFormat(extension, detected) == extension) {
+		mimeType = hint.MimeType
+	}
+	format := resolveFormat(extension, detected)
+
+	return &types.FileInfo{
+		Extension: extension,
+		Format:    format,
+		MimeType:  mimeType,
+		MediaType: determineMediaType(format, mimeType),
+	}
+}
+
+// spoolFileName returns the name used for the spooled file, ending in the format's
+// extension that extractors and external tools rely on
+func spoolFileName(hint types.SourceHint, format string) string {
+	name := "input"
+	if hint.Name != "" {
+		if base := SanitizeFileName(filepath.Base(hint.Name)); base != "" && base != "." {
+			name = base
+		}
+	}
+	if format != "" && !strings.EqualFold(filepath.Ext(name), "."+format) {
+		name += "." + format
+	}
+	return name
+}
+
+// createSpoolFile creates the private temporary directory and the spool file
+func (s *SpooledInput) createSpoolFile() (*os.File, error) {
+	dir, err := os.MkdirTemp("", constants.AppName+"-stream-")
+	if err != nil {
+		return nil, WrapError(err, ErrorTypeIO, "failed to create spool directory")
+	}
+	s.dir = dir
+	s.path = filepath.Join(dir, s.fileName)
+
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, constants.DefaultFilePermission)
+	if err != nil {
+		s.Cleanup()
+		return nil, WrapError(err, ErrorTypeIO, "failed to create spool file")
+	}
+	return file, nil
+}
+
+// Name returns the name given in the hint, or the name of the spool file
+func (s *SpooledInput) Name() string {
+	if s.hint.Name != "" {
+		return s.hint.Name
+	}
+	return s.fileName
+}
+
+// FileInfo returns the type, size and hashes of the stream
+func (s *SpooledInput) FileInfo() *types.FileInfo {
+	info := *s.info
+	return &info
+}
+
+// Open returns a reader over the complete stream; it can be called repeatedly
+func (s *SpooledInput) Open() (io.ReadCloser, error) {
+	if s.data != nil {
+		return io.NopCloser(bytes.NewReader(s.data)), nil
+	}
+	file, err := os.Open(s.path)
+	if err != nil {
+		return nil, WrapError(err, ErrorTypeIO, "failed to open spool file")
+	}
+	return file, nil
+}
+
+// ReaderAtCloser is a random-access reader that must be closed after use
+type ReaderAtCloser interface {
+	io.ReaderAt
+	io.Closer
+}
+
+// memoryReaderAt is a random-access reader over an in-memory stream
+type memoryReaderAt struct {
+	*bytes.Reader
+}
+
+// Close implements io.Closer
+func (memoryReaderAt) Close() error {
+	return nil
+}
+
+// OpenReaderAt returns a random-access reader over the complete stream, e.g. for
+// formats whose properties are read from the end of the file
+func (s *SpooledInput) OpenReaderAt() (ReaderAtCloser, error) {
+	if s.data != nil {
+		return memoryReaderAt{bytes.NewReader(s.data)}, nil
+	}
+	file, err := os.Open(s.path)
+	if err != nil {
+		return nil, WrapError(err, ErrorTypeIO, "failed to open spool file")
+	}
+	return file, nil
+}
+
+// Path returns a file holding the stream, writing it on first use. Intermediate
+// files extractors create next to it are removed by Cleanup as well.
+func (s *SpooledInput) Path() (string, error) {
+	if s.path != "" {
+		return s.path, nil
+	}
+
+	file, err := s.createSpoolFile()
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	if _, err := file.Write(s.data); err != nil {
+		s.Cleanup()
+		return "", WrapError(err, ErrorTypeIO, "failed to spool input stream")
+	}
+	return s.path, nil
+}
+
+// Cleanup removes the spool directory, if one was created
+func (s *SpooledInput) Cleanup() error {
+	if s.dir == "" {
+		return nil
+	}
+	dir := s.dir
+	s.dir, s.path = "", ""
+	return os.RemoveAll(dir)
+}
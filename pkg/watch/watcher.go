@@ -0,0 +1,337 @@
+package watch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"doc-to-text/pkg/batch"
+	"doc-to-text/pkg/cache"
+	"doc-to-text/pkg/constants"
+	"doc-to-text/pkg/interfaces"
+	"doc-to-text/pkg/logger"
+	"doc-to-text/pkg/utils"
+)
+
+// Default spool directory names inside the watched directory
+const (
+	DefaultOutputDirName = "output"
+	DefaultDoneDirName   = "done"
+	DefaultFailedDirName = "failed"
+	ErrorSidecarSuffix   = ".error.json"
+)
+
+// ProcessFunc extracts the text of inputFile into outputFile
+type ProcessFunc func(ctx context.Context, inputFile, outputFile string) (*interfaces.ExtractionResult, error)
+
+// Options configure a hot-folder watcher
+type Options struct {
+	InboxDir    string        // directory scanned for new files
+	OutputDir   string        // text outputs, mirroring the inbox tree
+	Extension   string        // extension of output files, e.g. ".txt"
+	DoneDir     string        // processed sources are moved here
+	FailedDir   string        // failed sources and their error sidecars are moved here
+	Interval    time.Duration // time between scans
+	Settle      time.Duration // a file must be unmodified this long before it is processed
+	Concurrency int           // files processed in parallel
+	Once        bool          // process the current inbox and return instead of polling
+	CacheRoot   string        // root of the work directories; empty keeps them next to the sources, and they move along
+}
+
+// ErrorSidecar is written next to a failed source in the failed directory
+type ErrorSidecar struct {
+	Source    string          `json:"source"`
+	Error     string          `json:"error"`
+	ErrorType utils.ErrorType `json:"error_type"`
+	FailedAt  time.Time       `json:"failed_at"`
+}
+
+// fileState is the size and modification time observed at the previous scan
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// Watcher polls an inbox directory and processes files once their size has settled.
+// All state lives in the filesystem: files still in the inbox are pending, so a
+// restarted watcher simply continues with them.
+type Watcher struct {
+	opts     Options
+	process  ProcessFunc
+	logger   *logger.Logger
+	observed map[string]fileState
+}
+
+// NewWatcher creates a watcher, filling unset spool directories with defaults inside the inbox
+func NewWatcher(opts Options, process ProcessFunc, log *logger.Logger) *Watcher {
+	if opts.OutputDir == "" {
+		opts.OutputDir = filepath.Join(opts.InboxDir, DefaultOutputDirName)
+	}
+	if opts.DoneDir == "" {
+		opts.DoneDir = filepath.Join(opts.InboxDir, DefaultDoneDirName)
+	}
+	if opts.FailedDir == "" {
+		opts.FailedDir = filepath.Join(opts.InboxDir, DefaultFailedDirName)
+	}
+	if opts.Concurrency < 1 {
+		opts.Concurrency = 1
+	}
+	if opts.Extension == "" {
+		opts.Extension = ".txt"
+	}
+
+	return &Watcher{
+		opts:     opts,
+		process:  process,
+		logger:   log,
+		observed: make(map[string]fileState),
+	}
+}
+
+// Run scans the inbox until ctx is cancelled, or once when Options.Once is set
+func (w *Watcher) Run(ctx context.Context) error {
+	for _, dir := range []string{w.opts.InboxDir, w.opts.OutputDir, w.opts.DoneDir, w.opts.FailedDir} {
+		if err := utils.EnsureDir(dir); err != nil {
+			return utils.WrapError(err, utils.ErrorTypePermission, fmt.Sprintf("failed to create directory '%s'", dir))
+		}
+	}
+
+	w.logger.ProgressAlways("👀", "Watching %s (every %s)", w.opts.InboxDir, w.opts.Interval)
+	w.logger.Progress("📤", "Output: %s, done: %s, failed: %s", w.opts.OutputDir, w.opts.DoneDir, w.opts.FailedDir)
+
+	if w.opts.Once {
+		// Files are only ready once a second scan saw them unchanged, so look at the
+		// inbox first and give files being written the settle time to change
+		if items, err := w.collect(); err == nil {
+			w.readyItems(items)
+		}
+		select {
+		case <-ctx.Done():
+			return w.stopped(ctx)
+		case <-time.After(w.opts.Settle):
+		}
+	}
+
+	for {
+		if err := w.scan(ctx); err != nil {
+			w.logger.Error("Scan failed: %v", err)
+		}
+		if ctx.Err() != nil {
+			return w.stopped(ctx)
+		}
+		if w.opts.Once {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return w.stopped(ctx)
+		case <-time.After(w.opts.Interval):
+		}
+	}
+}
+
+// stopped returns the error of a watcher stopped by its context; files not yet
+// processed stay in the inbox for the next start
+func (w *Watcher) stopped(ctx context.Context) error {
+	return utils.NewError(utils.ErrorTypeInterrupted, fmt.Sprintf("stopped watching %s; unprocessed files stay in the inbox", w.opts.InboxDir), ctx.Err())
+}
+
+// collect lists the files in the inbox
+func (w *Watcher) collect() ([]batch.Item, error) {
+	return batch.Collect([]string{w.opts.InboxDir}, batch.Options{Exclude: w.spoolExcludes()})
+}
+
+// scan processes the inbox files that are ready
+func (w *Watcher) scan(ctx context.Context) error {
+	items, err := w.collect()
+	if err != nil {
+		return err
+	}
+
+	ready := w.readyItems(items)
+	if len(ready) == 0 {
+		return nil
+	}
+
+	w.logger.ProgressAlways("📥", "Found %d new file(s)", len(ready))
+	summary := batch.Run(ctx, ready, w.opts.Concurrency, func(ctx context.Context, item batch.Item) (*interfaces.ExtractionResult, error) {
+		outputFile := filepath.Join(w.opts.OutputDir, filepath.FromSlash(item.RelPath)+w.opts.Extension)
+		return w.process(ctx, item.Path, outputFile)
+	}, w.logger)
+
+	for _, result := range summary.Results {
+		// Cancelled runs leave the file in the inbox for the next start
+		if ctx.Err() != nil && result.Status == batch.StatusFailed {
+			continue
+		}
+		// Files another process is extracting stay as well, for the next scan
+		if result.ErrorType == utils.ErrorTypeLocked {
+			continue
+		}
+		w.finish(result)
+	}
+	return nil
+}
+
+// readyItems returns the files whose size and modification time have settled: seen
+// unchanged by the previous scan, and not modified for the settle time. A file seen for
+// the first time always waits, as copies may set an old modification time early.
+func (w *Watcher) readyItems(items []batch.Item) []batch.Item {
+	var ready []batch.Item
+	seen := make(map[string]bool)
+	now := time.Now()
+
+	for _, item := range items {
+		seen[item.Path] = true
+		info, err := os.Stat(item.Path)
+		if err != nil {
+			continue
+		}
+
+		current := fileState{size: info.Size(), modTime: info.ModTime()}
+		previous, known := w.observed[item.Path]
+		w.observed[item.Path] = current
+
+		// New files, files still growing and files written too recently wait for a later scan
+		if !known || previous != current {
+			continue
+		}
+		if now.Sub(current.modTime) < w.opts.Settle {
+			continue
+		}
+		ready = append(ready, item)
+	}
+
+	// Forget files that have left the inbox
+	for path := range w.observed {
+		if !seen[path] {
+			delete(w.observed, path)
+		}
+	}
+
+	return ready
+}
+
+// finish moves a processed source (and its {md5} work directory) to done/ or failed/
+func (w *Watcher) finish(result batch.Result) {
+	delete(w.observed, result.Item.Path)
+
+	targetRoot := w.opts.DoneDir
+	if result.Status == batch.StatusFailed {
+		targetRoot = w.opts.FailedDir
+	}
+
+	target, err := w.moveSource(result.Item, targetRoot)
+	if err != nil {
+		w.logger.Error("Failed to move %s: %v", result.Item.RelPath, err)
+		return
+	}
+
+	if result.Status != batch.StatusFailed {
+		w.logger.ProgressAlways("✅", "%s → %s", result.Item.RelPath, target)
+		return
+	}
+
+	sidecar := ErrorSidecar{
+		Source:    result.Item.RelPath,
+		Error:     result.Error.Error(),
+		ErrorType: result.ErrorType,
+		FailedAt:  time.Now(),
+	}
+	data, _ := json.MarshalIndent(sidecar, "", "  ")
+	if err := os.WriteFile(target+ErrorSidecarSuffix, data, constants.DefaultFilePermission); err != nil {
+		w.logger.Error("Failed to write error sidecar for %s: %v", result.Item.RelPath, err)
+	}
+	w.logger.ProgressAlways("❌", "%s → %s [%s]", result.Item.RelPath, target, result.ErrorType)
+}
+
+// moveSource moves a source below targetRoot keeping its relative path, adding a
+// timestamp when a file of the same name was processed before
+func (w *Watcher) moveSource(item batch.Item, targetRoot string) (string, error) {
+	target := filepath.Join(targetRoot, filepath.FromSlash(item.RelPath))
+	if _, err := os.Stat(target); err == nil {
+		ext := filepath.Ext(target)
+		target = fmt.Sprintf("%s.%s%s", strings.TrimSuffix(target, ext), time.Now().Format("20060102-150405"), ext)
+	}
+
+	if err := utils.EnsureDir(filepath.Dir(target)); err != nil {
+		return "", err
+	}
+
+	if w.opts.CacheRoot != "" {
+		if err := moveFile(item.Path, target); err != nil {
+			return "", err
+		}
+		return target, nil
+	}
+
+	// Work directories next to the sources move along, so cached work stays with them
+	md5Hash, hashErr := utils.CalculateFileMD5(item.Path)
+
+	if err := moveFile(item.Path, target); err != nil {
+		return "", err
+	}
+
+	if hashErr == nil {
+		workDir := cache.WorkDir("", item.Path, md5Hash)
+		if _, err := os.Stat(workDir); err == nil {
+			targetWorkDir := cache.WorkDir("", target, md5Hash)
+			if _, err := os.Stat(targetWorkDir); err == nil {
+				os.RemoveAll(workDir)
+			} else if err := os.Rename(workDir, targetWorkDir); err != nil {
+				w.logger.Warn("Failed to move work directory %s: %v", workDir, err)
+			}
+		}
+	}
+
+	return target, nil
+}
+
+// spoolExcludes keeps the output, done and failed directories out of the inbox scan
+func (w *Watcher) spoolExcludes() []string {
+	var excludes []string
+	for _, dir := range []string{w.opts.OutputDir, w.opts.DoneDir, w.opts.FailedDir} {
+		rel, err := filepath.Rel(w.opts.InboxDir, dir)
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		excludes = append(excludes, filepath.ToSlash(rel)+"/**")
+	}
+	return excludes
+}
+
+// moveFile renames a file, copying it when source and target are on different devices
+func moveFile(source, target string) error {
+	if err := os.Rename(source, target); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(target)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(target)
+		return err
+	}
+
+	in.Close()
+	return os.Remove(source)
+}